@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+)
+
+// ghHost is a host the gh CLI is logged in to.
+type ghHost struct {
+	Host     string
+	User     string
+	HasToken bool
+}
+
+// ghConfigDir follows gh's own lookup order.
+func ghConfigDir() string {
+	if d := os.Getenv("GH_CONFIG_DIR"); d != "" {
+		return d
+	}
+	if d := os.Getenv("XDG_CONFIG_HOME"); d != "" {
+		return filepath.Join(d, "gh")
+	}
+	if runtime.GOOS == "windows" {
+		if d := os.Getenv("AppData"); d != "" {
+			return filepath.Join(d, "GitHub CLI")
+		}
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".config", "gh")
+}
+
+// ghHosts reads gh's hosts.yml. Tokens kept in the system keyring are not
+// in the file, so gh itself is asked whether it has one.
+func ghHosts() []ghHost {
+	b, err := os.ReadFile(filepath.Join(ghConfigDir(), "hosts.yml"))
+	if err != nil {
+		return nil
+	}
+	var raw map[string]struct {
+		User       string `yaml:"user"`
+		OAuthToken string `yaml:"oauth_token"`
+	}
+	if yaml.Unmarshal(b, &raw) != nil {
+		return nil
+	}
+	_, ghErr := exec.LookPath("gh")
+	var hosts []ghHost
+	for name, h := range raw {
+		has := h.OAuthToken != ""
+		if !has && ghErr == nil {
+			has = exec.Command("gh", "auth", "token", "--hostname", name).Run() == nil
+		}
+		hosts = append(hosts, ghHost{Host: name, User: h.User, HasToken: has})
+	}
+	sort.Slice(hosts, func(i, j int) bool { return hosts[i].Host < hosts[j].Host })
+	return hosts
+}
+
+// adoptable is a setting found in gh, git, or the environment that maps to
+// an AutoCodit config key.
+type adoptable struct {
+	key, value, source, current string
+}
+
+func gitGlobal(key string) string {
+	v, err := git("config", "--global", "--get", key)
+	if err != nil {
+		return ""
+	}
+	return v
+}
+
+// detectAdoptable lists settings worth importing: ones not yet in the
+// config or set to something else there.
+func detectAdoptable(cfg *Config, hosts []ghHost) []adoptable {
+	var found []adoptable
+	add := func(key, value, source, current string) {
+		if value != "" && value != current {
+			found = append(found, adoptable{key: key, value: value, source: source, current: current})
+		}
+	}
+	add("git_author_name", gitGlobal("user.name"), "git user.name", cfg.GitAuthorName)
+	add("git_author_email", gitGlobal("user.email"), "git user.email", cfg.GitAuthorEmail)
+
+	editor, source := gitGlobal("core.editor"), "git core.editor"
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if editor == "" {
+			editor, source = os.Getenv(env), "$"+env
+		}
+	}
+	add("editor", editor, source, cfg.Editor)
+	pager, source := gitGlobal("core.pager"), "git core.pager"
+	if pager == "" {
+		pager, source = os.Getenv("PAGER"), "$PAGER"
+	}
+	add("pager", pager, source, cfg.Pager)
+
+	// With several gh hosts, prefer github.com; the rest can be set by hand.
+	if len(hosts) > 0 {
+		pick := hosts[0]
+		for _, h := range hosts {
+			if h.Host == "github.com" {
+				pick = h
+			}
+		}
+		if pick.User != "" {
+			add("github_host", pick.Host, "gh hosts.yml", cfg.GitHubHost)
+			add("github_user", pick.User, "gh hosts.yml", cfg.GitHubUser)
+		}
+	}
+	return found
+}
+
+func printAdoptable(found []adoptable, hosts []ghHost) {
+	for _, a := range found {
+		change := ""
+		if a.current != "" {
+			change = colorize(colorGray, fmt.Sprintf(" (now %q)", a.current))
+		}
+		fmt.Fprintf(os.Stderr, "  %-17s %-32s from %s%s\n", a.key, a.value, a.source, change)
+	}
+	for _, h := range hosts {
+		token := "no token"
+		if h.HasToken {
+			token = "token stays with gh"
+		}
+		fmt.Fprintln(os.Stderr, colorize(colorGray, fmt.Sprintf("  gh: logged in to %s as %s, %s", h.Host, h.User, token)))
+	}
+}
+
+func adopt(found []adoptable) error {
+	for _, a := range found {
+		v := a.value
+		if err := setConfigKey("", a.key, &v); err != nil {
+			return err
+		}
+	}
+	audit("config.import", map[string]any{"keys": len(found)})
+	return nil
+}
+
+// offerAdoption runs once, on the first interactive run without a config
+// file, so a new user does not have to repeat what gh and git already know.
+func offerAdoption(cfg *Config) {
+	if viper.ConfigFileUsed() != "" || ephemeralState != "" || !term.IsTerminal(int(os.Stdin.Fd())) {
+		return
+	}
+	var offered bool
+	if readState("import-offered.json", &offered) == nil && offered {
+		return
+	}
+	_ = writeState("import-offered.json", true)
+	found := detectAdoptable(cfg, ghHosts())
+	if len(found) == 0 {
+		return
+	}
+	fmt.Fprintln(os.Stderr, "Found existing settings AutoCodit can use:")
+	printAdoptable(found, nil)
+	if !confirm("Import them into ~/.autocodit/autocodit.yaml?") {
+		fmt.Fprintln(os.Stderr, "Skipped; run autocodit import-config any time.")
+		return
+	}
+	if err := adopt(found); err != nil {
+		fmt.Fprintln(os.Stderr, colorize(colorYellow, "warning: "+err.Error()))
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Imported %d setting(s).\n\n", len(found))
+}
+
+func cmdImportConfig(c *Client) *cobra.Command {
+	var yes, dryRun bool
+	cmd := &cobra.Command{
+		Use:   "import-config",
+		Short: "Import git identity, editor, pager, and gh account into the config",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			hosts := ghHosts()
+			found := detectAdoptable(c.cfg, hosts)
+			if len(found) == 0 {
+				fmt.Println("Nothing to import; the config already matches gh and git")
+				return nil
+			}
+			printAdoptable(found, hosts)
+			if dryRun {
+				return nil
+			}
+			if !yes {
+				if !term.IsTerminal(int(os.Stdin.Fd())) {
+					return fmt.Errorf("refusing to change the config without confirmation; pass --yes")
+				}
+				if !confirm("Import these settings?") {
+					return fmt.Errorf("aborted")
+				}
+			}
+			if err := adopt(found); err != nil {
+				return err
+			}
+			keys := make([]string, len(found))
+			for i, a := range found {
+				keys[i] = a.key
+			}
+			fmt.Println("Imported", strings.Join(keys, ", "))
+			return nil
+		},
+	}
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "import without asking")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "only show what would be imported")
+	return cmd
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// agentConfigKinds are the agent_config keys the CLI itself sends, with the
+// JSON type the server expects for each. Other keys pass through as given.
+var agentConfigKinds = map[string]string{
+	"branch_name":             "string",
+	"branch_pattern":          "string",
+	"commit_message_template": "string",
+	"base_branch":             "string",
+	"base_sha":                "string",
+	"model":                   "string",
+	"github_user":             "string",
+	"commit_author":           "object",
+	"commit_author.name":      "string",
+	"commit_author.email":     "string",
+}
+
+func jsonKind(v any) string {
+	switch v.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	}
+	return "null"
+}
+
+func aKind(kind string) string {
+	if kind == "object" || kind == "array" {
+		return "an " + kind
+	}
+	return "a " + kind
+}
+
+// mergeAgentConfig applies key=value overrides, dotted keys reaching into
+// nested settings, to a copy of config. A value is read as JSON unless the
+// key holds a string (by agentConfigKinds or its current value), so
+// --set branch_name=1234 stays a string.
+func mergeAgentConfig(config map[string]any, sets []string) (map[string]any, error) {
+	if config == nil {
+		config = map[string]any{}
+	}
+	// The round trip through JSON turns typed maps such as commit_author's
+	// into map[string]any, so a dotted key adds to them instead of
+	// replacing them.
+	doc, err := applyPatch(map[string]any{"agent_config": config}, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range sets {
+		k, raw, ok := strings.Cut(s, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("expected key=value, got %q", s)
+		}
+		parts := append([]string{"agent_config"}, strings.Split(k, ".")...)
+		for i := 2; i < len(parts); i++ {
+			if v, err := getAt(doc, parts[:i]); err == nil && jsonKind(v) != "object" {
+				return nil, fmt.Errorf("%s: %s is %s, not an object", k, strings.Join(parts[1:i], "."), aKind(jsonKind(v)))
+			}
+		}
+		ops, err := setOps(doc.(map[string]any), []string{"agent_config." + s})
+		if err != nil {
+			return nil, err
+		}
+		want := agentConfigKinds[k]
+		if current, err := getAt(doc, parts); want == "" && err == nil && jsonKind(current) != "null" {
+			want = jsonKind(current)
+		}
+		last := &ops[len(ops)-1]
+		switch got := jsonKind(last.Value); {
+		case want == "string" && got != "string":
+			last.Value = raw
+		case want != "" && want != got && got != "null":
+			return nil, fmt.Errorf("%s must be %s, got %q", k, aKind(want), raw)
+		}
+		if doc, err = applyPatch(doc, ops); err != nil {
+			return nil, err
+		}
+	}
+	merged, _ := doc.(map[string]any)["agent_config"].(map[string]any)
+	return merged, nil
+}
+
+// checkAgentConfig fails on a known key of the wrong type. Numbers and
+// booleans given for a string key, as YAML reads model: 4 or a bare sha,
+// are turned into strings.
+func checkAgentConfig(ac map[string]any) error {
+	keys := make([]string, 0, len(agentConfigKinds))
+	for k := range agentConfigKinds {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		parts := strings.Split(k, ".")
+		v, err := getAt(ac, parts)
+		if err != nil {
+			continue
+		}
+		want, got := agentConfigKinds[k], jsonKind(v)
+		switch {
+		case want == got || got == "null":
+		case want == "string" && (got == "number" || got == "boolean"):
+			parent, _ := getAt(ac, parts[:len(parts)-1])
+			if f, ok := v.(float64); ok {
+				v = strconv.FormatFloat(f, 'f', -1, 64)
+			}
+			parent.(map[string]any)[parts[len(parts)-1]] = fmt.Sprint(v)
+		default:
+			return fmt.Errorf("agent_config.%s must be %s, not %s", k, aKind(want), aKind(got))
+		}
+	}
+	return nil
+}
+
+// agentConfigOverrides are create's --agent-config file and --set flags,
+// applied over the agent_config the CLI computes for each task.
+type agentConfigOverrides struct {
+	file map[string]any
+	sets []string
+}
+
+// loadAgentConfigOverrides reads path as JSON or YAML and checks the
+// overrides against an empty config, so mistakes surface before any request.
+func loadAgentConfigOverrides(path string, sets []string) (agentConfigOverrides, error) {
+	o := agentConfigOverrides{sets: sets}
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return o, err
+		}
+		var m map[string]any
+		trimmed := bytes.TrimSpace(b)
+		if strings.EqualFold(filepath.Ext(path), ".json") || (len(trimmed) > 0 && trimmed[0] == '{') {
+			err = json.Unmarshal(b, &m)
+		} else {
+			err = yaml.Unmarshal(b, &m)
+		}
+		if err != nil {
+			return o, fmt.Errorf("%s: %w", path, err)
+		}
+		// YAML maps with non-string keys don't survive the JSON round trip.
+		normalized, err := applyPatch(m, nil)
+		if err != nil {
+			return o, fmt.Errorf("%s: %w", path, err)
+		}
+		o.file, _ = normalized.(map[string]any)
+		if err := checkAgentConfig(o.file); err != nil {
+			return o, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if _, err := o.apply(map[string]any{}); err != nil {
+		return o, err
+	}
+	return o, nil
+}
+
+func (o agentConfigOverrides) empty() bool {
+	return len(o.file) == 0 && len(o.sets) == 0
+}
+
+// apply returns ac with the file's keys replacing it at the top level, then
+// the --set keys.
+func (o agentConfigOverrides) apply(ac map[string]any) (map[string]any, error) {
+	if o.empty() {
+		return ac, nil
+	}
+	out := make(map[string]any, len(ac)+len(o.file))
+	for k, v := range ac {
+		out[k] = v
+	}
+	for k, v := range o.file {
+		out[k] = v
+	}
+	out, err := mergeAgentConfig(out, o.sets)
+	if err != nil {
+		return nil, fmt.Errorf("--set: %w", err)
+	}
+	if err := checkAgentConfig(out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
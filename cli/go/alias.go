@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+var aliasName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// splitArgs splits s like a POSIX shell would, honoring single quotes,
+// double quotes, and backslash escapes, without any expansion.
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inArg := false
+	var quote rune
+	rs := []rune(s)
+	for i := 0; i < len(rs); i++ {
+		r := rs[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\\' && quote != '\'':
+			if i+1 < len(rs) {
+				i++
+				cur.WriteRune(rs[i])
+			}
+			inArg = true
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else {
+				cur.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote", quote)
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	return args, nil
+}
+
+var aliasPlaceholder = regexp.MustCompile(`\$(\d+|@)`)
+
+// expandAlias substitutes $1..$N and $@ in the alias body with args.
+// Arguments not consumed by a placeholder are appended.
+func expandAlias(body string, args []string) ([]string, error) {
+	words, err := splitArgs(body)
+	if err != nil {
+		return nil, err
+	}
+	used := make([]bool, len(args))
+	var out []string
+	for _, w := range words {
+		if w == "$@" {
+			out = append(out, args...)
+			for i := range used {
+				used[i] = true
+			}
+			continue
+		}
+		var missing error
+		w = aliasPlaceholder.ReplaceAllStringFunc(w, func(p string) string {
+			if p == "$@" {
+				for i := range used {
+					used[i] = true
+				}
+				return strings.Join(args, " ")
+			}
+			n, _ := strconv.Atoi(p[1:])
+			if n < 1 || n > len(args) {
+				missing = fmt.Errorf("alias needs argument %s", p)
+				return ""
+			}
+			used[n-1] = true
+			return args[n-1]
+		})
+		if missing != nil {
+			return nil, missing
+		}
+		out = append(out, w)
+	}
+	for i, a := range args {
+		if !used[i] {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+// resolveAlias rewrites argv when its first non-flag word names an alias.
+// Built-in commands always win over aliases.
+func resolveAlias(root *cobra.Command, aliases map[string]string, argv []string) ([]string, bool, error) {
+	for i, a := range argv {
+		if strings.HasPrefix(a, "-") {
+			continue
+		}
+		body, ok := aliases[a]
+		if !ok || isBuiltin(root, a) {
+			return argv, false, nil
+		}
+		expanded, err := expandAlias(body, argv[i+1:])
+		if err != nil {
+			return nil, false, fmt.Errorf("alias %s: %w", a, err)
+		}
+		return append(append([]string{}, argv[:i]...), expanded...), true, nil
+	}
+	return argv, false, nil
+}
+
+func isBuiltin(root *cobra.Command, name string) bool {
+	for _, c := range root.Commands() {
+		if c.Name() == name || c.HasAlias(name) {
+			return true
+		}
+	}
+	return name == "help"
+}
+
+func userConfigPath() (string, error) {
+	if p := viper.ConfigFileUsed(); p != "" && filepath.Base(filepath.Dir(p)) == ".autocodit" {
+		return p, nil
+	}
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "autocodit.yaml"), nil
+}
+
+// setConfigKey sets (or, with a nil value, deletes) section.key in the user's
+// config file, or a top-level key when section is empty, editing the YAML
+// tree so comments and ordering survive.
+func setConfigKey(section, key string, value *string) error {
+	p, err := userConfigPath()
+	if err != nil {
+		return err
+	}
+	unlock, err := lockPath(p)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	var doc yaml.Node
+	if b, err := os.ReadFile(p); err == nil && len(strings.TrimSpace(string(b))) > 0 {
+		if err := yaml.Unmarshal(b, &doc); err != nil {
+			return fmt.Errorf("%s: %w", p, err)
+		}
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s: top level is not a mapping", p)
+	}
+
+	sec := root
+	if section != "" {
+		sec = nil
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			if root.Content[i].Value == section {
+				sec = root.Content[i+1]
+			}
+		}
+		if sec == nil || sec.Kind != yaml.MappingNode {
+			if value == nil {
+				return nil
+			}
+			sec = &yaml.Node{Kind: yaml.MappingNode}
+			root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: section}, sec)
+		}
+		sec.Style = 0
+	}
+	for i := 0; i+1 < len(sec.Content); i += 2 {
+		if sec.Content[i].Value != key {
+			continue
+		}
+		if value == nil {
+			sec.Content = append(sec.Content[:i], sec.Content[i+2:]...)
+		} else {
+			sec.Content[i+1] = &yaml.Node{Kind: yaml.ScalarNode, Value: *value}
+		}
+		return writeConfigFile(p, &doc)
+	}
+	if value == nil {
+		return nil
+	}
+	sec.Content = append(sec.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, &yaml.Node{Kind: yaml.ScalarNode, Value: *value})
+	return writeConfigFile(p, &doc)
+}
+
+func writeConfigFile(p string, doc *yaml.Node) error {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return writeFileAtomic(p, buf.Bytes(), 0o600)
+}
+
+func cmdAlias(c *Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "alias",
+		Short: "Manage command aliases",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "set [name] [expansion]",
+		Short: "Define an alias; $1, $2… and $@ refer to its arguments",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if !aliasName.MatchString(name) {
+				return fmt.Errorf("invalid alias name %q (lowercase letters, digits, - and _)", name)
+			}
+			if isBuiltin(cmd.Root(), name) {
+				return fmt.Errorf("%q is a built-in command", name)
+			}
+			words, err := splitArgs(args[1])
+			if err != nil {
+				return err
+			}
+			if len(words) == 0 {
+				return fmt.Errorf("empty expansion")
+			}
+			if _, ok := c.cfg.Aliases[words[0]]; ok && !isBuiltin(cmd.Root(), words[0]) {
+				return fmt.Errorf("aliases cannot expand to other aliases (%s)", words[0])
+			}
+			if err := setConfigKey("aliases", name, &args[1]); err != nil {
+				return err
+			}
+			fmt.Printf("Alias %s = %s\n", name, args[1])
+			return nil
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List aliases",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			aliases := make(map[string]string, len(c.cfg.Aliases))
+			names := make([]string, 0, len(c.cfg.Aliases))
+			for n, exp := range c.cfg.Aliases {
+				aliases[n] = exp
+				names = append(names, n)
+			}
+			sort.Strings(names)
+			return printOutput(aliases, func() {
+				if len(names) == 0 {
+					fmt.Println("No aliases defined")
+					return
+				}
+				tbl := newTable(os.Stdout, c.tableMaxWidth(), column{header: "ALIAS"}, column{header: "EXPANSION", flex: true})
+				for _, n := range names {
+					tbl.add(n, c.cfg.Aliases[n])
+				}
+				tbl.render()
+			})
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "delete [name]",
+		Short: "Remove an alias",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if _, ok := c.cfg.Aliases[args[0]]; !ok {
+				return fmt.Errorf("no alias %q", args[0])
+			}
+			if err := setConfigKey("aliases", args[0], nil); err != nil {
+				return err
+			}
+			fmt.Println("Deleted alias", args[0])
+			return nil
+		},
+	})
+	return cmd
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// diffComment is review feedback anchored to a line of a task's diff. Side
+// "new" refers to the line number after the change, "old" to a removed
+// line's number before it. Line 0 is a comment on the file as a whole.
+type diffComment struct {
+	Path string `json:"path"`
+	Line int    `json:"line,omitempty"`
+	Side string `json:"side,omitempty"`
+	Body string `json:"body"`
+}
+
+func (dc diffComment) anchor() string {
+	if dc.Line == 0 {
+		return dc.Path
+	}
+	if dc.Side == "old" {
+		return fmt.Sprintf("%s:-%d", dc.Path, dc.Line)
+	}
+	return fmt.Sprintf("%s:%d", dc.Path, dc.Line)
+}
+
+// annotationsState is where comments wait until they are submitted as one
+// batch.
+func annotationsState(id string) string {
+	return filepath.Join("annotations", id+".json")
+}
+
+func pendingComments(id string) ([]diffComment, error) {
+	var comments []diffComment
+	if err := readState(annotationsState(id), &comments); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// diffLines walks the lines of a unified diff and calls fn with the index
+// of each file header and hunk line, the file it belongs to, and the line
+// number it has on its side of the change (0 for headers).
+func diffLines(lines []string, fn func(i int, path string, line int, side string)) {
+	var path string
+	var oldLine, newLine, oldLeft, newLeft int
+	for i, l := range lines {
+		if oldLeft > 0 || newLeft > 0 {
+			switch {
+			case strings.HasPrefix(l, "+"):
+				fn(i, path, newLine, "new")
+				newLine++
+				newLeft--
+			case strings.HasPrefix(l, "-"):
+				fn(i, path, oldLine, "old")
+				oldLine++
+				oldLeft--
+			case strings.HasPrefix(l, " "), l == "":
+				fn(i, path, newLine, "new")
+				oldLine, newLine = oldLine+1, newLine+1
+				oldLeft, newLeft = oldLeft-1, newLeft-1
+			}
+			continue
+		}
+		switch {
+		case strings.HasPrefix(l, "diff --git "):
+			path = ""
+		case strings.HasPrefix(l, "--- "):
+			if p := strings.TrimPrefix(l[4:], "a/"); p != "/dev/null" {
+				path = p
+			}
+		case strings.HasPrefix(l, "+++ "):
+			if p := strings.TrimPrefix(l[4:], "b/"); p != "/dev/null" {
+				path = p
+			}
+			fn(i, path, 0, "")
+		case strings.HasPrefix(l, "@@ "):
+			// @@ -start[,count] +start[,count] @@
+			f := strings.Fields(l)
+			if len(f) < 3 {
+				continue
+			}
+			oldLine, oldLeft = hunkRange(strings.TrimPrefix(f[1], "-"))
+			newLine, newLeft = hunkRange(strings.TrimPrefix(f[2], "+"))
+			fn(i, path, 0, "")
+		}
+	}
+}
+
+func hunkRange(s string) (start, count int) {
+	a, b, ok := strings.Cut(s, ",")
+	start, _ = strconv.Atoi(a)
+	count = 1
+	if ok {
+		count, _ = strconv.Atoi(b)
+	}
+	return start, count
+}
+
+func splitLines(b []byte) []string {
+	return strings.Split(strings.TrimRight(string(b), "\n"), "\n")
+}
+
+// checkAnchor reports whether dc points at a file, and line, in the diff.
+func checkAnchor(patch []byte, dc diffComment) error {
+	var file, line bool
+	diffLines(splitLines(patch), func(_ int, path string, n int, side string) {
+		if path != dc.Path {
+			return
+		}
+		file = true
+		if n == dc.Line && side == dc.Side {
+			line = true
+		}
+	})
+	switch {
+	case !file:
+		return fmt.Errorf("%s is not changed by this task", dc.Path)
+	case dc.Line > 0 && !line:
+		return fmt.Errorf("line %d (%s side) of %s is not part of the diff", dc.Line, dc.Side, dc.Path)
+	}
+	return nil
+}
+
+const annotateHeader = `# Add comments on their own lines starting with ">", directly below the
+# diff line (or the +++ file header) they refer to. Everything else is
+# ignored, so the diff itself can be left as it is. Save and close to add.
+`
+
+// annotateInEditor opens the diff in the editor and returns the comments
+// the user wrote into it.
+func annotateInEditor(id string, patch []byte) ([]diffComment, error) {
+	p, err := statePath("annotations", id+".diff")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(p, append([]byte(annotateHeader), redact(string(patch))...), 0o600); err != nil {
+		return nil, err
+	}
+	defer os.Remove(p)
+	if err := runEditor(p); err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+
+	// Comment lines are dropped before walking the diff so line numbers come
+	// out as in the original; each is attached to the line it followed.
+	var kept []string
+	notes := map[int][]string{}
+	for _, l := range splitLines(b) {
+		switch {
+		case strings.HasPrefix(l, "#"):
+		case strings.HasPrefix(l, ">"):
+			notes[len(kept)-1] = append(notes[len(kept)-1], strings.TrimSpace(l[1:]))
+		default:
+			kept = append(kept, l)
+		}
+	}
+	anchors := map[int]diffComment{}
+	diffLines(kept, func(i int, path string, n int, side string) {
+		anchors[i] = diffComment{Path: path, Line: n, Side: side}
+	})
+	var comments []diffComment
+	for i := -1; i < len(kept); i++ {
+		text := strings.TrimSpace(strings.Join(notes[i], "\n"))
+		if text == "" {
+			continue
+		}
+		a, ok := anchors[i]
+		if !ok || a.Path == "" {
+			return nil, fmt.Errorf("comment %q is not below a diff line", firstLine(text))
+		}
+		a.Body = text
+		comments = append(comments, a)
+	}
+	return comments, nil
+}
+
+func printComments(comments []diffComment) {
+	for _, dc := range comments {
+		fmt.Printf("%s\n", colorize(colorBlue, dc.anchor()))
+		for _, l := range strings.Split(dc.Body, "\n") {
+			fmt.Printf("    %s\n", redact(l))
+		}
+	}
+}
+
+// showScreenshots shows task id's images ahead of the review editor. Drawn
+// inline they'd be hidden by the editor at once, so it waits for Enter
+// first. Failing to list or fetch them doesn't stop the review.
+func (c *Client) showScreenshots(ctx context.Context, id string) {
+	if machineOutput() || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return
+	}
+	arts, err := c.artifacts(ctx, id)
+	if err != nil {
+		return
+	}
+	var images []artifact
+	for _, a := range arts {
+		if isImageArtifact(a) {
+			images = append(images, a)
+		}
+	}
+	if len(images) == 0 {
+		return
+	}
+	fmt.Printf("Screenshots from %s:\n", id)
+	shown, err := c.showImages(ctx, id, images, false)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s %v\n", colorize(colorYellow, "warning:"), err)
+	}
+	for _, s := range shown {
+		if s.How == "inline" && term.IsTerminal(int(os.Stdin.Fd())) {
+			fmt.Fprint(os.Stderr, "Press Enter to open the editor ")
+			_, _ = bufio.NewReader(os.Stdin).ReadString('\n')
+			return
+		}
+	}
+}
+
+func cmdAnnotateDiff(c *Client) *cobra.Command {
+	var dc diffComment
+	var summary string
+	var list, discard, submit, watch, screenshots bool
+	cmd := &cobra.Command{
+		Use:   "annotate-diff [id]",
+		Short: "Comment on lines of a task's diff and send them back as review feedback",
+		Long: `Comments are collected locally, either one at a time with --file, --line and
+-m, or all at once in $EDITOR when no --file is given, and sent to the agent
+as one review with --submit. Before the editor opens, the task's screenshots
+are shown as artifacts show would, so a UI change can be checked against them.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			id := args[0]
+			switch {
+			case discard:
+				p, err := statePath(annotationsState(id))
+				if err != nil {
+					return err
+				}
+				if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+					return err
+				}
+				fmt.Println("Discarded pending comments on", id)
+				return nil
+			case list:
+				comments, err := pendingComments(id)
+				if err != nil {
+					return err
+				}
+				if len(comments) == 0 {
+					fmt.Println("No pending comments")
+					return nil
+				}
+				printComments(comments)
+				return nil
+			}
+
+			if dc.Path == "" && (dc.Body != "" || dc.Line != 0) {
+				return fmt.Errorf("-m and --line need --file")
+			}
+			var added []diffComment
+			if dc.Path != "" || !submit {
+				patch, err := c.taskPatch(ctx, id)
+				if err != nil {
+					return err
+				}
+				if dc.Path != "" {
+					if dc.Body == "" {
+						return fmt.Errorf("--file needs a comment; pass -m")
+					}
+					if dc.Side != "new" && dc.Side != "old" {
+						return fmt.Errorf("unknown --side %q (new|old)", dc.Side)
+					}
+					if dc.Line == 0 {
+						dc.Side = ""
+					}
+					if err := checkAnchor(patch, dc); err != nil {
+						return err
+					}
+					added = []diffComment{dc}
+				} else {
+					if screenshots {
+						c.showScreenshots(ctx, id)
+					}
+					if added, err = annotateInEditor(id, patch); err != nil {
+						return err
+					}
+					if len(added) == 0 && !submit {
+						fmt.Println("No comments added")
+						return nil
+					}
+				}
+			}
+
+			var comments []diffComment
+			if err := updateState(annotationsState(id), &comments, func() {
+				comments = append(comments, added...)
+			}); err != nil {
+				return err
+			}
+			if !submit {
+				fmt.Printf("Added %d comment(s), %d pending; send them with: autocodit annotate-diff %s --submit\n", len(added), len(comments), id)
+				return nil
+			}
+			if len(comments) == 0 {
+				return fmt.Errorf("no pending comments on %s", id)
+			}
+
+			req := map[string]any{"comments": comments}
+			if summary != "" {
+				req["summary"] = summary
+			}
+			var t Task
+			if err := c.DoJSON(ctx, http.MethodPost, "/api/v1/tasks/"+id+"/review", req, &t); err != nil {
+				return fmt.Errorf("%w (comments kept, retry with: autocodit annotate-diff %s --submit)", err, id)
+			}
+			if p, err := statePath(annotationsState(id)); err == nil {
+				_ = os.Remove(p)
+			}
+			audit("task.review", map[string]any{"task": id, "comments": len(comments)})
+			fmt.Printf("Sent %d comment(s) to %s; task is %s\n", len(comments), id, t.Status)
+			if !watch {
+				return nil
+			}
+			_, err := c.waitTask(ctx, id, printProgress)
+			fmt.Println()
+			return err
+		},
+	}
+	cmd.Flags().StringVar(&dc.Path, "file", "", "file the comment is about")
+	cmd.Flags().IntVar(&dc.Line, "line", 0, "line of --file the comment is about (omit for the whole file)")
+	cmd.Flags().StringVar(&dc.Side, "side", "new", "new for a line as changed, old for a removed line")
+	cmd.Flags().StringVarP(&dc.Body, "message", "m", "", "comment text")
+	cmd.Flags().StringVar(&summary, "summary", "", "overall note sent with the comments")
+	cmd.Flags().BoolVar(&list, "list", false, "show pending comments")
+	cmd.Flags().BoolVar(&discard, "discard", false, "drop pending comments")
+	cmd.Flags().BoolVar(&submit, "submit", false, "send pending comments to the agent for a revision pass")
+	cmd.Flags().BoolVarP(&watch, "watch", "w", false, "watch the task after submitting")
+	cmd.Flags().BoolVar(&screenshots, "screenshots", true, "show the task's screenshots before opening the editor")
+	cmd.MarkFlagsMutuallyExclusive("list", "discard", "submit")
+	cmd.MarkFlagsMutuallyExclusive("list", "discard", "file")
+	return cmd
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const maxConflictContext = 32 * 1024
+
+// applyReport sorts a patch's files by how git apply handled them.
+type applyReport struct {
+	Clean     []string `json:"clean"`
+	Conflicts []string `json:"conflicts"` // merged with conflict markers (--3way)
+	Failed    []string `json:"failed"`    // not applied at all
+}
+
+var (
+	applyConflictLine = regexp.MustCompile(`^Applied patch to '(.+)' with conflicts\.$`)
+	applyFailedLine   = regexp.MustCompile(`^error: (.+): (patch does not apply|does not exist in index|No such file or directory|already exists in working directory)$`)
+)
+
+// gitApplyPatch runs git apply on patch, only checking it with check, and
+// reports per file from what git printed.
+func gitApplyPatch(patch []byte, check, threeWay bool) (applyReport, error) {
+	args := []string{"apply", "-v"}
+	if check {
+		args = append(args, "--check")
+	}
+	if threeWay {
+		args = append(args, "--3way")
+	}
+	cmd := exec.Command("git", append(args, "-")...)
+	cmd.Stdin = bytes.NewReader(patch)
+	var stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = io.Discard, &stderr
+	runErr := cmd.Run()
+
+	r := applyReport{Clean: []string{}, Conflicts: []string{}, Failed: []string{}}
+	state := map[string]string{}
+	var errs []string
+	for _, l := range strings.Split(stderr.String(), "\n") {
+		if m := applyConflictLine.FindStringSubmatch(l); m != nil {
+			state[m[1]] = "conflict"
+		} else if m := applyFailedLine.FindStringSubmatch(l); m != nil {
+			state[m[1]] = "failed"
+		} else if strings.HasPrefix(l, "error: ") || strings.HasPrefix(l, "fatal: ") {
+			errs = append(errs, l)
+		}
+	}
+	for _, f := range patchFileStats(patch) {
+		switch state[f.Path] {
+		case "conflict":
+			r.Conflicts = append(r.Conflicts, f.Path)
+		case "failed":
+			r.Failed = append(r.Failed, f.Path)
+		default:
+			r.Clean = append(r.Clean, f.Path)
+		}
+	}
+	if runErr != nil && len(r.Conflicts) == 0 && len(r.Failed) == 0 {
+		// Not a per-file problem: a corrupt patch, not a repository, ...
+		msg := strings.Join(errs, "\n")
+		if msg == "" {
+			msg = runErr.Error()
+		}
+		return r, fmt.Errorf("git apply: %s", msg)
+	}
+	return r, nil
+}
+
+// conflictMarkers counts the conflicted regions git left in file.
+func conflictMarkers(file string) int {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return 0
+	}
+	n := 0
+	for _, l := range strings.Split(string(b), "\n") {
+		if strings.HasPrefix(l, "<<<<<<< ") {
+			n++
+		}
+	}
+	return n
+}
+
+func printApplyReport(r applyReport) {
+	for _, f := range r.Clean {
+		fmt.Printf("  %s %s\n", colorize(colorGreen, "clean   "), f)
+	}
+	for _, f := range r.Conflicts {
+		fmt.Printf("  %s %s\n", colorize(colorYellow, "conflict"), f)
+	}
+	for _, f := range r.Failed {
+		fmt.Printf("  %s %s\n", colorize(colorRed, "fails   "), f)
+	}
+}
+
+func (c *Client) taskPatch(ctx context.Context, id string) ([]byte, error) {
+	body, err := c.Fetch(ctx, "/api/v1/tasks/"+id+"/diff")
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+func cmdApply(c *Client) *cobra.Command {
+	var resolve, createBranch, commit, sparse, check, threeWay bool
+	var branchName, message string
+	cmd := &cobra.Command{
+		Use:   "apply [id]",
+		Short: "Apply a task's patch to the working tree",
+		Long: `apply downloads the patch a task produced and applies it to the working tree
+with git apply, so its changes can be tried before the agent opens a pull request.
+
+With --3way (the default) hunks that don't apply are merged, leaving conflict
+markers; with --3way=false the patch applies completely or not at all. --check
+reports how each file would apply without changing anything, and exits non-zero
+unless all of them apply cleanly.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			id := args[0]
+			if check {
+				patch, err := c.taskPatch(ctx, id)
+				if err != nil {
+					return err
+				}
+				r, err := gitApplyPatch(patch, true, threeWay)
+				if err != nil {
+					return err
+				}
+				if err := printOutput(r, func() { printApplyReport(r) }); err != nil {
+					return err
+				}
+				if n := len(r.Conflicts) + len(r.Failed); n > 0 {
+					return fmt.Errorf("patch from task %s does not apply cleanly: %d of %d file(s)", id, n, n+len(r.Clean))
+				}
+				return nil
+			}
+			var task Task
+			if err := c.DoJSON(ctx, http.MethodGet, "/api/v1/tasks/"+id, nil, &task); err != nil {
+				return err
+			}
+			rs := c.cfg.repoSettings(task.Repository)
+			vars := conventionVars(task.ActionType, task.Title, task.ID, task.Repository)
+			if branchName != "" {
+				if err := checkConvention(rs.BranchPattern, branchName); err != nil {
+					return err
+				}
+				createBranch = true
+			}
+			if createBranch {
+				if branchName == "" {
+					branchName = renderConvention(rs.BranchPattern, vars)
+				}
+				if _, err := git("checkout", "-b", branchName); err != nil {
+					return err
+				}
+				fmt.Println("Switched to new branch", branchName)
+			}
+
+			patch, err := c.taskPatch(ctx, id)
+			if err != nil {
+				return err
+			}
+			if sparse {
+				if err := sparseCheckout(patch); err != nil {
+					return err
+				}
+			}
+			r, err := gitApplyPatch(patch, false, threeWay)
+			if err != nil {
+				return err
+			}
+			switch {
+			case len(r.Failed) > 0:
+				// git apply writes nothing unless every file applies or merges.
+				fmt.Fprintln(os.Stderr, "Patch does not apply; nothing was changed. Failing file(s):")
+				for _, f := range r.Failed {
+					fmt.Fprintln(os.Stderr, "  "+f)
+				}
+				if !threeWay {
+					fmt.Fprintln(os.Stderr, "Re-run with --3way to merge what applies and mark the conflicts.")
+				}
+				return fmt.Errorf("patch from task %s does not apply", id)
+			case len(r.Conflicts) > 0:
+				fmt.Fprintf(os.Stderr, "Applied patch from task %s with conflicts:\n", id)
+				root, _ := git("rev-parse", "--show-toplevel")
+				for _, f := range r.Conflicts {
+					fmt.Fprintf(os.Stderr, "  %s (%d conflict(s))\n", f, conflictMarkers(filepath.Join(root, f)))
+				}
+				if !resolve {
+					fmt.Fprintln(os.Stderr, "Resolve them and git add the files, or re-run with --resolve-with-agent to have the agent rebase the patch.")
+					return fmt.Errorf("%d file(s) with conflicts", len(r.Conflicts))
+				}
+				if patch, err = c.resolveWithAgent(ctx, task, patch, r.Conflicts); err != nil {
+					return err
+				}
+			default:
+				fmt.Println("Applied patch from task", id)
+			}
+
+			if !commit {
+				return nil
+			}
+			if message == "" {
+				message = renderConvention(rs.CommitTemplate, vars)
+			} else if err := checkConvention(rs.CommitTemplate, firstLine(message)); err != nil {
+				return err
+			}
+			return commitPatch(patch, message)
+		},
+	}
+	cmd.Flags().BoolVar(&resolve, "resolve-with-agent", false, "on conflicts, create a fix task to rebase the patch and retry")
+	cmd.Flags().BoolVar(&createBranch, "create-branch", false, "apply on a new branch named by the repo's branch_pattern")
+	cmd.Flags().StringVar(&branchName, "branch-name", "", "explicit branch name, checked against branch_pattern")
+	cmd.Flags().BoolVar(&commit, "commit", false, "commit the applied changes using the repo's commit_template")
+	cmd.Flags().StringVarP(&message, "message", "m", "", "explicit commit message, checked against commit_template")
+	cmd.Flags().BoolVar(&sparse, "sparse", false, "limit the checkout to directories the patch touches (git sparse-checkout)")
+	cmd.Flags().BoolVar(&check, "check", false, "report whether the patch applies without changing anything")
+	cmd.Flags().BoolVar(&threeWay, "3way", true, "merge hunks that don't apply, leaving conflict markers")
+	for _, f := range []string{"resolve-with-agent", "create-branch", "branch-name", "commit", "message", "sparse"} {
+		cmd.MarkFlagsMutuallyExclusive("check", f)
+	}
+	return cmd
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
+
+func patchFiles(patch []byte) ([]string, error) {
+	out, err := gitInput(patch, "apply", "--numstat", "-")
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if f := strings.Split(line, "\t"); len(f) == 3 {
+			files = append(files, f[2])
+		}
+	}
+	return files, nil
+}
+
+// commitPatch stages exactly the files the patch touches and commits them.
+func commitPatch(patch []byte, message string) error {
+	files, err := patchFiles(patch)
+	if err != nil {
+		return err
+	}
+	if _, err := git(append([]string{"add", "-A", "--"}, files...)...); err != nil {
+		return err
+	}
+	if _, err := git("commit", "-m", message); err != nil {
+		return err
+	}
+	fmt.Println("Committed:", firstLine(message))
+	return nil
+}
+
+// resolveWithAgent hands the conflicts to a new fix task, waits for it, and
+// applies its patch in place of the original one, origPatch. It returns the
+// patch applied.
+func (c *Client) resolveWithAgent(ctx context.Context, orig Task, origPatch []byte, conflicts []string) ([]byte, error) {
+	id := orig.ID
+	head, err := git("rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	// Paths from git apply are relative to the top of the work tree, not
+	// to where we were run.
+	root, err := git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	branch, _ := git("rev-parse", "--abbrev-ref", "HEAD")
+	log, _ := git("log", "-5", "--oneline")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "The patch from task %s does not apply cleanly on %s (%s).\n", id, branch, head)
+	fmt.Fprintf(&b, "Produce a patch with the same intent rebased onto this HEAD.\n\nRecent local commits:\n%s\n", log)
+	for _, f := range conflicts {
+		data, err := os.ReadFile(filepath.Join(root, f))
+		if err != nil {
+			continue
+		}
+		if b.Len()+len(data) > maxConflictContext {
+			fmt.Fprintf(&b, "\n--- %s (omitted, context limit reached)\n", f)
+			continue
+		}
+		fmt.Fprintf(&b, "\n--- %s\n%s\n", f, data)
+	}
+
+	req := CreateTaskRequest{
+		Title:       "Resolve conflicts for task " + id,
+		Description: b.String(),
+		Repository:  orig.Repository,
+		ActionType:  "fix",
+		Priority:    "high",
+		AgentConfig: map[string]interface{}{"rebase_of": id, "base_sha": head},
+	}
+	if err := c.checkFreeze(&req, ""); err != nil {
+		return nil, err
+	}
+	var fix Task
+	if err := c.createTask(ctx, req, &fix); err != nil {
+		return nil, err
+	}
+	fmt.Println("Conflict resolution task created:", fix.ID)
+
+	fix, err = c.waitTask(ctx, fix.ID, printProgress)
+	fmt.Println()
+	if err != nil {
+		return nil, err
+	}
+	if fix.Status != "completed" {
+		return nil, fmt.Errorf("conflict resolution task %s %s", fix.ID, fix.Status)
+	}
+
+	if err := resetPatchedFiles(root, origPatch); err != nil {
+		return nil, err
+	}
+	patch, err := c.taskPatch(ctx, fix.ID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := gitInput(patch, "apply", "--3way", "-"); err != nil {
+		return nil, fmt.Errorf("rebased patch from %s still does not apply: %w", fix.ID, err)
+	}
+	fmt.Println("Applied rebased patch from task", fix.ID)
+	return patch, nil
+}
+
+// resetPatchedFiles undoes patch in the index and work tree, cleanly applied
+// files and conflicts alike, so a replacement patch starts from HEAD. Files
+// the patch created are removed.
+func resetPatchedFiles(root string, patch []byte) error {
+	files, err := patchFiles(patch)
+	if err != nil {
+		return err
+	}
+	var tracked []string
+	for _, f := range files {
+		p := filepath.Join(root, f)
+		if _, err := git("cat-file", "-e", "HEAD:"+filepath.ToSlash(f)); err == nil {
+			tracked = append(tracked, p)
+			continue
+		}
+		if _, err := git("rm", "-q", "--cached", "--ignore-unmatch", "--", p); err != nil {
+			return err
+		}
+		if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if len(tracked) == 0 {
+		return nil
+	}
+	_, err = git(append([]string{"checkout", "HEAD", "--"}, tracked...)...)
+	return err
+}
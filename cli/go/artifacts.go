@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// artifact is a file a task produced: a patch, a report, generated code.
+type artifact struct {
+	Name        string    `json:"name"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256,omitempty"`
+	ContentType string    `json:"content_type,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func (c *Client) artifacts(ctx context.Context, id string) ([]artifact, error) {
+	var out []artifact
+	err := c.DoJSON(ctx, http.MethodGet, "/api/v1/tasks/"+id+"/artifacts", nil, &out)
+	return out, err
+}
+
+// artifactPath places name under dir, refusing names that would land
+// outside it.
+func artifactPath(dir, name string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("refusing artifact name %q: it points outside --dir", name)
+	}
+	return filepath.Join(dir, clean), nil
+}
+
+// fileSHA256 returns the hex digest of the file at p.
+func fileSHA256(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// progressReader hashes what it reads and, on a terminal, redraws a
+// progress bar for it on stderr.
+type progressReader struct {
+	r     io.Reader
+	h     hash.Hash
+	label string
+	total int64
+	read  int64
+	shown time.Time
+	bar   bool
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.h.Write(b[:n])
+	p.read += int64(n)
+	if p.bar && (time.Since(p.shown) > 100*time.Millisecond || err == io.EOF) {
+		p.shown = time.Now()
+		p.draw()
+	}
+	return n, err
+}
+
+func (p *progressReader) draw() {
+	const width = 30
+	line := fmt.Sprintf("%s %s", p.label, formatBytes(int(p.read)))
+	if p.total > 0 {
+		frac := min(float64(p.read)/float64(p.total), 1)
+		filled := int(frac * width)
+		line = fmt.Sprintf("%s [%s%s] %3.0f%% %s/%s", p.label, strings.Repeat("=", filled), strings.Repeat(" ", width-filled),
+			frac*100, formatBytes(int(p.read)), formatBytes(int(p.total)))
+	}
+	fmt.Fprintf(os.Stderr, "\r\x1b[K%s", truncateWidth(line, terminalWidth()))
+}
+
+// downloadArtifact streams a into dir through a temporary file, checking
+// its SHA-256 before moving it into place. It reports false when the file
+// was already there with the right checksum.
+func (c *Client) downloadArtifact(ctx context.Context, id string, a artifact, dir string) (bool, error) {
+	dst, err := artifactPath(dir, a.Name)
+	if err != nil {
+		return false, err
+	}
+	if a.SHA256 != "" {
+		if sum, err := fileSHA256(dst); err == nil && strings.EqualFold(sum, a.SHA256) {
+			return false, nil
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return false, err
+	}
+	body, err := c.Fetch(ctx, "/api/v1/tasks/"+id+"/artifacts/"+url.PathEscape(a.Name))
+	if err != nil {
+		return false, err
+	}
+	defer body.Close()
+
+	f, err := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".*.part")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(f.Name())
+	pr := &progressReader{r: body, h: sha256.New(), label: a.Name, total: a.Size,
+		bar: !machineOutput() && term.IsTerminal(int(os.Stderr.Fd()))}
+	_, err = io.Copy(f, pr)
+	if pr.bar {
+		fmt.Fprintln(os.Stderr)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", a.Name, err)
+	}
+	if a.Size > 0 && pr.read != a.Size {
+		return false, fmt.Errorf("%s: got %d bytes, expected %d", a.Name, pr.read, a.Size)
+	}
+	if sum := hex.EncodeToString(pr.h.Sum(nil)); a.SHA256 != "" && !strings.EqualFold(sum, a.SHA256) {
+		return false, fmt.Errorf("%s: checksum mismatch (got sha256 %s, expected %s); nothing was written", a.Name, sum, a.SHA256)
+	}
+	return true, os.Rename(f.Name(), dst)
+}
+
+func cmdArtifacts(c *Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "artifacts",
+		Short: "List, download, and view the files a task produced",
+	}
+
+	list := &cobra.Command{
+		Use:   "list [id]",
+		Short: "List a task's artifacts",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			arts, err := c.artifacts(cmd.Context(), args[0])
+			if err != nil {
+				return err
+			}
+			if arts == nil {
+				arts = []artifact{}
+			}
+			return printOutput(arts, func() {
+				if len(arts) == 0 {
+					fmt.Println("No artifacts")
+					return
+				}
+				tbl := newTable(os.Stdout, c.tableMaxWidth(), column{header: "NAME", flex: true},
+					column{header: "SIZE", right: true}, column{header: "SHA256"}, column{header: "CREATED"})
+				for _, a := range arts {
+					sum := a.SHA256
+					if len(sum) > 12 {
+						sum = sum[:12]
+					}
+					tbl.add(a.Name, formatBytes(int(a.Size)), sum, formatTime(&a.CreatedAt))
+				}
+				tbl.render()
+			})
+		},
+	}
+
+	var dir string
+	download := &cobra.Command{
+		Use:   "download [id] [name]",
+		Short: "Download one artifact, or all of them, verifying checksums",
+		Long: `download saves a task's artifacts under --dir, all of them unless one is named.
+Each file is checked against the SHA-256 the server lists before it is moved into
+place; files already there with the right checksum are skipped.`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			arts, err := c.artifacts(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			if len(args) == 2 {
+				var found []artifact
+				for _, a := range arts {
+					if a.Name == args[1] {
+						found = append(found, a)
+					}
+				}
+				if len(found) == 0 {
+					return fmt.Errorf("task %s has no artifact %q", args[0], args[1])
+				}
+				arts = found
+			}
+			if len(arts) == 0 {
+				return fmt.Errorf("task %s has no artifacts", args[0])
+			}
+
+			type result struct {
+				Name   string `json:"name"`
+				Path   string `json:"path"`
+				SHA256 string `json:"sha256,omitempty"`
+				Status string `json:"status"`
+			}
+			var out []result
+			for _, a := range arts {
+				wrote, err := c.downloadArtifact(ctx, args[0], a, dir)
+				if err != nil {
+					return err
+				}
+				p, _ := artifactPath(dir, a.Name)
+				r := result{Name: a.Name, Path: p, SHA256: a.SHA256, Status: "downloaded"}
+				if !wrote {
+					r.Status = "up to date"
+				}
+				out = append(out, r)
+				if !machineOutput() {
+					verified := ""
+					if a.SHA256 != "" {
+						verified = colorize(colorGreen, " (sha256 verified)")
+					}
+					fmt.Printf("%s %s%s\n", strings.ToUpper(r.Status[:1])+r.Status[1:]+":", p, verified)
+				}
+			}
+			if !machineOutput() {
+				return nil
+			}
+			return printOutput(out, func() {})
+		},
+	}
+	download.Flags().StringVarP(&dir, "dir", "d", ".", "directory to save into")
+
+	var open bool
+	show := &cobra.Command{
+		Use:   "show [id] [name]",
+		Short: "Show a task's screenshots and other images in the terminal",
+		Long: `show draws a task's image artifacts, such as the screenshots the agent took
+while checking a UI change, right in the terminal: all of them unless one is
+named. iTerm2, WezTerm, kitty, Ghostty, and sixel terminals such as foot are
+supported; set inline_images to iterm, kitty, or sixel where auto doesn't
+recognize yours, or to never. Elsewhere, or with --open, each image is
+downloaded and opened in the system viewer. Over SSH without a terminal that
+draws images, show says where it saved them. Only files whose contents are a
+PNG, JPEG, GIF, or WebP image are drawn or opened, whatever their name or
+content type; anything else is just saved.`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			arts, err := c.artifacts(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			var images []artifact
+			for _, a := range arts {
+				if len(args) == 2 && a.Name == args[1] || len(args) == 1 && isImageArtifact(a) {
+					images = append(images, a)
+				}
+			}
+			switch {
+			case len(images) == 0 && len(args) == 2:
+				return fmt.Errorf("task %s has no artifact %q", args[0], args[1])
+			case len(images) == 0:
+				return fmt.Errorf("task %s has no screenshots or other images; see: autocodit artifacts list %s", args[0], args[0])
+			}
+			shown, err := c.showImages(ctx, args[0], images, open)
+			if err != nil {
+				return err
+			}
+			if machineOutput() {
+				return printOutput(shown, func() {})
+			}
+			return nil
+		},
+	}
+	show.Flags().BoolVar(&open, "open", false, "open the images in the system viewer instead of the terminal")
+
+	cmd.AddCommand(list, download, show)
+	return cmd
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+// taskEvent is one entry of the server's events log, GET /api/v1/events.
+// Data is what the task's event stream carried at the time.
+type taskEvent struct {
+	sdk.Event
+	TaskID string    `json:"task_id"`
+	Time   time.Time `json:"time"`
+}
+
+type taskEventList struct {
+	Items   []taskEvent `json:"items"`
+	HasNext bool        `json:"has_next"`
+}
+
+// tasksAsOf returns the tasks that existed at at, as they were then: the
+// server's events log replayed up to at when it keeps one, else a guess
+// from each task's created, started, and completed times. source says which.
+func (c *Client) tasksAsOf(ctx context.Context, at time.Time, opts sdk.ListTasksOptions) (tasks []Task, source string, err error) {
+	q := url.Values{"until": {at.UTC().Format(time.RFC3339Nano)}, "per_page": {"500"}}
+	for k, v := range map[string]string{"repository": opts.Repository, "action_type": opts.ActionType, "priority": opts.Priority, "user": opts.User} {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	if opts.AllUsers {
+		q.Set("all_users", "true")
+	}
+	if !opts.Since.IsZero() {
+		q.Set("since", opts.Since.UTC().Format(time.RFC3339Nano))
+	}
+	var events []taskEvent
+	for page := 1; ; page++ {
+		q.Set("page", strconv.Itoa(page))
+		var list taskEventList
+		err := c.DoJSON(ctx, http.MethodGet, "/api/v1/events?"+q.Encode(), nil, &list)
+		var apiErr *sdk.APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound && page == 1 {
+			tasks, err := c.reconstructAsOf(ctx, at, opts)
+			return tasks, "reconstructed", err
+		}
+		if err != nil {
+			return nil, "", userScopeError(err, opts)
+		}
+		events = append(events, list.Items...)
+		if !list.HasNext || len(list.Items) == 0 {
+			break
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time.Before(events[j].Time) })
+	byID := map[string]*Task{}
+	var order []string
+	for _, ev := range events {
+		if ev.TaskID == "" || ev.Time.After(at) {
+			continue
+		}
+		t, ok := byID[ev.TaskID]
+		if !ok {
+			t = &Task{ID: ev.TaskID, CreatedAt: ev.Time}
+			byID[ev.TaskID], order = t, append(order, ev.TaskID)
+		}
+		applyEvent(t, ev.Event)
+		t.UpdatedAt = ev.Time
+	}
+	// Events mostly carry only what changed, so the rest comes from the
+	// tasks as they are now: one listing, then a fetch each for those it
+	// misses. A task deleted since keeps what the log had.
+	var missing []string
+	for _, id := range order {
+		if byID[id].Title == "" {
+			missing = append(missing, id)
+		}
+	}
+	current := map[string]Task{}
+	if len(missing) > 0 {
+		lo := opts
+		lo.Status, lo.Limit, lo.Page, lo.PerPage = "", 0, 1, 100
+		stream, errc := c.ListTasks(lo).Stream(ctx)
+		for t := range stream {
+			current[t.ID] = t
+		}
+		if err := <-errc; err != nil {
+			return nil, "", userScopeError(err, opts)
+		}
+	}
+	for _, id := range missing {
+		if _, ok := current[id]; ok {
+			continue
+		}
+		var t Task
+		if err := c.DoJSON(ctx, http.MethodGet, "/api/v1/tasks/"+id, nil, &t); err != nil {
+			var apiErr *sdk.APIError
+			if !errors.As(err, &apiErr) || (apiErr.StatusCode != http.StatusNotFound && apiErr.StatusCode != http.StatusForbidden) {
+				return nil, "", err
+			}
+			continue
+		}
+		current[id] = t
+	}
+	for _, id := range order {
+		then := byID[id]
+		cur, ok := current[id]
+		if then.Title != "" || !ok {
+			tasks = append(tasks, rewindTask(*then, then.Status, at))
+			continue
+		}
+		cur.Status, cur.Progress, cur.Metrics, cur.UpdatedAt = then.Status, then.Progress, then.Metrics, then.UpdatedAt
+		if cur.CreatedAt.IsZero() || cur.CreatedAt.After(at) {
+			cur.CreatedAt = then.CreatedAt
+		}
+		tasks = append(tasks, rewindTask(cur, cur.Status, at))
+	}
+	return tasks, "server", nil
+}
+
+// reconstructAsOf guesses each task's state at at from its timestamps, for
+// servers without an events log. Progress and retries in between are lost.
+func (c *Client) reconstructAsOf(ctx context.Context, at time.Time, opts sdk.ListTasksOptions) ([]Task, error) {
+	opts.Status, opts.Limit, opts.Page = "", 0, 1
+	if opts.PerPage == 0 {
+		opts.PerPage = 100
+	}
+	var tasks []Task
+	stream, errc := c.ListTasks(opts).Stream(ctx)
+	for t := range stream {
+		switch {
+		case t.CreatedAt.After(at):
+		case t.CompletedAt != nil && !t.CompletedAt.After(at):
+			tasks = append(tasks, t)
+		case t.StartedAt != nil && !t.StartedAt.After(at):
+			// Today's progress would overstate how far it had got.
+			t.Progress, t.Metrics = 0, nil
+			tasks = append(tasks, rewindTask(t, "running", at))
+		default:
+			tasks = append(tasks, rewindTask(t, "queued", at))
+		}
+	}
+	return tasks, userScopeError(<-errc, opts)
+}
+
+// rewindTask sets t's status to what it was at at and drops what only
+// happened later.
+func rewindTask(t Task, status string, at time.Time) Task {
+	t.Status = status
+	if isFinished(status) {
+		return t
+	}
+	t.CompletedAt, t.ErrorMessage, t.PRNumber, t.DiffStats = nil, "", nil, nil
+	if t.StartedAt != nil && t.StartedAt.After(at) || status == "queued" || status == "pending" {
+		t.StartedAt = nil
+	}
+	if status != "running" {
+		t.Progress, t.Metrics = 0, nil
+	}
+	return t
+}
+
+// writeAsOf lists the tasks as they were at at through w, newest first,
+// with the same filters list applies to current tasks.
+func (c *Client) writeAsOf(ctx context.Context, w taskWriter, at time.Time, opts sdk.ListTasksOptions, repos []string) error {
+	tasks, source, err := c.tasksAsOf(ctx, at, opts)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(tasks, func(i, j int) bool { return tasks[i].CreatedAt.After(tasks[j].CreatedAt) })
+	if !machineOutput() {
+		from := "from the server's events log"
+		if source == "reconstructed" {
+			from = "reconstructed from task timestamps; the server keeps no events log, so progress and retries in between are not shown"
+		}
+		fmt.Fprintln(os.Stderr, colorize(colorGray, fmt.Sprintf("As of %s, %s", at.Local().Format("2006-01-02 15:04:05 MST"), from)))
+	}
+	n := 0
+	for _, t := range tasks {
+		switch {
+		case opts.Status != "" && t.Status != opts.Status,
+			opts.Repository != "" && t.Repository != opts.Repository,
+			len(repos) > 0 && !contains(repos, t.Repository),
+			opts.ActionType != "" && t.ActionType != opts.ActionType,
+			opts.Priority != "" && t.Priority != opts.Priority,
+			!listed(t, opts):
+			continue
+		}
+		if opts.Limit > 0 && n == opts.Limit {
+			break
+		}
+		if err := w.write(t); err != nil {
+			return err
+		}
+		n++
+	}
+	return w.close()
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+type auditEntry struct {
+	Time   time.Time      `json:"time"`
+	Event  string         `json:"event"`
+	Actor  string         `json:"actor,omitempty"`
+	Fields map[string]any `json:"fields,omitempty"`
+}
+
+// audit appends an entry to ~/.autocodit/audit.log. Failures are ignored so
+// auditing never blocks the command being audited.
+func audit(event string, fields map[string]any) {
+	p, err := statePath("audit.log")
+	if err != nil {
+		return
+	}
+	f, err := os.OpenFile(p, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	b, _ := json.Marshal(auditEntry{Time: time.Now().UTC(), Event: event, Actor: serviceActor, Fields: fields})
+	_, _ = f.Write(append(b, '\n'))
+}
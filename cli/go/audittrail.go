@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+// trailRecord is one line of an exported audit trail. Prev is the SHA-256 of
+// the previous line exactly as written, so changing, dropping, or reordering
+// any line breaks the chain from there on. The first line is a header and
+// the last an end marker with the record count.
+type trailRecord struct {
+	Seq     int             `json:"seq"`
+	Prev    string          `json:"prev"`
+	Time    time.Time       `json:"time"`
+	Kind    string          `json:"kind"`
+	Actor   string          `json:"actor,omitempty"`
+	Summary string          `json:"summary,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func lineHash(line []byte) string {
+	sum := sha256.Sum256(line)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func trailData(v any) json.RawMessage {
+	b, _ := json.Marshal(v)
+	return b
+}
+
+// taskTrail returns the task's audit records, oldest first: the server's
+// trail when it keeps one, else one rebuilt from the task, its timeline and
+// comments, and this machine's audit log. source says which.
+func (c *Client) taskTrail(ctx context.Context, t Task) (recs []trailRecord, source string, err error) {
+	err = c.DoJSON(ctx, http.MethodGet, "/api/v1/tasks/"+t.ID+"/audit", nil, &recs)
+	var apiErr *sdk.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		return recs, "server", err
+	}
+
+	recs = []trailRecord{{Time: t.CreatedAt, Kind: "state", Actor: pick(t.TriggeredBy, t.UserID), Summary: "created",
+		Data: trailData(map[string]any{"title": redact(t.Title), "description": redact(t.Description), "repository": t.Repository,
+			"action_type": t.ActionType, "priority": t.Priority})}}
+	if t.StartedAt != nil {
+		recs = append(recs, trailRecord{Time: *t.StartedAt, Kind: "state", Actor: "agent", Summary: "started"})
+	}
+	if t.CompletedAt != nil {
+		recs = append(recs, trailRecord{Time: *t.CompletedAt, Kind: "state", Actor: "agent", Summary: t.Status,
+			Data: trailData(map[string]any{"error_message": redact(t.ErrorMessage), "pr_number": t.PRNumber})})
+	}
+	phases, err := c.taskTimeline(ctx, t)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, p := range phases {
+		recs = append(recs, trailRecord{Time: p.StartedAt, Kind: "phase", Actor: "agent", Summary: p.Name,
+			Data: trailData(map[string]any{"ended_at": p.EndedAt})})
+	}
+	comments, err := c.taskComments(ctx, t.ID, time.Time{})
+	if err != nil && (!errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound) {
+		return nil, "", err
+	}
+	for _, tc := range comments {
+		recs = append(recs, trailRecord{Time: tc.CreatedAt, Kind: "message", Actor: pick(tc.Author, tc.Role), Summary: firstLine(redact(tc.Body)),
+			Data: trailData(map[string]any{"id": tc.ID, "role": tc.Role, "kind": tc.Kind, "body": redact(tc.Body)})})
+	}
+	for _, e := range localAuditEntries(t.ID) {
+		recs = append(recs, trailRecord{Time: e.Time, Kind: "cli", Actor: pick(e.Actor, "local"), Summary: e.Event, Data: trailData(e.Fields)})
+	}
+	sort.SliceStable(recs, func(i, j int) bool { return recs[i].Time.Before(recs[j].Time) })
+	return recs, "reconstructed", nil
+}
+
+// localAuditEntries are this machine's audit log entries about task id.
+func localAuditEntries(id string) []auditEntry {
+	p, err := statePath("audit.log")
+	if err != nil {
+		return nil
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var out []auditEntry
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for sc.Scan() {
+		var e auditEntry
+		if json.Unmarshal(sc.Bytes(), &e) != nil {
+			continue
+		}
+		if task, _ := e.Fields["task"].(string); task == id {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// writeTrail writes recs between a header and an end marker, chaining each
+// line to the one before, and returns the hash of the last line.
+func writeTrail(w io.Writer, header map[string]any, recs []trailRecord) (string, error) {
+	now := time.Now().UTC()
+	all := append([]trailRecord{{Time: now, Kind: "header", Data: trailData(header)}}, recs...)
+	all = append(all, trailRecord{Time: now, Kind: "end", Data: trailData(map[string]int{"records": len(recs)})})
+	prev := ""
+	for i, r := range all {
+		r.Seq, r.Prev = i, prev
+		line, err := json.Marshal(r)
+		if err != nil {
+			return "", err
+		}
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			return "", err
+		}
+		prev = lineHash(line)
+	}
+	return prev, nil
+}
+
+// trailCheck is the result of audit verify.
+type trailCheck struct {
+	Valid   bool   `json:"valid"`
+	Task    string `json:"task,omitempty"`
+	Records int    `json:"records"`
+	Head    string `json:"head,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// verifyTrail checks r's chain, and that it ends at head when head is set.
+func verifyTrail(r io.Reader, head string) trailCheck {
+	var c trailCheck
+	fail := func(line int, format string, args ...any) trailCheck {
+		c.Line, c.Error = line, fmt.Sprintf(format, args...)
+		return c
+	}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	prev, n, ended := "", 0, false
+	for sc.Scan() {
+		n++
+		line := sc.Bytes()
+		if ended {
+			return fail(n, "line after the end marker")
+		}
+		var rec trailRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return fail(n, "not a trail record: %v", err)
+		}
+		switch {
+		case rec.Seq != n-1:
+			return fail(n, "sequence number %d, expected %d", rec.Seq, n-1)
+		case rec.Prev != prev:
+			return fail(n, "chain broken: the previous line was changed, removed, or reordered")
+		case n == 1 && rec.Kind != "header":
+			return fail(n, "missing header")
+		case n > 1 && rec.Kind == "header":
+			return fail(n, "second header")
+		}
+		if n == 1 {
+			var h struct {
+				Task string `json:"task"`
+			}
+			_ = json.Unmarshal(rec.Data, &h)
+			c.Task = h.Task
+		}
+		if rec.Kind == "end" {
+			var end struct {
+				Records int `json:"records"`
+			}
+			if err := json.Unmarshal(rec.Data, &end); err != nil || end.Records != n-2 {
+				return fail(n, "end marker counts %d records, the file has %d", end.Records, n-2)
+			}
+			ended = true
+		}
+		prev = lineHash(line)
+	}
+	if err := sc.Err(); err != nil {
+		return fail(n+1, "%v", err)
+	}
+	switch {
+	case n == 0:
+		return fail(0, "empty file")
+	case !ended:
+		return fail(n, "no end marker: the trail is truncated")
+	case head != "" && head != prev:
+		return fail(n, "chain ends at %s, not the expected %s", prev, head)
+	}
+	c.Valid, c.Records, c.Head = true, n-2, prev
+	return c
+}
+
+func cmdAudit(c *Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "audit",
+		Short: "Export and verify tamper-evident audit trails of tasks",
+	}
+	cmd.AddCommand(cmdAuditExport(c), cmdAuditVerify())
+	return cmd
+}
+
+func cmdAuditExport(c *Client) *cobra.Command {
+	var out string
+	cmd := &cobra.Command{
+		Use:   "export [id]",
+		Short: "Export a task's audit trail as hash-chained JSONL",
+		Long: `export writes every recorded state change, phase, approval, and message of a
+task as JSON lines, each holding the SHA-256 of the line before it. Where the
+server keeps no trail for the task, it is rebuilt from the task, its timeline
+and comments, and this machine's audit log, and the header says so.
+
+The hash of the last line is printed when done. Keep it somewhere the file
+can't be changed alongside it, such as the change ticket: with it,
+audit verify --head proves the whole file is as exported.
+
+  autocodit audit export 3fa2 --out 3fa2-audit.jsonl`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			var t Task
+			if err := c.DoJSON(ctx, http.MethodGet, "/api/v1/tasks/"+args[0], nil, &t); err != nil {
+				return err
+			}
+			recs, source, err := c.taskTrail(ctx, t)
+			if err != nil {
+				return err
+			}
+			header := map[string]any{"task": t.ID, "repository": t.Repository, "endpoint": c.BaseURL,
+				"source": source, "exported_by": pick(serviceActor, c.cfg.GitHubUser), "cli_version": version}
+			w := io.Writer(os.Stdout)
+			if out != "" && out != "-" {
+				f, err := os.OpenFile(out, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				w = f
+			}
+			head, err := writeTrail(w, header, recs)
+			if err != nil {
+				return err
+			}
+			audit("audit.export", map[string]any{"task": t.ID, "records": len(recs), "source": source, "head": head})
+			fmt.Fprintf(os.Stderr, "Exported %d record(s) of %s (%s). Chain head:\n  %s\n", len(recs), t.ID, source, head)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&out, "out", "", "write to this file, which must not exist yet (default: stdout)")
+	return cmd
+}
+
+func cmdAuditVerify() *cobra.Command {
+	var head string
+	cmd := &cobra.Command{
+		Use:   "verify [file]",
+		Short: "Check that an exported audit trail's hash chain is intact",
+		Long: `verify checks that every line of an audit export is chained to the one before
+and that the trail ends with its end marker; - reads stdin. With --head it also
+checks the chain ends at the hash printed by export, which catches a file
+rewritten from some point on. It exits 1 when the trail doesn't verify.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			r := io.Reader(os.Stdin)
+			if args[0] != "-" {
+				f, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				r = f
+			}
+			check := verifyTrail(r, head)
+			if err := printOutput(check, func() {
+				if check.Valid {
+					fmt.Printf("%s %d record(s) of %s, chain head %s\n", colorize(colorGreen, "OK"), check.Records, check.Task, check.Head)
+					if head == "" {
+						fmt.Println(colorize(colorGray, "Pass --head with the hash from export to rule out a rewritten file."))
+					}
+					return
+				}
+				fmt.Printf("%s line %d: %s\n", colorize(colorRed, "FAILED"), check.Line, check.Error)
+			}); err != nil {
+				return err
+			}
+			if !check.Valid {
+				cmd.SilenceErrors, cmd.SilenceUsage = true, true
+				return exitCodeError{code: 1}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&head, "head", "", "expected hash of the last line, as printed by audit export")
+	return cmd
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type benchSample struct {
+	create, queue, total time.Duration
+	status               string
+	err                  error
+}
+
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+// benchTask submits one synthetic task and polls it to completion. Queue wait
+// comes from the server's started_at when reported, otherwise from the first
+// poll that saw the task leave the queue.
+func (c *Client) benchTask(ctx context.Context, req CreateTaskRequest, poll time.Duration) benchSample {
+	var s benchSample
+	submitted := time.Now()
+	var t Task
+	if s.err = c.createTask(ctx, req, &t); s.err != nil {
+		return s
+	}
+	s.create = time.Since(submitted)
+
+	var dequeued time.Time
+	for !isFinished(t.Status) {
+		select {
+		case <-ctx.Done():
+			s.err = ctx.Err()
+			return s
+		case <-time.After(poll):
+		}
+		if s.err = c.DoJSON(ctx, http.MethodGet, "/api/v1/tasks/"+t.ID, nil, &t); s.err != nil {
+			return s
+		}
+		if dequeued.IsZero() && t.Status != "queued" && t.Status != "pending" {
+			dequeued = time.Now()
+		}
+	}
+	s.total = time.Since(submitted)
+	s.status = t.Status
+	switch {
+	case t.StartedAt != nil && !t.CreatedAt.IsZero():
+		s.queue = t.StartedAt.Sub(t.CreatedAt)
+	case !dequeued.IsZero():
+		s.queue = dequeued.Sub(submitted)
+	}
+	return s
+}
+
+func cmdBenchmark(c *Client) *cobra.Command {
+	var repo, action, timeout, poll string
+	var count, concurrency int
+	cmd := &cobra.Command{
+		Use:   "benchmark",
+		Short: "Submit synthetic tasks and report latency and throughput",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if repo == "" {
+				repo = c.cfg.DefaultRepo
+			}
+			if repo == "" {
+				return fmt.Errorf("--repo or default_repo required")
+			}
+			if count < 1 || concurrency < 1 {
+				return fmt.Errorf("--count and --concurrency must be positive")
+			}
+			limit, err := parseDuration(timeout)
+			if err != nil {
+				return fmt.Errorf("invalid --timeout %q", timeout)
+			}
+			interval, err := parseDuration(poll)
+			if err != nil || interval <= 0 {
+				return fmt.Errorf("invalid --poll %q", poll)
+			}
+			ctx, cancel := context.WithTimeout(cmd.Context(), limit)
+			defer cancel()
+
+			run := strconv.FormatInt(time.Now().Unix(), 36)
+			jobs := make(chan int)
+			samples := make([]benchSample, count)
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			done := 0
+			start := time.Now()
+			for w := 0; w < concurrency; w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := range jobs {
+						req := CreateTaskRequest{
+							Title:       fmt.Sprintf("benchmark %s #%d", run, i+1),
+							Description: "Synthetic benchmark task; performs no work.",
+							Repository:  repo,
+							ActionType:  action,
+							Priority:    "low",
+							AgentConfig: map[string]interface{}{"benchmark_run": run},
+						}
+						samples[i] = c.benchTask(ctx, req, interval)
+						mu.Lock()
+						done++
+						fmt.Fprintf(os.Stderr, "\r%d/%d tasks finished", done, count)
+						mu.Unlock()
+					}
+				}()
+			}
+			for i := 0; i < count; i++ {
+				jobs <- i
+			}
+			close(jobs)
+			wg.Wait()
+			wall := time.Since(start)
+			fmt.Fprintln(os.Stderr)
+
+			var create, queue, total []time.Duration
+			failed, errs := 0, 0
+			var firstErr error
+			for _, s := range samples {
+				if s.err != nil {
+					if errs++; firstErr == nil {
+						firstErr = s.err
+					}
+					continue
+				}
+				if s.status != "completed" {
+					failed++
+				}
+				create = append(create, s.create)
+				queue = append(queue, s.queue)
+				total = append(total, s.total)
+			}
+			for _, d := range [][]time.Duration{create, queue, total} {
+				sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+			}
+
+			fmt.Printf("Run %s: %d tasks, concurrency %d, %s wall time\n", run, count, concurrency, wall.Round(time.Millisecond))
+			fmt.Printf("Throughput: %.2f tasks/s; %d not completed, %d errors\n\n", float64(len(total))/wall.Seconds(), failed, errs)
+			tbl := newTable(os.Stdout, c.tableMaxWidth(), column{header: "METRIC"},
+				column{header: "P50", right: true}, column{header: "P90", right: true},
+				column{header: "P95", right: true}, column{header: "P99", right: true}, column{header: "MAX", right: true})
+			for _, m := range []struct {
+				name string
+				d    []time.Duration
+			}{{"create", create}, {"queue wait", queue}, {"end-to-end", total}} {
+				row := []string{m.name}
+				for _, p := range []float64{50, 90, 95, 99, 100} {
+					row = append(row, percentile(m.d, p).Round(time.Millisecond).String())
+				}
+				tbl.add(row...)
+			}
+			tbl.render()
+			if firstErr != nil {
+				return fmt.Errorf("%d of %d tasks errored, first: %w", errs, count, firstErr)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&repo, "repo", "r", "", "owner/repo the synthetic tasks are filed against")
+	cmd.Flags().StringVarP(&action, "type", "t", "noop", "action type to submit")
+	cmd.Flags().IntVarP(&count, "count", "n", 20, "number of tasks to submit")
+	cmd.Flags().IntVarP(&concurrency, "concurrency", "c", 4, "tasks in flight at once")
+	cmd.Flags().StringVar(&timeout, "timeout", "15m", "abort the run after this long")
+	cmd.Flags().StringVar(&poll, "poll", "1s", "status poll interval per task")
+	return cmd
+}
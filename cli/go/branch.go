@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+// sparseCheckout restricts the working tree to the directories touched by
+// patch using cone-mode sparse-checkout, which keeps huge monorepos fast.
+func sparseCheckout(patch []byte) error {
+	files, err := patchFiles(patch)
+	if err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	var dirs []string
+	for _, f := range files {
+		d := path.Dir(f)
+		if d == "." || seen[d] {
+			continue
+		}
+		seen[d] = true
+		dirs = append(dirs, d)
+	}
+	sort.Strings(dirs)
+	if _, err := git(append([]string{"sparse-checkout", "set", "--cone"}, dirs...)...); err != nil {
+		return err
+	}
+	fmt.Printf("Sparse checkout limited to %d director(ies); restore with: git sparse-checkout disable\n", len(dirs))
+	return nil
+}
+
+func cmdBranch(c *Client) *cobra.Command {
+	var remote string
+	var sparse bool
+	cmd := &cobra.Command{
+		Use:   "branch [id]",
+		Short: "Check out the agent's branch for a task",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			var t Task
+			if err := c.DoJSON(ctx, http.MethodGet, "/api/v1/tasks/"+args[0], nil, &t); err != nil {
+				return err
+			}
+			if t.BranchName == "" {
+				return fmt.Errorf("task %s has no branch yet", t.ID)
+			}
+			if sparse {
+				patch, err := c.taskPatch(ctx, t.ID)
+				if err != nil {
+					return err
+				}
+				if err := sparseCheckout(patch); err != nil {
+					return err
+				}
+			}
+			ref := "refs/remotes/" + remote + "/" + t.BranchName
+			if _, err := git("fetch", remote, "+refs/heads/"+t.BranchName+":"+ref); err != nil {
+				return err
+			}
+			if _, err := git("checkout", "-B", t.BranchName, "--track", remote+"/"+t.BranchName); err != nil {
+				return err
+			}
+			fmt.Println("Switched to branch", t.BranchName)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&remote, "remote", "origin", "git remote the agent pushed to")
+	cmd.Flags().BoolVar(&sparse, "sparse", false, "limit the checkout to directories the task touches (git sparse-checkout)")
+	return cmd
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+const budgetPath = "/api/v1/budget"
+
+type budgetSettings struct {
+	Monthly float64 `json:"monthly"`
+	Alert   float64 `json:"alert_threshold"`
+	Enforce bool    `json:"enforce"`
+}
+
+type budgetSpend struct {
+	Repository string  `json:"repository"`
+	ActionType string  `json:"action_type"`
+	Tasks      int     `json:"tasks"`
+	Cost       float64 `json:"cost"`
+}
+
+type budgetStatus struct {
+	budgetSettings
+	Spent       float64       `json:"spent"`
+	PeriodStart time.Time     `json:"period_start"`
+	PeriodEnd   time.Time     `json:"period_end"`
+	Breakdown   []budgetSpend `json:"breakdown"`
+}
+
+func (b budgetStatus) used() float64 {
+	if b.Monthly <= 0 {
+		return 0
+	}
+	return b.Spent / b.Monthly
+}
+
+// parsePercent accepts "80%", "80", or "0.8".
+func parsePercent(s string) (float64, error) {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid percentage %q", s)
+	}
+	if strings.HasSuffix(s, "%") || v > 1 {
+		v /= 100
+	}
+	if v > 1 {
+		return 0, fmt.Errorf("invalid percentage %q", s)
+	}
+	return v, nil
+}
+
+// fetchBudget returns nil without error when no budget is configured.
+func (c *Client) fetchBudget(ctx context.Context) (*budgetStatus, error) {
+	var b budgetStatus
+	err := c.DoJSON(ctx, http.MethodGet, budgetPath+"/status", nil, &b)
+	var apiErr *sdk.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// checkBudget warns once spend passes the alert threshold and refuses create
+// when the budget is enforced.
+func (c *Client) checkBudget(ctx context.Context) error {
+	b, err := c.fetchBudget(ctx)
+	if err != nil || b == nil || b.Monthly <= 0 || b.used() < b.Alert {
+		return nil
+	}
+	msg := fmt.Sprintf("monthly budget %.0f%% used ($%.2f of $%.2f)", b.used()*100, b.Spent, b.Monthly)
+	if b.Enforce {
+		return fmt.Errorf("%s; budget is enforced, raise it with: autocodit budget set --monthly N", msg)
+	}
+	fmt.Fprintln(os.Stderr, colorize(colorYellow, "Warning: "+msg))
+	return nil
+}
+
+// localBreakdown sums task cost for the current period when the server does
+// not report a breakdown.
+func (c *Client) localBreakdown(ctx context.Context, since time.Time) ([]budgetSpend, error) {
+	type key struct{ repo, action string }
+	sums := map[key]*budgetSpend{}
+	tasks, errc := c.ListTasks(sdk.ListTasksOptions{PerPage: 100}).Stream(ctx)
+	for t := range tasks {
+		if t.CreatedAt.Before(since) {
+			continue
+		}
+		k := key{t.Repository, t.ActionType}
+		s := sums[k]
+		if s == nil {
+			s = &budgetSpend{Repository: t.Repository, ActionType: t.ActionType}
+			sums[k] = s
+		}
+		s.Tasks++
+		s.Cost += t.Cost
+	}
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+	var out []budgetSpend
+	for _, s := range sums {
+		out = append(out, *s)
+	}
+	return out, nil
+}
+
+func burnBar(used float64, width int) string {
+	n := int(used * float64(width))
+	if n > width {
+		n = width
+	}
+	bar := strings.Repeat("█", n) + strings.Repeat("░", width-n)
+	switch {
+	case used >= 1:
+		return colorize(colorRed, bar)
+	case used >= 0.8:
+		return colorize(colorYellow, bar)
+	}
+	return colorize(colorGreen, bar)
+}
+
+func cmdBudget(c *Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "budget",
+		Short: "Configure and inspect monthly spend budgets",
+	}
+
+	var monthly float64
+	var alert string
+	var enforce bool
+	set := &cobra.Command{
+		Use:   "set",
+		Short: "Set the monthly budget and alert threshold",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if monthly <= 0 {
+				return fmt.Errorf("--monthly must be positive")
+			}
+			threshold, err := parsePercent(alert)
+			if err != nil {
+				return err
+			}
+			req := budgetSettings{Monthly: monthly, Alert: threshold, Enforce: enforce}
+			if err := c.DoJSON(cmd.Context(), http.MethodPut, budgetPath, &req, nil); err != nil {
+				return err
+			}
+			mode := "warn"
+			if enforce {
+				mode = "block"
+			}
+			fmt.Printf("Budget set: $%.2f/month, %s at %.0f%%\n", monthly, mode, threshold*100)
+			return nil
+		},
+	}
+	set.Flags().Float64Var(&monthly, "monthly", 0, "monthly budget in USD")
+	set.Flags().StringVar(&alert, "alert", "80%", "warn when this share of the budget is spent")
+	set.Flags().BoolVar(&enforce, "enforce", false, "refuse create past the alert threshold instead of warning")
+
+	status := &cobra.Command{
+		Use:   "status",
+		Short: "Show spend against the budget by repository and action type",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			b, err := c.fetchBudget(cmd.Context())
+			if err != nil {
+				return err
+			}
+			if b == nil {
+				fmt.Println("No budget configured; set one with: autocodit budget set --monthly N")
+				return nil
+			}
+			if b.Breakdown == nil {
+				start := b.PeriodStart
+				if start.IsZero() {
+					now := time.Now()
+					start = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+				}
+				if b.Breakdown, err = c.localBreakdown(cmd.Context(), start); err != nil {
+					return err
+				}
+			}
+
+			fmt.Printf("%s %.0f%%  $%.2f of $%.2f", burnBar(b.used(), 30), b.used()*100, b.Spent, b.Monthly)
+			if !b.PeriodEnd.IsZero() {
+				fmt.Printf(", resets %s", b.PeriodEnd.Local().Format("Jan 2"))
+			}
+			fmt.Println()
+			if b.Enforce {
+				fmt.Printf("Enforced: create is refused past %.0f%%\n", b.Alert*100)
+			} else {
+				fmt.Printf("Alert at %.0f%%\n", b.Alert*100)
+			}
+			if len(b.Breakdown) == 0 {
+				return nil
+			}
+
+			sort.Slice(b.Breakdown, func(i, j int) bool { return b.Breakdown[i].Cost > b.Breakdown[j].Cost })
+			fmt.Println()
+			tbl := newTable(os.Stdout, c.tableMaxWidth(), column{header: "REPOSITORY", flex: true},
+				column{header: "TYPE"}, column{header: "TASKS", right: true},
+				column{header: "COST", right: true}, column{header: "SHARE", right: true})
+			for _, s := range b.Breakdown {
+				share := 0.0
+				if b.Spent > 0 {
+					share = s.Cost / b.Spent * 100
+				}
+				tbl.add(s.Repository, s.ActionType, strconv.Itoa(s.Tasks), fmt.Sprintf("$%.2f", s.Cost), fmt.Sprintf("%.0f%%", share))
+			}
+			tbl.render()
+			return nil
+		},
+	}
+
+	cmd.AddCommand(set, status)
+	return cmd
+}
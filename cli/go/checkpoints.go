@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+// checkpoint is a snapshot of the task workspace taken after an agent step.
+// Step 0 is the workspace before the agent changed anything.
+type checkpoint struct {
+	Step      int            `json:"step"`
+	ID        string         `json:"id"`
+	Summary   string         `json:"summary"`
+	Commit    string         `json:"commit,omitempty"`
+	CreatedAt time.Time      `json:"created_at"`
+	DiffStats *sdk.DiffStats `json:"diff_stats,omitempty"`
+}
+
+func (c *Client) checkpoints(ctx context.Context, id string) ([]checkpoint, error) {
+	var cps []checkpoint
+	err := c.DoJSON(ctx, http.MethodGet, "/api/v1/tasks/"+id+"/checkpoints", nil, &cps)
+	var apiErr *sdk.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("no checkpoints recorded for task %s", id)
+	}
+	return cps, err
+}
+
+func (c *Client) checkpointDiff(ctx context.Context, id string, from, to int) ([]byte, error) {
+	body, err := c.Fetch(ctx, fmt.Sprintf("/api/v1/tasks/%s/checkpoints/diff?from=%d&to=%d", id, from, to))
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+// parseStepRange accepts "A..B", or a single step N meaning what step N
+// changed (N-1..N).
+func parseStepRange(s string) (int, int, error) {
+	a, b, ok := strings.Cut(s, "..")
+	if !ok {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return 0, 0, fmt.Errorf("invalid step %q", s)
+		}
+		return n - 1, n, nil
+	}
+	from, err1 := strconv.Atoi(a)
+	to, err2 := strconv.Atoi(b)
+	if err1 != nil || err2 != nil || from < 0 || to <= from {
+		return 0, 0, fmt.Errorf("invalid step range %q (want A..B with A < B)", s)
+	}
+	return from, to, nil
+}
+
+func hasStep(cps []checkpoint, step int) bool {
+	if step == 0 {
+		return true
+	}
+	for _, cp := range cps {
+		if cp.Step == step {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Client) printCheckpoints(cps []checkpoint) {
+	tbl := newTable(os.Stdout, c.tableMaxWidth(), column{header: "STEP", right: true},
+		column{header: "TIME"}, column{header: "CHANGES"}, column{header: "SUMMARY", flex: true})
+	for _, cp := range cps {
+		tbl.add(strconv.Itoa(cp.Step), cp.CreatedAt.Local().Format("15:04:05"), diffColumn(cp.DiffStats), redact(firstLine(cp.Summary)))
+	}
+	tbl.render()
+}
+
+// printPatch writes a unified diff with colored hunks, redacting secrets.
+func printPatch(w io.Writer, patch []byte) {
+	for _, l := range strings.Split(strings.TrimRight(string(patch), "\n"), "\n") {
+		l = redact(l)
+		switch {
+		case strings.HasPrefix(l, "diff --git "):
+			l = colorize("1", l)
+		case strings.HasPrefix(l, "+++ "), strings.HasPrefix(l, "--- "):
+			l = colorize("1", l)
+		case strings.HasPrefix(l, "index "), strings.HasPrefix(l, "new file mode "), strings.HasPrefix(l, "deleted file mode "),
+			strings.HasPrefix(l, "old mode "), strings.HasPrefix(l, "new mode "), strings.HasPrefix(l, "similarity index "),
+			strings.HasPrefix(l, "rename from "), strings.HasPrefix(l, "rename to "), strings.HasPrefix(l, "Binary files "):
+			l = colorize(colorGray, l)
+		case strings.HasPrefix(l, "@@"):
+			// Color the range, not the function name git puts after it.
+			if i := strings.Index(l[2:], "@@"); i >= 0 {
+				l = colorize(colorBlue, l[:i+4]) + l[i+4:]
+				break
+			}
+			l = colorize(colorBlue, l)
+		case strings.HasPrefix(l, "+"):
+			l = colorize(colorGreen, l)
+		case strings.HasPrefix(l, "-"):
+			l = colorize(colorRed, l)
+		}
+		fmt.Fprintln(w, l)
+	}
+}
+
+func cmdDiff(c *Client) *cobra.Command {
+	var between string
+	var list, stat, nameOnly bool
+	cmd := &cobra.Command{
+		Use:   "diff [id]",
+		Short: "Show a task's changes, overall or between agent steps",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			id := args[0]
+			if list {
+				cps, err := c.checkpoints(ctx, id)
+				if err != nil {
+					return err
+				}
+				c.printCheckpoints(cps)
+				return nil
+			}
+
+			var patch []byte
+			var err error
+			if between == "" {
+				patch, err = c.taskPatch(ctx, id)
+			} else {
+				from, to, perr := parseStepRange(between)
+				if perr != nil {
+					return perr
+				}
+				cps, cerr := c.checkpoints(ctx, id)
+				if cerr != nil {
+					return cerr
+				}
+				for _, s := range []int{from, to} {
+					if !hasStep(cps, s) {
+						return fmt.Errorf("task %s has no step %d; see: autocodit diff %s --list", id, s, id)
+					}
+				}
+				patch, err = c.checkpointDiff(ctx, id, from, to)
+			}
+			if err != nil {
+				return err
+			}
+			if len(bytes.TrimSpace(patch)) == 0 {
+				fmt.Println("No changes")
+				return nil
+			}
+			switch {
+			case stat:
+				files := patchFileStats(patch)
+				return printOutput(files, func() { printFileStats(os.Stdout, files, terminalWidth()) })
+			case nameOnly:
+				var names []string
+				for _, f := range patchFileStats(patch) {
+					names = append(names, f.Path)
+				}
+				return printOutput(names, func() { fmt.Println(strings.Join(names, "\n")) })
+			}
+			return withPager(c.cfg.Pager, func(w io.Writer) { printPatch(w, patch) })
+		},
+	}
+	cmd.Flags().StringVar(&between, "between", "", "steps to compare, as A..B or N for what step N changed")
+	cmd.Flags().BoolVar(&list, "list", false, "list the task's checkpoints")
+	cmd.Flags().BoolVar(&stat, "stat", false, "print lines changed per file instead of the patch")
+	cmd.Flags().BoolVar(&nameOnly, "name-only", false, "print only the paths of changed files")
+	cmd.MarkFlagsMutuallyExclusive("list", "between")
+	cmd.MarkFlagsMutuallyExclusive("stat", "name-only", "list")
+	return cmd
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+// staleBranch is an agent branch nothing will push to again, with the
+// finished tasks that used it.
+type staleBranch struct {
+	Branch string   `json:"branch"`
+	Reason string   `json:"reason"`
+	Tasks  []string `json:"tasks"`
+}
+
+// staleBranches finds the branches of finished tasks on repo whose pull
+// request merged or that were cancelled, leaving out any branch an
+// unfinished task or an open pull request also uses. Branches the repository
+// no longer has are left out when the server reports its branch list.
+func (c *Client) staleBranches(ctx context.Context, repo string) ([]staleBranch, error) {
+	var meta RepoMeta
+	if err := c.DoJSON(ctx, http.MethodGet, "/api/v1/repositories/"+repo, nil, &meta); err != nil {
+		return nil, err
+	}
+	exists := map[string]bool{}
+	for _, b := range meta.Branches {
+		exists[b] = true
+	}
+
+	byBranch := map[string]*staleBranch{}
+	// Tasks share branches, e.g. after retry --clone, so a branch a
+	// running task or an open pull request still uses is kept whatever
+	// the other tasks on it say.
+	keep := map[string]bool{}
+	tasks, errc := c.ListTasks(sdk.ListTasksOptions{Repository: repo, PerPage: 100}).Stream(ctx)
+	for t := range tasks {
+		if t.BranchName == "" || t.BranchName == meta.DefaultBranch || keep[t.BranchName] {
+			continue
+		}
+		if len(exists) > 0 && !exists[t.BranchName] {
+			continue
+		}
+		var pr *pullState
+		if t.PRNumber != nil {
+			path := fmt.Sprintf("/api/v1/repositories/%s/pulls/%d", repo, *t.PRNumber)
+			pr = &pullState{}
+			if err := c.DoJSON(ctx, http.MethodGet, path, nil, pr); err != nil {
+				// Unknown is treated as open.
+				pr.State = "open"
+			}
+		}
+		if !isFinished(t.Status) || pr != nil && !pr.Merged && pr.State != "closed" {
+			keep[t.BranchName] = true
+			delete(byBranch, t.BranchName)
+			continue
+		}
+		reason := ""
+		if t.Status == "cancelled" {
+			reason = "task cancelled"
+		}
+		if pr != nil && pr.Merged {
+			reason = fmt.Sprintf("PR #%d merged", *t.PRNumber)
+		}
+		if reason == "" {
+			continue
+		}
+		sb := byBranch[t.BranchName]
+		if sb == nil {
+			sb = &staleBranch{Branch: t.BranchName, Reason: reason}
+			byBranch[t.BranchName] = sb
+		}
+		sb.Tasks = append(sb.Tasks, t.ID)
+	}
+	if err := <-errc; err != nil {
+		return nil, err
+	}
+
+	var out []staleBranch
+	for _, sb := range byBranch {
+		out = append(out, *sb)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Branch < out[j].Branch })
+	return out, nil
+}
+
+func (c *Client) deleteBranch(ctx context.Context, repo, branch string) error {
+	err := c.DoJSON(ctx, http.MethodDelete, "/api/v1/repositories/"+repo+"/branches/"+branch, nil, nil)
+	var apiErr *sdk.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return nil // already gone
+	}
+	return err
+}
+
+func cmdCleanup(c *Client) *cobra.Command {
+	var repo string
+	var yes, dryRun, keepTasks bool
+	cmd := &cobra.Command{
+		Use:   "cleanup",
+		Short: "Delete agent branches whose PRs merged or whose tasks were cancelled, and archive the tasks",
+		Long: `cleanup looks at the finished tasks on a repository and picks out the branches
+nothing will push to again: those whose pull request merged and those of
+cancelled tasks. It lists them, then, once confirmed, deletes each remote branch
+and archives its tasks. A branch that any unfinished task or open pull
+request still uses is never touched, even if other tasks on it are done.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			if repo == "" {
+				repo = c.cfg.DefaultRepo
+			}
+			if repo == "" {
+				return fmt.Errorf("--repo is required (or set default_repo)")
+			}
+			stale, err := c.staleBranches(ctx, repo)
+			if err != nil {
+				return err
+			}
+			if len(stale) == 0 {
+				return printOutput([]staleBranch{}, func() { fmt.Printf("No stale agent branches in %s\n", repo) })
+			}
+
+			// The preview is the output of --dry-run; otherwise it goes to
+			// stderr next to the prompt.
+			w := os.Stderr
+			if dryRun {
+				w = os.Stdout
+			}
+			tasks := 0
+			tbl := newTable(w, c.tableMaxWidth(), column{header: "BRANCH", flex: true}, column{header: "REASON"}, column{header: "TASKS"})
+			for _, sb := range stale {
+				tasks += len(sb.Tasks)
+				ids := sb.Tasks[0]
+				if len(sb.Tasks) > 1 {
+					ids += fmt.Sprintf(" +%d", len(sb.Tasks)-1)
+				}
+				tbl.add(sb.Branch, sb.Reason, ids)
+			}
+			if dryRun {
+				return printOutput(stale, tbl.render)
+			}
+			if !machineOutput() || !yes {
+				tbl.render()
+			}
+
+			question := fmt.Sprintf("Delete %d branch(es) from %s and archive %d task(s)?", len(stale), repo, tasks)
+			if keepTasks {
+				question = fmt.Sprintf("Delete %d branch(es) from %s?", len(stale), repo)
+			}
+			if !yes {
+				if !term.IsTerminal(int(os.Stdin.Fd())) {
+					return fmt.Errorf("refusing to delete branches without confirmation; pass --yes")
+				}
+				if !confirm(question) {
+					return fmt.Errorf("aborted")
+				}
+			}
+
+			deleted, archived := 0, 0
+			for _, sb := range stale {
+				if err := c.deleteBranch(ctx, repo, sb.Branch); err != nil {
+					fmt.Fprintf(os.Stderr, "%s deleting %s: %v\n", colorize(colorYellow, "warning:"), sb.Branch, err)
+					continue
+				}
+				deleted++
+				if keepTasks {
+					continue
+				}
+				for _, id := range sb.Tasks {
+					if err := c.applyFix(ctx, "archive", Task{ID: id}); err != nil {
+						fmt.Fprintf(os.Stderr, "%s archiving %s: %v\n", colorize(colorYellow, "warning:"), id, err)
+						continue
+					}
+					archived++
+				}
+			}
+			audit("cleanup", map[string]any{"repository": repo, "branches": deleted, "archived": archived})
+			return printOutput(stale, func() {
+				fmt.Printf("Deleted %d branch(es) and archived %d task(s) in %s\n", deleted, archived, repo)
+			})
+		},
+	}
+	cmd.Flags().StringVarP(&repo, "repo", "r", "", "repository to clean up (owner/name; default: default_repo)")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "skip the confirmation prompt")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "list the stale branches without deleting anything")
+	cmd.Flags().BoolVar(&keepTasks, "keep-tasks", false, "delete branches but leave their tasks unarchived")
+	return cmd
+}
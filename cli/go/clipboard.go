@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// osc52Max is about what terminals accept in one OSC 52 sequence; some drop
+// anything longer without a word.
+const osc52Max = 100_000
+
+// clipboardCommands are the native tools tried in order, by platform.
+func clipboardCommands() [][]string {
+	switch runtime.GOOS {
+	case "darwin":
+		return [][]string{{"pbcopy"}}
+	case "windows":
+		return [][]string{{"clip.exe"}}
+	}
+	var cmds [][]string
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		cmds = append(cmds, []string{"wl-copy"})
+	}
+	if os.Getenv("DISPLAY") != "" {
+		cmds = append(cmds, []string{"xclip", "-selection", "clipboard"}, []string{"xsel", "--clipboard", "--input"})
+	}
+	// WSL, where the Windows clipboard is the one that matters.
+	return append(cmds, []string{"clip.exe"})
+}
+
+// osc52 asks the terminal itself to set the clipboard, which reaches the
+// local machine's clipboard from an SSH session. Inside tmux the sequence
+// has to be passed through.
+func osc52(text string) error {
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+	if len(seq) > osc52Max {
+		return fmt.Errorf("%s is too much for the terminal clipboard; redirect it to a file instead (--to-clipboard=false)", formatBytes(len(text)))
+	}
+	seq = passthrough(seq)
+	tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		if !term.IsTerminal(int(os.Stderr.Fd())) {
+			return fmt.Errorf("no clipboard tool found and no terminal to send OSC 52 to")
+		}
+		tty = os.Stderr
+	} else {
+		defer tty.Close()
+	}
+	_, err = tty.WriteString(seq)
+	return err
+}
+
+// copyToClipboard puts text on the clipboard and says how. Over SSH the
+// terminal's clipboard is used, since native tools would fill the remote
+// machine's.
+func copyToClipboard(text string) (string, error) {
+	if os.Getenv("SSH_TTY") == "" && os.Getenv("SSH_CONNECTION") == "" {
+		for _, args := range clipboardCommands() {
+			if _, err := exec.LookPath(args[0]); err != nil {
+				continue
+			}
+			cmd := exec.Command(args[0], args[1:]...)
+			cmd.Stdin = strings.NewReader(text)
+			if err := cmd.Run(); err == nil {
+				return args[0], nil
+			}
+		}
+	}
+	if err := osc52(text); err != nil {
+		return "", err
+	}
+	return "OSC 52", nil
+}
+
+func cmdCopy(c *Client) *cobra.Command {
+	var prURLOnly, diff, asJSON, link, toClipboard bool
+	cmd := &cobra.Command{
+		Use:   "copy [id]",
+		Short: "Copy a task's ID, PR URL, diff, or JSON to the clipboard",
+		Long: `copy puts the task's ID on the clipboard, or with a flag its pull request URL,
+web URL, diff, or full JSON. It uses pbcopy, clip.exe, wl-copy, xclip, or xsel,
+and the terminal's own clipboard (OSC 52) over SSH or when none is available.
+The diff and JSON go through secret redaction.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			id := args[0]
+			var t Task
+			if err := c.DoJSON(ctx, http.MethodGet, "/api/v1/tasks/"+id, nil, &t); err != nil {
+				return err
+			}
+			what, text := "ID", t.ID
+			switch {
+			case prURLOnly:
+				if t.PRNumber == nil {
+					return fmt.Errorf("%s has no pull request yet", t.ID)
+				}
+				what, text = "PR URL", prURL(t.Repository, *t.PRNumber)
+			case link:
+				if text = c.taskURL(t.ID); text == "" {
+					return fmt.Errorf("set web_url in the config to copy task URLs")
+				}
+				what = "URL"
+			case diff:
+				patch, err := c.taskPatch(ctx, t.ID)
+				if err != nil {
+					return err
+				}
+				if len(patch) == 0 {
+					return fmt.Errorf("%s has no diff", t.ID)
+				}
+				what, text = "diff", redact(string(patch))
+			case asJSON:
+				b, err := json.MarshalIndent(t, "", "  ")
+				if err != nil {
+					return err
+				}
+				what, text = "JSON", redact(string(b))
+			}
+			if !toClipboard {
+				fmt.Println(text)
+				return nil
+			}
+			how, err := copyToClipboard(text)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Copied the %s of %s to the clipboard (%s, %s)\n", what, t.ID, how, formatBytes(len(text)))
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&prURLOnly, "pr-url", false, "copy the pull request URL")
+	cmd.Flags().BoolVar(&link, "url", false, "copy the task's web URL (needs web_url)")
+	cmd.Flags().BoolVar(&diff, "diff", false, "copy the task's diff")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "copy the task as JSON")
+	cmd.Flags().BoolVar(&toClipboard, "to-clipboard", true, "put it on the clipboard; --to-clipboard=false prints it instead")
+	cmd.MarkFlagsMutuallyExclusive("pr-url", "url", "diff", "json")
+	return cmd
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// taskComment is one message on a task's thread: guidance from a person, or
+// a note or question from the agent.
+type taskComment struct {
+	ID        string    `json:"id"`
+	Author    string    `json:"author"`
+	Role      string    `json:"role"`
+	Kind      string    `json:"kind,omitempty"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (tc taskComment) key() string {
+	if tc.ID != "" {
+		return tc.ID
+	}
+	return tc.CreatedAt.String() + "\x00" + tc.Author + "\x00" + tc.Body
+}
+
+func (c *Client) taskComments(ctx context.Context, id string, since time.Time) ([]taskComment, error) {
+	path := "/api/v1/tasks/" + id + "/comments"
+	if !since.IsZero() {
+		path += "?" + url.Values{"since": {since.UTC().Format(time.RFC3339Nano)}}.Encode()
+	}
+	var out []taskComment
+	err := c.DoJSON(ctx, http.MethodGet, path, nil, &out)
+	return out, err
+}
+
+func formatComment(tc taskComment) string {
+	who := tc.Author
+	if who == "" {
+		who = tc.Role
+	}
+	if tc.Role == "agent" {
+		who = colorize(colorBlue, who)
+	}
+	mark := " "
+	if tc.Kind == "question" {
+		mark = colorize(colorYellow, "?")
+	}
+	lines := strings.Split(strings.TrimRight(redact(tc.Body), "\n"), "\n")
+	head := fmt.Sprintf("%s %s %s ", colorize(colorGray, tc.CreatedAt.Local().Format("01-02 15:04:05")), mark, who)
+	indent := strings.Repeat(" ", displayWidth(head))
+	for i := 1; i < len(lines); i++ {
+		lines[i] = indent + lines[i]
+	}
+	return head + strings.Join(lines, "\n")
+}
+
+// commentText is the comment from args, else piped stdin, else written in
+// the editor.
+func commentText(id string, args []string) (string, error) {
+	if len(args) > 1 {
+		if args[1] == "-" {
+			b, err := io.ReadAll(os.Stdin)
+			return strings.TrimSpace(string(b)), err
+		}
+		return strings.TrimSpace(args[1]), nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		b, err := io.ReadAll(os.Stdin)
+		return strings.TrimSpace(string(b)), err
+	}
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	p := filepath.Join(dir, "comment-"+id+".md")
+	if err := os.WriteFile(p, []byte("\n# Comment for the agent working on "+id+". Lines starting with # are ignored.\n"), 0o600); err != nil {
+		return "", err
+	}
+	defer os.Remove(p)
+	if err := runEditor(p); err != nil {
+		return "", err
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return "", err
+	}
+	return stripComments(string(b)), nil
+}
+
+func cmdComment(c *Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "comment [id] [text]",
+		Short: "Leave guidance for the agent on a task's comment thread",
+		Long: `comment posts text to a task's thread, where the agent picks it up between
+steps; use it to answer the agent's questions or steer a run. The text comes
+from the argument, stdin when piped or given as -, or $EDITOR.
+
+  autocodit comment 3fa2 "Keep the public API unchanged; add a wrapper instead"`,
+		Args: cobra.RangeArgs(1, 2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			id := args[0]
+			var t Task
+			if err := c.DoJSON(ctx, http.MethodGet, "/api/v1/tasks/"+id, nil, &t); err != nil {
+				return err
+			}
+			text, err := commentText(t.ID, args)
+			if err != nil {
+				return err
+			}
+			if text == "" {
+				return fmt.Errorf("empty comment; nothing sent")
+			}
+			if isFinished(t.Status) {
+				fmt.Fprintf(os.Stderr, "Warning: %s is %s; the agent won't read this unless the task is retried\n", t.ID, t.Status)
+			}
+			var out taskComment
+			if err := c.DoJSON(ctx, http.MethodPost, "/api/v1/tasks/"+t.ID+"/comments", map[string]string{"body": text}, &out); err != nil {
+				return err
+			}
+			audit("task.comment", map[string]any{"task": t.ID, "comment": out.ID, "length": len(text)})
+			return printOutput(out, func() { fmt.Println("Comment added to", c.taskLink(t.ID)) })
+		},
+	}
+	return cmd
+}
+
+func cmdComments(c *Client) *cobra.Command {
+	var watch bool
+	cmd := &cobra.Command{
+		Use:   "comments [id]",
+		Short: "Read a task's comment thread, including the agent's questions",
+		Long: `comments prints a task's thread oldest first; the agent's questions are marked
+with ?. --watch keeps printing new comments until the task finishes or you
+press Ctrl-C, one JSON object per line with -o json or ndjson.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id := args[0]
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+			comments, err := c.taskComments(ctx, id, time.Time{})
+			if err != nil {
+				return err
+			}
+			if !watch && outputFormat != "ndjson" {
+				if comments == nil {
+					comments = []taskComment{}
+				}
+				return printOutput(comments, func() {
+					if len(comments) == 0 {
+						fmt.Println("No comments")
+					}
+					for _, tc := range comments {
+						fmt.Println(formatComment(tc))
+					}
+				})
+			}
+
+			seen := map[string]bool{}
+			var last time.Time
+			show := func(comments []taskComment) error {
+				for _, tc := range comments {
+					if seen[tc.key()] {
+						continue
+					}
+					seen[tc.key()] = true
+					if tc.CreatedAt.After(last) {
+						last = tc.CreatedAt
+					}
+					if !machineOutput() {
+						fmt.Println(formatComment(tc))
+						continue
+					}
+					if err := writeOutput(os.Stdout, "ndjson", tc); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+			if err := show(comments); err != nil || !watch {
+				return err
+			}
+			tick := time.NewTicker(logPollInterval)
+			defer tick.Stop()
+			for {
+				var t Task
+				if err := c.DoJSON(ctx, http.MethodGet, "/api/v1/tasks/"+id, nil, &t); err != nil {
+					if ctx.Err() != nil {
+						return nil // Ctrl-C
+					}
+					return err
+				}
+				// A finished task still gets one more fetch for its last comments.
+				done := isFinished(t.Status)
+				if !done {
+					select {
+					case <-ctx.Done():
+						return nil
+					case <-tick.C:
+					}
+				}
+				comments, err := c.taskComments(ctx, id, last)
+				if err != nil {
+					if ctx.Err() != nil {
+						return nil
+					}
+					return err
+				}
+				if err := show(comments); err != nil {
+					return err
+				}
+				if done {
+					if !machineOutput() {
+						fmt.Fprintln(os.Stderr, colorize(colorGray, fmt.Sprintf("task %s %s", id, t.Status)))
+					}
+					return nil
+				}
+			}
+		},
+	}
+	cmd.Flags().BoolVarP(&watch, "watch", "w", false, "keep printing new comments until the task finishes")
+	return cmd
+}
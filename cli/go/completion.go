@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+// completionCacheTTL keeps repeated TABs from each hitting the API while
+// still offering tasks created a moment ago.
+const completionCacheTTL = 30 * time.Second
+
+// taskIDCommands take a task ID as their first argument, or task IDs as all
+// of them when their usage says [id...]. The value is true where only
+// unfinished tasks make sense.
+var taskIDCommands = map[string]bool{
+	"get": false, "cancel": true, "retry": false, "delete": false, "watch": false, "logs": false, "diff": false, "apply": false,
+	"edit": false, "exec": false, "branch": false, "rollback": false, "timeline": false,
+	"verify": false, "annotate-diff": false, "port-forward": false, "pr describe": false,
+	"artifacts list": false, "artifacts download": false, "artifacts show": false, "copy": false, "comment": true, "comments": false, "audit export": false,
+}
+
+func takesManyIDs(cmd *cobra.Command) bool {
+	return strings.Contains(cmd.Use, "[id...]")
+}
+
+type completionTask struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Title  string `json:"title"`
+}
+
+// completionData is what completion offers for one endpoint, cached in
+// cache/completion.json.
+type completionData struct {
+	FetchedAt time.Time        `json:"fetched_at"`
+	Tasks     []completionTask `json:"tasks"`
+	Repos     []string         `json:"repos"`
+}
+
+// completionData returns recent tasks and known repositories, from the cache
+// when it is fresh. Completion must stay quick and quiet, so the fetch is
+// bounded and a failure falls back to whatever was cached before.
+func (c *Client) completionData(ctx context.Context) completionData {
+	var all map[string]completionData
+	_ = readState("cache/completion.json", &all)
+	cached, ok := all[c.BaseURL]
+	if ok && time.Since(cached.FetchedAt) < completionCacheTTL {
+		return cached
+	}
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	d := completionData{FetchedAt: time.Now()}
+	repos := map[string]bool{}
+	tasks, errc := c.ListTasks(sdk.ListTasksOptions{Limit: 100}).Stream(ctx)
+	for t := range tasks {
+		d.Tasks = append(d.Tasks, completionTask{ID: t.ID, Status: t.Status, Title: firstLine(redact(t.Title))})
+		repos[t.Repository] = true
+	}
+	if err := <-errc; err != nil {
+		return cached
+	}
+	var known []RepoMeta
+	if err := c.DoJSON(ctx, http.MethodGet, "/api/v1/repositories", nil, &known); err == nil {
+		for _, r := range known {
+			repos[r.FullName] = true
+		}
+	}
+	repoCache.mu.Lock()
+	for name := range loadRepoCache() {
+		repos[name] = true
+	}
+	repoCache.mu.Unlock()
+	repos[c.cfg.DefaultRepo] = true
+	delete(repos, "")
+	for r := range repos {
+		d.Repos = append(d.Repos, r)
+	}
+	sort.Strings(d.Repos)
+	_ = updateState("cache/completion.json", &all, func() {
+		if all == nil {
+			all = map[string]completionData{}
+		}
+		all[c.BaseURL] = d
+	})
+	return d
+}
+
+func (c *Client) completeTaskIDs(unfinishedOnly bool) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 && !takesManyIDs(cmd) {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		var out []string
+		for _, t := range c.completionData(cmd.Context()).Tasks {
+			if !strings.HasPrefix(t.ID, toComplete) || (unfinishedOnly && isFinished(t.Status)) || contains(args, t.ID) {
+				continue
+			}
+			out = append(out, t.ID+"\t"+t.Status+"  "+t.Title)
+		}
+		return out, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveKeepOrder
+	}
+}
+
+func (c *Client) completeRepos(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	var out []string
+	for _, r := range c.completionData(cmd.Context()).Repos {
+		if strings.HasPrefix(r, toComplete) {
+			out = append(out, r)
+		}
+	}
+	return out, cobra.ShellCompDirectiveNoFileComp
+}
+
+func (c *Client) completeRepoGroups(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	var out []string
+	for name := range c.cfg.RepoGroups {
+		if repos, err := c.repoGroup(name); err == nil && strings.HasPrefix(name, toComplete) {
+			out = append(out, name+"\t"+strings.Join(repos, ", "))
+		}
+	}
+	sort.Strings(out)
+	return out, cobra.ShellCompDirectiveNoFileComp
+}
+
+// registerCompletions wires live task ID completion into taskIDCommands,
+// repository completion into every --repo flag, and group names into
+// --repo-group.
+func (c *Client) registerCompletions(root *cobra.Command) {
+	var walk func(cmd *cobra.Command)
+	walk = func(cmd *cobra.Command) {
+		path := strings.TrimPrefix(cmd.CommandPath(), root.Name()+" ")
+		if unfinishedOnly, ok := taskIDCommands[path]; ok && cmd.ValidArgsFunction == nil {
+			cmd.ValidArgsFunction = c.completeTaskIDs(unfinishedOnly)
+		}
+		if cmd.LocalFlags().Lookup("repo") != nil {
+			_ = cmd.RegisterFlagCompletionFunc("repo", c.completeRepos)
+		}
+		if cmd.LocalFlags().Lookup("repo-group") != nil {
+			_ = cmd.RegisterFlagCompletionFunc("repo-group", c.completeRepoGroups)
+		}
+		for _, sub := range cmd.Commands() {
+			walk(sub)
+		}
+	}
+	walk(root)
+}
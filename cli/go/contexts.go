@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func cmdConfig(c *Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Switch between the deployments in the config's profiles",
+		Long: `Each entry under profiles in ~/.autocodit/autocodit.yaml is a context:
+
+  profiles:
+    staging:
+      endpoint: https://autocodit.staging.example.com
+      default_repo: org/web
+    prod:
+      endpoint: https://autocodit.example.com
+
+use-context makes one the default for later commands; --context picks one for a
+single command.`,
+	}
+
+	getContexts := &cobra.Command{
+		Use:   "get-contexts",
+		Short: "List the contexts in the config",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			type contextInfo struct {
+				Name        string `json:"name"`
+				Current     bool   `json:"current"`
+				Endpoint    string `json:"endpoint"`
+				DefaultRepo string `json:"default_repo,omitempty"`
+			}
+			var out []contextInfo
+			for _, name := range c.cfg.contextNames() {
+				pcfg := *c.cfg
+				pcfg.applyProfile(c.cfg.Profiles[name])
+				out = append(out, contextInfo{name, name == strings.ToLower(c.cfg.CurrentContext), pcfg.APIEndpoint, pcfg.DefaultRepo})
+			}
+			return printOutput(out, func() {
+				if len(out) == 0 {
+					fmt.Println("No contexts; add them under profiles in the config.")
+					return
+				}
+				tbl := newTable(os.Stdout, c.tableMaxWidth(), column{header: "CURRENT"}, column{header: "NAME"},
+					column{header: "ENDPOINT", flex: true}, column{header: "DEFAULT REPO"})
+				for _, ci := range out {
+					mark := ""
+					if ci.Current {
+						mark = "*"
+					}
+					tbl.add(mark, ci.Name, ci.Endpoint, ci.DefaultRepo)
+				}
+				tbl.render()
+			})
+		},
+	}
+
+	currentContext := &cobra.Command{
+		Use:   "current-context",
+		Short: "Print the context in use",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if c.cfg.CurrentContext == "" {
+				return fmt.Errorf("no current context; using the top-level settings (%s)", c.cfg.APIEndpoint)
+			}
+			fmt.Println(c.cfg.CurrentContext)
+			return nil
+		},
+	}
+
+	var clear bool
+	useContext := &cobra.Command{
+		Use:   "use-context [name]",
+		Short: "Make a context the default for later commands",
+		Args:  cobra.RangeArgs(0, 1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if clear {
+				if err := setConfigKey("", "current_context", nil); err != nil {
+					return err
+				}
+				fmt.Println("Using the top-level settings")
+				return nil
+			}
+			if len(args) != 1 {
+				return fmt.Errorf("give a context name, or --clear to go back to the top-level settings")
+			}
+			name := strings.ToLower(args[0])
+			if _, ok := c.cfg.Profiles[name]; !ok {
+				return fmt.Errorf("no context %q in the config (have: %s)", args[0], strings.Join(c.cfg.contextNames(), ", "))
+			}
+			if err := setConfigKey("", "current_context", &name); err != nil {
+				return err
+			}
+			fmt.Printf("Switched to context %s\n", name)
+			return nil
+		},
+	}
+	useContext.Flags().BoolVar(&clear, "clear", false, "stop using a context")
+
+	cmd.AddCommand(getContexts, currentContext, useContext)
+	return cmd
+}
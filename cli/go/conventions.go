@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	defaultBranchPattern  = "autocodit/{type}/{slug}"
+	defaultCommitTemplate = "{cc_type}: {title}"
+)
+
+// RepoSettings holds per-repository overrides under repos.<owner/repo> in
+// the config file.
+type RepoSettings struct {
+	BranchPattern  string `mapstructure:"branch_pattern"`
+	CommitTemplate string `mapstructure:"commit_template"`
+	// PRTemplate is a markdown text/template file for pr describe.
+	PRTemplate string `mapstructure:"pr_template"`
+	// Critical repositories need confirmation (or --yes) to create tasks on.
+	Critical bool `mapstructure:"critical"`
+	// VerifyCommands replace the top-level verify_commands for this repo.
+	VerifyCommands []string `mapstructure:"verify_commands"`
+	VerifyImage    string   `mapstructure:"verify_image"`
+}
+
+// repoSettings returns the settings for repo with unset fields filled from
+// the top-level config and built-in defaults. Viper lowercases map keys, so
+// lookups are case-insensitive.
+func (cfg *Config) repoSettings(repo string) RepoSettings {
+	s := cfg.Repos[strings.ToLower(repo)]
+	if s.BranchPattern == "" {
+		s.BranchPattern = cfg.BranchPattern
+	}
+	if s.BranchPattern == "" {
+		s.BranchPattern = defaultBranchPattern
+	}
+	if s.CommitTemplate == "" {
+		s.CommitTemplate = cfg.CommitTemplate
+	}
+	if s.CommitTemplate == "" {
+		s.CommitTemplate = defaultCommitTemplate
+	}
+	return s
+}
+
+var conventionalTypes = map[string]string{
+	"fix":      "fix",
+	"refactor": "refactor",
+	"document": "docs",
+	"test":     "test",
+	"optimize": "perf",
+}
+
+var slugStrip = regexp.MustCompile(`[^a-z0-9]+`)
+
+func slugify(s string) string {
+	s = strings.Trim(slugStrip.ReplaceAllString(strings.ToLower(s), "-"), "-")
+	if len(s) > 40 {
+		s = strings.TrimRight(s[:40], "-")
+	}
+	if s == "" {
+		s = "task"
+	}
+	return s
+}
+
+func conventionVars(actionType, title, id, repo string) map[string]string {
+	cc := conventionalTypes[actionType]
+	if cc == "" {
+		cc = "chore"
+	}
+	short := id
+	if len(short) > 8 {
+		short = short[:8]
+	}
+	return map[string]string{
+		"type":     actionType,
+		"cc_type":  cc,
+		"slug":     slugify(title),
+		"title":    title,
+		"id":       id,
+		"short_id": short,
+		"repo":     repo,
+	}
+}
+
+func renderConvention(pattern string, vars map[string]string) string {
+	pairs := make([]string, 0, len(vars)*2)
+	for k, v := range vars {
+		pairs = append(pairs, "{"+k+"}", v)
+	}
+	return strings.NewReplacer(pairs...).Replace(pattern)
+}
+
+var conventionPlaceholders = map[string]string{
+	"type":     `[a-z]+`,
+	"cc_type":  `[a-z]+`,
+	"slug":     `[a-z0-9-]+`,
+	"id":       `[A-Za-z0-9-]+`,
+	"short_id": `[A-Za-z0-9-]+`,
+	"repo":     `[^\s]+`,
+	"title":    `.+`,
+}
+
+// checkConvention reports whether s could have been produced by pattern.
+func checkConvention(pattern, s string) error {
+	re := regexp.QuoteMeta(pattern)
+	for k, v := range conventionPlaceholders {
+		re = strings.ReplaceAll(re, regexp.QuoteMeta("{"+k+"}"), v)
+	}
+	if !regexp.MustCompile("^" + re + "$").MatchString(s) {
+		return fmt.Errorf("%q does not follow the convention %q", s, pattern)
+	}
+	return nil
+}
@@ -0,0 +1,618 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+const (
+	daemonDiscoverInterval = time.Minute
+	// Finished tasks are kept this long so a watch started after the end
+	// still gets the final state from the daemon.
+	daemonKeepFinished = 24 * time.Hour
+)
+
+// daemonTask is a task the daemon follows and what it last saw of it.
+type daemonTask struct {
+	Task      Task      `json:"task"`
+	Since     time.Time `json:"since"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Watchers  int       `json:"watchers"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// daemonState is the daemon's status as served on its socket, and saved in
+// daemon.json so a restarted daemon picks up where the last one stopped.
+type daemonState struct {
+	PID       int                    `json:"pid"`
+	Endpoint  string                 `json:"endpoint"`
+	Version   string                 `json:"version"`
+	StartedAt time.Time              `json:"started_at"`
+	Tasks     map[string]*daemonTask `json:"tasks"`
+}
+
+func daemonSocketPath() (string, error) {
+	return statePath("daemon.sock")
+}
+
+// daemonHTTP talks HTTP to the daemon over its Unix socket. There is no
+// overall timeout, since task streams stay open until the task finishes.
+func daemonHTTP() *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			p, err := daemonSocketPath()
+			if err != nil {
+				return nil, err
+			}
+			return (&net.Dialer{Timeout: time.Second}).DialContext(ctx, "unix", p)
+		},
+	}}
+}
+
+// queryDaemon asks the running daemon for path and decodes the answer into
+// v. It fails quickly when no daemon is listening.
+func queryDaemon(ctx context.Context, method, path string, v any) error {
+	ctx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, "http://daemon"+path, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := daemonHTTP().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("daemon: %s", resp.Status)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+type taskDaemon struct {
+	c      *Client
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu        sync.Mutex
+	state     daemonState
+	following map[string]bool
+	subs      map[string]map[chan Task]bool
+	saved     time.Time
+}
+
+func logDaemon(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "%s %s\n", time.Now().Format(time.RFC3339), fmt.Sprintf(format, args...))
+}
+
+// follow tracks id until it finishes, through c.waitTask's reconnects and
+// re-syncs. A task that can't be fetched at all is retried; one the server
+// no longer has is dropped.
+func (d *taskDaemon) follow(id string) {
+	defer func() {
+		d.mu.Lock()
+		delete(d.following, id)
+		d.mu.Unlock()
+	}()
+	for d.ctx.Err() == nil {
+		t, err := d.c.waitTask(d.ctx, id, d.update)
+		if err == nil {
+			d.update(t)
+			logDaemon("%s %s", id, t.Status)
+			return
+		}
+		if d.ctx.Err() != nil {
+			return
+		}
+		var apiErr *sdk.APIError
+		if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusNotFound || apiErr.StatusCode == http.StatusForbidden) {
+			logDaemon("%s: %v; no longer following it", id, err)
+			d.mu.Lock()
+			delete(d.state.Tasks, id)
+			// Closing tells the streams watching it to end with an error.
+			for ch := range d.subs[id] {
+				close(ch)
+			}
+			delete(d.subs, id)
+			d.mu.Unlock()
+			return
+		}
+		logDaemon("%s: %v; retrying", id, err)
+		d.mu.Lock()
+		if dt := d.state.Tasks[id]; dt != nil {
+			dt.Error = err.Error()
+		}
+		d.mu.Unlock()
+		select {
+		case <-d.ctx.Done():
+		case <-time.After(30 * time.Second):
+		}
+	}
+}
+
+// track starts following id unless it already is.
+func (d *taskDaemon) track(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.following[id] {
+		return
+	}
+	if dt := d.state.Tasks[id]; dt != nil && isFinished(dt.Task.Status) {
+		return
+	}
+	d.following[id] = true
+	if d.state.Tasks[id] == nil {
+		d.state.Tasks[id] = &daemonTask{Task: Task{ID: id}, Since: time.Now()}
+		logDaemon("following %s", id)
+	}
+	go d.follow(id)
+}
+
+// update records t and hands it to everyone watching it. Only the latest
+// state waits for a slow watcher.
+func (d *taskDaemon) update(t Task) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dt := d.state.Tasks[t.ID]
+	if dt == nil {
+		dt = &daemonTask{Since: time.Now()}
+		d.state.Tasks[t.ID] = dt
+	}
+	statusChanged := dt.Task.Status != t.Status
+	dt.Task, dt.UpdatedAt, dt.Error = t, time.Now(), ""
+	for ch := range d.subs[t.ID] {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- t
+	}
+	if statusChanged || time.Since(d.saved) > 10*time.Second {
+		d.save()
+	}
+}
+
+// save writes the state; d.mu must be held.
+func (d *taskDaemon) save() {
+	for id, dt := range d.state.Tasks {
+		if isFinished(dt.Task.Status) && time.Since(dt.UpdatedAt) > daemonKeepFinished && len(d.subs[id]) == 0 {
+			delete(d.state.Tasks, id)
+		}
+	}
+	if err := writeState("daemon.json", d.state); err != nil {
+		logDaemon("saving state: %v", err)
+	}
+	d.saved = time.Now()
+}
+
+// discover follows every active task of the user's the server lists.
+func (d *taskDaemon) discover() {
+	for _, status := range []string{"running", "queued", "pending"} {
+		tasks, errc := d.c.ListTasks(sdk.ListTasksOptions{Status: status, PerPage: 100}).Stream(d.ctx)
+		for t := range tasks {
+			d.track(t.ID)
+		}
+		if err := <-errc; err != nil {
+			if d.ctx.Err() == nil {
+				logDaemon("listing %s tasks: %v", status, err)
+			}
+			return
+		}
+	}
+}
+
+func (d *taskDaemon) snapshot() daemonState {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s := d.state
+	s.Tasks = make(map[string]*daemonTask, len(d.state.Tasks))
+	for id, dt := range d.state.Tasks {
+		cp := *dt
+		cp.Watchers = len(d.subs[id])
+		s.Tasks[id] = &cp
+	}
+	return s
+}
+
+func (d *taskDaemon) subscribe(id string) (chan Task, *daemonTask, func()) {
+	ch := make(chan Task, 1)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.subs[id] == nil {
+		d.subs[id] = map[chan Task]bool{}
+	}
+	d.subs[id][ch] = true
+	var cur *daemonTask
+	if dt := d.state.Tasks[id]; dt != nil && !dt.UpdatedAt.IsZero() {
+		cp := *dt
+		cur = &cp
+	}
+	return ch, cur, func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		delete(d.subs[id], ch)
+	}
+}
+
+// The socket API: GET /v1/status, GET /v1/tasks/ID, GET
+// /v1/tasks/ID/stream (newline-delimited tasks until it finishes, following
+// it first if need be; a line with only "error" ends it early), and POST
+// /v1/stop.
+func (d *taskDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	path := strings.TrimPrefix(r.URL.Path, "/v1/")
+	switch {
+	case path == "status" && r.Method == http.MethodGet:
+		_ = json.NewEncoder(w).Encode(d.snapshot())
+	case path == "stop" && r.Method == http.MethodPost:
+		w.WriteHeader(http.StatusAccepted)
+		logDaemon("stop requested")
+		d.cancel()
+	case strings.HasPrefix(path, "tasks/") && r.Method == http.MethodGet:
+		id, rest, _ := strings.Cut(strings.TrimPrefix(path, "tasks/"), "/")
+		switch rest {
+		case "":
+			dt := d.snapshot().Tasks[id]
+			if dt == nil {
+				http.Error(w, `{"detail":"not followed"}`, http.StatusNotFound)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(dt)
+		case "stream":
+			d.stream(w, r, id)
+		default:
+			http.NotFound(w, r)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (d *taskDaemon) stream(w http.ResponseWriter, r *http.Request, id string) {
+	ch, cur, unsubscribe := d.subscribe(id)
+	defer unsubscribe()
+	d.track(id)
+	flusher, _ := w.(http.Flusher)
+	enc := json.NewEncoder(w)
+	send := func(t Task) bool {
+		if enc.Encode(t) != nil {
+			return false
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return !isFinished(t.Status)
+	}
+	if cur != nil && !send(cur.Task) {
+		return
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-d.ctx.Done():
+			return
+		case t, ok := <-ch:
+			if !ok {
+				_ = enc.Encode(map[string]string{"error": "the daemon can no longer fetch the task"})
+				return
+			}
+			if !send(t) {
+				return
+			}
+		}
+	}
+}
+
+// runDaemon serves the socket and follows tasks until ctx is done or a
+// stop is requested.
+func (c *Client) runDaemon(ctx context.Context) error {
+	sock, err := daemonSocketPath()
+	if err != nil {
+		return err
+	}
+	var running daemonState
+	if queryDaemon(ctx, http.MethodGet, "/v1/status", &running) == nil {
+		return fmt.Errorf("a daemon is already running (pid %d)", running.PID)
+	}
+	// Nothing answered, so a socket file left there is stale.
+	_ = os.Remove(sock)
+	ln, err := net.Listen("unix", sock)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(sock)
+	if err := os.Chmod(sock, 0o600); err != nil {
+		ln.Close()
+		return err
+	}
+
+	d := &taskDaemon{c: c, following: map[string]bool{}, subs: map[string]map[chan Task]bool{}}
+	d.ctx, d.cancel = context.WithCancel(ctx)
+	defer d.cancel()
+	var prev daemonState
+	_ = readState("daemon.json", &prev)
+	d.state = daemonState{PID: os.Getpid(), Endpoint: c.BaseURL, Version: version, StartedAt: time.Now(), Tasks: map[string]*daemonTask{}}
+	if prev.Endpoint == c.BaseURL {
+		for id, dt := range prev.Tasks {
+			d.state.Tasks[id] = dt
+		}
+	}
+	if c.cfg.RefreshToken != "" && c.pool == nil && c.Token == c.cfg.AuthToken {
+		// A daemon outlives the login token; refresh it as requests need it.
+		var authMu sync.Mutex
+		c.Hooks.Auth = func(ctx context.Context) (string, error) {
+			authMu.Lock()
+			defer authMu.Unlock()
+			c.refreshLogin(ctx)
+			return c.Token, nil
+		}
+	}
+
+	srv := &http.Server{Handler: d, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-d.ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	go func() {
+		for _, id := range sortedKeys(d.state.Tasks) {
+			d.track(id)
+		}
+		d.discover()
+		wake := newWakeDetector().watch(d.ctx)
+		tick := time.NewTicker(daemonDiscoverInterval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-d.ctx.Done():
+				return
+			case reason := <-wake:
+				logDaemon("%s; re-syncing", reason)
+			case <-tick.C:
+			}
+			d.discover()
+		}
+	}()
+	logDaemon("daemon %s listening on %s for %s", version, sock, c.BaseURL)
+	err = srv.Serve(ln)
+	d.mu.Lock()
+	d.save()
+	d.mu.Unlock()
+	logDaemon("daemon stopped")
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// watchViaDaemon follows id through the running daemon, so the task keeps
+// being watched when this process goes away. It reports false when there is
+// no daemon for this endpoint, or it went away before the task finished,
+// for the caller to watch directly instead.
+func (c *Client) watchViaDaemon(ctx context.Context, id string, onUpdate func(Task)) (Task, bool, error) {
+	var st daemonState
+	var last Task
+	if err := queryDaemon(ctx, http.MethodGet, "/v1/status", &st); err != nil || st.Endpoint != c.BaseURL {
+		return last, false, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://daemon/v1/tasks/"+id+"/stream", nil)
+	if err != nil {
+		return last, false, err
+	}
+	resp, err := daemonHTTP().Do(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		if err == nil {
+			resp.Body.Close()
+		}
+		return last, false, nil
+	}
+	defer resp.Body.Close()
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for sc.Scan() {
+		var line struct {
+			Task
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(sc.Bytes(), &line) != nil {
+			continue
+		}
+		if line.Error != "" {
+			// Watching directly reports why, such as the task being gone.
+			return last, false, nil
+		}
+		t := line.Task
+		last = t
+		onUpdate(t)
+		if isFinished(t.Status) {
+			return t, true, nil
+		}
+	}
+	if ctx.Err() != nil {
+		return last, true, ctx.Err()
+	}
+	fmt.Fprintf(os.Stderr, "\n%s\n", colorize(colorYellow, "the daemon went away; watching directly"))
+	return last, false, nil
+}
+
+func cmdDaemon(c *Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Follow all your active tasks in the background and serve their state locally",
+		Long: `daemon runs in the foreground until interrupted, following every active task of
+yours: new ones are picked up every minute and after the machine wakes. It
+reconnects across network drops and sleep, saves what it has seen so a
+restart carries on, and serves task state on a Unix socket in ~/.autocodit.
+
+While it runs, watch follows tasks through it, so closing the terminal or
+suspending the laptop loses nothing: the daemon keeps following the task,
+and a new watch picks up its current state at once. Run it under launchd or
+systemd, or detached with daemon start.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return c.runDaemon(ctx)
+		},
+	}
+	cmd.AddCommand(cmdDaemonStart(), cmdDaemonStop(), cmdDaemonStatus(c))
+	return cmd
+}
+
+func cmdDaemonStart() *cobra.Command {
+	return &cobra.Command{
+		Use:   "start",
+		Short: "Start the daemon detached from the terminal, logging to ~/.autocodit/daemon.log",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			var st daemonState
+			if queryDaemon(ctx, http.MethodGet, "/v1/status", &st) == nil {
+				fmt.Printf("Daemon already running (pid %d)\n", st.PID)
+				return nil
+			}
+			exe, err := os.Executable()
+			if err != nil {
+				return err
+			}
+			logPath, err := statePath("daemon.log")
+			if err != nil {
+				return err
+			}
+			logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+			if err != nil {
+				return err
+			}
+			defer logFile.Close()
+			daemonArgs := []string{"daemon"}
+			cmd.InheritedFlags().VisitAll(func(f *pflag.Flag) {
+				// A temporary state directory would hide the daemon's
+				// socket from everyone else, and it prints no output.
+				if !f.Changed || f.Name == "ephemeral-state" || f.Name == "output" {
+					return
+				}
+				if sv, ok := f.Value.(pflag.SliceValue); ok {
+					for _, v := range sv.GetSlice() {
+						daemonArgs = append(daemonArgs, "--"+f.Name+"="+v)
+					}
+					return
+				}
+				daemonArgs = append(daemonArgs, "--"+f.Name+"="+f.Value.String())
+			})
+			proc := exec.Command(exe, daemonArgs...)
+			proc.Stdout, proc.Stderr = logFile, logFile
+			proc.SysProcAttr = detachedProcAttr()
+			if err := proc.Start(); err != nil {
+				return err
+			}
+			pid := proc.Process.Pid
+			_ = proc.Process.Release()
+			for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(100 * time.Millisecond) {
+				if queryDaemon(ctx, http.MethodGet, "/v1/status", &st) == nil {
+					fmt.Printf("Daemon started (pid %d); log: %s\n", pid, logPath)
+					return nil
+				}
+			}
+			return fmt.Errorf("the daemon didn't come up; see %s", logPath)
+		},
+	}
+}
+
+func cmdDaemonStop() *cobra.Command {
+	return &cobra.Command{
+		Use:   "stop",
+		Short: "Stop the running daemon",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			var st daemonState
+			if queryDaemon(ctx, http.MethodGet, "/v1/status", &st) != nil {
+				fmt.Println("Daemon is not running")
+				return nil
+			}
+			if err := queryDaemon(ctx, http.MethodPost, "/v1/stop", nil); err != nil {
+				return err
+			}
+			for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(100 * time.Millisecond) {
+				if queryDaemon(ctx, http.MethodGet, "/v1/status", nil) != nil {
+					fmt.Printf("Daemon stopped (pid %d)\n", st.PID)
+					return nil
+				}
+			}
+			return fmt.Errorf("daemon (pid %d) is still running", st.PID)
+		},
+	}
+}
+
+func cmdDaemonStatus(c *Client) *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show whether the daemon runs and the tasks it follows; exits 1 when it doesn't",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var st daemonState
+			if err := queryDaemon(cmd.Context(), http.MethodGet, "/v1/status", &st); err != nil {
+				if !machineOutput() {
+					fmt.Println("Daemon is not running; start it with: autocodit daemon start")
+				}
+				cmd.SilenceErrors, cmd.SilenceUsage = true, true
+				return exitCodeError{code: 1}
+			}
+			return printOutput(st, func() {
+				fmt.Printf("Daemon %s running (pid %d) since %s for %s\n", st.Version, st.PID, formatTime(&st.StartedAt), st.Endpoint)
+				if st.Endpoint != c.BaseURL {
+					fmt.Println(colorize(colorYellow, "It follows another endpoint than this context's, so watch won't use it."))
+				}
+				if len(st.Tasks) == 0 {
+					fmt.Println("No active tasks")
+					return
+				}
+				tbl := newTable(os.Stdout, c.tableMaxWidth(), column{header: "ID"}, column{header: "STATUS"},
+					column{header: "PROGRESS", right: true}, column{header: "TITLE", flex: true}, column{header: "WATCHERS", right: true},
+					column{header: "UPDATED"})
+				for _, id := range sortedKeys(st.Tasks) {
+					dt := st.Tasks[id]
+					status := dt.Task.Status
+					if dt.Error != "" {
+						status += colorize(colorYellow, " (retrying)")
+					}
+					tbl.add(id, status, fmt.Sprintf("%.0f%%", dt.Task.Progress*100), redact(dt.Task.Title),
+						fmt.Sprint(dt.Watchers), formatTime(&dt.UpdatedAt))
+				}
+				tbl.render()
+			})
+		},
+	}
+}
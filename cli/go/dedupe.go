@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/term"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+const maxDedupeScan = 200
+
+type similarTask struct {
+	Task
+	Score float64 `json:"score"`
+}
+
+func words(s string) map[string]bool {
+	set := map[string]bool{}
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if len(w) > 2 {
+			set[w] = true
+		}
+	}
+	return set
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	n := 0
+	for w := range a {
+		if b[w] {
+			n++
+		}
+	}
+	return float64(n) / float64(len(a)+len(b)-n)
+}
+
+// dedupeText is what similarity is computed over. Titles generated by create
+// are just "<type> task", so the description carries the signal when present.
+func dedupeText(title, description string) string {
+	if description != "" {
+		return description
+	}
+	return title
+}
+
+// similarTasks asks the server for similar open tasks and falls back to a
+// fuzzy match over the most recent tasks on the repository.
+func (c *Client) similarTasks(ctx context.Context, req CreateTaskRequest) ([]similarTask, error) {
+	var resp struct {
+		Items []similarTask `json:"items"`
+	}
+	body := map[string]string{"repository": req.Repository, "title": req.Title, "description": req.Description}
+	err := c.DoJSON(ctx, http.MethodPost, "/api/v1/tasks/similar", body, &resp)
+	var apiErr *sdk.APIError
+	if err == nil || !errors.As(err, &apiErr) || (apiErr.StatusCode != http.StatusNotFound && apiErr.StatusCode != http.StatusMethodNotAllowed) {
+		return resp.Items, err
+	}
+
+	want := words(dedupeText(req.Title, req.Description))
+	var found []similarTask
+	p := c.ListTasks(sdk.ListTasksOptions{Repository: req.Repository, PerPage: 100})
+	for scanned := 0; p.More() && scanned < maxDedupeScan; {
+		list, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range list.Items {
+			if isFinished(t.Status) {
+				continue
+			}
+			if s := jaccard(want, words(dedupeText(t.Title, t.Description))); s >= c.cfg.DedupeThreshold {
+				found = append(found, similarTask{Task: t, Score: s})
+			}
+		}
+		scanned += len(list.Items)
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Score > found[j].Score })
+	return found, nil
+}
+
+// dedupe warns about similar open tasks before create. It returns true when the
+// user chose to attach to or watch an existing task instead of creating one.
+func (c *Client) dedupe(ctx context.Context, req CreateTaskRequest) (bool, error) {
+	similar, err := c.similarTasks(ctx, req)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Warning: duplicate check failed:", err)
+		return false, nil
+	}
+	if len(similar) == 0 {
+		return false, nil
+	}
+	if len(similar) > 3 {
+		similar = similar[:3]
+	}
+	for _, s := range similar {
+		fmt.Fprintf(os.Stderr, "A similar task %s is already %s (%.0f%% match): %s\n",
+			s.ID, s.Status, s.Score*100, redact(firstLine(dedupeText(s.Title, s.Description))))
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false, nil
+	}
+
+	best := similar[0]
+	fmt.Fprintf(os.Stderr, "[c]ontinue creating, [a]ttach to %s, [w]atch it instead, or [q]uit? ", best.ID)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "c", "continue":
+		return false, nil
+	case "a", "attach":
+		desc := best.Description
+		if desc != "" {
+			desc += "\n\n"
+		}
+		patch := map[string]string{"description": desc + req.Description}
+		if err := c.DoJSON(ctx, http.MethodPatch, "/api/v1/tasks/"+best.ID, patch, nil); err != nil {
+			return true, err
+		}
+		fmt.Println("Attached to task:", best.ID)
+		return true, nil
+	case "w", "watch":
+		_, err := c.waitTask(ctx, best.ID, printProgress)
+		fmt.Println()
+		return true, err
+	}
+	return true, fmt.Errorf("aborted")
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+// maxStdinDescription bounds how much of stdin create will buffer, well
+// above anything a server accepts, so a runaway pipe fails fast.
+const maxStdinDescription = 64 << 20
+
+// stdinPiped reports whether stdin is a pipe or file rather than a terminal.
+func stdinPiped() bool {
+	return !term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+func readStdinDescription() (string, error) {
+	b, err := io.ReadAll(io.LimitReader(os.Stdin, maxStdinDescription+1))
+	if err != nil {
+		return "", fmt.Errorf("reading description from stdin: %w", err)
+	}
+	if len(b) > maxStdinDescription {
+		return "", fmt.Errorf("description on stdin is over %s", formatBytes(maxStdinDescription))
+	}
+	if !utf8.Valid(b) {
+		return "", fmt.Errorf("description on stdin is not UTF-8 text")
+	}
+	s := strings.TrimRight(string(b), "\r\n")
+	if strings.TrimSpace(s) == "" {
+		return "", fmt.Errorf("description on stdin is empty")
+	}
+	return s, nil
+}
+
+// serverLimits are the limits from GET /api/v1/limits: request sizes, and
+// how many tasks the runners take at once.
+type serverLimits struct {
+	MaxDescriptionBytes int `json:"max_description_bytes"`
+	MaxRequestBytes     int `json:"max_request_bytes"`
+	MaxConcurrentTasks  int `json:"max_concurrent_tasks"`
+}
+
+// descriptionLimit returns the most bytes the server accepts in a task
+// description, or 0 if neither the server nor the config says.
+func (c *Client) descriptionLimit(ctx context.Context) int {
+	var l serverLimits
+	if err := c.DoJSON(ctx, http.MethodGet, "/api/v1/limits", nil, &l); err == nil && l.MaxDescriptionBytes > 0 {
+		return l.MaxDescriptionBytes
+	}
+	return c.cfg.MaxDescriptionLength
+}
+
+func formatBytes(n int) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
+
+// checkDescriptionSize refuses a description the server would reject,
+// before uploading it. Only long descriptions cost the extra request.
+func (c *Client) checkDescriptionSize(ctx context.Context, desc string) error {
+	if len(desc) < 4096 {
+		return nil
+	}
+	if limit := c.descriptionLimit(ctx); limit > 0 && len(desc) > limit {
+		return fmt.Errorf("description is %s, over the server's limit of %s; trim it or link to the spec instead",
+			formatBytes(len(desc)), formatBytes(limit))
+	}
+	return nil
+}
+
+// tooLargeError rewords a 413 from the server.
+func tooLargeError(err error, desc string) error {
+	var apiErr *sdk.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusRequestEntityTooLarge {
+		return fmt.Errorf("the server rejected the %s description as too large; trim it or link to the spec instead", formatBytes(len(desc)))
+	}
+	return err
+}
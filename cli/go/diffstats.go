@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+// Completed tasks never change their diff, so computed stats are cached
+// without expiry in cache/diffstats.json.
+var diffStatsCache struct {
+	once    sync.Once
+	dirty   bool
+	entries map[string]sdk.DiffStats
+}
+
+func patchStats(patch []byte) sdk.DiffStats {
+	var s sdk.DiffStats
+	for _, l := range bytes.Split(patch, []byte("\n")) {
+		switch {
+		case bytes.HasPrefix(l, []byte("diff --git ")):
+			s.FilesChanged++
+		case bytes.HasPrefix(l, []byte("+++ ")), bytes.HasPrefix(l, []byte("--- ")):
+		case bytes.HasPrefix(l, []byte("+")):
+			s.Additions++
+		case bytes.HasPrefix(l, []byte("-")):
+			s.Deletions++
+		}
+	}
+	return s
+}
+
+// diffStats returns the task's diff stats, computing them from its patch when
+// the server did not include them. Only completed tasks are looked up.
+func (c *Client) diffStats(ctx context.Context, t Task) *sdk.DiffStats {
+	if t.DiffStats != nil || t.Status != "completed" {
+		return t.DiffStats
+	}
+	diffStatsCache.once.Do(func() {
+		diffStatsCache.entries = map[string]sdk.DiffStats{}
+		_ = readState("cache/diffstats.json", &diffStatsCache.entries)
+	})
+	if s, ok := diffStatsCache.entries[t.ID]; ok {
+		return &s
+	}
+	patch, err := c.taskPatch(ctx, t.ID)
+	if err != nil {
+		return nil
+	}
+	s := patchStats(patch)
+	diffStatsCache.entries[t.ID] = s
+	diffStatsCache.dirty = true
+	return &s
+}
+
+func saveDiffStats() {
+	if !diffStatsCache.dirty {
+		return
+	}
+	onDisk := map[string]sdk.DiffStats{}
+	_ = updateState("cache/diffstats.json", &onDisk, func() {
+		for id, s := range diffStatsCache.entries {
+			onDisk[id] = s
+		}
+	})
+}
+
+// diffColumn renders a fixed-width "files +adds -dels" column, blank when
+// there are no stats, so rows stay aligned with color enabled.
+func diffColumn(s *sdk.DiffStats) string {
+	if s == nil {
+		return fmt.Sprintf("%-18s", "")
+	}
+	return fmt.Sprintf("%3df %s %s", s.FilesChanged,
+		colorize(colorGreen, fmt.Sprintf("%-6s", fmt.Sprintf("+%d", s.Additions))),
+		colorize(colorRed, fmt.Sprintf("%-6s", fmt.Sprintf("-%d", s.Deletions))))
+}
+
+// fileStat is one file's share of a patch.
+type fileStat struct {
+	Path      string `json:"path"`
+	Additions int    `json:"additions"`
+	Deletions int    `json:"deletions"`
+	Binary    bool   `json:"binary,omitempty"`
+}
+
+// patchFileStats splits patchStats by file, in patch order. Renamed files
+// are listed under their new path.
+func patchFileStats(patch []byte) []fileStat {
+	var out []fileStat
+	var cur *fileStat
+	for _, l := range strings.Split(string(patch), "\n") {
+		switch {
+		case strings.HasPrefix(l, "diff --git "):
+			path := strings.TrimPrefix(l, "diff --git ")
+			if i := strings.Index(path, " b/"); i >= 0 {
+				path = path[i+3:]
+			}
+			out = append(out, fileStat{Path: path})
+			cur = &out[len(out)-1]
+		case cur == nil:
+		case strings.HasPrefix(l, "rename to "):
+			cur.Path = strings.TrimPrefix(l, "rename to ")
+		case strings.HasPrefix(l, "Binary files "), l == "GIT binary patch":
+			cur.Binary = true
+		case strings.HasPrefix(l, "+++ "), strings.HasPrefix(l, "--- "):
+		case strings.HasPrefix(l, "+"):
+			cur.Additions++
+		case strings.HasPrefix(l, "-"):
+			cur.Deletions++
+		}
+	}
+	return out
+}
+
+// printFileStats writes a git diff --stat style summary: one line per file
+// with a +/- bar scaled to fit width, then the totals.
+func printFileStats(w io.Writer, files []fileStat, width int) {
+	nameWidth, most, adds, dels := 0, 0, 0, 0
+	for _, f := range files {
+		nameWidth = max(nameWidth, displayWidth(f.Path))
+		most = max(most, f.Additions+f.Deletions)
+		adds += f.Additions
+		dels += f.Deletions
+	}
+	countWidth := len(strconv.Itoa(most))
+	// Long paths give way before the bar does.
+	nameWidth = min(nameWidth, max(width/2, 20))
+	bar := max(width-nameWidth-countWidth-6, 10)
+	for _, f := range files {
+		name := padWidth(truncateWidth(f.Path, nameWidth), nameWidth, false)
+		if f.Binary {
+			fmt.Fprintf(w, " %s | %s\n", name, padWidth("Bin", countWidth, true))
+			continue
+		}
+		n := f.Additions + f.Deletions
+		plus, minus := f.Additions, f.Deletions
+		if most > bar {
+			plus, minus = scaleBar(f.Additions, most, bar), scaleBar(f.Deletions, most, bar)
+		}
+		fmt.Fprintf(w, " %s | %*d %s%s\n", name, countWidth, n,
+			colorize(colorGreen, strings.Repeat("+", plus)), colorize(colorRed, strings.Repeat("-", minus)))
+	}
+	fmt.Fprintf(w, " %d file(s) changed, %s, %s\n", len(files),
+		colorize(colorGreen, fmt.Sprintf("%d insertion(s)", adds)),
+		colorize(colorRed, fmt.Sprintf("%d deletion(s)", dels)))
+}
+
+// scaleBar shrinks n to the bar's width, keeping any change visible.
+func scaleBar(n, most, bar int) int {
+	if n == 0 {
+		return 0
+	}
+	return max(n*bar/most, 1)
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const draftHeader = "# Lines starting with '#' are ignored. Save and close the editor to submit.\n"
+
+// draft is an in-progress editor session. The text lives in drafts/<id>.md
+// and is edited in place so that whatever the editor last saved survives a
+// crash or Ctrl-C; metadata lives next to it in drafts/<id>.json.
+type draft struct {
+	ID        string          `json:"id"`
+	Kind      string          `json:"kind"`
+	Target    string          `json:"target,omitempty"`
+	Request   json.RawMessage `json:"request"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+func newDraft(kind, target string, req any) (*draft, error) {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	raw, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	d := &draft{ID: hex.EncodeToString(b), Kind: kind, Target: target, Request: raw, CreatedAt: time.Now()}
+	return d, writeState(filepath.Join("drafts", d.ID+".json"), d)
+}
+
+func loadDraft(id string) (*draft, error) {
+	d := &draft{}
+	if err := readState(filepath.Join("drafts", id+".json"), d); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no draft %q", id)
+		}
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *draft) textPath() (string, error) {
+	return statePath("drafts", d.ID+".md")
+}
+
+// edit writes initial to the draft file unless it already has content,
+// opens the user's editor on it, and returns the text with comment lines
+// removed.
+func (d *draft) edit(initial string) (string, error) {
+	p, err := d.textPath()
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(p); os.IsNotExist(err) {
+		if err := os.WriteFile(p, []byte(draftHeader+initial), 0o600); err != nil {
+			return "", err
+		}
+	}
+	if err := runEditor(p); err != nil {
+		return "", fmt.Errorf("%w (draft saved, resume with: autocodit drafts resume %s)", err, d.ID)
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return "", err
+	}
+	text := stripComments(string(b))
+	if text == "" {
+		if d.Kind == "edit" {
+			return "", fmt.Errorf("empty task, aborting (draft %s kept)", d.ID)
+		}
+		return "", fmt.Errorf("empty description, aborting (draft %s kept)", d.ID)
+	}
+	return text, nil
+}
+
+func (d *draft) remove() {
+	if p, err := d.textPath(); err == nil {
+		_ = os.Remove(p)
+	}
+	if p, err := statePath("drafts", d.ID+".json"); err == nil {
+		_ = os.Remove(p)
+	}
+}
+
+// preferredEditor is the editor from the config, used before $VISUAL and
+// $EDITOR.
+var preferredEditor string
+
+func runEditor(path string) error {
+	editor := preferredEditor
+	if editor == "" {
+		editor = os.Getenv("VISUAL")
+	}
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	cmd := exec.Command("sh", "-c", editor+` "$1"`, "--", path)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	// The editor owns the terminal; let it handle Ctrl-C itself.
+	signal.Ignore(os.Interrupt)
+	defer signal.Reset(os.Interrupt)
+	return cmd.Run()
+}
+
+func stripComments(s string) string {
+	var out []string
+	for _, line := range strings.Split(s, "\n") {
+		if !strings.HasPrefix(line, "#") {
+			out = append(out, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
+
+// submitDraft finishes a draft once its text is final, keeping it on
+// failure so the user can retry with drafts resume.
+func (c *Client) submitDraft(ctx context.Context, d *draft, text string, checkDup bool) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+	var err error
+	switch d.Kind {
+	case "create":
+		var req CreateTaskRequest
+		if err = json.Unmarshal(d.Request, &req); err != nil {
+			return err
+		}
+		req.Description = text
+		handled := false
+		err = c.checkDescriptionSize(ctx, req.Description)
+		if checkDup && err == nil {
+			handled, err = c.dedupe(ctx, req)
+		}
+		if !handled && err == nil {
+			var task Task
+			if err = tooLargeError(c.createTask(ctx, req, &task), req.Description); err == nil {
+				err = printOutput(task, func() { fmt.Println("Task created:", task.ID) })
+			}
+		}
+	case "retry":
+		var req CreateTaskRequest
+		if err = json.Unmarshal(d.Request, &req); err != nil {
+			return err
+		}
+		req.Description = text
+		var task Task
+		if task, err = c.createClone(ctx, req); err == nil {
+			audit("retry", map[string]any{"task": d.Target, "retry": task.ID, "clone": true, "edited": true, "priority": req.Priority})
+			err = printOutput(task, func() { fmt.Printf("Retry of %s created: %s\n", d.Target, c.taskLink(task.ID)) })
+		}
+	case "edit":
+		var ed editDraft
+		if err = json.Unmarshal(d.Request, &ed); err != nil {
+			return err
+		}
+		var after map[string]any
+		if err = json.Unmarshal([]byte(text), &after); err == nil {
+			err = c.updateTask(ctx, d.Target, ed.Before, after, true, ed.Yes)
+		}
+	default:
+		return fmt.Errorf("draft %s has unknown kind %q", d.ID, d.Kind)
+	}
+	if err != nil {
+		return fmt.Errorf("%w (draft saved, resume with: autocodit drafts resume %s)", err, d.ID)
+	}
+	d.remove()
+	return nil
+}
+
+// recheckDraft repeats the checks create or retry ran when the draft was
+// started, since freeze windows and the budget may have changed since. An
+// override reason given then still counts.
+func (c *Client) recheckDraft(ctx context.Context, d *draft) error {
+	if d.Kind != "create" && d.Kind != "retry" {
+		return nil
+	}
+	var req CreateTaskRequest
+	if err := json.Unmarshal(d.Request, &req); err != nil {
+		return err
+	}
+	reason := ""
+	if o, ok := req.AgentConfig["freeze_override"].(map[string]any); ok {
+		reason, _ = o["reason"].(string)
+	}
+	if err := c.checkFreeze(&req, reason); err != nil {
+		return fmt.Errorf("%w (draft %s kept)", err, d.ID)
+	}
+	if err := c.checkBudget(ctx); err != nil {
+		return fmt.Errorf("%w (draft %s kept)", err, d.ID)
+	}
+	raw, err := json.Marshal(&req)
+	if err != nil {
+		return err
+	}
+	d.Request = raw
+	return writeState(filepath.Join("drafts", d.ID+".json"), d)
+}
+
+func cmdDrafts(c *Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "drafts",
+		Short: "Manage unsubmitted editor drafts",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List saved drafts",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir, err := statePath("drafts", "")
+			if err != nil {
+				return err
+			}
+			matches, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+			var drafts []*draft
+			for _, m := range matches {
+				if d, err := loadDraft(strings.TrimSuffix(filepath.Base(m), ".json")); err == nil {
+					drafts = append(drafts, d)
+				}
+			}
+			sort.Slice(drafts, func(i, j int) bool { return drafts[i].CreatedAt.After(drafts[j].CreatedAt) })
+			for _, d := range drafts {
+				p, _ := d.textPath()
+				b, _ := os.ReadFile(p)
+				first, _, _ := strings.Cut(stripComments(string(b)), "\n")
+				fmt.Printf("%s %-8s %s %s\n", d.ID, d.Kind, d.CreatedAt.Format(time.DateTime), first)
+			}
+			return nil
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "resume [id]",
+		Short: "Reopen a draft in the editor and submit it",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			d, err := loadDraft(args[0])
+			if err != nil {
+				return err
+			}
+			if err := c.recheckDraft(cmd.Context(), d); err != nil {
+				return err
+			}
+			text, err := d.edit("")
+			if err != nil {
+				return err
+			}
+			return c.submitDraft(cmd.Context(), d, text, true)
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "delete [id]",
+		Short: "Discard a draft",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			d, err := loadDraft(args[0])
+			if err != nil {
+				return err
+			}
+			d.remove()
+			return nil
+		},
+	})
+	return cmd
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseDuration extends time.ParseDuration with a "d" (24h) unit, e.g. 7d or 1d12h.
+func parseDuration(s string) (time.Duration, error) {
+	if days, rest, ok := strings.Cut(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return time.ParseDuration(s)
+		}
+		d := time.Duration(n) * 24 * time.Hour
+		if rest == "" {
+			return d, nil
+		}
+		r, err := time.ParseDuration(rest)
+		return d + r, err
+	}
+	return time.ParseDuration(s)
+}
+
+// parseSince reads a --since value: a duration back from now (7d, 12h), a
+// date, or an RFC 3339 time.
+func parseSince(s string) (time.Time, error) {
+	if d, err := parseDuration(s); err == nil && d > 0 {
+		return time.Now().Add(-d), nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q (want a duration like 7d, a date, or an RFC 3339 time)", s)
+}
+
+// parseAsOf reads a --as-of value: a duration back from now (2h, 1d, "90m
+// ago"), a clock time today, "yesterday" or "today" with an optional clock
+// time, a date with an optional time, or an RFC 3339 time. Times without a
+// zone are local.
+func parseAsOf(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if d, err := parseDuration(strings.TrimSpace(strings.TrimSuffix(s, "ago"))); err == nil && d > 0 {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	day, clock, _ := strings.Cut(s, " ")
+	y, m, d := now.Date()
+	switch strings.ToLower(day) {
+	case "today":
+	case "yesterday":
+		d--
+	default:
+		for _, layout := range []string{time.DateOnly, "2006-01-02 15:04", time.DateTime} {
+			if t, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+				return t, nil
+			}
+		}
+		clock = s
+	}
+	if clock == "" {
+		return time.Date(y, m, d, 0, 0, 0, 0, now.Location()), nil
+	}
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.Parse(layout, clock); err == nil {
+			return time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), 0, now.Location()), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf(`invalid --as-of %q (want e.g. "yesterday 18:00", "09:30", 2h, 2026-10-13 18:00, or an RFC 3339 time)`, s)
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var editableFields = []string{"title", "description", "priority", "agent_config", "timeout_minutes"}
+
+func editableDoc(task map[string]any) map[string]any {
+	doc := map[string]any{}
+	for _, f := range editableFields {
+		if v, ok := task[f]; ok && v != nil {
+			doc[f] = v
+		}
+	}
+	return doc
+}
+
+// changedFields returns the top-level fields of after that differ from
+// before, with removed fields set to nil.
+func changedFields(before, after map[string]any) map[string]any {
+	changes := map[string]any{}
+	for k, v := range after {
+		if old, ok := before[k]; !ok || !jsonEqual(old, v) {
+			changes[k] = v
+		}
+	}
+	for k := range before {
+		if _, ok := after[k]; !ok {
+			changes[k] = nil
+		}
+	}
+	return changes
+}
+
+// editDraft is what an edit draft needs besides its text: the fields as
+// they were when editing started, to diff against.
+type editDraft struct {
+	Before map[string]any `json:"before"`
+	Yes    bool           `json:"yes,omitempty"`
+}
+
+// editInEditor opens doc as JSON in the editor from a draft, until it
+// parses or the user gives up, and returns the draft and its text. The
+// draft survives a failed update or Ctrl-C for drafts resume.
+func editInEditor(id string, before, doc map[string]any, yes bool) (*draft, string, error) {
+	b, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, "", err
+	}
+	d, err := newDraft("edit", id, editDraft{Before: before, Yes: yes})
+	if err != nil {
+		return nil, "", err
+	}
+	initial := string(b) + "\n"
+	for {
+		text, err := d.edit(initial)
+		if err != nil {
+			return nil, "", err
+		}
+		var edited map[string]any
+		err = json.Unmarshal([]byte(text), &edited)
+		if err == nil {
+			return d, text, nil
+		}
+		if !confirm(fmt.Sprintf("Invalid JSON (%v). Edit again?", err)) {
+			return nil, "", fmt.Errorf("edit abandoned: %w (draft %s kept)", err, d.ID)
+		}
+		initial = ""
+	}
+}
+
+// updateTask checks after against the editable fields and PATCHes what
+// changed since before, with diff set showing the changes to confirm first.
+func (c *Client) updateTask(ctx context.Context, id string, before, after map[string]any, diff, yes bool) error {
+	for k := range after {
+		if !contains(editableFields, k) {
+			return fmt.Errorf("field %q is not editable (editable: %v)", k, editableFields)
+		}
+	}
+	if p, ok := after["priority"].(string); ok && !validPriority(p) {
+		return fmt.Errorf("priority must be one of low, normal, high, urgent")
+	}
+	changes := changedFields(before, after)
+	if len(changes) == 0 {
+		fmt.Println("No changes")
+		return nil
+	}
+	if diff {
+		printChanges(before, changes)
+		if !yes && !confirm("Update "+id+"?") {
+			return fmt.Errorf("aborted")
+		}
+	}
+	if err := c.DoJSON(ctx, http.MethodPatch, "/api/v1/tasks/"+id, changes, nil); err != nil {
+		return err
+	}
+	fmt.Println("Task updated:", id)
+	return nil
+}
+
+// valueLines is how a field's value is shown in a diff: strings line by
+// line, anything else as indented JSON.
+func valueLines(v any) []string {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case string:
+		return strings.Split(v, "\n")
+	}
+	b, _ := json.MarshalIndent(v, "", "  ")
+	return strings.Split(string(b), "\n")
+}
+
+// lineDiff is a longest-common-subsequence diff of a and b, each line
+// prefixed with " ", "-", or "+".
+func lineDiff(a, b []string) []string {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var out []string
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			out = append(out, " "+a[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, "-"+a[i])
+			i++
+		default:
+			out = append(out, "+"+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		out = append(out, "-"+a[i])
+	}
+	for ; j < len(b); j++ {
+		out = append(out, "+"+b[j])
+	}
+	return out
+}
+
+func printChanges(before, changes map[string]any) {
+	fields := make([]string, 0, len(changes))
+	for k := range changes {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	for _, k := range fields {
+		fmt.Println(colorize("1", k+":"))
+		for _, line := range lineDiff(valueLines(before[k]), valueLines(changes[k])) {
+			switch line[0] {
+			case '-':
+				line = colorize(colorRed, line)
+			case '+':
+				line = colorize(colorGreen, line)
+			}
+			fmt.Println("  " + line)
+		}
+	}
+}
+
+func cmdEdit(c *Client) *cobra.Command {
+	var patch, title, description, priority string
+	var sets []string
+	var timeout int
+	var editor, yes bool
+	cmd := &cobra.Command{
+		Use:   "edit [id]",
+		Short: "Update a queued task",
+		Long: `edit changes a task that has not started yet. Set fields with --title,
+--description, --priority, and --timeout, any editable field (dotted keys for
+agent_config) with --set, or write an RFC 6902 --patch. With --editor the
+task's editable fields open as JSON in $EDITOR, starting from any flags given,
+and the changes are shown as a diff to confirm before they are sent.
+
+Editable fields: ` + strings.Join(editableFields, ", ") + ".",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id := args[0]
+			var ops []patchOp
+			if patch != "" {
+				if err := json.Unmarshal([]byte(patch), &ops); err != nil {
+					return fmt.Errorf("--patch: %w", err)
+				}
+			}
+			flags := cmd.Flags()
+			if flags.Changed("title") {
+				ops = append(ops, patchOp{Op: "add", Path: "/title", Value: title})
+			}
+			if flags.Changed("description") {
+				ops = append(ops, patchOp{Op: "add", Path: "/description", Value: description})
+			}
+			if flags.Changed("priority") {
+				if !validPriority(priority) {
+					return fmt.Errorf("--priority must be one of low, normal, high, urgent")
+				}
+				ops = append(ops, patchOp{Op: "add", Path: "/priority", Value: priority})
+			}
+			if flags.Changed("timeout") {
+				if timeout <= 0 {
+					return fmt.Errorf("--timeout must be a positive number of minutes")
+				}
+				ops = append(ops, patchOp{Op: "add", Path: "/timeout_minutes", Value: timeout})
+			}
+			if len(ops) == 0 && len(sets) == 0 && !editor {
+				return fmt.Errorf("nothing to change: use a field flag, --set, --patch, or --editor")
+			}
+
+			var task map[string]any
+			if err := c.DoJSON(cmd.Context(), http.MethodGet, "/api/v1/tasks/"+id, nil, &task); err != nil {
+				return err
+			}
+			if status, _ := task["status"].(string); status != "" && !isWaiting(status) {
+				return fmt.Errorf("%s is %s; only queued tasks can be edited", id, status)
+			}
+			before := editableDoc(task)
+			more, err := setOps(before, sets)
+			if err != nil {
+				return err
+			}
+			patched, err := applyPatch(before, append(ops, more...))
+			if err != nil {
+				return err
+			}
+			after, ok := patched.(map[string]any)
+			if !ok {
+				return fmt.Errorf("patch must leave the task an object")
+			}
+			if editor {
+				d, text, err := editInEditor(id, before, after, yes)
+				if err != nil {
+					return err
+				}
+				return c.submitDraft(cmd.Context(), d, text, false)
+			}
+			return c.updateTask(cmd.Context(), id, before, after, false, yes)
+		},
+	}
+	cmd.Flags().StringVar(&title, "title", "", "new title")
+	cmd.Flags().StringVar(&description, "description", "", "new description")
+	cmd.Flags().StringVarP(&priority, "priority", "p", "", "new priority (low|normal|high|urgent)")
+	cmd.Flags().IntVar(&timeout, "timeout", 0, "new timeout in minutes")
+	cmd.Flags().StringVar(&patch, "patch", "", "RFC 6902 JSON Patch applied to the task's editable fields")
+	cmd.Flags().StringArrayVar(&sets, "set", nil, "field=value shortcut, repeatable; dotted keys address agent_config")
+	cmd.Flags().BoolVarP(&editor, "editor", "e", false, "edit the fields as JSON in $EDITOR and confirm the diff before submitting")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "with --editor, submit without confirming the diff")
+	return cmd
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
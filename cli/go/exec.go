@@ -0,0 +1,263 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+type execControl struct {
+	Type string `json:"type"`
+	Cols int    `json:"cols,omitempty"`
+	Rows int    `json:"rows,omitempty"`
+	Code int    `json:"code,omitempty"`
+	Msg  string `json:"message,omitempty"`
+}
+
+type exitCodeError struct{ code int }
+
+func (e exitCodeError) Error() string { return "exit status " + strconv.Itoa(e.code) }
+
+func (c *Client) wsURL(path string, q url.Values) (string, error) {
+	u, err := url.Parse(c.BaseURL + path)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	default:
+		u.Scheme = "ws"
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func (c *Client) dialWS(ctx context.Context, path string, q url.Values) (*websocket.Conn, error) {
+	u, err := c.wsURL(path, q)
+	if err != nil {
+		return nil, err
+	}
+	h := http.Header{}
+	token := c.Token
+	if c.pool != nil {
+		token = c.pool.Token()
+	}
+	if token != "" {
+		h.Set("Authorization", "Bearer "+token)
+	}
+	d := *websocket.DefaultDialer
+	if c.tunnel != nil {
+		d.NetDialContext = c.tunnel.dial
+	}
+	conn, resp, err := d.DialContext(ctx, u, h)
+	if err != nil {
+		if resp != nil {
+			b, _ := io.ReadAll(resp.Body)
+			return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(b)))
+		}
+		return nil, err
+	}
+	return conn, nil
+}
+
+func cmdExec(c *Client) *cobra.Command {
+	var roWorkspace, noTTY bool
+	cmd := &cobra.Command{
+		Use:   "exec [id] -- command [args...]",
+		Short: "Run a command or shell inside a running task's sandbox",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, command := args[0], args[1:]
+			stdinFd := int(os.Stdin.Fd())
+			tty := !noTTY && term.IsTerminal(stdinFd)
+
+			q := url.Values{"cmd": command}
+			if tty {
+				q.Set("tty", "1")
+				if w, h, err := term.GetSize(stdinFd); err == nil {
+					q.Set("cols", strconv.Itoa(w))
+					q.Set("rows", strconv.Itoa(h))
+				}
+			}
+			if roWorkspace {
+				q.Set("read_only", "1")
+			}
+			conn, err := c.dialWS(cmd.Context(), "/api/v1/tasks/"+id+"/exec", q)
+			if err != nil {
+				return err
+			}
+			defer conn.Close()
+
+			start := time.Now()
+			audit("exec.start", map[string]any{"task": id, "command": command, "ro_workspace": roWorkspace, "tty": tty})
+			lines := &inputLines{emit: func(line string) {
+				audit("exec.input", map[string]any{"task": id, "line": redactInput(line)})
+			}}
+			code, err := runExecSession(conn, stdinFd, tty, lines)
+			lines.flush()
+			audit("exec.end", map[string]any{"task": id, "exit_code": code, "duration_ms": time.Since(start).Milliseconds()})
+			if err != nil {
+				return err
+			}
+			if code != 0 {
+				return exitCodeError{code}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&roWorkspace, "ro-workspace", false, "mount the sandbox workspace read-only for this session")
+	cmd.Flags().BoolVarP(&noTTY, "no-tty", "T", false, "disable pseudo-terminal allocation")
+	return cmd
+}
+
+// inputLines splits what is typed into an exec session into lines for the
+// audit log, applying backspaces and dropping escape sequences so a line
+// reads as it was sent.
+type inputLines struct {
+	mu   sync.Mutex
+	buf  []rune
+	esc  int // 1 after ESC, 2 inside a CSI sequence
+	emit func(line string)
+}
+
+func (l *inputLines) write(b []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, r := range string(b) {
+		switch {
+		case l.esc == 1:
+			l.esc = 0
+			if r == '[' || r == 'O' {
+				l.esc = 2
+			}
+			continue
+		case l.esc == 2:
+			if r >= 0x40 && r <= 0x7e {
+				l.esc = 0
+			}
+			continue
+		case r == 0x1b:
+			l.esc = 1
+			continue
+		}
+		switch r {
+		case '\r', '\n':
+			l.flushLocked()
+		case 0x7f, '\b':
+			if len(l.buf) > 0 {
+				l.buf = l.buf[:len(l.buf)-1]
+			}
+		case 0x03, 0x15: // ^C, ^U discard the line
+			l.buf = l.buf[:0]
+		default:
+			if r >= ' ' || r == '\t' {
+				l.buf = append(l.buf, r)
+			}
+		}
+	}
+}
+
+func (l *inputLines) flush() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.flushLocked()
+}
+
+func (l *inputLines) flushLocked() {
+	if line := strings.TrimSpace(string(l.buf)); line != "" {
+		l.emit(line)
+	}
+	l.buf = l.buf[:0]
+}
+
+// secretAssignment matches shell assignments to variables named like
+// secrets, whose values are often too short for redact to spot.
+var secretAssignment = regexp.MustCompile(`(?i)\b([A-Z0-9_]*(?:TOKEN|SECRET|PASSW(?:OR)?D|API_?KEY|CREDENTIALS?)[A-Z0-9_]*=)("[^"]*"|'[^']*'|\S+)`)
+
+// redactInput hides secrets in a line typed into an exec session before it
+// is audited.
+func redactInput(line string) string {
+	return redact(secretAssignment.ReplaceAllString(line, "${1}[REDACTED]"))
+}
+
+func runExecSession(conn *websocket.Conn, stdinFd int, tty bool, lines *inputLines) (int, error) {
+	var mu sync.Mutex
+	send := func(typ int, b []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+		return conn.WriteMessage(typ, b)
+	}
+	sendControl := func(ctl execControl) error {
+		b, _ := json.Marshal(ctl)
+		return send(websocket.TextMessage, b)
+	}
+
+	if tty {
+		old, err := term.MakeRaw(stdinFd)
+		if err != nil {
+			return -1, err
+		}
+		defer term.Restore(stdinFd, old)
+		stop := watchResize(func() {
+			if w, h, err := term.GetSize(stdinFd); err == nil {
+				_ = sendControl(execControl{Type: "resize", Cols: w, Rows: h})
+			}
+		})
+		defer stop()
+	}
+
+	go func() {
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if n > 0 {
+				lines.write(buf[:n])
+				if send(websocket.BinaryMessage, buf[:n]) != nil {
+					return
+				}
+			}
+			if err != nil {
+				_ = sendControl(execControl{Type: "eof"})
+				return
+			}
+		}
+	}()
+
+	for {
+		typ, msg, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				return 0, nil
+			}
+			return -1, err
+		}
+		if typ == websocket.BinaryMessage {
+			_, _ = os.Stdout.Write(msg)
+			continue
+		}
+		var ctl execControl
+		if json.Unmarshal(msg, &ctl) != nil {
+			continue
+		}
+		switch ctl.Type {
+		case "exit":
+			return ctl.Code, nil
+		case "error":
+			return -1, fmt.Errorf("exec: %s", ctl.Msg)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+type finding struct {
+	Kind   string
+	Task   Task
+	Detail string
+	Fixes  []string
+}
+
+var fixKeys = map[byte]string{'c': "cancel", 'r': "retry", 'a': "archive"}
+
+type pullState struct {
+	State  string `json:"state"`
+	Merged bool   `json:"merged"`
+}
+
+// findAnomalies looks for unfinished tasks that have stopped moving, that
+// repeat another open task on the same repository, or whose pull request was
+// closed without merging while the task kept running.
+func (c *Client) findAnomalies(ctx context.Context, opts sdk.ListTasksOptions, stuckAfter time.Duration) ([]finding, error) {
+	var open []Task
+	tasks, errc := c.ListTasks(opts).Stream(ctx)
+	for t := range tasks {
+		if !isFinished(t.Status) {
+			open = append(open, t)
+		}
+	}
+	if err := <-errc; err != nil {
+		return nil, userScopeError(err, opts)
+	}
+	sort.Slice(open, func(i, j int) bool { return open[i].CreatedAt.Before(open[j].CreatedAt) })
+
+	var out []finding
+	for _, t := range open {
+		if idle := time.Since(t.UpdatedAt); !t.UpdatedAt.IsZero() && idle > stuckAfter {
+			out = append(out, finding{"stuck", t,
+				fmt.Sprintf("no progress from %.0f%% for %s", t.Progress*100, idle.Truncate(time.Minute)),
+				[]string{"cancel", "retry"}})
+		}
+	}
+
+	for i, t := range open {
+		tw := words(dedupeText(t.Title, t.Description))
+		for _, prev := range open[:i] {
+			if prev.Repository != t.Repository {
+				continue
+			}
+			if s := jaccard(tw, words(dedupeText(prev.Title, prev.Description))); s >= c.cfg.DedupeThreshold {
+				out = append(out, finding{"duplicate", t,
+					fmt.Sprintf("%.0f%% similar to older task %s", s*100, prev.ID),
+					[]string{"cancel", "archive"}})
+				break
+			}
+		}
+	}
+
+	for _, t := range open {
+		if t.PRNumber == nil {
+			continue
+		}
+		var pr pullState
+		path := fmt.Sprintf("/api/v1/repositories/%s/pulls/%d", t.Repository, *t.PRNumber)
+		if err := c.DoJSON(ctx, http.MethodGet, path, nil, &pr); err != nil {
+			continue
+		}
+		if pr.State == "closed" && !pr.Merged {
+			out = append(out, finding{"zombie", t,
+				fmt.Sprintf("PR #%d was closed without merging", *t.PRNumber),
+				[]string{"cancel", "archive"}})
+		}
+	}
+	return out, nil
+}
+
+// readKey reads a single keystroke without waiting for Enter.
+func readKey() (byte, error) {
+	fd := int(os.Stdin.Fd())
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return 0, err
+	}
+	defer term.Restore(fd, state)
+	b := make([]byte, 1)
+	if _, err := os.Stdin.Read(b); err != nil {
+		return 0, err
+	}
+	return b[0], nil
+}
+
+func (c *Client) applyFix(ctx context.Context, fix string, t Task) error {
+	return c.DoJSON(ctx, http.MethodPost, "/api/v1/tasks/"+t.ID+"/"+fix, nil, nil)
+}
+
+func cmdDuplicateFinder(c *Client) *cobra.Command {
+	var opts sdk.ListTasksOptions
+	var stuckAfter string
+	cmd := &cobra.Command{
+		Use:   "duplicate-finder",
+		Short: "Find stuck, duplicate, and zombie tasks and offer fixes",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			stuck, err := parseDuration(stuckAfter)
+			if err != nil || stuck <= 0 {
+				return fmt.Errorf("invalid --stuck-after %q", stuckAfter)
+			}
+			opts.PerPage = 100
+			findings, err := c.findAnomalies(cmd.Context(), opts, stuck)
+			if err != nil {
+				return err
+			}
+			if len(findings) == 0 {
+				fmt.Println("No anomalies found")
+				return nil
+			}
+
+			interactive := term.IsTerminal(int(os.Stdin.Fd()))
+			fixed := map[string]bool{}
+			for _, f := range findings {
+				if fixed[f.Task.ID] {
+					continue
+				}
+				fmt.Printf("%s %s [%s] %s: %s\n", colorize(colorYellow, fmt.Sprintf("%-9s", f.Kind)), c.taskLink(f.Task.ID), repoLink(f.Task.Repository), f.Detail, redact(firstLine(f.Task.Title)))
+				if !interactive {
+					continue
+				}
+				var keys []string
+				for _, fix := range f.Fixes {
+					keys = append(keys, "["+fix[:1]+"]"+fix[1:])
+				}
+				fmt.Printf("  %s, [s]kip, [q]uit? ", strings.Join(keys, ", "))
+				key, err := readKey()
+				fmt.Println()
+				if err != nil {
+					return err
+				}
+				if key == 'q' || key == 3 {
+					return nil
+				}
+				fix, ok := fixKeys[key]
+				if !ok || !contains(f.Fixes, fix) {
+					continue
+				}
+				if err := c.applyFix(cmd.Context(), fix, f.Task); err != nil {
+					fmt.Fprintf(os.Stderr, "  %s %s failed: %v\n", fix, f.Task.ID, err)
+					continue
+				}
+				fixed[f.Task.ID] = true
+				audit("finder."+fix, map[string]any{"task": f.Task.ID, "kind": f.Kind})
+				fmt.Printf("  %s: %s\n", fix, f.Task.ID)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&stuckAfter, "stuck-after", "6h", "flag unfinished tasks with no update for this long")
+	cmd.Flags().StringVarP(&opts.Repository, "repo", "r", "", "only tasks for owner/repo")
+	addUserScopeFlags(cmd, &opts)
+	return cmd
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// FreezeWindow blocks task creation for matching repositories and action
+// types between Start and End. Bounds are "HH:MM" for a daily window or
+// "Fri 18:00" for a weekly one; a window may wrap past midnight or Sunday.
+type FreezeWindow struct {
+	Name     string   `mapstructure:"name"`
+	Repos    []string `mapstructure:"repos"`
+	Types    []string `mapstructure:"types"`
+	Start    string   `mapstructure:"start"`
+	End      string   `mapstructure:"end"`
+	Timezone string   `mapstructure:"timezone"`
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseWindowBound returns minutes since the start of the day, or of the week
+// (Sunday 00:00) when a weekday is given.
+func parseWindowBound(s string) (int, bool, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return 0, false, fmt.Errorf("empty freeze window time")
+	}
+	weekly := len(fields) == 2
+	clock := fields[len(fields)-1]
+	var h, m int
+	if _, err := fmt.Sscanf(clock, "%d:%d", &h, &m); err != nil || h > 23 || m > 59 || len(fields) > 2 {
+		return 0, false, fmt.Errorf("invalid freeze window time %q", s)
+	}
+	mins := h*60 + m
+	if weekly {
+		day := strings.ToLower(fields[0])
+		if len(day) > 3 {
+			day = day[:3]
+		}
+		d, ok := weekdays[day]
+		if !ok {
+			return 0, false, fmt.Errorf("invalid freeze window day %q", fields[0])
+		}
+		mins += int(d) * 24 * 60
+	}
+	return mins, weekly, nil
+}
+
+// active reports whether now falls inside the window and, if so, when it ends.
+func (w FreezeWindow) active(now time.Time) (bool, time.Time, error) {
+	if w.Timezone != "" {
+		loc, err := time.LoadLocation(w.Timezone)
+		if err != nil {
+			return false, time.Time{}, fmt.Errorf("freeze window %s: %w", w.Name, err)
+		}
+		now = now.In(loc)
+	}
+	start, weekly, err := parseWindowBound(w.Start)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	end, endWeekly, err := parseWindowBound(w.End)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+	if weekly != endWeekly {
+		return false, time.Time{}, fmt.Errorf("freeze window %s: start and end must both name a day or neither", w.Name)
+	}
+
+	period := 24 * 60
+	cur := now.Hour()*60 + now.Minute()
+	if weekly {
+		period *= 7
+		cur += int(now.Weekday()) * 24 * 60
+	}
+	in := cur >= start && cur < end
+	if start > end {
+		in = cur >= start || cur < end
+	}
+	if !in {
+		return false, time.Time{}, nil
+	}
+	left := (end - cur + period) % period
+	until := now.Truncate(time.Minute).Add(time.Duration(left) * time.Minute)
+	return true, until, nil
+}
+
+func (w FreezeWindow) applies(repo, action string) bool {
+	if len(w.Types) > 0 && !contains(w.Types, action) {
+		return false
+	}
+	if len(w.Repos) == 0 {
+		return true
+	}
+	for _, p := range w.Repos {
+		if globMatch(p, repo) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkFreeze refuses req during a matching freeze window unless a reason is
+// given, in which case the override is recorded on the task and in the audit log.
+func (c *Client) checkFreeze(req *CreateTaskRequest, reason string) error {
+	for _, w := range c.cfg.FreezeWindows {
+		if !w.applies(req.Repository, req.ActionType) {
+			continue
+		}
+		in, until, err := w.active(time.Now())
+		if err != nil {
+			return err
+		}
+		if !in {
+			continue
+		}
+		if reason == "" {
+			return fmt.Errorf("%s tasks on %s are frozen by %q until %s; pass --override-freeze REASON to create anyway",
+				req.ActionType, req.Repository, w.Name, until.Format("Mon 2 Jan 15:04 MST"))
+		}
+		if req.AgentConfig == nil {
+			req.AgentConfig = map[string]interface{}{}
+		}
+		req.AgentConfig["freeze_override"] = map[string]string{"window": w.Name, "reason": reason}
+		audit("freeze.override", map[string]any{"window": w.Name, "reason": reason, "repo": req.Repository, "type": req.ActionType})
+		fmt.Fprintf(os.Stderr, "Overriding freeze window %q: %s\n", w.Name, reason)
+		return nil
+	}
+	return nil
+}
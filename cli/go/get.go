@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+func terminalWidth() int {
+	if w, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && w > 20 {
+		return w
+	}
+	return 100
+}
+
+// wrap breaks s into lines of at most width cells, preserving paragraphs.
+func wrap(s string, width int) []string {
+	var lines []string
+	for _, para := range strings.Split(s, "\n") {
+		words := strings.Fields(para)
+		if len(words) == 0 {
+			lines = append(lines, "")
+			continue
+		}
+		line := words[0]
+		for _, w := range words[1:] {
+			if displayWidth(line)+1+displayWidth(w) > width {
+				lines = append(lines, line)
+				line = w
+				continue
+			}
+			line += " " + w
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func statusColor(status string) string {
+	switch status {
+	case "completed":
+		return colorize(colorGreen, status)
+	case "failed":
+		return colorize(colorRed, status)
+	case "cancelled":
+		return colorize(colorGray, status)
+	}
+	return colorize(colorYellow, status)
+}
+
+func (c *Client) taskURL(id string) string {
+	if c.cfg.WebURL == "" {
+		return ""
+	}
+	return strings.TrimRight(c.cfg.WebURL, "/") + "/tasks/" + id
+}
+
+func prURL(repo string, n int) string {
+	return fmt.Sprintf("https://github.com/%s/pull/%d", repo, n)
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.DateTime)
+}
+
+func (c *Client) printTaskDetail(t Task) {
+	width := terminalWidth()
+	section := func(name string) {
+		fmt.Println()
+		fmt.Println(colorize("1", name))
+	}
+	field := func(k, v string) {
+		if v != "" {
+			fmt.Printf("  %-12s %s\n", k+":", v)
+		}
+	}
+
+	fmt.Println(colorize("1", redact(t.Title)))
+	section("Overview")
+	field("ID", c.taskLink(t.ID))
+	field("Status", fmt.Sprintf("%s (%.0f%%)%s", statusColor(t.Status), t.Progress*100, slaLabel(t)))
+	field("Progress", metricsSummary(t))
+	field("Type", t.ActionType)
+	field("Priority", t.Priority)
+	field("Repository", repoLink(t.Repository))
+	field("Creator", t.UserID)
+	field("Triggered by", t.TriggeredBy)
+	if t.ErrorMessage != "" {
+		field("Error", colorize(colorRed, redact(t.ErrorMessage)))
+	}
+	if t.Description != "" {
+		fmt.Println()
+		renderMarkdown(os.Stdout, redact(t.Description), width, "  ")
+	}
+
+	section("Timeline")
+	field("Created", formatTime(&t.CreatedAt))
+	field("Started", formatTime(t.StartedAt))
+	field("Completed", formatTime(t.CompletedAt))
+	if t.StartedAt != nil {
+		end := time.Now()
+		if t.CompletedAt != nil {
+			end = *t.CompletedAt
+		}
+		field("Duration", end.Sub(*t.StartedAt).Round(time.Second).String())
+	}
+	if t.RetryCount > 0 {
+		field("Retries", fmt.Sprint(t.RetryCount))
+	}
+
+	section("Changes")
+	field("Branch", t.BranchName)
+	if t.PRNumber != nil {
+		field("PR", prLink(t.Repository, *t.PRNumber))
+	}
+	if t.DiffStats != nil {
+		field("Diff", fmt.Sprintf("%d file(s), %s %s", t.DiffStats.FilesChanged,
+			colorize(colorGreen, fmt.Sprintf("+%d", t.DiffStats.Additions)),
+			colorize(colorRed, fmt.Sprintf("-%d", t.DiffStats.Deletions))))
+	} else if t.BranchName == "" {
+		field("Diff", "none yet")
+	}
+
+	section("Cost")
+	field("Tokens", fmt.Sprint(taskTokens(t)))
+	field("Cost", fmt.Sprintf("$%.2f", t.Cost))
+
+	section("Links")
+	field("Task", c.taskURL(t.ID))
+	if t.PRNumber != nil {
+		field("PR", prURL(t.Repository, *t.PRNumber))
+	}
+	if t.IssueNumber != nil {
+		field("Issue", fmt.Sprintf("https://github.com/%s/issues/%d", t.Repository, *t.IssueNumber))
+	}
+	if c.cfg.WebURL == "" && t.PRNumber == nil && t.IssueNumber == nil {
+		fmt.Println("  none (set web_url in config to link the task)")
+	}
+}
+
+func cmdGet(c *Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "get [id]",
+		Short: "Get a task",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var t Task
+			if err := c.DoJSON(cmd.Context(), http.MethodGet, "/api/v1/tasks/"+args[0], nil, &t); err != nil {
+				return err
+			}
+			// Without -o, pipes get json as before the flag was global.
+			if !cmd.Flags().Changed("output") && !term.IsTerminal(int(os.Stdout.Fd())) {
+				outputFormat = "json"
+			}
+			return printOutput(t, func() { c.printTaskDetail(t) })
+		},
+	}
+	return cmd
+}
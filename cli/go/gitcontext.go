@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+const (
+	gitContextCommits  = 5
+	gitContextMaxDirty = 100
+)
+
+type gitContext struct {
+	Branch        string   `json:"branch"`
+	Head          string   `json:"head"`
+	Dirty         []string `json:"dirty,omitempty"`
+	Omitted       int      `json:"omitted,omitempty"`
+	RecentCommits []string `json:"recent_commits,omitempty"`
+}
+
+// excluded reports whether p matches one of the git_context_exclude globs,
+// either as a whole path or by its base name.
+func excluded(patterns []string, p string) bool {
+	for _, pat := range patterns {
+		if ok, _ := path.Match(pat, p); ok {
+			return true
+		}
+		if ok, _ := path.Match(pat, path.Base(p)); ok {
+			return true
+		}
+		if strings.HasSuffix(pat, "/") && strings.HasPrefix(p, pat) {
+			return true
+		}
+	}
+	return false
+}
+
+// localGitContext describes the working tree the command runs in. Paths
+// matching git_context_exclude are left out (only counted), and commit
+// subjects pass through secret redaction.
+func (c *Client) localGitContext() (*gitContext, error) {
+	head, err := git("rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	gc := &gitContext{Head: head}
+	gc.Branch, _ = git("rev-parse", "--abbrev-ref", "HEAD")
+
+	changed, _ := gitLines("diff", "--name-only", "HEAD")
+	untracked, _ := gitLines("ls-files", "--others", "--exclude-standard")
+	for _, f := range append(changed, untracked...) {
+		if excluded(c.cfg.GitContextExclude, f) || len(gc.Dirty) >= gitContextMaxDirty {
+			gc.Omitted++
+			continue
+		}
+		gc.Dirty = append(gc.Dirty, f)
+	}
+
+	subjects, _ := gitLines("log", fmt.Sprintf("-%d", gitContextCommits), "--format=%h %s")
+	for _, s := range subjects {
+		gc.RecentCommits = append(gc.RecentCommits, redact(s))
+	}
+	return gc, nil
+}
+
+// originRepo returns owner/repo for the working tree's origin remote, from
+// either an scp-style (git@host:owner/repo.git) or URL remote.
+func originRepo() (string, error) {
+	remote, err := git("remote", "get-url", "origin")
+	if err != nil {
+		return "", err
+	}
+	remote = strings.TrimSuffix(strings.TrimSuffix(remote, "/"), ".git")
+	parts := strings.FieldsFunc(remote, func(r rune) bool { return r == '/' || r == ':' })
+	if len(parts) < 2 {
+		return "", fmt.Errorf("cannot parse origin remote %q", remote)
+	}
+	return parts[len(parts)-2] + "/" + parts[len(parts)-1], nil
+}
+
+// attachGitContext adds the local git state to req when attach_git_context is
+// on and the working tree is a checkout of the task's repository.
+func (c *Client) attachGitContext(req *CreateTaskRequest) {
+	if !c.cfg.AttachGitContext {
+		return
+	}
+	origin, err := originRepo()
+	if err != nil {
+		return
+	}
+	if !strings.EqualFold(origin, req.Repository) {
+		fmt.Fprintf(os.Stderr, "Note: not attaching git context, working tree is not a checkout of %s\n", req.Repository)
+		return
+	}
+	gc, err := c.localGitContext()
+	if err != nil {
+		return
+	}
+	if req.AgentConfig == nil {
+		req.AgentConfig = map[string]interface{}{}
+	}
+	req.AgentConfig["git_context"] = gc
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+type taskLog struct {
+	Timestamp time.Time `json:"timestamp"`
+	Level     string    `json:"level"`
+	Message   string    `json:"message"`
+	Component string    `json:"component,omitempty"`
+}
+
+type logMatch struct {
+	TaskID string `json:"task_id"`
+	taskLog
+}
+
+func (c *Client) taskLogs(ctx context.Context, id string) ([]taskLog, error) {
+	var logs []taskLog
+	err := c.DoJSON(ctx, http.MethodGet, "/api/v1/tasks/"+id+"/logs", nil, &logs)
+	return logs, err
+}
+
+// searchLogsServer asks the server to search; ok is false when the server
+// has no search endpoint.
+func (c *Client) searchLogsServer(ctx context.Context, pattern string, fixed, ignoreCase bool, opts sdk.ListTasksOptions, since time.Time) ([]logMatch, bool, error) {
+	q := url.Values{"q": {pattern}}
+	if fixed {
+		q.Set("fixed", "true")
+	}
+	if ignoreCase {
+		q.Set("ignore_case", "true")
+	}
+	for k, v := range map[string]string{"status": opts.Status, "repository": opts.Repository, "action_type": opts.ActionType, "user": opts.User} {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	if opts.AllUsers {
+		q.Set("all_users", "true")
+	}
+	if !since.IsZero() {
+		q.Set("since", since.UTC().Format(time.RFC3339))
+	}
+	var matches []logMatch
+	err := c.DoJSON(ctx, http.MethodGet, "/api/v1/logs/search?"+q.Encode(), nil, &matches)
+	var apiErr *sdk.APIError
+	if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusNotFound || apiErr.StatusCode == http.StatusMethodNotAllowed) {
+		return nil, false, nil
+	}
+	return matches, err == nil, err
+}
+
+// searchLogsLocal fetches logs of the matching tasks with at most workers
+// requests in flight, sending each task's matches as one group so output
+// from different tasks does not interleave.
+func (c *Client) searchLogsLocal(ctx context.Context, re *regexp.Regexp, opts sdk.ListTasksOptions, since time.Time, maxTasks, workers int, out func([]logMatch)) (scanned int, failed int, err error) {
+	ids := make(chan string)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range ids {
+				logs, err := c.taskLogs(ctx, id)
+				mu.Lock()
+				if err != nil {
+					failed++
+					mu.Unlock()
+					continue
+				}
+				var group []logMatch
+				for _, l := range logs {
+					if re.MatchString(l.Message) {
+						group = append(group, logMatch{TaskID: id, taskLog: l})
+					}
+				}
+				if len(group) > 0 {
+					out(group)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	lctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	tasks, errc := c.ListTasks(opts).Stream(lctx)
+	for t := range tasks {
+		if !since.IsZero() && t.CreatedAt.Before(since) {
+			continue
+		}
+		if maxTasks > 0 && scanned >= maxTasks {
+			cancel()
+			break
+		}
+		scanned++
+		ids <- t.ID
+	}
+	close(ids)
+	wg.Wait()
+	err = <-errc
+	if errors.Is(err, context.Canceled) && ctx.Err() == nil {
+		err = nil
+	}
+	return scanned, failed, err
+}
+
+func printLogMatch(re *regexp.Regexp, m logMatch) {
+	msg := redact(m.Message)
+	if re != nil {
+		msg = re.ReplaceAllStringFunc(msg, func(s string) string { return colorize("1;31", s) })
+	}
+	fmt.Printf("%s %s %s %s\n", colorize(colorBlue, m.TaskID), colorize(colorGray, m.Timestamp.Local().Format("01-02 15:04:05")), levelColor(m.Level), msg)
+}
+
+// noMatches exits 1 without an error message, like grep(1).
+func noMatches(cmd *cobra.Command) error {
+	cmd.SilenceErrors, cmd.SilenceUsage = true, true
+	return exitCodeError{code: 1}
+}
+
+func cmdGrep(c *Client) *cobra.Command {
+	var opts sdk.ListTasksOptions
+	var since string
+	var fixed, ignoreCase, local bool
+	var workers, maxTasks int
+	cmd := &cobra.Command{
+		Use:   "grep [pattern]",
+		Short: "Search logs across tasks",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			expr := args[0]
+			if fixed {
+				expr = regexp.QuoteMeta(expr)
+			}
+			if ignoreCase {
+				expr = "(?i)" + expr
+			}
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return fmt.Errorf("invalid pattern: %w", err)
+			}
+			if workers < 1 {
+				return fmt.Errorf("--concurrency must be at least 1")
+			}
+			var from time.Time
+			if since != "" {
+				d, err := parseDuration(since)
+				if err != nil || d <= 0 {
+					return fmt.Errorf("invalid --since %q", since)
+				}
+				from = time.Now().Add(-d)
+			}
+
+			if !local {
+				matches, ok, err := c.searchLogsServer(ctx, args[0], fixed, ignoreCase, opts, from)
+				if err != nil {
+					return userScopeError(err, opts)
+				}
+				if ok {
+					for _, m := range matches {
+						printLogMatch(re, m)
+					}
+					if len(matches) == 0 {
+						return noMatches(cmd)
+					}
+					return nil
+				}
+			}
+
+			var count, tasks int
+			scanned, failed, err := c.searchLogsLocal(ctx, re, opts, from, maxTasks, workers, func(group []logMatch) {
+				tasks++
+				for _, m := range group {
+					count++
+					printLogMatch(re, m)
+				}
+			})
+			if err != nil {
+				return userScopeError(err, opts)
+			}
+			summary := fmt.Sprintf("%d match(es) in %d of %d task(s)", count, tasks, scanned)
+			if failed > 0 {
+				summary += fmt.Sprintf("; logs unavailable for %d", failed)
+			}
+			fmt.Fprintln(os.Stderr, colorize(colorGray, summary))
+			if count == 0 {
+				return noMatches(cmd)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&opts.Status, "status", "s", "", "only tasks with this status")
+	cmd.Flags().StringVarP(&opts.Repository, "repo", "r", "", "only tasks on this repository")
+	cmd.Flags().StringVarP(&opts.ActionType, "type", "t", "", "only tasks of this type")
+	cmd.Flags().StringVar(&since, "since", "", "only tasks created within this long, e.g. 7d")
+	cmd.Flags().BoolVarP(&fixed, "fixed-strings", "F", false, "treat the pattern as a literal string")
+	cmd.Flags().BoolVarP(&ignoreCase, "ignore-case", "i", false, "match case-insensitively")
+	cmd.Flags().BoolVar(&local, "local", false, "search client-side even if the server supports log search")
+	cmd.Flags().IntVarP(&workers, "concurrency", "c", 8, "log downloads in flight when searching client-side")
+	cmd.Flags().IntVar(&maxTasks, "max-tasks", 500, "stop after scanning this many tasks client-side (0 for no limit)")
+	addUserScopeFlags(cmd, &opts)
+	return cmd
+}
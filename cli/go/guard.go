@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// confirmTarget asks before creating a task on a repository other than the
+// checkout in the current directory, or on one marked critical under
+// repos.<owner/repo>. Proceeding either way is recorded in the audit log.
+func (c *Client) confirmTarget(repo string, yes bool) error {
+	var reasons []string
+	if origin, err := originRepo(); err == nil && !strings.EqualFold(origin, repo) {
+		reasons = append(reasons, "the current directory is a checkout of "+origin)
+	}
+	if c.cfg.repoSettings(repo).Critical {
+		reasons = append(reasons, repo+" is marked production-critical")
+	}
+	if len(reasons) == 0 {
+		return nil
+	}
+	how := "flag"
+	if !yes {
+		for _, r := range reasons {
+			fmt.Fprintf(os.Stderr, "%s creating a task on %s, but %s\n", colorize(colorYellow, "Warning:"), repo, r)
+		}
+		if !confirm(fmt.Sprintf("Create the task on %s?", repo)) {
+			return fmt.Errorf("aborted; pass --yes to create on %s anyway", repo)
+		}
+		how = "prompt"
+	}
+	audit("create.target_confirmed", map[string]any{"repo": repo, "reasons": reasons, "via": how})
+	return nil
+}
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+// failure is a failed command as recorded in the audit log under
+// command.error.
+type failure struct {
+	Time    time.Time `json:"time"`
+	Command string    `json:"command"`
+	Args    []string  `json:"args"`
+	Task    string    `json:"task,omitempty"`
+	Error   string    `json:"error"`
+	Status  int       `json:"status,omitempty"`
+}
+
+// recordFailure audits err so help-me can explain it later. Arguments are
+// redacted, since they can carry descriptions and tokens.
+func recordFailure(cmd *cobra.Command, args []string, err error) {
+	f := map[string]any{"error": strings.TrimSpace(redact(err.Error()))}
+	redacted := make([]string, len(args))
+	for i, a := range args {
+		redacted[i] = redact(a)
+	}
+	f["args"] = redacted
+	if cmd != nil && cmd != cmd.Root() {
+		path := strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+		if path == "help-me" {
+			return
+		}
+		f["command"] = path
+		if _, ok := taskIDCommands[path]; ok && cmd.Flags().NArg() > 0 {
+			f["task"] = cmd.Flags().Arg(0)
+		}
+	}
+	var apiErr *sdk.APIError
+	if errors.As(err, &apiErr) {
+		f["status"] = apiErr.StatusCode
+	}
+	audit("command.error", f)
+}
+
+// lastFailure returns the most recent command.error in the audit log.
+func lastFailure() (failure, bool) {
+	var last failure
+	p, err := statePath("audit.log")
+	if err != nil {
+		return last, false
+	}
+	file, err := os.Open(p)
+	if err != nil {
+		return last, false
+	}
+	defer file.Close()
+	found := false
+	sc := bufio.NewScanner(file)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for sc.Scan() {
+		var e auditEntry
+		if json.Unmarshal(sc.Bytes(), &e) != nil || e.Event != "command.error" {
+			continue
+		}
+		b, _ := json.Marshal(e.Fields)
+		var f failure
+		if json.Unmarshal(b, &f) == nil {
+			f.Time = e.Time
+			last, found = f, true
+		}
+	}
+	return last, found
+}
+
+var plainArg = regexp.MustCompile(`^[A-Za-z0-9_./:=@%+,-]+$`)
+
+func shellQuote(s string) string {
+	if plainArg.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// commandLine is the failed command as typed, without the flags in drop
+// and with extra appended.
+func (f failure) commandLine(drop []string, extra ...string) string {
+	parts := []string{"autocodit"}
+	if len(f.Args) == 0 {
+		parts = append(parts, "COMMAND")
+	}
+	for _, a := range f.Args {
+		name, _, _ := strings.Cut(a, "=")
+		if !contains(drop, name) {
+			parts = append(parts, shellQuote(a))
+		}
+	}
+	return strings.Join(append(parts, extra...), " ")
+}
+
+func (f failure) help() string {
+	if f.Command == "" {
+		return "autocodit --help"
+	}
+	return "autocodit " + f.Command + " --help"
+}
+
+// failureHint is one knowledge base entry: errors whose status is status,
+// or whose message matches match, are explained by why and fixed by the
+// commands fixes returns.
+type failureHint struct {
+	status int
+	match  *regexp.Regexp
+	why    string
+	fixes  func(f failure) []string
+}
+
+func (h failureHint) matches(f failure) bool {
+	if h.status != 0 && (f.Status == h.status || h.status == 500 && f.Status > 500) {
+		return true
+	}
+	return h.match != nil && h.match.MatchString(f.Error)
+}
+
+// failureHints is checked in order; the first match is shown.
+var failureHints = []failureHint{
+	{
+		match: regexp.MustCompile(`read-only mode`),
+		why:   "Read-only mode refuses commands that change tasks or settings.",
+		fixes: func(f failure) []string {
+			return []string{f.commandLine([]string{"--read-only"}), "# or set read_only: false in the config"}
+		},
+	},
+	{
+		status: http.StatusUnauthorized,
+		match:  regexp.MustCompile(`(?i)token rejected|no auth_token|unauthorized`),
+		why:    "The API didn't accept your token: it is missing, expired, or for another deployment.",
+		fixes: func(f failure) []string {
+			return []string{"autocodit login", "autocodit config current-context", f.commandLine(nil)}
+		},
+	},
+	{
+		status: http.StatusForbidden,
+		why:    "Your token works but may not act on this task or repository; it may be missing a scope or be for another account.",
+		fixes: func(f failure) []string {
+			return []string{"autocodit tokens list", "autocodit login", "autocodit config get-contexts"}
+		},
+	},
+	{
+		status: http.StatusNotFound,
+		why:    "Nothing matched on this endpoint: the ID may be mistyped, deleted, or from another context.",
+		fixes: func(f failure) []string {
+			fixes := []string{"autocodit list --limit 10"}
+			if f.Task != "" {
+				fixes = append(fixes, "autocodit trash list")
+			}
+			return append(fixes, "autocodit config get-contexts")
+		},
+	},
+	{
+		status: http.StatusTooManyRequests,
+		match:  regexp.MustCompile(`no usable token in the token pool`),
+		why:    "The server is rate limiting your token; waiting for the limit to reset or retrying with backoff helps.",
+		fixes: func(f failure) []string {
+			return []string{"autocodit limits", f.commandLine(nil, "--retries", "6")}
+		},
+	},
+	{
+		match: regexp.MustCompile(`\$\w+ is not set`),
+		why:   "A token_pool entry names an environment variable that isn't set in this shell.",
+		fixes: func(f failure) []string {
+			return []string{"export NAME=<token>   # the variable named in the error", "autocodit limits"}
+		},
+	},
+	{
+		match: regexp.MustCompile(`(?i)connection refused|no such host|unreachable|dial tcp|server misbehaving`),
+		why:   "The API can't be reached; check api_endpoint, your network or VPN, and whether it sits behind a bastion.",
+		fixes: func(f failure) []string {
+			return []string{"autocodit verify-config-connectivity", "autocodit config current-context",
+				f.commandLine(nil, "--ssh-tunnel", "user@bastion")}
+		},
+	},
+	{
+		match: regexp.MustCompile(`x509|certificate`),
+		why:   "TLS verification failed: the endpoint's certificate isn't trusted here, often a proxy or a self-signed deployment.",
+		fixes: func(f failure) []string {
+			return []string{"autocodit verify-config-connectivity", "SSL_CERT_FILE=/path/to/ca.pem " + f.commandLine(nil)}
+		},
+	},
+	{
+		status: 500,
+		match:  regexp.MustCompile(`context deadline exceeded|Client\.Timeout|i/o timeout`),
+		why:    "The request failed on the server side or timed out; these are usually transient.",
+		fixes: func(f failure) []string {
+			return []string{f.commandLine(nil, "--retries", "6"), "autocodit verify-config-connectivity"}
+		},
+	},
+	{
+		match: regexp.MustCompile(`--repo or default_repo|needs --repo|repo required`),
+		why:   "No repository was given, and the config has no default_repo to fall back on.",
+		fixes: func(f failure) []string {
+			return []string{f.commandLine(nil, "--repo", "OWNER/REPO"), "# or set default_repo: OWNER/REPO in ~/.autocodit/autocodit.yaml"}
+		},
+	},
+	{
+		match: regexp.MustCompile(`description required|description on stdin is empty`),
+		why:   "create needs a description: as an argument, on stdin, or written in $EDITOR.",
+		fixes: func(f failure) []string {
+			return []string{f.commandLine(nil, shellQuote("Describe the change")), f.commandLine(nil, "--edit"),
+				"cat spec.md | " + f.commandLine(nil, "-")}
+		},
+	},
+	{
+		match: regexp.MustCompile(`only finished tasks can be retried`),
+		why:   "The task is still running; retry only restarts finished tasks.",
+		fixes: func(f failure) []string {
+			return []string{"autocodit cancel " + pick(f.Task, "ID"), f.commandLine(nil)}
+		},
+	},
+	{
+		match: regexp.MustCompile(`are frozen by`),
+		why:   "A freeze window in the config blocks new tasks of this type on this repository.",
+		fixes: func(f failure) []string {
+			return []string{f.commandLine(nil, "--override-freeze", shellQuote("hotfix for INC-123"))}
+		},
+	},
+	{
+		match: regexp.MustCompile(`budget is enforced`),
+		why:   "This month's task budget is used up and the budget is enforced.",
+		fixes: func(f failure) []string {
+			return []string{"autocodit budget status", "autocodit budget set --monthly N"}
+		},
+	},
+	{
+		status: http.StatusRequestEntityTooLarge,
+		match:  regexp.MustCompile(`too large|over the server's limit`),
+		why:    "The description is over the server's size limit.",
+		fixes: func(f failure) []string {
+			return []string{"autocodit limits", "# trim the description, or link to the spec instead of pasting it"}
+		},
+	},
+	{
+		match: regexp.MustCompile(`unknown (shorthand )?flag|flag needs an argument|invalid argument`),
+		why:   "A flag is misspelled, missing its value, or belongs to another command.",
+		fixes: func(f failure) []string { return []string{f.help()} },
+	},
+	{
+		match: regexp.MustCompile(`unknown command`),
+		why:   "That isn't a command or alias; the error lists close matches when there are any.",
+		fixes: func(f failure) []string { return []string{"autocodit --help", "autocodit alias list"} },
+	},
+	{
+		match: regexp.MustCompile(`accepts (at most )?\d+ arg|requires at least|accepts between|received \d+`),
+		why:   "The command got the wrong number of arguments; quote descriptions that contain spaces.",
+		fixes: func(f failure) []string { return []string{f.help()} },
+	},
+}
+
+func lookupHint(f failure) (failureHint, bool) {
+	for _, h := range failureHints {
+		if h.matches(f) {
+			return h, true
+		}
+	}
+	return failureHint{}, false
+}
+
+// offerHelp points at help-me after an error it knows how to explain.
+func offerHelp(err error) {
+	if !term.IsTerminal(int(os.Stderr.Fd())) {
+		return
+	}
+	if _, ok := lookupHint(failure{Error: err.Error(), Status: statusOf(err)}); ok {
+		fmt.Fprintln(os.Stderr, colorize(colorGray, "Run autocodit help-me for a suggested fix."))
+	}
+}
+
+func statusOf(err error) int {
+	var apiErr *sdk.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
+
+type helpReport struct {
+	Failure failure  `json:"failure"`
+	Matched bool     `json:"matched"`
+	Why     string   `json:"why,omitempty"`
+	Fixes   []string `json:"fixes"`
+}
+
+func cmdHelpMe() *cobra.Command {
+	return &cobra.Command{
+		Use:   "help-me [error]",
+		Short: "Explain the last failed command and suggest commands that fix it",
+		Long: `help-me reads the last failed command from the audit log, matches its error
+against common failures, and prints what went wrong with commands to try
+next. Pass an error message instead to explain that one.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var f failure
+			if len(args) == 1 {
+				f = failure{Error: strings.TrimPrefix(args[0], "Error: ")}
+			} else {
+				var ok bool
+				if f, ok = lastFailure(); !ok {
+					return printOutput(helpReport{Fixes: []string{}}, func() { fmt.Println("No failed commands recorded.") })
+				}
+			}
+			h, ok := lookupHint(f)
+			r := helpReport{Failure: f, Matched: ok, Why: h.why}
+			if ok {
+				r.Fixes = h.fixes(f)
+			} else {
+				r.Fixes = []string{f.help(), "autocodit verify-config-connectivity"}
+			}
+			return printOutput(r, func() {
+				if !f.Time.IsZero() {
+					fmt.Printf("%s %s\n", colorize(colorGray, "Failed "+time.Since(f.Time).Round(time.Second).String()+" ago:"), f.commandLine(nil))
+				}
+				fmt.Println(colorize(colorRed, "Error: "+f.Error))
+				fmt.Println()
+				if ok {
+					fmt.Println(colorize("1", r.Why))
+				} else {
+					fmt.Println("This isn't a failure help-me knows; the command's help may cover it.")
+				}
+				fmt.Println()
+				fmt.Println("Try:")
+				for _, fix := range r.Fixes {
+					if strings.HasPrefix(fix, "#") {
+						fmt.Println("  " + colorize(colorGray, fix))
+					} else {
+						fmt.Println("  " + fix)
+					}
+				}
+			})
+		},
+	}
+}
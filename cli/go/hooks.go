@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// watchHooks are shell commands run on task state transitions. Each gets the
+// task as JSON on stdin and its main fields in AUTOCODIT_TASK_* variables.
+type watchHooks struct {
+	onComplete, onFail, onChange string
+}
+
+func (c *Client) runHook(name, command string, t Task, previous string) error {
+	body, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	cmd.Env = append(os.Environ(),
+		"AUTOCODIT_HOOK="+name,
+		"AUTOCODIT_TASK_ID="+t.ID,
+		"AUTOCODIT_TASK_STATUS="+t.Status,
+		"AUTOCODIT_TASK_PREVIOUS_STATUS="+previous,
+		"AUTOCODIT_TASK_REPO="+t.Repository,
+		"AUTOCODIT_TASK_TYPE="+t.ActionType,
+		"AUTOCODIT_TASK_TITLE="+t.Title,
+		"AUTOCODIT_TASK_BRANCH="+t.BranchName,
+		"AUTOCODIT_TASK_URL="+c.taskURL(t.ID),
+		"AUTOCODIT_TASK_ERROR="+t.ErrorMessage,
+	)
+	if t.PRNumber != nil {
+		cmd.Env = append(cmd.Env,
+			"AUTOCODIT_TASK_PR="+strconv.Itoa(*t.PRNumber),
+			"AUTOCODIT_TASK_PR_URL="+prURL(t.Repository, *t.PRNumber))
+	}
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("--%s hook: %w", name, err)
+	}
+	return nil
+}
+
+// hookChanged runs the on-change hook for a status transition. Failures are
+// reported but do not stop watching.
+func (c *Client) hookChanged(h watchHooks, t Task, previous string) {
+	if h.onChange == "" || previous == "" || previous == t.Status {
+		return
+	}
+	fmt.Println()
+	if err := c.runHook("on-change", h.onChange, t, previous); err != nil {
+		fmt.Fprintln(os.Stderr, "Warning:", err)
+	}
+}
+
+// hookFinished runs the on-complete or on-fail hook for a finished task; a hook
+// failure becomes the command's error.
+func (c *Client) hookFinished(h watchHooks, t Task, previous string) error {
+	switch {
+	case t.Status == "completed" && h.onComplete != "":
+		return c.runHook("on-complete", h.onComplete, t, previous)
+	case t.Status == "failed" && h.onFail != "":
+		return c.runHook("on-fail", h.onFail, t, previous)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// useHyperlinks is decided once in main from the hyperlinks setting.
+var useHyperlinks bool
+
+// hyperlinksSupported resolves hyperlinks: auto|always|never. In auto mode
+// links are emitted only to terminals known to render OSC 8; others would
+// print the escape sequence literally or drop the text.
+func hyperlinksSupported(mode string) bool {
+	switch mode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	if os.Getenv("FORCE_HYPERLINK") == "1" {
+		return true
+	}
+	if os.Getenv("NO_COLOR") != "" || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return false
+	}
+	switch os.Getenv("TERM_PROGRAM") {
+	case "iTerm.app", "WezTerm", "vscode", "Hyper", "ghostty", "Tabby":
+		return true
+	}
+	if os.Getenv("WT_SESSION") != "" || os.Getenv("KITTY_WINDOW_ID") != "" || os.Getenv("KONSOLE_VERSION") != "" {
+		return true
+	}
+	if v, err := strconv.Atoi(os.Getenv("VTE_VERSION")); err == nil && v >= 5000 {
+		return true
+	}
+	return false
+}
+
+func hyperlink(url, text string) string {
+	if !useHyperlinks || url == "" {
+		return text
+	}
+	return "\x1b]8;;" + url + "\x1b\\" + text + "\x1b]8;;\x1b\\"
+}
+
+func (c *Client) taskLink(id string) string {
+	return hyperlink(c.taskURL(id), id)
+}
+
+func repoLink(repo string) string {
+	if repo == "" || !strings.Contains(repo, "/") {
+		return repo
+	}
+	return hyperlink("https://github.com/"+repo, repo)
+}
+
+func prLink(repo string, n int) string {
+	return hyperlink(prURL(repo, n), fmt.Sprintf("#%d", n))
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+// minIDPrefix is the shortest abbreviation resolved, as with git's short
+// SHAs; anything shorter is passed through as typed.
+const minIDPrefix = 4
+
+// rememberedTasks caps the tasks kept for resolving prefixes locally.
+const rememberedTasks = 200
+
+var fullUUID = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// ambiguousIDError lists what an abbreviation matched so the user can pick.
+func ambiguousIDError(prefix string, matches []completionTask) error {
+	lines := make([]string, len(matches))
+	for i, t := range matches {
+		lines[i] = fmt.Sprintf("  %s  %s  %s", t.ID, t.Status, t.Title)
+	}
+	return fmt.Errorf("task ID %s is ambiguous; it matches %d tasks:\n%s\nuse more characters", prefix, len(matches), strings.Join(lines, "\n"))
+}
+
+func idMatches(tasks []completionTask, prefix string) (exact bool, matches []completionTask) {
+	for _, t := range tasks {
+		if t.ID == prefix {
+			return true, nil
+		}
+		if strings.HasPrefix(t.ID, prefix) {
+			matches = append(matches, t)
+		}
+	}
+	return false, matches
+}
+
+// rememberTask adds a task created here to the IDs resolveTaskID knows
+// without asking the server.
+func (c *Client) rememberTask(t Task) {
+	var all map[string]completionData
+	_ = updateState("cache/completion.json", &all, func() {
+		if all == nil {
+			all = map[string]completionData{}
+		}
+		d := all[c.BaseURL]
+		d.Tasks = append([]completionTask{{ID: t.ID, Status: t.Status, Title: firstLine(redact(t.Title))}}, d.Tasks...)
+		if len(d.Tasks) > rememberedTasks {
+			d.Tasks = d.Tasks[:rememberedTasks]
+		}
+		all[c.BaseURL] = d
+	})
+}
+
+// resolveTaskID expands an unambiguous ID prefix to the full ID. The server
+// decides, since another task may share a prefix the local cache (tasks
+// completion last saw or this machine created) has only one of; the cache is
+// used alone only when the server can't be asked. An ID that matches nothing
+// is returned unchanged for the command to report.
+func (c *Client) resolveTaskID(ctx context.Context, id string) (string, error) {
+	if len(id) < minIDPrefix || fullUUID.MatchString(id) {
+		return id, nil
+	}
+	var all map[string]completionData
+	_ = readState("cache/completion.json", &all)
+	cached := all[c.BaseURL].Tasks
+	if exact, _ := idMatches(cached, id); exact {
+		return id, nil
+	}
+
+	var found []completionTask
+	tasks, errc := c.ListTasks(sdk.ListTasksOptions{IDPrefix: id, Limit: 20}).Stream(ctx)
+	for t := range tasks {
+		found = append(found, completionTask{ID: t.ID, Status: t.Status, Title: firstLine(redact(t.Title))})
+	}
+	exact, matches := idMatches(found, id)
+	if err := <-errc; err != nil {
+		exact, matches = idMatches(cached, id)
+	} else if len(matches) < len(found) {
+		// Servers without prefix lookup ignore id_prefix and list the
+		// latest tasks, so older matches can only come from the cache.
+		_, more := idMatches(cached, id)
+		for _, t := range more {
+			if !containsTask(matches, t.ID) {
+				matches = append(matches, t)
+			}
+		}
+	}
+	switch {
+	case exact:
+		return id, nil
+	case len(matches) == 1:
+		return matches[0].ID, nil
+	case len(matches) > 1:
+		return "", ambiguousIDError(id, matches)
+	}
+	return id, nil
+}
+
+func containsTask(tasks []completionTask, id string) bool {
+	for _, t := range tasks {
+		if t.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveTaskIDArgs makes every command in taskIDCommands accept ID
+// prefixes in place of full IDs.
+func (c *Client) resolveTaskIDArgs(root *cobra.Command) {
+	var walk func(cmd *cobra.Command)
+	walk = func(cmd *cobra.Command) {
+		path := strings.TrimPrefix(cmd.CommandPath(), root.Name()+" ")
+		if _, ok := taskIDCommands[path]; ok && cmd.RunE != nil {
+			run := cmd.RunE
+			cmd.RunE = func(cmd *cobra.Command, args []string) error {
+				n := min(len(args), 1)
+				if takesManyIDs(cmd) {
+					n = len(args)
+				}
+				for i := range args[:n] {
+					id, err := c.resolveTaskID(cmd.Context(), args[i])
+					if err != nil {
+						return err
+					}
+					args[i] = id
+				}
+				return run(cmd, args)
+			}
+		}
+		for _, sub := range cmd.Commands() {
+			walk(sub)
+		}
+	}
+	walk(root)
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// inlineImageMax is the largest image drawn in the terminal; bigger ones
+// are opened in the viewer instead.
+const inlineImageMax = 20 << 20
+
+// isImageArtifact reports whether a claims to be a picture, such as a
+// screenshot the agent took while checking a UI change. It only picks what to
+// download; viewableImage decides from the bytes whether it is shown.
+func isImageArtifact(a artifact) bool {
+	if strings.HasPrefix(a.ContentType, "image/") {
+		return true
+	}
+	switch strings.ToLower(path.Ext(a.Name)) {
+	case ".png", ".jpg", ".jpeg", ".gif", ".webp":
+		return true
+	}
+	return false
+}
+
+// imageExts maps the image types viewableImage accepts to the extension a
+// viewer is handed them with.
+var imageExts = map[string]string{"image/png": ".png", "image/jpeg": ".jpg", "image/gif": ".gif", "image/webp": ".webp"}
+
+// viewableImage sniffs the file at p and, if it is a PNG, JPEG, GIF, or WebP
+// image, returns a path to it ending in that type's extension, copying it
+// when its own name says otherwise. Anything else returns "": an
+// agent-written file named or labelled as an image may be a script or
+// launcher the system viewer would run.
+func viewableImage(p string) (string, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	ext, ok := imageExts[http.DetectContentType(head[:n])]
+	if !ok {
+		return "", nil
+	}
+	switch have := strings.ToLower(filepath.Ext(p)); {
+	case have == ext, have == ".jpeg" && ext == ".jpg":
+		return p, nil
+	}
+	safe := p + ext
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return safe, replaceFile(safe, f)
+}
+
+// imageProtocol resolves inline_images: auto|iterm|kitty|sixel|never to the
+// protocol images are drawn with, or "" to open them in a viewer instead.
+// In auto mode only terminals known to speak one are used; others would
+// print the escape sequences as garbage.
+func imageProtocol(mode string) string {
+	switch mode {
+	case "iterm", "kitty", "sixel":
+		return mode
+	case "never":
+		return ""
+	}
+	if os.Getenv("NO_COLOR") != "" || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return ""
+	}
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "", os.Getenv("TERM") == "xterm-kitty", os.Getenv("TERM_PROGRAM") == "ghostty":
+		return "kitty"
+	case os.Getenv("LC_TERMINAL") == "iTerm2":
+		// iTerm2 sets this one for SSH sessions and tmux too.
+		return "iterm"
+	}
+	switch os.Getenv("TERM_PROGRAM") {
+	case "iTerm.app", "WezTerm", "mintty":
+		return "iterm"
+	}
+	switch t := os.Getenv("TERM"); {
+	case strings.Contains(t, "sixel"), t == "foot", strings.HasPrefix(t, "mlterm"), strings.HasPrefix(t, "contour"):
+		return "sixel"
+	}
+	return ""
+}
+
+// passthrough wraps an escape sequence so tmux hands it to the terminal
+// outside it instead of eating it.
+func passthrough(seq string) string {
+	if os.Getenv("TMUX") == "" {
+		return seq
+	}
+	return "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+}
+
+// drawImage writes the image in b to w with protocol proto.
+func drawImage(w io.Writer, proto, name string, b []byte) error {
+	switch proto {
+	case "iterm":
+		_, err := fmt.Fprint(w, passthrough(fmt.Sprintf("\x1b]1337;File=name=%s;size=%d;inline=1;preserveAspectRatio=1:%s\a",
+			base64.StdEncoding.EncodeToString([]byte(name)), len(b), base64.StdEncoding.EncodeToString(b))))
+		if err == nil {
+			_, err = fmt.Fprintln(w)
+		}
+		return err
+	case "kitty":
+		return drawKitty(w, b)
+	case "sixel":
+		return drawSixel(w, b)
+	}
+	return fmt.Errorf("unknown image protocol %q", proto)
+}
+
+func decodeImage(b []byte) (image.Image, error) {
+	img, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("can't decode the image for the terminal: %w", err)
+	}
+	return img, nil
+}
+
+// drawKitty sends b as PNG with kitty's graphics protocol, in the 4096
+// byte chunks it requires. Images wider than the window are scaled to it.
+func drawKitty(w io.Writer, b []byte) error {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("can't decode the image for the terminal: %w", err)
+	}
+	if format != "png" {
+		img, err := decodeImage(b)
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return err
+		}
+		b = buf.Bytes()
+	}
+	params := "a=T,f=100"
+	// Cells are at least 8 pixels wide, so only a wider image needs
+	// shrinking to fit.
+	if cols := terminalWidth(); cfg.Width > cols*8 {
+		params += fmt.Sprintf(",c=%d", cols)
+	}
+	data := base64.StdEncoding.EncodeToString(b)
+	for first := true; len(data) > 0; first = false {
+		chunk := data[:min(len(data), 4096)]
+		data = data[len(chunk):]
+		more := 0
+		if len(data) > 0 {
+			more = 1
+		}
+		ctrl := fmt.Sprintf("m=%d", more)
+		if first {
+			ctrl = params + "," + ctrl
+		}
+		if _, err := fmt.Fprint(w, passthrough("\x1b_G"+ctrl+";"+chunk+"\x1b\\")); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintln(w)
+	return err
+}
+
+// drawSixel quantizes b to the web-safe palette, scaled down to fit the
+// window, and writes it as DEC sixels: bands six pixels high, one pass per
+// colour, run-length encoded.
+func drawSixel(w io.Writer, b []byte) error {
+	src, err := decodeImage(b)
+	if err != nil {
+		return err
+	}
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if maxWidth := min(terminalWidth()*8, 1600); width > maxWidth {
+		width, height = maxWidth, max(height*maxWidth/width, 1)
+	}
+	scaled := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			scaled.Set(x, y, src.At(bounds.Min.X+x*bounds.Dx()/width, bounds.Min.Y+y*bounds.Dy()/height))
+		}
+	}
+	img := image.NewPaletted(scaled.Bounds(), palette.WebSafe)
+	draw.FloydSteinberg.Draw(img, img.Bounds(), scaled, image.Point{})
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "\x1bPq\"1;1;%d;%d", width, height)
+	for i, c := range palette.WebSafe {
+		r, g, bl, _ := c.RGBA()
+		fmt.Fprintf(&out, "#%d;2;%d;%d;%d", i, r*100/0xffff, g*100/0xffff, bl*100/0xffff)
+	}
+	row := make([]byte, width)
+	for top := 0; top < height; top += 6 {
+		used := map[uint8]bool{}
+		for y := top; y < min(top+6, height); y++ {
+			for x := 0; x < width; x++ {
+				used[img.ColorIndexAt(x, y)] = true
+			}
+		}
+		for ci := range palette.WebSafe {
+			if !used[uint8(ci)] {
+				continue
+			}
+			for x := 0; x < width; x++ {
+				bits := byte(0)
+				for dy := 0; dy < 6 && top+dy < height; dy++ {
+					if img.ColorIndexAt(x, top+dy) == uint8(ci) {
+						bits |= 1 << dy
+					}
+				}
+				row[x] = '?' + bits
+			}
+			fmt.Fprintf(&out, "#%d", ci)
+			for x := 0; x < width; {
+				n := 1
+				for x+n < width && row[x+n] == row[x] {
+					n++
+				}
+				if n > 3 {
+					fmt.Fprintf(&out, "!%d%c", n, row[x])
+				} else {
+					out.WriteString(strings.Repeat(string(row[x]), n))
+				}
+				x += n
+			}
+			out.WriteByte('$')
+		}
+		out.WriteByte('-')
+	}
+	out.WriteString("\x1b\\")
+	_, err = fmt.Fprintln(w, passthrough(out.String()))
+	return err
+}
+
+// canOpenFiles reports whether a viewer opened here would be seen: not over
+// SSH, and on Linux only with a display.
+func canOpenFiles() bool {
+	if os.Getenv("SSH_TTY") != "" || os.Getenv("SSH_CONNECTION") != "" {
+		return false
+	}
+	return runtime.GOOS != "linux" || os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != ""
+}
+
+// shownImage is how one image artifact was shown.
+type shownImage struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	How  string `json:"how"`
+}
+
+// showImages downloads task id's images into the state directory and draws
+// each in the terminal when it can, else opens it in the system viewer, else
+// says where it was saved. With open set, the terminal isn't tried. Nothing
+// is drawn or opened for machine output, or for files whose contents are not
+// an image.
+func (c *Client) showImages(ctx context.Context, id string, arts []artifact, open bool) ([]shownImage, error) {
+	dir, err := statePath("artifacts", id)
+	if err != nil {
+		return nil, err
+	}
+	proto := ""
+	if !open && !machineOutput() {
+		proto = imageProtocol(c.cfg.InlineImages)
+	}
+	var shown []shownImage
+	for _, a := range arts {
+		if _, err := c.downloadArtifact(ctx, id, a, dir); err != nil {
+			return shown, err
+		}
+		p, _ := artifactPath(dir, a.Name)
+		s := shownImage{Name: a.Name, Path: p, How: "saved"}
+		img, err := viewableImage(p)
+		if err != nil {
+			return shown, err
+		}
+		if machineOutput() {
+			shown = append(shown, s)
+			continue
+		}
+		fmt.Println(colorize("1", a.Name) + colorize(colorGray, " "+formatBytes(int(a.Size))))
+		if img == "" {
+			fmt.Println("  not a PNG, JPEG, GIF, or WebP image; saved to", p)
+			shown = append(shown, s)
+			continue
+		}
+		s.Path, p = img, img
+		var drawErr error
+		if proto != "" {
+			if b, err := os.ReadFile(p); err != nil {
+				drawErr = err
+			} else if len(b) > inlineImageMax {
+				drawErr = fmt.Errorf("%s is too big to draw in the terminal", formatBytes(len(b)))
+			} else if drawErr = drawImage(os.Stdout, proto, a.Name, b); drawErr == nil {
+				s.How = "inline"
+			}
+		}
+		if s.How != "inline" {
+			if drawErr != nil {
+				fmt.Fprintf(os.Stderr, "%s %v\n", colorize(colorYellow, "warning:"), drawErr)
+			}
+			if canOpenFiles() && openBrowser(p) == nil {
+				s.How = "opened"
+				fmt.Println("  opened", p)
+			} else {
+				fmt.Println("  saved to", p)
+			}
+		}
+		shown = append(shown, s)
+	}
+	return shown, nil
+}
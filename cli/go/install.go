@@ -0,0 +1,451 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// Set at build time by scripts/build-cli.sh. Package builds set installMethod
+// (homebrew, apt, snap, scoop, winget) so update leaves them alone.
+var (
+	version       = "dev"
+	installMethod = ""
+)
+
+var upgradeHints = map[string]string{
+	"homebrew": "brew upgrade autocodit",
+	"apt":      "sudo apt install --only-upgrade autocodit",
+	"rpm":      "sudo dnf upgrade autocodit",
+	"snap":     "sudo snap refresh autocodit",
+	"scoop":    "scoop update autocodit",
+	"winget":   "winget upgrade autocodit",
+}
+
+type installReceipt struct {
+	Method      string    `json:"method"`
+	Version     string    `json:"version"`
+	Prefix      string    `json:"prefix"`
+	InstalledAt time.Time `json:"installed_at"`
+}
+
+func executablePath() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return filepath.EvalSymlinks(exe)
+}
+
+// detectInstallMethod trusts the build-time value first, then the location of
+// the running binary, then a receipt left by install-completion-and-man.
+func detectInstallMethod() string {
+	if installMethod != "" {
+		return installMethod
+	}
+	exe, err := executablePath()
+	if err != nil {
+		return "manual"
+	}
+	p := filepath.ToSlash(exe)
+	switch {
+	case strings.Contains(p, "/Cellar/"), strings.Contains(p, "/homebrew/"), strings.Contains(p, "/linuxbrew/"):
+		return "homebrew"
+	case strings.HasPrefix(p, "/snap/"):
+		return "snap"
+	case strings.HasPrefix(p, "/usr/bin/"):
+		return "apt"
+	case strings.Contains(strings.ToLower(p), "/scoop/"):
+		return "scoop"
+	case strings.Contains(p, "/WinGet/"):
+		return "winget"
+	}
+	var r installReceipt
+	b, err := os.ReadFile(filepath.Join(filepath.Dir(exe), "..", "share", "autocodit", "install.json"))
+	if err == nil && json.Unmarshal(b, &r) == nil && r.Method != "" {
+		return r.Method
+	}
+	return "manual"
+}
+
+type completionFile struct{ shell, path string }
+
+type installLayout struct {
+	bin, man    string
+	completions []completionFile
+}
+
+func layoutFor(prefix string) installLayout {
+	if runtime.GOOS == "windows" {
+		return installLayout{
+			bin:         filepath.Join(prefix, "bin", "autocodit.exe"),
+			completions: []completionFile{{"powershell", filepath.Join(prefix, "completions", "autocodit.ps1")}},
+		}
+	}
+	share := filepath.Join(prefix, "share")
+	return installLayout{
+		bin: filepath.Join(prefix, "bin", "autocodit"),
+		man: filepath.Join(share, "man", "man1"),
+		completions: []completionFile{
+			{"bash", filepath.Join(share, "bash-completion", "completions", "autocodit")},
+			{"zsh", filepath.Join(share, "zsh", "site-functions", "_autocodit")},
+			{"fish", filepath.Join(share, "fish", "vendor_completions.d", "autocodit.fish")},
+		},
+	}
+}
+
+func defaultPrefix() string {
+	home, _ := os.UserHomeDir()
+	if runtime.GOOS == "windows" {
+		if dir := os.Getenv("LOCALAPPDATA"); dir != "" {
+			return filepath.Join(dir, "Programs", "autocodit")
+		}
+		return filepath.Join(home, "AppData", "Local", "Programs", "autocodit")
+	}
+	return filepath.Join(home, ".local")
+}
+
+func writeCompletion(root *cobra.Command, shell, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	switch shell {
+	case "bash":
+		return root.GenBashCompletionV2(f, true)
+	case "zsh":
+		return root.GenZshCompletion(f)
+	case "fish":
+		return root.GenFishCompletion(f, true)
+	case "powershell":
+		return root.GenPowerShellCompletionWithDesc(f)
+	}
+	return fmt.Errorf("unsupported shell %q", shell)
+}
+
+func roff(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\e`)
+	s = strings.ReplaceAll(s, "-", `\-`)
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		if strings.HasPrefix(l, ".") || strings.HasPrefix(l, "'") {
+			lines[i] = `\&` + l
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func manName(cmd *cobra.Command) string {
+	return strings.ReplaceAll(cmd.CommandPath(), " ", "-")
+}
+
+// writeManPages emits one section 1 page per visible command, named after its
+// command path the way git does (autocodit-template-lint.1).
+func writeManPages(cmd *cobra.Command, dir string) (int, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, err
+	}
+	var b strings.Builder
+	name := manName(cmd)
+	fmt.Fprintf(&b, ".TH %q 1 %q %q \"AutoCodit Manual\"\n", strings.ToUpper(name), time.Now().Format("2006-01-02"), "autocodit "+version)
+	fmt.Fprintf(&b, ".SH NAME\n%s \\- %s\n", roff(name), roff(cmd.Short))
+	fmt.Fprintf(&b, ".SH SYNOPSIS\n.B %s\n", roff(cmd.UseLine()))
+	desc := cmd.Long
+	if desc == "" {
+		desc = cmd.Short
+	}
+	fmt.Fprintf(&b, ".SH DESCRIPTION\n%s\n", roff(desc))
+	writeFlags := func(title string, fs *pflag.FlagSet) {
+		if !fs.HasAvailableFlags() {
+			return
+		}
+		fmt.Fprintf(&b, ".SH %s\n", title)
+		fs.VisitAll(func(f *pflag.Flag) {
+			if f.Hidden {
+				return
+			}
+			b.WriteString(".TP\n")
+			if f.Shorthand != "" {
+				fmt.Fprintf(&b, "\\fB\\-%s\\fR, ", f.Shorthand)
+			}
+			fmt.Fprintf(&b, "\\fB\\-\\-%s\\fR", roff(f.Name))
+			if t := f.Value.Type(); t != "bool" {
+				fmt.Fprintf(&b, " \\fI%s\\fR", t)
+			}
+			fmt.Fprintf(&b, "\n%s\n", roff(f.Usage))
+		})
+	}
+	writeFlags("OPTIONS", cmd.NonInheritedFlags())
+	writeFlags("GLOBAL OPTIONS", cmd.InheritedFlags())
+	var see []string
+	if cmd.HasParent() {
+		see = append(see, manName(cmd.Parent())+"(1)")
+	}
+	for _, sub := range cmd.Commands() {
+		if sub.IsAvailableCommand() {
+			see = append(see, manName(sub)+"(1)")
+		}
+	}
+	if len(see) > 0 {
+		fmt.Fprintf(&b, ".SH SEE ALSO\n%s\n", roff(strings.Join(see, ", ")))
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".1"), []byte(b.String()), 0o644); err != nil {
+		return 0, err
+	}
+
+	n := 1
+	for _, sub := range cmd.Commands() {
+		if !sub.IsAvailableCommand() || sub.IsAdditionalHelpTopicCommand() {
+			continue
+		}
+		m, err := writeManPages(sub, dir)
+		if err != nil {
+			return n, err
+		}
+		n += m
+	}
+	return n, nil
+}
+
+func copyExecutable(dst string) error {
+	src, err := executablePath()
+	if err != nil {
+		return err
+	}
+	if abs, err := filepath.Abs(dst); err == nil {
+		if resolved, err := filepath.EvalSymlinks(abs); err == nil && resolved == src {
+			return nil
+		}
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return replaceFile(dst, in)
+}
+
+// replaceFile writes r next to dst and renames it into place. Windows cannot
+// overwrite a running executable, so the old one is moved aside first.
+func replaceFile(dst string, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	tmp := dst + ".new"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if runtime.GOOS == "windows" {
+		old := dst + ".old"
+		os.Remove(old)
+		if err := os.Rename(dst, old); err != nil && !os.IsNotExist(err) {
+			os.Remove(tmp)
+			return err
+		}
+	}
+	return os.Rename(tmp, dst)
+}
+
+func onPath(dir string) bool {
+	for _, p := range filepath.SplitList(os.Getenv("PATH")) {
+		if filepath.Clean(p) == filepath.Clean(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+func cmdInstall() *cobra.Command {
+	var prefix string
+	var skipBinary bool
+	cmd := &cobra.Command{
+		Use:   "install-completion-and-man",
+		Short: "Install the binary, shell completions, and man pages under a prefix",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if strings.HasPrefix(prefix, "~") {
+				home, err := os.UserHomeDir()
+				if err != nil {
+					return err
+				}
+				prefix = filepath.Join(home, prefix[1:])
+			}
+			l := layoutFor(prefix)
+			root := cmd.Root()
+
+			method := detectInstallMethod()
+			if !skipBinary && upgradeHints[method] != "" {
+				fmt.Fprintf(os.Stderr, "Note: this binary is managed by %s; installing completions and man pages only\n", method)
+				skipBinary = true
+			}
+			if !skipBinary {
+				if err := copyExecutable(l.bin); err != nil {
+					return fmt.Errorf("install binary: %w", err)
+				}
+				fmt.Println("Installed", l.bin)
+				receipt := installReceipt{Method: "install-script", Version: version, Prefix: prefix, InstalledAt: time.Now()}
+				b, _ := json.MarshalIndent(receipt, "", "  ")
+				rp := filepath.Join(prefix, "share", "autocodit", "install.json")
+				if err := os.MkdirAll(filepath.Dir(rp), 0o755); err != nil {
+					return err
+				}
+				if err := writeFileAtomic(rp, b, 0o644); err != nil {
+					return err
+				}
+			}
+			for _, f := range l.completions {
+				if err := writeCompletion(root, f.shell, f.path); err != nil {
+					return fmt.Errorf("%s completion: %w", f.shell, err)
+				}
+				fmt.Println("Installed", f.path)
+			}
+			if l.man != "" {
+				n, err := writeManPages(root, l.man)
+				if err != nil {
+					return fmt.Errorf("man pages: %w", err)
+				}
+				fmt.Printf("Installed %d man pages in %s\n", n, l.man)
+			}
+
+			if bin := filepath.Dir(l.bin); !skipBinary && !onPath(bin) {
+				fmt.Printf("Add %s to your PATH to use autocodit\n", bin)
+			}
+			if runtime.GOOS == "windows" {
+				fmt.Printf("Add `. %s` to your PowerShell $PROFILE to enable completion\n", l.completions[0].path)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&prefix, "prefix", defaultPrefix(), "installation prefix")
+	cmd.Flags().BoolVar(&skipBinary, "skip-binary", false, "install completions and man pages only")
+	return cmd
+}
+
+func releaseAsset() string {
+	name := fmt.Sprintf("autocodit_%s_%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// releaseOrigin is where checksums.txt is always fetched from, so a mirror
+// set with update_url can serve the binary but cannot vouch for it.
+const releaseOrigin = "https://github.com/arturwyroslak/autocodit-agent/releases/latest/download"
+
+// fetchRelease downloads url, rejecting responses larger than max bytes.
+func fetchRelease(ctx context.Context, url string, max int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download %s: %s", url, resp.Status)
+	}
+	b, err := io.ReadAll(io.LimitReader(resp.Body, max+1))
+	if err != nil {
+		return nil, fmt.Errorf("download %s: %w", url, err)
+	}
+	if int64(len(b)) > max {
+		return nil, fmt.Errorf("download %s: larger than %d bytes", url, max)
+	}
+	return b, nil
+}
+
+// releaseChecksum finds asset's SHA-256 in a sha256sum-format checksums.txt.
+func releaseChecksum(sums []byte, asset string) (string, error) {
+	for _, line := range strings.Split(string(sums), "\n") {
+		f := strings.Fields(line)
+		if len(f) == 2 && strings.TrimPrefix(f[1], "*") == asset {
+			return strings.ToLower(f[0]), nil
+		}
+	}
+	return "", fmt.Errorf("checksums.txt has no entry for %s", asset)
+}
+
+func cmdUpdate(c *Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "update",
+		Short: "Replace this binary with the latest release",
+		Long: `Replace this binary with the latest release.
+
+The binary is downloaded from update_url, which may be a mirror, and is only
+installed if its SHA-256 matches checksums.txt from the project's releases.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			method := detectInstallMethod()
+			if hint, ok := upgradeHints[method]; ok {
+				return fmt.Errorf("autocodit was installed with %s; upgrade it with: %s", method, hint)
+			}
+			exe, err := executablePath()
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := context.WithTimeout(cmd.Context(), 5*time.Minute)
+			defer cancel()
+			asset := releaseAsset()
+			sums, err := fetchRelease(ctx, releaseOrigin+"/checksums.txt", 1<<20)
+			if err != nil {
+				return err
+			}
+			want, err := releaseChecksum(sums, asset)
+			if err != nil {
+				return err
+			}
+			url := strings.TrimRight(c.cfg.UpdateURL, "/") + "/" + asset
+			bin, err := fetchRelease(ctx, url, 512<<20)
+			if err != nil {
+				return err
+			}
+			if got := fmt.Sprintf("%x", sha256.Sum256(bin)); got != want {
+				return fmt.Errorf("download %s: SHA-256 %s does not match checksums.txt (%s); not installed", url, got, want)
+			}
+			if err := replaceFile(exe, bytes.NewReader(bin)); err != nil {
+				return fmt.Errorf("replace %s: %w", exe, err)
+			}
+			fmt.Println("Updated", exe)
+			return nil
+		},
+	}
+	return cmd
+}
+
+func cmdVersion() *cobra.Command {
+	return &cobra.Command{
+		Use:   "version",
+		Short: "Show version, platform, and install method",
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Printf("autocodit %s %s/%s (%s)\n", version, runtime.GOOS, runtime.GOARCH, detectInstallMethod())
+		},
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// patchOp is a single RFC 6902 JSON Patch operation.
+type patchOp struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	From  string `json:"from,omitempty"`
+	Value any    `json:"value,omitempty"`
+}
+
+func parsePointer(p string) ([]string, error) {
+	if p == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(p, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", p)
+	}
+	parts := strings.Split(p[1:], "/")
+	for i, s := range parts {
+		parts[i] = strings.ReplaceAll(strings.ReplaceAll(s, "~1", "/"), "~0", "~")
+	}
+	return parts, nil
+}
+
+// applyPatch applies ops to a deep copy of doc and returns the result.
+func applyPatch(doc any, ops []patchOp) (any, error) {
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var out any
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	for i, op := range ops {
+		if out, err = applyOp(out, op); err != nil {
+			return nil, fmt.Errorf("patch op %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return out, nil
+}
+
+func applyOp(doc any, op patchOp) (any, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch op.Op {
+	case "add":
+		return setAt(doc, path, op.Value, true)
+	case "replace":
+		if _, err := getAt(doc, path); err != nil {
+			return nil, err
+		}
+		return setAt(doc, path, op.Value, false)
+	case "remove":
+		return removeAt(doc, path)
+	case "test":
+		v, err := getAt(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonEqual(v, op.Value) {
+			return nil, fmt.Errorf("test failed: value is %v", v)
+		}
+		return doc, nil
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		v, err := getAt(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "move" {
+			if doc, err = removeAt(doc, from); err != nil {
+				return nil, err
+			}
+		}
+		return setAt(doc, path, v, true)
+	}
+	return nil, fmt.Errorf("unsupported op %q", op.Op)
+}
+
+func getAt(doc any, path []string) (any, error) {
+	cur := doc
+	for _, key := range path {
+		switch node := cur.(type) {
+		case map[string]any:
+			v, ok := node[key]
+			if !ok {
+				return nil, fmt.Errorf("path not found: %s", key)
+			}
+			cur = v
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, fmt.Errorf("invalid index %q", key)
+			}
+			cur = node[i]
+		default:
+			return nil, fmt.Errorf("cannot traverse into %q", key)
+		}
+	}
+	return cur, nil
+}
+
+func setAt(doc any, path []string, value any, insert bool) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := getAt(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	key := path[len(path)-1]
+	switch node := parent.(type) {
+	case map[string]any:
+		node[key] = value
+		return doc, nil
+	case []any:
+		i := len(node)
+		if key != "-" {
+			if i, err = strconv.Atoi(key); err != nil || i < 0 || i > len(node) || !insert && i == len(node) {
+				return nil, fmt.Errorf("invalid index %q", key)
+			}
+		}
+		if insert {
+			node = append(node[:i], append([]any{value}, node[i:]...)...)
+		} else {
+			node[i] = value
+		}
+		return setAt(doc, path[:len(path)-1], node, false)
+	}
+	return nil, fmt.Errorf("cannot set %q on a scalar", key)
+}
+
+func removeAt(doc any, path []string) (any, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("cannot remove the document root")
+	}
+	parent, err := getAt(doc, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	key := path[len(path)-1]
+	switch node := parent.(type) {
+	case map[string]any:
+		if _, ok := node[key]; !ok {
+			return nil, fmt.Errorf("path not found: %s", key)
+		}
+		delete(node, key)
+		return doc, nil
+	case []any:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(node) {
+			return nil, fmt.Errorf("invalid index %q", key)
+		}
+		return setAt(doc, path[:len(path)-1], append(node[:i], node[i+1:]...), false)
+	}
+	return nil, fmt.Errorf("cannot remove %q from a scalar", key)
+}
+
+func jsonEqual(a, b any) bool {
+	ab, _ := json.Marshal(a)
+	bb, _ := json.Marshal(b)
+	var av, bv any
+	_ = json.Unmarshal(ab, &av)
+	_ = json.Unmarshal(bb, &bv)
+	return reflect.DeepEqual(av, bv)
+}
+
+// parseSetValue interprets v as JSON when it parses, otherwise as a string,
+// so --set timeout_minutes=90 sends a number and --set title=x a string.
+func parseSetValue(v string) any {
+	var out any
+	if err := json.Unmarshal([]byte(v), &out); err == nil {
+		return out
+	}
+	return v
+}
+
+// setOps turns field=value shortcuts into add operations. Dotted keys
+// address nested objects, e.g. agent_config.model=gpt-4.
+func setOps(doc map[string]any, sets []string) ([]patchOp, error) {
+	var ops []patchOp
+	created := map[string]bool{}
+	for _, s := range sets {
+		k, v, ok := strings.Cut(s, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("--set expects field=value, got %q", s)
+		}
+		parts := strings.Split(k, ".")
+		for i := 1; i < len(parts); i++ {
+			p := pointer(parts[:i])
+			if created[p] {
+				continue
+			}
+			if _, err := getAt(doc, parts[:i]); err != nil {
+				ops = append(ops, patchOp{Op: "add", Path: p, Value: map[string]any{}})
+				created[p] = true
+			}
+		}
+		ops = append(ops, patchOp{Op: "add", Path: pointer(parts), Value: parseSetValue(v)})
+	}
+	return ops, nil
+}
+
+func pointer(parts []string) string {
+	var b strings.Builder
+	for _, p := range parts {
+		b.WriteByte('/')
+		b.WriteString(strings.ReplaceAll(strings.ReplaceAll(p, "~", "~0"), "/", "~1"))
+	}
+	return b.String()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/zalando/go-keyring"
+)
+
+// keyringService names the CLI's entries in the OS keychain: Keychain on
+// macOS, Credential Manager on Windows, the Secret Service elsewhere. Each
+// entry is keyed by API endpoint, so profiles keep separate tokens.
+const keyringService = "autocodit"
+
+// Values of credential_store.
+const (
+	storeAuto    = "auto"    // keyring when available, else the config file
+	storeKeyring = "keyring" // keyring only; fail where there is none
+	storeFile    = "file"    // plain text in autocodit.yaml, for headless machines
+)
+
+type credentials struct {
+	AuthToken    string `json:"auth_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+func checkCredentialStore(store string) error {
+	switch store {
+	case storeAuto, storeKeyring, storeFile:
+		return nil
+	}
+	return fmt.Errorf("invalid credential_store %q (want auto, keyring, or file)", store)
+}
+
+func keyringCredentials(endpoint string) (credentials, error) {
+	var cr credentials
+	secret, err := keyring.Get(keyringService, endpoint)
+	if err != nil {
+		return cr, err
+	}
+	return cr, json.Unmarshal([]byte(secret), &cr)
+}
+
+// loadKeyringCredentials fills in the token from the keyring when the config
+// file and environment have none.
+func (cfg *Config) loadKeyringCredentials() {
+	if cfg.AuthToken != "" || cfg.CredentialStore == storeFile {
+		return
+	}
+	if cr, err := keyringCredentials(cfg.APIEndpoint); err == nil {
+		cfg.AuthToken, cfg.RefreshToken = cr.AuthToken, cr.RefreshToken
+	}
+}
+
+// storeCredentials saves cr for endpoint in the keyring, or in the config
+// file when credential_store says so or, in auto mode, when there is no
+// keyring. It reports where the token went.
+func storeCredentials(store, endpoint string, cr credentials) (string, error) {
+	if store != storeFile {
+		err := setKeyringCredentials(endpoint, cr)
+		if err == nil {
+			// Drop any plain-text copy so it cannot shadow the keyring.
+			for _, key := range []string{"auth_token", "refresh_token"} {
+				if err := setConfigKey("", key, nil); err != nil {
+					return "", err
+				}
+			}
+			return "the system keyring", nil
+		}
+		if store == storeKeyring {
+			return "", fmt.Errorf("saving to the system keyring: %w (set credential_store: file on machines without one)", err)
+		}
+		fmt.Fprintf(os.Stderr, "%s no system keyring (%v); saving the token in the config file\n", colorize(colorYellow, "warning:"), err)
+	}
+	for _, kv := range [][2]string{{"auth_token", cr.AuthToken}, {"refresh_token", cr.RefreshToken}} {
+		value := &kv[1]
+		if kv[1] == "" {
+			value = nil
+		}
+		if err := setConfigKey("", kv[0], value); err != nil {
+			return "", err
+		}
+	}
+	p, _ := userConfigPath()
+	return p, nil
+}
+
+func setKeyringCredentials(endpoint string, cr credentials) error {
+	b, _ := json.Marshal(cr)
+	return keyring.Set(keyringService, endpoint, string(b))
+}
+
+func deleteKeyringCredentials(endpoint string) error {
+	err := keyring.Delete(keyringService, endpoint)
+	if errors.Is(err, keyring.ErrNotFound) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+// taskWriter renders tasks one at a time so --all output can start before
+// the last page has been fetched.
+type taskWriter interface {
+	write(t Task) error
+	close() error
+}
+
+type textTaskWriter struct {
+	ctx         context.Context
+	c           *Client
+	showCreator bool
+	showProfile bool
+	profileW    int
+	table       *table
+}
+
+func (w *textTaskWriter) write(t Task) error {
+	return w.writeFrom(w.c, "", t)
+}
+
+// writeFrom renders a task listed through pc, which may be another
+// profile's client than w.c.
+func (w *textTaskWriter) writeFrom(pc *Client, profile string, t Task) error {
+	if w.table == nil {
+		var cols []column
+		if w.showProfile {
+			cols = append(cols, column{width: w.profileW})
+		}
+		cols = append(cols, column{width: displayWidth(t.ID)}, column{width: 10}, column{width: 6, right: true})
+		if w.showCreator {
+			cols = append(cols, column{width: 12})
+		}
+		cols = append(cols, column{width: 18}, column{flex: true})
+		w.table = newTable(os.Stdout, w.c.tableMaxWidth(), cols...)
+	}
+	var cells []string
+	if w.showProfile {
+		cells = append(cells, colorize(colorGray, profile))
+	}
+	cells = append(cells, pc.taskLink(t.ID), t.Status, fmt.Sprintf("%.1f%%", t.Progress*100))
+	if w.showCreator {
+		cells = append(cells, t.UserID)
+	}
+	cells = append(cells, diffColumn(pc.diffStats(w.ctx, t)), pc.languageBadge(t.Repository)+t.Title+slaLabel(t))
+	w.table.writeRow(cells)
+	return nil
+}
+
+func (w *textTaskWriter) close() error {
+	saveDiffStats()
+	return nil
+}
+
+type ndjsonTaskWriter struct {
+	w io.Writer
+}
+
+func (w *ndjsonTaskWriter) write(t Task) error { return w.writeValue(t) }
+func (w *ndjsonTaskWriter) close() error       { return nil }
+
+func (w *ndjsonTaskWriter) writeValue(v any) error {
+	return writeOutput(w.w, "ndjson", v)
+}
+
+// jsonArrayTaskWriter emits a single JSON array incrementally.
+type jsonArrayTaskWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (w *jsonArrayTaskWriter) write(t Task) error { return w.writeValue(t) }
+
+func (w *jsonArrayTaskWriter) writeValue(v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	sep := ",\n  "
+	if w.count == 0 {
+		sep = "[\n  "
+	}
+	w.count++
+	_, err = fmt.Fprintf(w.w, "%s%s", sep, redact(string(b)))
+	return err
+}
+
+func (w *jsonArrayTaskWriter) close() error {
+	if w.count == 0 {
+		_, err := fmt.Fprintln(w.w, "[]")
+		return err
+	}
+	_, err := fmt.Fprintln(w.w, "\n]")
+	return err
+}
+
+// yamlTaskWriter emits a YAML sequence one item at a time.
+type yamlTaskWriter struct {
+	w     io.Writer
+	count int
+}
+
+func (w *yamlTaskWriter) write(t Task) error { return w.writeValue(t) }
+
+func (w *yamlTaskWriter) writeValue(v any) error {
+	w.count++
+	return writeOutput(w.w, "yaml", []any{v})
+}
+
+func (w *yamlTaskWriter) close() error {
+	if w.count == 0 {
+		_, err := fmt.Fprintln(w.w, "[]")
+		return err
+	}
+	return nil
+}
+
+func newTaskWriter(ctx context.Context, c *Client, output string, opts sdk.ListTasksOptions) (taskWriter, error) {
+	switch output {
+	case "", "table", "text":
+		return &textTaskWriter{ctx: ctx, c: c, showCreator: opts.AllUsers || opts.User != ""}, nil
+	case "json":
+		return &jsonArrayTaskWriter{w: os.Stdout}, nil
+	case "ndjson":
+		return &ndjsonTaskWriter{w: os.Stdout}, nil
+	case "yaml":
+		return &yamlTaskWriter{w: os.Stdout}, nil
+	}
+	return nil, checkOutputFormat(output)
+}
+
+func cmdList(c *Client) *cobra.Command {
+	var opts sdk.ListTasksOptions
+	var all, allProfiles bool
+	var since, repoGroup, asOf string
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List tasks",
+		Long: `List tasks.
+
+With --as-of, list the tasks as they were at a past time instead, such as
+what was running when an incident started:
+
+  autocodit list --as-of "yesterday 18:00" --status running
+
+The server's events log is replayed up to that time. Servers without one get
+a reconstruction from each task's created, started, and completed times.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if since != "" {
+				from, err := parseSince(since)
+				if err != nil {
+					return err
+				}
+				opts.Since = from
+			}
+			w, err := newTaskWriter(cmd.Context(), c, outputFormat, opts)
+			if err != nil {
+				return err
+			}
+			if err := checkCreatedBy(opts.CreatedBy); err != nil {
+				return err
+			}
+			if opts.Limit < 0 {
+				return fmt.Errorf("--limit must not be negative")
+			}
+			if opts.Page < 1 {
+				return fmt.Errorf("--page must be at least 1")
+			}
+			opts.Expand = []string{"diff_stats"}
+			// A limit is followed across pages like --all, just cut short.
+			all = all || opts.Limit > 0
+			if all && opts.PerPage == 0 {
+				opts.PerPage = 100
+			}
+			if asOf != "" {
+				at, err := parseAsOf(asOf, time.Now())
+				if err != nil {
+					return err
+				}
+				if at.After(time.Now()) {
+					return fmt.Errorf("--as-of %s is in the future", at.Local().Format(time.DateTime))
+				}
+				var repos []string
+				if repoGroup != "" {
+					if repos, err = c.repoGroup(repoGroup); err != nil {
+						return err
+					}
+				}
+				return c.writeAsOf(cmd.Context(), w, at, opts, repos)
+			}
+			if allProfiles {
+				return c.listProfiles(cmd.Context(), w, opts, all)
+			}
+			if repoGroup != "" {
+				repos, err := c.repoGroup(repoGroup)
+				if err != nil {
+					return err
+				}
+				tasks, err := c.listRepoGroup(cmd.Context(), opts, repos, all)
+				if err != nil {
+					return err
+				}
+				for _, t := range tasks {
+					if !listed(t, opts) {
+						continue
+					}
+					if err := w.write(t); err != nil {
+						return err
+					}
+				}
+				return w.close()
+			}
+			pager := c.ListTasks(opts)
+			if !all {
+				resp, err := pager.NextPage(cmd.Context())
+				if err != nil {
+					return userScopeError(err, opts)
+				}
+				for _, t := range resp.Items {
+					if !listed(t, opts) {
+						continue
+					}
+					if err := w.write(t); err != nil {
+						return err
+					}
+				}
+				return w.close()
+			}
+
+			tasks, errc := pager.Stream(cmd.Context())
+			for t := range tasks {
+				if !listed(t, opts) {
+					continue
+				}
+				if err := w.write(t); err != nil {
+					return err
+				}
+			}
+			// Close the writer even on error so json output stays a valid array.
+			closeErr := w.close()
+			if err := <-errc; err != nil {
+				return userScopeError(err, opts)
+			}
+			return closeErr
+		},
+	}
+	addUserScopeFlags(cmd, &opts)
+	cmd.Flags().StringVarP(&opts.Status, "status", "s", "", "only tasks with this status")
+	cmd.Flags().StringVarP(&opts.Repository, "repo", "r", "", "only tasks on this repository")
+	cmd.Flags().StringVar(&repoGroup, "repo-group", "", "only tasks on the repositories of this repo_groups entry")
+	cmd.Flags().StringVarP(&opts.ActionType, "type", "t", "", "only tasks of this type")
+	cmd.Flags().StringVarP(&opts.Priority, "priority", "p", "", "only tasks with this priority")
+	cmd.Flags().StringVar(&since, "since", "", "only tasks created within this long (7d) or since a date")
+	cmd.Flags().BoolVar(&all, "all", false, "follow every page, streaming results as they arrive")
+	cmd.Flags().IntVar(&opts.Limit, "limit", 0, "show at most this many tasks, fetching further pages as needed")
+	cmd.Flags().IntVar(&opts.Page, "page", 1, "page to show, or to start from with --all")
+	cmd.Flags().BoolVar(&opts.SLABreached, "sla-breached", false, "only unfinished tasks past their SLA deadline")
+	cmd.Flags().BoolVar(&allProfiles, "all-profiles", false, "list tasks from every configured profile, tagged with its name")
+	cmd.MarkFlagsMutuallyExclusive("repo", "repo-group")
+	cmd.Flags().StringVar(&asOf, "as-of", "", `show tasks as they were at this time, e.g. "yesterday 18:00" or 2h`)
+	cmd.MarkFlagsMutuallyExclusive("all-profiles", "repo-group")
+	cmd.MarkFlagsMutuallyExclusive("as-of", "all-profiles")
+	cmd.MarkFlagsMutuallyExclusive("as-of", "page")
+	return cmd
+}
+
+// listed double-checks the filters servers may not support, so an older
+// deployment returning everything does not show tasks the flags ruled out.
+func listed(t Task, opts sdk.ListTasksOptions) bool {
+	if opts.SLABreached && !slaBreached(t) {
+		return false
+	}
+	if opts.CreatedBy != "" && isServiceTask(t) != (opts.CreatedBy == "service") {
+		return false
+	}
+	return opts.Since.IsZero() || !t.CreatedAt.Before(opts.Since)
+}
+
+func (c *Client) listProfiles(ctx context.Context, w taskWriter, opts sdk.ListTasksOptions, all bool) error {
+	tasks, err := c.listAllProfiles(ctx, opts, all)
+	if err != nil {
+		return err
+	}
+	if tw, ok := w.(*textTaskWriter); ok {
+		tw.showProfile = true
+		for _, pt := range tasks {
+			tw.profileW = max(tw.profileW, displayWidth(pt.Profile))
+		}
+	}
+	for _, pt := range tasks {
+		if !listed(pt.Task, opts) {
+			continue
+		}
+		switch w := w.(type) {
+		case *textTaskWriter:
+			err = w.writeFrom(pt.client, pt.Profile, pt.Task)
+		case *jsonArrayTaskWriter:
+			err = w.writeValue(pt)
+		case *yamlTaskWriter:
+			err = w.writeValue(pt)
+		case *ndjsonTaskWriter:
+			err = w.writeValue(pt)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return w.close()
+}
+
+func addUserScopeFlags(cmd *cobra.Command, opts *sdk.ListTasksOptions) {
+	cmd.Flags().StringVar(&opts.User, "user", "", "only tasks created by this login")
+	cmd.Flags().StringVar(&opts.CreatedBy, "created-by", "", "human or service: only tasks people or machine identities created")
+	cmd.Flags().BoolVar(&opts.AllUsers, "all-users", false, "tasks from every user in the organization")
+	cmd.MarkFlagsMutuallyExclusive("user", "all-users")
+}
+
+func userScopeError(err error, opts sdk.ListTasksOptions) error {
+	var apiErr *sdk.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusForbidden && (opts.AllUsers || opts.User != "") {
+		return fmt.Errorf("viewing other users' tasks requires organization admin permission")
+	}
+	return err
+}
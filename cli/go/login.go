@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+// oauthClientID identifies the CLI to the API's OAuth endpoints.
+const oauthClientID = "autocodit-cli"
+
+func openBrowser(url string) error {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", url).Start()
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", url).Start()
+	}
+	return exec.Command("xdg-open", url).Start()
+}
+
+// saveLogin stores tok where credential_store says, and its expiry in the
+// config file. It reports where the token went.
+func (cfg *Config) saveLogin(tok *sdk.Token, issued time.Time) (string, error) {
+	if cfg.context != "" {
+		// The file's top-level token belongs to the top-level endpoint.
+		if cfg.CredentialStore == storeFile {
+			return "", fmt.Errorf("with credential_store: file, put the token for context %s under profiles.%s.auth_token", cfg.context, cfg.context)
+		}
+		if err := setKeyringCredentials(cfg.APIEndpoint, credentials{AuthToken: tok.AccessToken}); err != nil {
+			return "", fmt.Errorf("saving to the system keyring: %w", err)
+		}
+		return "the system keyring", nil
+	}
+	where, err := storeCredentials(cfg.CredentialStore, cfg.APIEndpoint, credentials{AuthToken: tok.AccessToken, RefreshToken: tok.RefreshToken})
+	if err != nil {
+		return "", err
+	}
+	var expires *string
+	if exp := tok.Expiry(issued); !exp.IsZero() {
+		s := exp.UTC().Format(time.RFC3339)
+		expires = &s
+	}
+	return where, setConfigKey("", "token_expires_at", expires)
+}
+
+// savedLogin re-reads the token fields from the config file and keyring,
+// which another process may have refreshed since this one started.
+func (cfg *Config) savedLogin() (token, refresh string, expires time.Time) {
+	if p, err := userConfigPath(); err == nil && cfg.context == "" {
+		if b, err := os.ReadFile(p); err == nil {
+			var v struct {
+				AuthToken      string    `yaml:"auth_token"`
+				RefreshToken   string    `yaml:"refresh_token"`
+				TokenExpiresAt time.Time `yaml:"token_expires_at"`
+			}
+			_ = yaml.Unmarshal(b, &v)
+			token, refresh, expires = v.AuthToken, v.RefreshToken, v.TokenExpiresAt
+		}
+	}
+	if token == "" && cfg.CredentialStore != storeFile {
+		if cr, err := keyringCredentials(cfg.APIEndpoint); err == nil {
+			token, refresh = cr.AuthToken, cr.RefreshToken
+		}
+	}
+	return token, refresh, expires
+}
+
+func expiresSoon(expires time.Time) bool {
+	return !expires.IsZero() && time.Until(expires) < time.Minute
+}
+
+// refreshLogin renews a token from login shortly before it expires. It holds
+// a lock so parallel invocations do not each spend the refresh token, which
+// the server may rotate.
+func (c *Client) refreshLogin(ctx context.Context) {
+	if c.cfg.RefreshToken == "" || !expiresSoon(c.cfg.TokenExpiresAt) {
+		return
+	}
+	unlock, err := lockState("login")
+	if err != nil {
+		return
+	}
+	defer unlock()
+	token, refresh, expires := c.cfg.savedLogin()
+	if refresh != c.cfg.RefreshToken {
+		// Changed underneath us: by another process, or by hand.
+		if token != "" && refresh != "" && !expiresSoon(expires) {
+			c.cfg.AuthToken, c.cfg.RefreshToken, c.cfg.TokenExpiresAt = token, refresh, expires
+			c.Token = token
+		}
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	issued := time.Now()
+	tok, err := c.RefreshToken(ctx, oauthClientID, refresh)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s could not refresh the login token (%v); run autocodit login if requests fail\n", colorize(colorYellow, "warning:"), err)
+		return
+	}
+	if tok.RefreshToken == "" {
+		tok.RefreshToken = refresh
+	}
+	if _, err := c.cfg.saveLogin(tok, issued); err != nil {
+		fmt.Fprintf(os.Stderr, "%s saving the refreshed token: %v\n", colorize(colorYellow, "warning:"), err)
+	}
+	c.cfg.AuthToken, c.cfg.RefreshToken = tok.AccessToken, tok.RefreshToken
+	c.cfg.TokenExpiresAt = tok.Expiry(issued)
+	c.Token = tok.AccessToken
+}
+
+func (c *Client) loginWithToken(ctx context.Context) error {
+	b, err := io.ReadAll(io.LimitReader(os.Stdin, 64<<10))
+	if err != nil {
+		return err
+	}
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return fmt.Errorf("no token on stdin")
+	}
+	c.Token = token
+	if err := c.DoJSON(ctx, http.MethodGet, "/api/v1/users/me", nil, nil); err != nil {
+		return fmt.Errorf("token rejected: %w", err)
+	}
+	where, err := c.cfg.saveLogin(&sdk.Token{AccessToken: token}, time.Now())
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Token for %s saved in %s\n", c.cfg.APIEndpoint, where)
+	audit("auth.login", map[string]any{"endpoint": c.cfg.APIEndpoint, "method": "token"})
+	return nil
+}
+
+func cmdLogin(c *Client) *cobra.Command {
+	var noBrowser, withToken bool
+	var scope string
+	cmd := &cobra.Command{
+		Use:   "login",
+		Short: "Sign in with the OAuth device flow and save the token",
+		Long: `login asks the API for a one-time code, which you approve in a browser
+signed in to AutoCodit. The resulting token, and the refresh token if the server
+issues one, are saved in the system keyring, or in ~/.autocodit/autocodit.yaml
+with credential_store: file or where there is no keyring. Tokens that expire are
+refreshed automatically.
+
+With --with-token, login instead reads an existing token from stdin and saves it
+the same way, which also moves a plain-text auth_token into the keyring.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			if withToken {
+				return c.loginWithToken(ctx)
+			}
+			dc, err := c.RequestDeviceCode(ctx, oauthClientID, scope)
+			var apiErr *sdk.APIError
+			if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+				return fmt.Errorf("%s does not support device login; create a token in the web UI and set auth_token in the config", c.cfg.APIEndpoint)
+			}
+			if err != nil {
+				return err
+			}
+			url := dc.VerificationURI
+			if dc.VerificationURIComplete != "" {
+				url = dc.VerificationURIComplete
+			}
+			fmt.Fprintf(os.Stderr, "Open %s and enter the code %s\n", dc.VerificationURI, colorize("1", dc.UserCode))
+			if !noBrowser && term.IsTerminal(int(os.Stdin.Fd())) {
+				if err := openBrowser(url); err == nil {
+					fmt.Fprintln(os.Stderr, colorize(colorGray, "Opened your browser; waiting for approval..."))
+				}
+			}
+			issued := time.Now()
+			tok, err := c.PollDeviceToken(ctx, oauthClientID, dc)
+			var oe *sdk.OAuthError
+			if errors.As(err, &oe) && oe.Code == "access_denied" {
+				return fmt.Errorf("login was denied in the browser")
+			}
+			if err != nil {
+				return err
+			}
+			where, err := c.cfg.saveLogin(tok, issued)
+			if err != nil {
+				return err
+			}
+			c.Token = tok.AccessToken
+			var me struct {
+				Login string `json:"login"`
+			}
+			if err := c.DoJSON(ctx, http.MethodGet, "/api/v1/users/me", nil, &me); err == nil && me.Login != "" {
+				fmt.Printf("Logged in to %s as %s; token saved in %s\n", c.cfg.APIEndpoint, me.Login, where)
+			} else {
+				fmt.Printf("Logged in to %s; token saved in %s\n", c.cfg.APIEndpoint, where)
+			}
+			if os.Getenv("AUTOCODIT_AUTH_TOKEN") != "" {
+				fmt.Fprintf(os.Stderr, "%s AUTOCODIT_AUTH_TOKEN is set and takes precedence over the saved token\n", colorize(colorYellow, "warning:"))
+			}
+			audit("auth.login", map[string]any{"endpoint": c.cfg.APIEndpoint})
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&noBrowser, "no-browser", false, "print the URL instead of opening a browser")
+	cmd.Flags().StringVar(&scope, "scope", "", "space-separated scopes to request (default: the server's)")
+	cmd.Flags().BoolVar(&withToken, "with-token", false, "read a token from stdin instead of signing in")
+	return cmd
+}
+
+func cmdLogout(c *Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "logout",
+		Short: "Revoke and remove the saved token",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			token, refresh, _ := c.cfg.savedLogin()
+			if token == "" && refresh == "" {
+				fmt.Println("Not logged in.")
+				return nil
+			}
+			// Revoking is best effort: the token is removed locally either way.
+			ctx, cancel := context.WithTimeout(cmd.Context(), 10*time.Second)
+			defer cancel()
+			for _, t := range []string{refresh, token} {
+				if t == "" {
+					continue
+				}
+				if err := c.RevokeToken(ctx, oauthClientID, t); err != nil {
+					fmt.Fprintf(os.Stderr, "%s could not revoke the token on the server: %v\n", colorize(colorYellow, "warning:"), err)
+					break
+				}
+			}
+			if c.cfg.CredentialStore != storeFile {
+				if err := deleteKeyringCredentials(c.cfg.APIEndpoint); err != nil && (c.cfg.CredentialStore == storeKeyring || c.cfg.context != "") {
+					return err
+				}
+			}
+			if c.cfg.context != "" {
+				fmt.Printf("Logged out of %s (context %s)\n", c.cfg.APIEndpoint, c.cfg.context)
+				audit("auth.logout", map[string]any{"endpoint": c.cfg.APIEndpoint, "context": c.cfg.context})
+				return nil
+			}
+			for _, key := range []string{"auth_token", "refresh_token", "token_expires_at"} {
+				if err := setConfigKey("", key, nil); err != nil {
+					return err
+				}
+			}
+			fmt.Println("Logged out of", c.cfg.APIEndpoint)
+			if os.Getenv("AUTOCODIT_AUTH_TOKEN") != "" {
+				fmt.Fprintf(os.Stderr, "%s AUTOCODIT_AUTH_TOKEN is still set\n", colorize(colorYellow, "warning:"))
+			}
+			audit("auth.logout", map[string]any{"endpoint": c.cfg.APIEndpoint})
+			return nil
+		},
+	}
+	return cmd
+}
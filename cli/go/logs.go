@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// logPollInterval is how often logs --follow asks for new lines.
+const logPollInterval = 2 * time.Second
+
+func (c *Client) taskLogsQuery(ctx context.Context, id string, q url.Values) ([]taskLog, error) {
+	path := "/api/v1/tasks/" + id + "/logs"
+	if len(q) > 0 {
+		path += "?" + q.Encode()
+	}
+	var logs []taskLog
+	err := c.DoJSON(ctx, http.MethodGet, path, nil, &logs)
+	return logs, err
+}
+
+func levelColor(level string) string {
+	switch strings.ToUpper(level) {
+	case "ERROR", "CRITICAL", "FATAL":
+		return colorize(colorRed, level)
+	case "WARN", "WARNING":
+		return colorize(colorYellow, level)
+	case "DEBUG", "TRACE":
+		return colorize(colorGray, level)
+	}
+	return level
+}
+
+func formatTaskLog(l taskLog) string {
+	prefix := ""
+	if l.Component != "" {
+		prefix = colorize(colorBlue, "["+l.Component+"]") + " "
+	}
+	return fmt.Sprintf("%s %s %s%s", colorize(colorGray, l.Timestamp.Local().Format("01-02 15:04:05")), padWidth(levelColor(l.Level), 5, false), prefix, redact(l.Message))
+}
+
+func printTaskLog(l taskLog) {
+	fmt.Println(formatTaskLog(l))
+}
+
+// logCursor remembers what has been printed so polls that overlap at the
+// last timestamp do not print a line twice.
+type logCursor struct {
+	last time.Time
+	seen map[string]bool
+}
+
+func (lc *logCursor) fresh(logs []taskLog) []taskLog {
+	var out []taskLog
+	for _, l := range logs {
+		if l.Timestamp.Before(lc.last) {
+			continue
+		}
+		key := l.Level + "\x00" + l.Component + "\x00" + l.Message
+		if l.Timestamp.After(lc.last) {
+			lc.last, lc.seen = l.Timestamp, map[string]bool{}
+		}
+		if lc.seen[key] {
+			continue
+		}
+		lc.seen[key] = true
+		out = append(out, l)
+	}
+	return out
+}
+
+func cmdLogs(c *Client) *cobra.Command {
+	var follow bool
+	var since string
+	var tail int
+	cmd := &cobra.Command{
+		Use:   "logs [id]",
+		Short: "Show what the agent has logged for a task",
+		Long: `logs prints a task's log lines, oldest first. --follow keeps polling for new
+lines until the task finishes or you press Ctrl-C.
+
+With -o json or yaml the lines are printed as one document; with --follow, or
+-o ndjson, as one JSON object per line.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id := args[0]
+			if tail < 0 {
+				return fmt.Errorf("--tail must not be negative")
+			}
+			q := url.Values{}
+			var from time.Time
+			if since != "" {
+				var err error
+				if from, err = parseSince(since); err != nil {
+					return err
+				}
+				q.Set("since", from.UTC().Format(time.RFC3339))
+			}
+			if tail > 0 {
+				q.Set("tail", strconv.Itoa(tail))
+			}
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+
+			logs, err := c.taskLogsQuery(ctx, id, q)
+			if err != nil {
+				return err
+			}
+			// Servers that ignore since and tail still get them applied.
+			kept := logs[:0]
+			for _, l := range logs {
+				if !l.Timestamp.Before(from) {
+					kept = append(kept, l)
+				}
+			}
+			logs = kept
+			if tail > 0 && len(logs) > tail {
+				logs = logs[len(logs)-tail:]
+			}
+
+			if !follow && outputFormat != "ndjson" {
+				if logs == nil {
+					logs = []taskLog{}
+				}
+				return printOutput(logs, func() {
+					for _, l := range logs {
+						printTaskLog(l)
+					}
+				})
+			}
+			show := func(logs []taskLog) error {
+				for _, l := range logs {
+					if !machineOutput() {
+						printTaskLog(l)
+						continue
+					}
+					if err := writeOutput(os.Stdout, "ndjson", l); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+			cursor := &logCursor{seen: map[string]bool{}}
+			if err := show(cursor.fresh(logs)); err != nil || !follow {
+				return err
+			}
+
+			tick := time.NewTicker(logPollInterval)
+			defer tick.Stop()
+			for {
+				var t Task
+				if err := c.DoJSON(ctx, http.MethodGet, "/api/v1/tasks/"+id, nil, &t); err != nil {
+					if ctx.Err() != nil {
+						return nil // Ctrl-C
+					}
+					return err
+				}
+				// A finished task still gets one more fetch so its last lines show.
+				done := isFinished(t.Status)
+				if !done {
+					select {
+					case <-ctx.Done():
+						return nil
+					case <-tick.C:
+					}
+				}
+				q := url.Values{}
+				if !cursor.last.IsZero() {
+					q.Set("since", cursor.last.UTC().Format(time.RFC3339Nano))
+				}
+				logs, err := c.taskLogsQuery(ctx, id, q)
+				if err != nil {
+					if ctx.Err() != nil {
+						return nil
+					}
+					return err
+				}
+				if err := show(cursor.fresh(logs)); err != nil {
+					return err
+				}
+				if done {
+					if !machineOutput() {
+						fmt.Fprintln(os.Stderr, colorize(colorGray, fmt.Sprintf("task %s %s", id, t.Status)))
+					}
+					return nil
+				}
+			}
+		},
+	}
+	cmd.Flags().BoolVarP(&follow, "follow", "f", false, "keep printing new lines until the task finishes")
+	cmd.Flags().StringVar(&since, "since", "", "only lines logged within this long (10m) or since a date or time")
+	cmd.Flags().IntVarP(&tail, "tail", "n", 0, "only the last N lines (0 for all)")
+	return cmd
+}
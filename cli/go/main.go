@@ -1,59 +1,288 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
+	"os/signal"
+	"reflect"
+	"strings"
 	"time"
 
+	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
 )
 
 type Config struct {
-	APIEndpoint string `mapstructure:"api_endpoint"`
-	AuthToken   string `mapstructure:"auth_token"`
-	DefaultRepo string `mapstructure:"default_repo"`
-}
+	APIEndpoint  string        `mapstructure:"api_endpoint"`
+	AuthToken    string        `mapstructure:"auth_token"`
+	DefaultRepo  string        `mapstructure:"default_repo"`
+	Preflight    bool          `mapstructure:"preflight"`
+	PreflightTTL time.Duration `mapstructure:"preflight_ttl"`
+	RepoCacheTTL time.Duration `mapstructure:"repo_cache_ttl"`
+	RecordRuns   bool          `mapstructure:"record_runs"`
+	RunsKeep     int           `mapstructure:"runs_keep"`
 
-type Client struct {
-	http  *http.Client
-	cfg   *Config
-	Token string
-}
+	TemplateMaxLength int      `mapstructure:"template_max_length"`
+	TemplateForbidden []string `mapstructure:"template_forbidden"`
+
+	// Set by login.
+	RefreshToken    string    `mapstructure:"refresh_token"`
+	TokenExpiresAt  time.Time `mapstructure:"token_expires_at"`
+	CredentialStore string    `mapstructure:"credential_store"`
+
+	// Machine identity for bots and CI (--as-service).
+	AsService           bool   `mapstructure:"as_service"`
+	ServiceClientID     string `mapstructure:"service_client_id"`
+	ServiceClientSecret string `mapstructure:"service_client_secret"`
+	ServiceScope        string `mapstructure:"service_scope"`
+
+	// MaxDescriptionLength applies when the server does not publish limits.
+	MaxDescriptionLength int `mapstructure:"max_description_length"`
+
+	BranchPattern  string                  `mapstructure:"branch_pattern"`
+	CommitTemplate string                  `mapstructure:"commit_template"`
+	PRTemplate     string                  `mapstructure:"pr_template"`
+	Repos          map[string]RepoSettings `mapstructure:"repos"`
+
+	// RepoGroups names sets of repositories for --repo-group, e.g.
+	// backend: [org/api, org/workers] or backend: org/api, org/workers.
+	RepoGroups map[string][]string `mapstructure:"repo_groups"`
+
+	// Issue labels mapped to task types and priorities on import.
+	LabelTypes      map[string]string `mapstructure:"label_types"`
+	LabelPriorities map[string]string `mapstructure:"label_priorities"`
+
+	NoRedact       bool     `mapstructure:"no_redact"`
+	RedactPatterns []string `mapstructure:"redact_patterns"`
+
+	RateLimitRPS   float64 `mapstructure:"rate_limit_rps"`
+	RateLimitBurst int     `mapstructure:"rate_limit_burst"`
+	// TokenPool rotates requests across several tokens in place of
+	// auth_token, where the server's terms allow pooling their limits.
+	TokenPool TokenPoolConfig `mapstructure:"token_pool"`
+
+	Retries       int           `mapstructure:"retries"`
+	RetryMaxDelay time.Duration `mapstructure:"retry_max_delay"`
+
+	WebURL string `mapstructure:"web_url"`
+
+	OrgDefaults     bool              `mapstructure:"org_defaults"`
+	DefaultModel    string            `mapstructure:"default_model"`
+	PolicyURL       string            `mapstructure:"policy_url"`
+	EndpointAliases map[string]string `mapstructure:"endpoint_aliases"`
+
+	UpdateURL string `mapstructure:"update_url"`
+	SSHTunnel string `mapstructure:"ssh_tunnel"`
+
+	DedupeThreshold float64 `mapstructure:"dedupe_threshold"`
+
+	FreezeWindows []FreezeWindow `mapstructure:"freeze_windows"`
+
+	TableMaxWidth  int `mapstructure:"table_max_width"`
+	MaxColumnWidth int `mapstructure:"max_column_width"`
+
+	AttachGitContext  bool     `mapstructure:"attach_git_context"`
+	GitContextExclude []string `mapstructure:"git_context_exclude"`
+
+	Hyperlinks   string `mapstructure:"hyperlinks"`
+	InlineImages string `mapstructure:"inline_images"`
+
+	Aliases map[string]string `mapstructure:"aliases"`
+
+	ReadOnly bool `mapstructure:"read_only"`
 
-type Task struct {
-	ID          string  `json:"id"`
-	Title       string  `json:"title"`
-	Description string  `json:"description"`
-	Repository  string  `json:"repository"`
-	ActionType  string  `json:"action_type"`
-	Status      string  `json:"status"`
-	Progress    float64 `json:"progress"`
+	WatchColumns []string `mapstructure:"watch_columns"`
+	WatchPaths   []string `mapstructure:"watch_paths"`
+	// PushMetricsURL is a Prometheus pushgateway watch reports finished
+	// tasks to.
+	PushMetricsURL string `mapstructure:"push_metrics_url"`
+
+	Profiles       map[string]Profile `mapstructure:"profiles"`
+	CurrentContext string             `mapstructure:"current_context"`
+	// context is the profile in use, from --context or current_context,
+	// and base the top-level settings it replaced.
+	context string
+	base    *Config
+
+	VerifyCommands   []string `mapstructure:"verify_commands"`
+	VerifyContainer  bool     `mapstructure:"verify_container"`
+	VerifyImage      string   `mapstructure:"verify_image"`
+	ContainerRuntime string   `mapstructure:"container_runtime"`
+
+	GitAuthorName  string `mapstructure:"git_author_name"`
+	GitAuthorEmail string `mapstructure:"git_author_email"`
+	GitHubHost     string `mapstructure:"github_host"`
+	GitHubUser     string `mapstructure:"github_user"`
+	Editor         string `mapstructure:"editor"`
+	Pager          string `mapstructure:"pager"`
 }
 
-type CreateTaskRequest struct {
-	Title       string                 `json:"title"`
-	Description string                 `json:"description"`
-	Repository  string                 `json:"repository"`
-	ActionType  string                 `json:"action_type"`
-	Priority    string                 `json:"priority"`
-	AgentConfig map[string]interface{} `json:"agent_config"`
+type Client struct {
+	*sdk.Client
+	cfg    *Config
+	tunnel *sshTunnel
+	pool   *sdk.TokenPool
 }
 
+type (
+	Task              = sdk.Task
+	CreateTaskRequest = sdk.CreateTaskRequest
+)
+
 func main() {
+	// State may be read while loading config, before cobra parses flags.
+	if os.Getenv("AUTOCODIT_EPHEMERAL_STATE") != "" || contains(os.Args[1:], "--ephemeral-state") {
+		if err := useEphemeralState(); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+	}
 	cfg := loadConfig()
-	c := &Client{http: &http.Client{Timeout: 30 * time.Second}, cfg: cfg, Token: cfg.AuthToken}
+	c := &Client{Client: sdk.New(cfg.APIEndpoint, cfg.AuthToken), cfg: cfg}
+	c.Limiter = sdk.NewRateLimiter(cfg.RateLimitRPS, cfg.RateLimitBurst)
+	c.OnOperation = printOperation
+	c.Retry = &sdk.RetryPolicy{Max: cfg.Retries, Base: 500 * time.Millisecond, MaxDelay: cfg.RetryMaxDelay, OnRetry: printRetry}
+	useHyperlinks = hyperlinksSupported(cfg.Hyperlinks)
+	preferredEditor = cfg.Editor
 
-	root := &cobra.Command{Use: "autocodit", Short: "AutoCodit Agent CLI"}
-	root.AddCommand(cmdCreate(c), cmdList(c), cmdGet(c), cmdCancel(c), cmdWatch(c))
+	var preflight, noRedact, repoContext, readOnly, asService bool
+	var tunnel, contextName string
+	var retries int
+	root := &cobra.Command{
+		Use:   "autocodit",
+		Short: "AutoCodit Agent CLI",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := redactions.configure(cfg.RedactPatterns, noRedact || cfg.NoRedact); err != nil {
+				return err
+			}
+			if cmd.Flags().Lookup("output") == cmd.Root().PersistentFlags().Lookup("output") {
+				if err := checkOutputFormat(outputFormat); err != nil {
+					return err
+				}
+			}
+			if err := checkCredentialStore(cfg.CredentialStore); err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("retries") {
+				if retries < 0 {
+					return fmt.Errorf("--retries must be 0 or more")
+				}
+				cfg.Retries, c.Retry.Max = retries, retries
+			}
+			if contextName == "" {
+				contextName = cfg.CurrentContext
+			}
+			if contextName != "" && cmd.Parent() != nil && cmd.Parent().Name() != "config" {
+				if err := cfg.useContext(contextName); err != nil {
+					return err
+				}
+				c.BaseURL, c.Token = cfg.APIEndpoint, cfg.AuthToken
+			}
+			if repoContext {
+				cfg.AttachGitContext = true
+			}
+			if !fullOutput {
+				columnCap = cfg.MaxColumnWidth
+			}
+			if tunnel == "" {
+				tunnel = cfg.SSHTunnel
+			}
+			if tunnel != "" {
+				t, err := newSSHTunnel(tunnel, cfg.APIEndpoint)
+				if err != nil {
+					return err
+				}
+				c.useTunnel(t)
+			}
+			if readOnly || cfg.ReadOnly {
+				cfg.ReadOnly = true
+				if err := checkReadOnly(cmd); err != nil {
+					return err
+				}
+				c.useReadOnly()
+			}
+			switch cmd.Name() {
+			case "help", "help-me", "completion", "verify-config-connectivity", "version", "update", "install-completion-and-man":
+				return nil
+			case cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+				// Completions must answer quickly: no prompts, refreshes, or backoff.
+				c.Retry.Max = 0
+				return nil
+			}
+			if cmd.Name() != "import-config" {
+				offerAdoption(cfg)
+			}
+			if cmd.Name() == "login" || cmd.Name() == "logout" {
+				return nil
+			}
+			if asService || cfg.AsService {
+				if err := c.useServiceIdentity(cmd.Context()); err != nil {
+					return err
+				}
+			} else if len(cfg.TokenPool.Tokens) > 0 {
+				if err := c.useTokenPool(); err != nil {
+					return err
+				}
+			} else {
+				c.refreshLogin(cmd.Context())
+			}
+			if preflight || cfg.Preflight {
+				return c.preflight(cmd.Context(), false)
+			}
+			return nil
+		},
+	}
+	root.PersistentFlags().BoolVar(&preflight, "preflight", false, "check token and endpoint before running the command")
+	root.PersistentFlags().IntVar(&retries, "retries", 3, "retry transient API failures this many times, with backoff (config: retries)")
+	root.PersistentFlags().StringVar(&contextName, "context", "", "use the named profile from the config instead of current_context")
+	root.PersistentFlags().BoolVar(&asService, "as-service", false, "authenticate as the configured machine identity (service_client_id) instead of your login")
+	root.PersistentFlags().StringVar(&tunnel, "ssh-tunnel", "", "reach the API through an SSH bastion (user@host)")
+	root.PersistentFlags().BoolVar(&repoContext, "repo-context", false, "attach local branch, HEAD, dirty files, and recent commits to created tasks")
+	root.PersistentFlags().BoolVar(&readOnly, "read-only", false, "refuse commands and requests that change tasks or settings")
+	root.PersistentFlags().Bool("ephemeral-state", false, "keep caches, drafts, history, and other local state in a temporary directory removed on exit")
+	root.PersistentFlags().StringVarP(&outputFormat, "output", "o", "table", "table|json|yaml, or ndjson for list")
+	root.PersistentFlags().BoolVar(&fullOutput, "full", false, "don't truncate table columns; json, yaml, and ndjson output never is")
+	root.PersistentFlags().BoolVar(&noRedact, "no-redact", false, "print secrets found in logs, diffs, and events as-is")
+	root.AddCommand(cmdCreate(c), cmdList(c), cmdGet(c), cmdCancel(c), cmdRetry(c), cmdWatch(c), cmdVerifyConnectivity(c),
+		cmdApply(c), cmdStats(c), cmdDrafts(c), cmdEdit(c),
+		cmdExec(c), cmdPortForward(c), cmdRules(c), cmdWebhooks(c),
+		cmdTokens(c), cmdRuns(), cmdTemplate(c), cmdBranch(c), cmdOrg(),
+		cmdSnapshotAPI(c), cmdInstall(), cmdUpdate(c), cmdVersion(),
+		cmdDuplicateFinder(c), cmdBenchmark(c), cmdBudget(c), cmdAlias(c), cmdMetrics(c), cmdDiff(c), cmdRollback(c), cmdGrep(c), cmdTimeline(c),
+		cmdAnnotateDiff(c), cmdImportConfig(c), cmdVerify(c), cmdCleanup(c), cmdLogs(c), cmdQuickfix(c), cmdArtifacts(c), cmdQueue(c), cmdLimits(c), cmdWatchFiles(c), cmdUI(c), cmdDelete(c), cmdTrash(c), cmdRestore(c), cmdCopy(c), cmdComment(c), cmdComments(c), cmdRunbook(c), cmdTestkit(c), cmdHelpMe(), cmdRepos(c), cmdAudit(c), cmdSchedule(c), cmdDaemon(c), cmdLogin(c), cmdLogout(c), cmdPR(c), cmdConfig(c))
+	c.registerCompletions(root)
+	c.resolveTaskIDArgs(root)
 
-	if err := root.Execute(); err != nil {
+	args, expanded, err := resolveAlias(root, cfg.Aliases, os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		cleanupState()
+		os.Exit(1)
+	}
+	if expanded {
+		root.SetArgs(args)
+	}
+	cmd, err := root.ExecuteC()
+	c.tunnel.close()
+	c.saveTokenPool()
+	redactions.report()
+	var exit exitCodeError
+	if err != nil && !errors.As(err, &exit) {
+		recordFailure(cmd, os.Args[1:], err)
+	}
+	cleanupState()
+	if err != nil {
+		if errors.As(err, &exit) {
+			os.Exit(exit.code)
+		}
 		fmt.Println("Error:", err)
+		offerHelp(err)
 		os.Exit(1)
 	}
 }
@@ -65,47 +294,180 @@ func loadConfig() *Config {
 	viper.AddConfigPath(".")
 	viper.SetEnvPrefix("AUTOCODIT")
 	viper.AutomaticEnv()
+	// Unmarshal only sees env vars for keys viper already knows about.
+	cfgType := reflect.TypeOf(Config{})
+	for i := 0; i < cfgType.NumField(); i++ {
+		if key := cfgType.Field(i).Tag.Get("mapstructure"); key != "" {
+			_ = viper.BindEnv(key)
+		}
+	}
 	viper.SetDefault("api_endpoint", "http://localhost:8000")
+	viper.SetDefault("preflight_ttl", 10*time.Minute)
+	viper.SetDefault("repo_cache_ttl", time.Hour)
+	viper.SetDefault("runs_keep", 50)
+	viper.SetDefault("template_max_length", 8000)
+	viper.SetDefault("rate_limit_rps", 10.0)
+	viper.SetDefault("rate_limit_burst", 20)
+	viper.SetDefault("retries", 3)
+	viper.SetDefault("retry_max_delay", 30*time.Second)
+	viper.SetDefault("org_defaults_ttl", time.Hour)
+	viper.SetDefault("dedupe_threshold", 0.6)
+	viper.SetDefault("table_max_width", 0)
+	viper.SetDefault("max_column_width", 0)
+	viper.SetDefault("hyperlinks", "auto")
+	viper.SetDefault("inline_images", "auto")
+	viper.SetDefault("credential_store", storeAuto)
+	viper.SetDefault("update_url", releaseOrigin)
 
-	_ = viper.ReadInConfig()
+	// Under testkit only the environment configures the CLI, so a config
+	// file can't point tests at a real deployment.
+	if os.Getenv("AUTOCODIT_TESTKIT_URL") == "" {
+		_ = viper.ReadInConfig()
+		mergeWorkspaceConfig()
+		if viper.GetBool("org_defaults") {
+			if cache, err := fetchOrgDefaults(false); err == nil || cache.Settings != nil {
+				layerOrgDefaults(cache.Settings)
+			}
+		}
+	}
 	cfg := &Config{}
-	_ = viper.Unmarshal(cfg)
+	_ = viper.Unmarshal(cfg, viper.DecodeHook(mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		mapstructure.StringToTimeHookFunc(time.RFC3339),
+	)))
+	if alias, ok := cfg.EndpointAliases[cfg.APIEndpoint]; ok {
+		cfg.APIEndpoint = alias
+	}
+	cfg.loadKeyringCredentials()
 	return cfg
 }
 
-func (c *Client) doJSON(ctx context.Context, method, path string, in any, out any) error {
-	var body io.Reader
-	if in != nil {
-		b, _ := json.Marshal(in)
-		body = bytes.NewReader(b)
+// agentConfig is the agent_config create sends: branch and commit
+// conventions for repo, plus the configured model and git identity.
+func (c *Client) agentConfig(repo, action, summary, baseBranch string) map[string]interface{} {
+	rs := c.cfg.repoSettings(repo)
+	ac := map[string]interface{}{
+		"branch_name":             renderConvention(rs.BranchPattern, conventionVars(action, summary, "", repo)),
+		"branch_pattern":          rs.BranchPattern,
+		"commit_message_template": rs.CommitTemplate,
 	}
-	req, _ := http.NewRequestWithContext(ctx, method, c.cfg.APIEndpoint+path, body)
-	req.Header.Set("Content-Type", "application/json")
-	if c.Token != "" {
-		req.Header.Set("Authorization", "Bearer "+c.Token)
+	if baseBranch != "" {
+		ac["base_branch"] = baseBranch
 	}
-	resp, err := c.http.Do(req)
-	if err != nil {
-		return err
+	if c.cfg.DefaultModel != "" {
+		ac["model"] = c.cfg.DefaultModel
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode >= 400 {
-		b, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("%s: %s", resp.Status, string(b))
+	if c.cfg.GitAuthorName != "" || c.cfg.GitAuthorEmail != "" {
+		ac["commit_author"] = map[string]string{"name": c.cfg.GitAuthorName, "email": c.cfg.GitAuthorEmail}
 	}
-	if out != nil {
-		return json.NewDecoder(resp.Body).Decode(out)
+	if c.cfg.GitHubUser != "" {
+		ac["github_user"] = c.cfg.GitHubUser
 	}
-	return nil
+	return ac
 }
 
 func cmdCreate(c *Client) *cobra.Command {
-	var repo, action, priority string
+	var repo, action, priority, baseBranch, sla, overrideFreeze string
+	var weight int
+	var edit, allowDup, yes, continueOnError bool
+	var manifest, repoGroup, agentConfigFile string
+	var sets []string
+	imp := importOptions{}
 	cmd := &cobra.Command{
-		Use:   "create [description]",
+		Use:   "create [description|-]",
 		Short: "Create a new task",
-		Args:  cobra.MinimumNArgs(1),
+		Long: `Create a new task. The description is the argument, or read from stdin when
+the argument is - or stdin is not a terminal:
+
+  cat spec.md | autocodit create --type refactor -
+
+With --from-project or --from-milestone it instead creates one task per open issue
+in a GitHub Project column or a milestone, using the GitHub CLI (gh). Issue labels
+pick the type and priority (see label_types and label_priorities); --type and
+--priority apply to the rest. Each task links back to its issue, and issues
+already imported are skipped when the import is run again:
+
+  autocodit create --from-project my-org/3 --column "Ready for agent" --dry-run
+
+With -f it creates every task in a YAML or JSON manifest: a list of tasks, or a
+mapping with "defaults" and "tasks". Each task takes title, description, repo,
+type, priority, base_branch, and agent_config; --repo, --type, and --priority fill
+in what neither sets. All entries are checked before any is created:
+
+  defaults: {repo: my-org/api, type: fix}
+  tasks:
+    - title: Fix token refresh race
+      description: Sessions are dropped when two requests refresh at once.
+    - {title: Document the retry policy, type: document}
+
+With --repo-group it creates the task once on each repository of a group from
+the config's repo_groups, with the same checks and reporting as -f:
+
+  repo_groups:
+    backend: [my-org/api, my-org/workers, my-org/billing]
+
+--agent-config and --set adjust the agent_config every task is sent with: the
+file's top-level keys replace the computed ones, then each --set key=value is
+applied, dotted keys reaching into nested settings. Values are read as JSON
+(numbers, true/false, objects) except for keys that hold strings:
+
+  autocodit create --agent-config agent.yaml --set max_steps=40 \
+    --set commit_author.email=bot@example.com "Fix the flaky upload test"`,
+		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			overrides, err := loadAgentConfigOverrides(agentConfigFile, sets)
+			if err != nil {
+				return err
+			}
+			imp.agentConfig = overrides
+			if manifest != "" {
+				if len(args) > 0 || edit || imp.project != "" || imp.milestone != "" {
+					return fmt.Errorf("-f takes no description, --edit, or --from-project/--from-milestone")
+				}
+				imp.action, imp.priority, imp.baseBranch, imp.overrideFreeze, imp.yes = action, priority, baseBranch, overrideFreeze, yes
+				imp.repo = repo
+				return c.createFromManifest(cmd.Context(), manifest, imp, continueOnError)
+			}
+			if imp.project != "" || imp.milestone != "" {
+				if len(args) > 0 || edit {
+					return fmt.Errorf("--from-project and --from-milestone take no description or --edit")
+				}
+				if imp.project != "" && imp.milestone != "" {
+					return fmt.Errorf("use one of --from-project and --from-milestone")
+				}
+				imp.repo, imp.action, imp.priority = repo, action, priority
+				if imp.repo == "" {
+					imp.repo = c.cfg.DefaultRepo
+				}
+				imp.baseBranch, imp.overrideFreeze, imp.yes = baseBranch, overrideFreeze, yes
+				return c.importTasks(cmd.Context(), imp)
+			}
+			if (len(args) == 0 && !edit && stdinPiped()) || (len(args) == 1 && args[0] == "-") {
+				if edit {
+					return fmt.Errorf("--edit needs a terminal; drop it to read the description from stdin")
+				}
+				desc, err := readStdinDescription()
+				if err != nil {
+					return err
+				}
+				args = []string{desc}
+			}
+			if len(args) == 0 && !edit {
+				return fmt.Errorf("description required (or use --edit, or pipe it on stdin)")
+			}
+			if repoGroup != "" {
+				if edit || cmd.Flags().Changed("weight") || sla != "" {
+					return fmt.Errorf("--repo-group does not take --edit, --weight, or --sla")
+				}
+				repos, err := c.repoGroup(repoGroup)
+				if err != nil {
+					return err
+				}
+				imp.action, imp.priority, imp.baseBranch, imp.overrideFreeze, imp.yes = action, priority, baseBranch, overrideFreeze, yes
+				m := repoGroupManifest(repos, CreateTaskRequest{Title: fmt.Sprintf("%s task", action), Description: args[0], ActionType: action, Priority: priority}, baseBranch)
+				return c.createTasks(cmd.Context(), m, "repo group "+repoGroup, "create.repo-group", imp, continueOnError)
+			}
 			if repo == "" {
 				repo = c.cfg.DefaultRepo
 			}
@@ -113,59 +475,99 @@ func cmdCreate(c *Client) *cobra.Command {
 				return fmt.Errorf("--repo or default_repo required")
 			}
 			req := CreateTaskRequest{
-				Title:       fmt.Sprintf("%s task", action),
-				Description: args[0],
-				Repository:  repo,
-				ActionType:  action,
-				Priority:    priority,
+				Title:      fmt.Sprintf("%s task", action),
+				Repository: repo,
+				ActionType: action,
+				Priority:   priority,
 			}
-			var task Task
-			if err := c.doJSON(cmd.Context(), http.MethodPost, "/api/v1/tasks", &req, &task); err != nil {
+			if len(args) > 0 {
+				req.Description = args[0]
+			}
+			if cmd.Flags().Changed("weight") {
+				if weight < 0 || weight > 100 {
+					return fmt.Errorf("--weight must be between 0 and 100")
+				}
+				req.Weight = &weight
+			}
+			if sla != "" {
+				d, err := parseDuration(sla)
+				if err != nil || d <= 0 {
+					return fmt.Errorf("invalid --sla %q", sla)
+				}
+				req.SLASeconds = int64(d / time.Second)
+			}
+			summary := req.Description
+			if summary == "" {
+				summary = req.Title
+			}
+			if req.AgentConfig, err = overrides.apply(c.agentConfig(repo, action, summary, baseBranch)); err != nil {
 				return err
 			}
-			fmt.Println("Task created:", task.ID)
-			return nil
-		},
-	}
-	cmd.Flags().StringVarP(&repo, "repo", "r", "", "owner/repo")
-	cmd.Flags().StringVarP(&action, "type", "t", "plan", "plan|apply|fix|review|test|refactor|document|optimize")
-	cmd.Flags().StringVarP(&priority, "priority", "p", "normal", "low|normal|high|urgent")
-	return cmd
-}
-
-func cmdList(c *Client) *cobra.Command {
-	cmd := &cobra.Command{
-		Use:   "list",
-		Short: "List tasks",
-		RunE: func(cmd *cobra.Command, args []string) error {
-			var resp struct{ Items []Task }
-			if err := c.doJSON(cmd.Context(), http.MethodGet, "/api/v1/tasks", nil, &resp); err != nil {
+			if b, ok := req.AgentConfig["base_branch"].(string); ok {
+				baseBranch = b
+			}
+			if err := c.checkCreateTarget(cmd.Context(), repo, baseBranch); err != nil {
 				return err
 			}
-			for _, t := range resp.Items {
-				fmt.Printf("%s %-10s %-6.1f%% %s\n", t.ID, t.Status, t.Progress*100, t.Title)
+			if err := c.confirmTarget(repo, yes); err != nil {
+				return err
 			}
-			return nil
-		},
-	}
-	return cmd
-}
-
-func cmdGet(c *Client) *cobra.Command {
-	cmd := &cobra.Command{
-		Use:   "get [id]",
-		Short: "Get a task",
-		Args:  cobra.ExactArgs(1),
-		RunE: func(cmd *cobra.Command, args []string) error {
-			var t Task
-			if err := c.doJSON(cmd.Context(), http.MethodGet, "/api/v1/tasks/"+args[0], nil, &t); err != nil {
+			if err := c.checkFreeze(&req, overrideFreeze); err != nil {
 				return err
 			}
-			out, _ := json.MarshalIndent(t, "", "  ")
-			fmt.Println(string(out))
-			return nil
+			if err := c.checkBudget(cmd.Context()); err != nil {
+				return err
+			}
+			c.attachGitContext(&req)
+			if edit {
+				d, err := newDraft("create", "", &req)
+				if err != nil {
+					return err
+				}
+				text, err := d.edit(req.Description)
+				if err != nil {
+					return err
+				}
+				return c.submitDraft(cmd.Context(), d, text, !allowDup)
+			}
+			if err := c.checkDescriptionSize(cmd.Context(), req.Description); err != nil {
+				return err
+			}
+			if !allowDup {
+				if handled, err := c.dedupe(cmd.Context(), req); handled || err != nil {
+					return err
+				}
+			}
+			var task Task
+			if err := c.createTask(cmd.Context(), req, &task); err != nil {
+				return tooLargeError(err, req.Description)
+			}
+			return printOutput(task, func() { fmt.Println("Task created:", task.ID) })
 		},
 	}
+	cmd.Flags().StringVarP(&repo, "repo", "r", "", "owner/repo")
+	cmd.Flags().StringVarP(&action, "type", "t", "plan", "plan|apply|fix|review|test|refactor|document|optimize")
+	cmd.Flags().StringVarP(&priority, "priority", "p", "normal", "low|normal|high|urgent")
+	cmd.Flags().BoolVarP(&edit, "edit", "e", false, "compose the description in $EDITOR")
+	cmd.Flags().StringVar(&baseBranch, "base-branch", "", "branch the agent starts from (default: repository default branch)")
+	cmd.Flags().IntVar(&weight, "weight", 0, "scheduling weight 0-100 for weighted fair queuing")
+	cmd.Flags().StringVar(&sla, "sla", "", "target completion time, e.g. 4h or 2d")
+	cmd.Flags().StringVar(&overrideFreeze, "override-freeze", "", "create during a freeze window, recording this reason")
+	cmd.Flags().BoolVar(&allowDup, "allow-duplicate", false, "skip the check for similar open tasks")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "create without confirming a repository other than the current checkout or a critical one")
+	cmd.Flags().StringVar(&imp.project, "from-project", "", "create tasks from a GitHub Project's cards (owner/number)")
+	cmd.Flags().StringVar(&imp.column, "column", "", "with --from-project, the column (field value) to import")
+	cmd.Flags().StringVar(&imp.field, "field", "Status", "with --from-project, the single-select field --column refers to")
+	cmd.Flags().StringVar(&imp.milestone, "from-milestone", "", "create tasks from the open issues in a milestone of --repo (title or number)")
+	cmd.Flags().IntVar(&imp.limit, "limit", 0, "import at most this many issues")
+	cmd.Flags().BoolVar(&imp.dryRun, "dry-run", false, "preview the tasks an import or manifest would create")
+	cmd.Flags().StringVarP(&manifest, "file", "f", "", "create the tasks in a YAML or JSON manifest (- for stdin)")
+	cmd.Flags().BoolVar(&continueOnError, "continue-on-error", false, "with -f or --repo-group, keep creating after a task fails")
+	cmd.Flags().StringVar(&repoGroup, "repo-group", "", "create the task on every repository of this repo_groups entry")
+	cmd.Flags().StringVar(&agentConfigFile, "agent-config", "", "YAML or JSON file of agent_config settings, replacing the computed ones by key")
+	cmd.Flags().StringArrayVar(&sets, "set", nil, "agent_config key=value, repeatable; dotted keys reach nested settings, values are read as JSON")
+	cmd.MarkFlagsMutuallyExclusive("repo", "repo-group")
+	cmd.MarkFlagsMutuallyExclusive("file", "repo-group")
 	return cmd
 }
 
@@ -176,32 +578,159 @@ func cmdCancel(c *Client) *cobra.Command {
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			var out map[string]any
-			return c.doJSON(cmd.Context(), http.MethodPost, "/api/v1/tasks/"+args[0]+"/cancel", nil, &out)
+			return c.DoJSON(cmd.Context(), http.MethodPost, "/api/v1/tasks/"+args[0]+"/cancel", nil, &out)
 		},
 	}
 	return cmd
 }
 
+func isFinished(status string) bool {
+	return status == "completed" || status == "failed" || status == "cancelled"
+}
+
+// waitTask polls until the task finishes. Connection failures are retried,
+// and after a sleep, network change, or outage the task is re-synced at once
+// with the events missed in between replayed from the last cursor.
+func (c *Client) waitTask(ctx context.Context, id string, onUpdate func(Task)) (Task, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	wake := newWakeDetector().watch(ctx)
+	events := c.followEvents(ctx, id)
+	var lost string
+	var t Task
+	for {
+		var cur Task
+		if err := c.DoJSON(ctx, http.MethodGet, "/api/v1/tasks/"+id, nil, &cur); err != nil {
+			if ctx.Err() != nil || !transientError(err) || t.ID == "" {
+				return t, err
+			}
+			if lost == "" {
+				lost = time.Now().Format("15:04:05")
+				fmt.Fprintf(os.Stderr, "\n%s\n", colorize(colorYellow, "connection lost, retrying: "+err.Error()))
+			}
+		} else {
+			if lost != "" {
+				noteGap("reconnected after outage since "+lost, events.replay())
+				lost = ""
+			}
+			t = cur
+			onUpdate(t)
+			if isFinished(t.Status) {
+				return t, nil
+			}
+		}
+
+		// While the event stream is up it drives updates and polling is only
+		// a safety net; without it, poll every few seconds.
+		interval := 3 * time.Second
+		if events.live() && lost == "" {
+			interval = 30 * time.Second
+		}
+		timer := time.NewTimer(interval)
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return t, ctx.Err()
+			case reason := <-wake:
+				if lost == "" {
+					noteGap(reason, events.replay())
+				}
+				break wait
+			case ev := <-events.updates:
+				if lost != "" || !applyEvent(&t, ev) {
+					continue
+				}
+				if isFinished(t.Status) {
+					// Fetch the final state in full.
+					break wait
+				}
+				onUpdate(t)
+			case <-timer.C:
+				break wait
+			}
+		}
+		timer.Stop()
+	}
+}
+
 func cmdWatch(c *Client) *cobra.Command {
+	var record, noDaemon bool
+	var hooks watchHooks
+	var columns []string
+	var pushURL string
 	cmd := &cobra.Command{
 		Use:   "watch [id]",
 		Short: "Watch task progress",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			id := args[0]
-			for {
-				var t Task
-				if err := c.doJSON(cmd.Context(), http.MethodGet, "/api/v1/tasks/"+id, nil, &t); err != nil {
+			if len(columns) == 0 {
+				columns = c.cfg.WatchColumns
+			}
+			cols, err := parseProgressColumns(columns)
+			if err != nil {
+				return err
+			}
+			var rec *runRecorder
+			if record || c.cfg.RecordRuns {
+				var err error
+				if rec, err = openRunRecorder(args[0], c.cfg.RunsKeep); err != nil {
 					return err
 				}
-				fmt.Printf("\r%-10s %-8s %6.1f%% %-60s", t.ID, t.Status, t.Progress*100, t.Title)
-				if t.Status == "completed" || t.Status == "failed" || t.Status == "cancelled" {
-					fmt.Println()
-					return nil
+			}
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+			var last, previous, emitted string
+			onUpdate := func(t Task) {
+				rec.observe(t)
+				c.hookChanged(hooks, t, last)
+				if t.Status != last {
+					previous, last = last, t.Status
+				}
+				if machineOutput() {
+					// One record per change rather than per poll.
+					if key := fmt.Sprint(t.Status, t.Progress, t.UpdatedAt, t.Metrics); key != emitted {
+						emitted = key
+						_ = outputStream(t)
+					}
+				} else {
+					printProgressColumns(t, cols)
+				}
+			}
+			var t Task
+			handled := false
+			if !noDaemon {
+				t, handled, err = c.watchViaDaemon(ctx, args[0], onUpdate)
+			}
+			if !handled {
+				t, err = c.waitTask(ctx, args[0], onUpdate)
+			}
+			rec.close(err)
+			if !machineOutput() {
+				fmt.Println()
+			}
+			if err != nil {
+				return err
+			}
+			if pushURL == "" {
+				pushURL = c.cfg.PushMetricsURL
+			}
+			if pushURL != "" && isFinished(t.Status) {
+				// Metrics are best effort: a gateway outage must not fail the job.
+				if err := pushTaskMetrics(cmd.Context(), pushURL, t); err != nil {
+					fmt.Fprintln(os.Stderr, "Warning: pushing metrics:", err)
 				}
-				time.Sleep(3 * time.Second)
 			}
+			return c.hookFinished(hooks, t, previous)
 		},
 	}
+	cmd.Flags().BoolVar(&record, "record", false, "save the observed timeline to ~/.autocodit/runs/<id>.jsonl")
+	cmd.Flags().BoolVar(&noDaemon, "no-daemon", false, "watch directly even when autocodit daemon is running")
+	cmd.Flags().StringVar(&pushURL, "push-metrics", "", "push duration, result, cost, and retries to this Prometheus pushgateway when the task finishes")
+	cmd.Flags().StringVar(&hooks.onComplete, "on-complete", "", "shell command to run when the task completes")
+	cmd.Flags().StringVar(&hooks.onFail, "on-fail", "", "shell command to run when the task fails")
+	cmd.Flags().StringVar(&hooks.onChange, "on-change", "", "shell command to run on every status change")
+	cmd.Flags().StringSliceVar(&columns, "columns", nil, "comma-separated columns to show: "+strings.Join(progressColumnNames(), ",")+" (default status,progress,title)")
 	return cmd
 }
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// manifestTask is one entry of a create -f manifest. Fields it leaves out
+// come from the manifest's defaults, then from the command's flags.
+type manifestTask struct {
+	Title       string         `yaml:"title" json:"title"`
+	Description string         `yaml:"description" json:"description"`
+	Repo        string         `yaml:"repo" json:"repo"`
+	Type        string         `yaml:"type" json:"type"`
+	Priority    string         `yaml:"priority" json:"priority"`
+	BaseBranch  string         `yaml:"base_branch" json:"base_branch"`
+	AgentConfig map[string]any `yaml:"agent_config" json:"agent_config"`
+}
+
+// taskManifest is the file create -f reads: either this mapping or just
+// the list of tasks.
+type taskManifest struct {
+	Defaults manifestTask   `yaml:"defaults" json:"defaults"`
+	Tasks    []manifestTask `yaml:"tasks" json:"tasks"`
+}
+
+// readManifest parses path ("-" for stdin) as JSON when it looks like JSON
+// and as YAML otherwise. Unknown fields are errors, so a misspelled key
+// doesn't silently drop a setting.
+func readManifest(path string) (taskManifest, error) {
+	var m taskManifest
+	var b []byte
+	var err error
+	if path == "-" {
+		b, err = io.ReadAll(os.Stdin)
+	} else {
+		b, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return m, err
+	}
+	trimmed := bytes.TrimSpace(b)
+	var probe any
+	if strings.EqualFold(filepath.Ext(path), ".json") || (len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[')) {
+		if err = json.Unmarshal(b, &probe); err == nil {
+			dec := json.NewDecoder(bytes.NewReader(b))
+			dec.DisallowUnknownFields()
+			if _, list := probe.([]any); list {
+				err = dec.Decode(&m.Tasks)
+			} else {
+				err = dec.Decode(&m)
+			}
+		}
+	} else if err = yaml.Unmarshal(b, &probe); err == nil && probe != nil {
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		dec.KnownFields(true)
+		if _, list := probe.([]any); list {
+			err = dec.Decode(&m.Tasks)
+		} else {
+			err = dec.Decode(&m)
+		}
+	}
+	if err != nil {
+		return m, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(m.Tasks) == 0 {
+		return m, fmt.Errorf("%s: no tasks", path)
+	}
+	return m, nil
+}
+
+func validPriority(p string) bool {
+	_, ok := priorityRank[p]
+	return ok
+}
+
+func pick(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// manifestResult is the outcome of one manifest entry.
+type manifestResult struct {
+	Index      int    `json:"index"`
+	Title      string `json:"title"`
+	Repository string `json:"repository"`
+	Status     string `json:"status"` // created, failed, or skipped
+	Task       string `json:"task,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+func (c *Client) createFromManifest(ctx context.Context, path string, o importOptions, continueOnError bool) error {
+	m, err := readManifest(path)
+	if err != nil {
+		return err
+	}
+	return c.createTasks(ctx, m, path, "create.manifest", o, continueOnError)
+}
+
+// createTasks validates every task in m before creating any, then creates
+// them in order. A failure stops the run unless continueOnError is set;
+// either way each entry's outcome is reported. source names where the tasks
+// came from in messages and the audit event.
+func (c *Client) createTasks(ctx context.Context, m taskManifest, source, event string, o importOptions, continueOnError bool) error {
+	d := m.Defaults
+	reqs := make([]CreateTaskRequest, len(m.Tasks))
+	branches := make([]string, len(m.Tasks))
+	var invalid []string
+	for i, t := range m.Tasks {
+		n := i + 1
+		req := CreateTaskRequest{
+			Title:       pick(t.Title, firstLine(t.Description)),
+			Description: t.Description,
+			Repository:  pick(t.Repo, d.Repo, o.repo, c.cfg.DefaultRepo),
+			ActionType:  pick(t.Type, d.Type, o.action),
+			Priority:    pick(t.Priority, d.Priority, o.priority),
+		}
+		branches[i] = pick(t.BaseBranch, d.BaseBranch, o.baseBranch)
+		switch {
+		case req.Title == "":
+			invalid = append(invalid, fmt.Sprintf("task %d: title or description required", n))
+		case req.Repository == "":
+			invalid = append(invalid, fmt.Sprintf("task %d (%s): repo required (set it, defaults.repo, --repo, or default_repo)", n, req.Title))
+		case !contains(actionTypes, req.ActionType):
+			invalid = append(invalid, fmt.Sprintf("task %d (%s): type %q is not one of %s", n, req.Title, req.ActionType, strings.Join(actionTypes, ", ")))
+		case !validPriority(req.Priority):
+			invalid = append(invalid, fmt.Sprintf("task %d (%s): priority %q is not one of low, normal, high, urgent", n, req.Title, req.Priority))
+		}
+		req.AgentConfig = c.agentConfig(req.Repository, req.ActionType, req.Title, branches[i])
+		for _, extra := range []map[string]any{d.AgentConfig, t.AgentConfig} {
+			for k, v := range extra {
+				req.AgentConfig[k] = v
+			}
+		}
+		if ac, err := o.agentConfig.apply(req.AgentConfig); err != nil {
+			invalid = append(invalid, fmt.Sprintf("task %d (%s): %v", i+1, req.Title, err))
+		} else {
+			req.AgentConfig = ac
+			if b, ok := ac["base_branch"].(string); ok {
+				branches[i] = b
+			}
+		}
+		reqs[i] = req
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("%s: %d invalid task(s), nothing created:\n  %s", source, len(invalid), strings.Join(invalid, "\n  "))
+	}
+
+	if machineOutput() && o.dryRun {
+		return printOutput(reqs, func() {})
+	}
+	w := os.Stdout
+	if machineOutput() {
+		w = os.Stderr
+	}
+	fmt.Fprintf(w, "%d task(s) in %s:\n", len(reqs), source)
+	tbl := newTable(w, c.tableMaxWidth(), column{header: "#", right: true}, column{header: "REPO"},
+		column{header: "TYPE"}, column{header: "PRIORITY"}, column{header: "TITLE", flex: true})
+	for i, req := range reqs {
+		tbl.add(strconv.Itoa(i+1), req.Repository, req.ActionType, req.Priority, redact(req.Title))
+	}
+	tbl.render()
+	if o.dryRun {
+		return nil
+	}
+
+	// Target checks run once per repository and base branch, and all of them
+	// before anything is created.
+	targetErr := map[string]error{}
+	var targets []string
+	for i, req := range reqs {
+		key := req.Repository + "\x00" + branches[i]
+		if _, ok := targetErr[key]; !ok {
+			targetErr[key] = nil
+			targets = append(targets, key)
+		}
+	}
+	sort.Strings(targets)
+	confirmed := map[string]bool{}
+	for _, key := range targets {
+		repo, branch, _ := strings.Cut(key, "\x00")
+		if err := c.checkCreateTarget(ctx, repo, branch); err != nil {
+			if !continueOnError {
+				return err
+			}
+			targetErr[key] = err
+		}
+		if !confirmed[repo] {
+			if err := c.confirmTarget(repo, o.yes); err != nil {
+				return err
+			}
+			confirmed[repo] = true
+		}
+	}
+	for i := range reqs {
+		if err := c.checkFreeze(&reqs[i], o.overrideFreeze); err != nil {
+			return err
+		}
+	}
+	if err := c.checkBudget(ctx); err != nil {
+		return err
+	}
+	if !o.yes && !confirm(fmt.Sprintf("Create %d task(s)?", len(reqs))) {
+		return fmt.Errorf("aborted; pass --yes to create without asking")
+	}
+
+	results := make([]manifestResult, len(reqs))
+	count := map[string]int{}
+	stopped := false
+	for i, req := range reqs {
+		r := manifestResult{Index: i + 1, Title: req.Title, Repository: req.Repository, Status: "skipped"}
+		if !stopped {
+			err := targetErr[req.Repository+"\x00"+branches[i]]
+			if err == nil {
+				err = c.checkDescriptionSize(ctx, req.Description)
+			}
+			var t Task
+			if err == nil {
+				err = tooLargeError(c.createTask(ctx, req, &t), req.Description)
+			}
+			if err != nil {
+				r.Status, r.Error = "failed", err.Error()
+				stopped = !continueOnError
+			} else {
+				r.Status, r.Task = "created", t.ID
+			}
+		}
+		results[i] = r
+		count[r.Status]++
+		if machineOutput() {
+			continue
+		}
+		switch r.Status {
+		case "created":
+			fmt.Printf("%3d %s %s %s\n", r.Index, colorize(colorGreen, "created"), c.taskLink(r.Task), redact(r.Title))
+		case "failed":
+			fmt.Printf("%3d %s %s: %s\n", r.Index, colorize(colorRed, "failed "), redact(r.Title), r.Error)
+		}
+	}
+	audit(event, map[string]any{"source": source, "created": count["created"], "failed": count["failed"], "skipped": count["skipped"]})
+	if machineOutput() {
+		if err := printOutput(results, func() {}); err != nil {
+			return err
+		}
+	}
+	switch {
+	case count["skipped"] > 0:
+		return fmt.Errorf("stopped at the first failure: %d created, %d failed, %d not attempted (--continue-on-error goes on past failures)",
+			count["created"], count["failed"], count["skipped"])
+	case count["failed"] > 0:
+		return fmt.Errorf("%d created, %d failed", count["created"], count["failed"])
+	}
+	if !machineOutput() {
+		fmt.Printf("Created %d task(s)\n", count["created"])
+	}
+	return nil
+}
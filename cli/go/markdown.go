@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// markdownWriter renders markdown for the terminal as it is written. Output
+// is produced a block at a time: paragraphs when they end, code lines as
+// they arrive, tables once their last row is seen. Close flushes the rest.
+type markdownWriter struct {
+	w      io.Writer
+	width  int
+	indent string
+
+	partial []byte
+	para    []string
+	table   []string
+	code    bool
+	lang    string
+	blank   bool
+}
+
+func newMarkdownWriter(w io.Writer, width int, indent string) *markdownWriter {
+	return &markdownWriter{w: w, width: width, indent: indent, blank: true}
+}
+
+// renderMarkdown is a convenience for rendering a complete document.
+func renderMarkdown(w io.Writer, s string, width int, indent string) {
+	md := newMarkdownWriter(w, width, indent)
+	io.WriteString(md, s)
+	md.Close()
+}
+
+func (m *markdownWriter) Write(p []byte) (int, error) {
+	m.partial = append(m.partial, p...)
+	for {
+		i := bytes.IndexByte(m.partial, '\n')
+		if i < 0 {
+			break
+		}
+		m.line(strings.TrimRight(string(m.partial[:i]), "\r"))
+		m.partial = m.partial[i+1:]
+	}
+	return len(p), nil
+}
+
+func (m *markdownWriter) Close() error {
+	if len(m.partial) > 0 {
+		m.line(string(m.partial))
+		m.partial = nil
+	}
+	m.flushPara()
+	m.flushTable()
+	return nil
+}
+
+var (
+	mdHeading = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*$`)
+	mdList    = regexp.MustCompile(`^(\s*)([-*+]|\d+[.)])\s+(.*)$`)
+	mdRule    = regexp.MustCompile(`^\s*([-*_])(\s*([-*_])){2,}\s*$`)
+	mdCode    = regexp.MustCompile("`([^`]+)`")
+	mdLink    = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	mdBold    = regexp.MustCompile(`\*\*([^*]+)\*\*|__([^_]+)__`)
+	mdItalic  = regexp.MustCompile(`\*([^*\s][^*]*)\*|\b_([^_]+)_\b`)
+)
+
+func (m *markdownWriter) println(s string) {
+	fmt.Fprintln(m.w, strings.TrimRight(m.indent+s, " "))
+	m.blank = s == ""
+}
+
+func (m *markdownWriter) line(l string) {
+	trimmed := strings.TrimSpace(l)
+	if m.code {
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			m.code = false
+			return
+		}
+		m.println(colorize(colorGray, "│ ") + highlightCode(m.lang, strings.ReplaceAll(l, "\t", "    ")))
+		return
+	}
+	if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+		m.flushPara()
+		m.flushTable()
+		m.code = true
+		m.lang = strings.ToLower(strings.TrimSpace(trimmed[3:]))
+		return
+	}
+	if strings.HasPrefix(trimmed, "|") {
+		m.flushPara()
+		m.table = append(m.table, trimmed)
+		return
+	}
+	m.flushTable()
+
+	switch {
+	case trimmed == "":
+		m.flushPara()
+		if !m.blank {
+			m.println("")
+		}
+	case mdHeading.MatchString(trimmed):
+		m.flushPara()
+		g := mdHeading.FindStringSubmatch(trimmed)
+		text := inlineMarkdown(g[2])
+		if len(g[1]) <= 2 {
+			text = strings.ToUpper(text)
+		}
+		if !m.blank {
+			m.println("")
+		}
+		m.println(colorize("1;4", text))
+	case mdRule.MatchString(trimmed):
+		m.flushPara()
+		m.println(colorize(colorGray, strings.Repeat("─", m.width-len(m.indent))))
+	case mdList.MatchString(l):
+		m.flushPara()
+		g := mdList.FindStringSubmatch(l)
+		bullet := "•"
+		if unicode.IsDigit(rune(g[2][0])) {
+			bullet = g[2]
+		}
+		lead := strings.Repeat(" ", len(g[1])) + bullet + " "
+		m.wrapped(lead, strings.Repeat(" ", displayWidth(lead)), inlineMarkdown(g[3]))
+	case strings.HasPrefix(trimmed, ">"):
+		m.flushPara()
+		bar := colorize(colorGray, "│ ")
+		m.wrapped(bar, bar, inlineMarkdown(strings.TrimSpace(strings.TrimPrefix(trimmed, ">"))))
+	default:
+		m.para = append(m.para, trimmed)
+	}
+}
+
+func (m *markdownWriter) wrapped(first, rest, text string) {
+	w := m.width - len(m.indent) - displayWidth(first)
+	if w < 20 {
+		w = 20
+	}
+	for i, l := range wrap(text, w) {
+		if i == 0 {
+			m.println(first + l)
+		} else {
+			m.println(rest + l)
+		}
+	}
+}
+
+func (m *markdownWriter) flushPara() {
+	if len(m.para) == 0 {
+		return
+	}
+	m.wrapped("", "", inlineMarkdown(strings.Join(m.para, " ")))
+	m.para = nil
+}
+
+func (m *markdownWriter) flushTable() {
+	if len(m.table) == 0 {
+		return
+	}
+	var rows [][]string
+	for _, l := range m.table {
+		cells := strings.Split(strings.Trim(l, "|"), "|")
+		sep := true
+		for i := range cells {
+			cells[i] = strings.TrimSpace(cells[i])
+			if strings.Trim(cells[i], ":-") != "" {
+				sep = false
+			}
+		}
+		if !sep {
+			rows = append(rows, cells)
+		}
+	}
+	m.table = nil
+	if len(rows) == 0 {
+		return
+	}
+	var buf bytes.Buffer
+	cols := make([]column, len(rows[0]))
+	for i, h := range rows[0] {
+		cols[i] = column{header: colorize("1", inlineMarkdown(h))}
+	}
+	tbl := newTable(&buf, m.width-len(m.indent), cols...)
+	for _, r := range rows[1:] {
+		for i := range r {
+			r[i] = inlineMarkdown(r[i])
+		}
+		tbl.add(r...)
+	}
+	tbl.render()
+	for _, l := range strings.Split(strings.TrimRight(buf.String(), "\n"), "\n") {
+		m.println(l)
+	}
+}
+
+// inlineMarkdown styles code spans, links, bold, and italic. Code spans and
+// links are swapped out first so their contents (and URLs) are left alone.
+func inlineMarkdown(s string) string {
+	var spans []string
+	hold := func(rendered string) string {
+		spans = append(spans, rendered)
+		return fmt.Sprintf("\x00%d\x00", len(spans)-1)
+	}
+	s = mdCode.ReplaceAllStringFunc(s, func(c string) string {
+		return hold(colorize("36", c[1:len(c)-1]))
+	})
+	s = mdLink.ReplaceAllStringFunc(s, func(l string) string {
+		g := mdLink.FindStringSubmatch(l)
+		if useHyperlinks {
+			return hold(hyperlink(g[2], colorize("4", g[1])))
+		}
+		return hold(colorize("4", g[1]) + " (" + g[2] + ")")
+	})
+	s = mdBold.ReplaceAllStringFunc(s, func(b string) string {
+		return colorize("1", b[2:len(b)-2])
+	})
+	s = mdItalic.ReplaceAllStringFunc(s, func(b string) string {
+		return colorize("3", b[1:len(b)-1])
+	})
+	for i, c := range spans {
+		s = strings.Replace(s, fmt.Sprintf("\x00%d\x00", i), c, 1)
+	}
+	return s
+}
+
+type codeSyntax struct {
+	comment  string
+	keywords map[string]bool
+}
+
+func keywordSet(words string) map[string]bool {
+	set := map[string]bool{}
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+	return set
+}
+
+var codeSyntaxes = map[string]codeSyntax{
+	"go":     {"//", keywordSet("break case chan const continue default defer else fallthrough for func go goto if import interface map package range return select struct switch type var nil true false")},
+	"python": {"#", keywordSet("and as assert async await break class continue def del elif else except finally for from global if import in is lambda not or pass raise return try while with yield None True False")},
+	"js":     {"//", keywordSet("async await break case catch class const continue default delete do else export extends finally for function if import in instanceof let new return switch this throw try typeof var void while yield null undefined true false interface type")},
+	"sh":     {"#", keywordSet("if then else elif fi for while do done case esac in function return export local set")},
+	"yaml":   {"#", keywordSet("true false null yes no")},
+	"json":   {"", keywordSet("true false null")},
+}
+
+var codeAliases = map[string]string{
+	"golang": "go", "py": "python", "javascript": "js", "ts": "js", "typescript": "js", "tsx": "js", "jsx": "js",
+	"bash": "sh", "shell": "sh", "zsh": "sh", "console": "sh", "yml": "yaml",
+}
+
+// highlightCode colors comments, strings, numbers, and keywords for the
+// languages in codeSyntaxes; anything else is printed as-is.
+func highlightCode(lang, line string) string {
+	if a, ok := codeAliases[lang]; ok {
+		lang = a
+	}
+	syn, ok := codeSyntaxes[lang]
+	if !ok || !useColor {
+		return line
+	}
+	var b strings.Builder
+	rs := []rune(line)
+	for i := 0; i < len(rs); {
+		r := rs[i]
+		switch {
+		case syn.comment != "" && strings.HasPrefix(string(rs[i:]), syn.comment):
+			b.WriteString(colorize(colorGray, string(rs[i:])))
+			return b.String()
+		case r == '"' || r == '\'' || r == '`':
+			j := i + 1
+			for j < len(rs) && rs[j] != r {
+				if rs[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(rs) {
+				j = len(rs) - 1
+			}
+			b.WriteString(colorize(colorGreen, string(rs[i:j+1])))
+			i = j + 1
+		case unicode.IsLetter(r) || r == '_':
+			j := i
+			for j < len(rs) && (unicode.IsLetter(rs[j]) || unicode.IsDigit(rs[j]) || rs[j] == '_') {
+				j++
+			}
+			word := string(rs[i:j])
+			if syn.keywords[word] {
+				word = colorize("35", word)
+			}
+			b.WriteString(word)
+			i = j
+		case unicode.IsDigit(r):
+			j := i
+			for j < len(rs) && (unicode.IsDigit(rs[j]) || rs[j] == '.' || rs[j] == 'x') {
+				j++
+			}
+			b.WriteString(colorize("36", string(rs[i:j])))
+			i = j
+		default:
+			b.WriteRune(r)
+			i++
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+var (
+	latencyBuckets   = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+	queueWaitBuckets = []float64{5, 15, 30, 60, 120, 300, 600, 1800, 3600}
+)
+
+type histogram struct {
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+}
+
+func (h *histogram) observe(v float64) {
+	for i, b := range h.buckets {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+type apiKey struct{ method, route, code string }
+
+// metricSet holds what the CLI knows about agent activity, exposed in the
+// Prometheus text format by serveMetrics.
+type metricSet struct {
+	mu        sync.Mutex
+	tasks     map[string]float64
+	requests  map[apiKey]uint64
+	latency   map[apiKey]*histogram
+	queueWait *histogram
+	waited    map[string]bool
+	scrapes   uint64
+	scrapeErr uint64
+}
+
+func newMetricSet() *metricSet {
+	return &metricSet{
+		tasks:     map[string]float64{},
+		requests:  map[apiKey]uint64{},
+		latency:   map[apiKey]*histogram{},
+		queueWait: newHistogram(queueWaitBuckets),
+		waited:    map[string]bool{},
+	}
+}
+
+// apiRoute collapses IDs out of a request path so label cardinality stays
+// bounded: /api/v1/tasks/ab12/diff becomes /api/v1/tasks/{id}/diff.
+func apiRoute(p string) string {
+	parts := strings.Split(strings.Trim(p, "/"), "/")
+	for i := 0; i < len(parts); i++ {
+		switch parts[i] {
+		case "repositories":
+			if i+2 < len(parts) {
+				parts = append(parts[:i+2], parts[i+3:]...)
+			}
+			if i+1 < len(parts) {
+				parts[i+1] = "{repo}"
+				i++
+			}
+		case "tasks", "webhooks", "tokens", "drafts", "automations", "pulls", "runs":
+			if i+1 < len(parts) && parts[i+1] != "similar" {
+				parts[i+1] = "{id}"
+				i++
+			}
+		}
+	}
+	return "/" + strings.Join(parts, "/")
+}
+
+type instrumentedTransport struct {
+	next http.RoundTripper
+	m    *metricSet
+}
+
+func (t instrumentedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	code := "error"
+	if err == nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	k := apiKey{req.Method, apiRoute(req.URL.Path), code}
+	t.m.mu.Lock()
+	t.m.requests[k]++
+	lk := apiKey{method: k.method, route: k.route}
+	h := t.m.latency[lk]
+	if h == nil {
+		h = newHistogram(latencyBuckets)
+		t.m.latency[lk] = h
+	}
+	h.observe(time.Since(start).Seconds())
+	t.m.mu.Unlock()
+	return resp, err
+}
+
+// instrument records latency and status for every API request the client
+// makes from now on.
+func (c *Client) instrument(m *metricSet) {
+	next := c.HTTP.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	c.HTTP.Transport = instrumentedTransport{next: next, m: m}
+}
+
+// scrapeTasks refreshes the per-status task counts and records queue wait
+// for tasks seen starting for the first time.
+func (c *Client) scrapeTasks(ctx context.Context, m *metricSet, opts sdk.ListTasksOptions) error {
+	counts := map[string]float64{}
+	var waits []float64
+	var started []string
+	tasks, errc := c.ListTasks(opts).Stream(ctx)
+	for t := range tasks {
+		counts[t.Status]++
+		if t.StartedAt != nil && !t.CreatedAt.IsZero() {
+			started = append(started, t.ID)
+			waits = append(waits, t.StartedAt.Sub(t.CreatedAt).Seconds())
+		}
+	}
+	err := <-errc
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scrapes++
+	if err != nil {
+		m.scrapeErr++
+		return err
+	}
+	m.tasks = counts
+	for i, id := range started {
+		if !m.waited[id] {
+			m.waited[id] = true
+			m.queueWait.observe(waits[i])
+		}
+	}
+	return nil
+}
+
+func promLabels(kv ...string) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(kv); i += 2 {
+		if b.Len() > 0 {
+			b.WriteByte(',')
+		}
+		v := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(kv[i+1])
+		fmt.Fprintf(&b, `%s="%s"`, kv[i], v)
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "{" + b.String() + "}"
+}
+
+func writeHistogram(w io.Writer, name string, h *histogram, labels ...string) {
+	for i, b := range h.buckets {
+		fmt.Fprintf(w, "%s_bucket%s %d\n", name, promLabels(append(labels, "le", strconv.FormatFloat(b, 'g', -1, 64))...), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket%s %d\n", name, promLabels(append(labels, "le", "+Inf")...), h.count)
+	fmt.Fprintf(w, "%s_sum%s %g\n", name, promLabels(labels...), h.sum)
+	fmt.Fprintf(w, "%s_count%s %d\n", name, promLabels(labels...), h.count)
+}
+
+func (m *metricSet) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	fmt.Fprintln(w, "# HELP autocodit_tasks Tasks by status at the last scrape.")
+	fmt.Fprintln(w, "# TYPE autocodit_tasks gauge")
+	statuses := make([]string, 0, len(m.tasks))
+	for s := range m.tasks {
+		statuses = append(statuses, s)
+	}
+	sort.Strings(statuses)
+	for _, s := range statuses {
+		fmt.Fprintf(w, "autocodit_tasks%s %g\n", promLabels("status", s), m.tasks[s])
+	}
+
+	fmt.Fprintln(w, "# HELP autocodit_task_queue_wait_seconds Time from creation until a task started running.")
+	fmt.Fprintln(w, "# TYPE autocodit_task_queue_wait_seconds histogram")
+	writeHistogram(w, "autocodit_task_queue_wait_seconds", m.queueWait)
+
+	keys := make([]apiKey, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.code < b.code
+	})
+	fmt.Fprintln(w, "# HELP autocodit_api_requests_total API requests by route and status code; code=\"error\" means no response.")
+	fmt.Fprintln(w, "# TYPE autocodit_api_requests_total counter")
+	for _, k := range keys {
+		fmt.Fprintf(w, "autocodit_api_requests_total%s %d\n", promLabels("method", k.method, "route", k.route, "code", k.code), m.requests[k])
+	}
+
+	lkeys := make([]apiKey, 0, len(m.latency))
+	for k := range m.latency {
+		lkeys = append(lkeys, k)
+	}
+	sort.Slice(lkeys, func(i, j int) bool {
+		if lkeys[i].route != lkeys[j].route {
+			return lkeys[i].route < lkeys[j].route
+		}
+		return lkeys[i].method < lkeys[j].method
+	})
+	fmt.Fprintln(w, "# HELP autocodit_api_request_duration_seconds API request latency.")
+	fmt.Fprintln(w, "# TYPE autocodit_api_request_duration_seconds histogram")
+	for _, k := range lkeys {
+		writeHistogram(w, "autocodit_api_request_duration_seconds", m.latency[k], "method", k.method, "route", k.route)
+	}
+
+	fmt.Fprintln(w, "# HELP autocodit_scrapes_total Task list refreshes, and how many failed.")
+	fmt.Fprintln(w, "# TYPE autocodit_scrapes_total counter")
+	fmt.Fprintf(w, "autocodit_scrapes_total%s %d\n", promLabels("result", "ok"), m.scrapes-m.scrapeErr)
+	fmt.Fprintf(w, "autocodit_scrapes_total%s %d\n", promLabels("result", "error"), m.scrapeErr)
+}
+
+func (m *metricSet) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(w)
+}
+
+// serveMetrics exposes m at addr/metrics until ctx is done.
+func serveMetrics(ctx context.Context, addr string, m *metricSet) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	fmt.Fprintf(os.Stderr, "Serving metrics on http://%s/metrics\n", ln.Addr())
+	if err := srv.Serve(ln); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func cmdMetrics(c *Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "metrics",
+		Short: "Export agent activity as Prometheus metrics",
+	}
+	var addr string
+	var interval time.Duration
+	var opts sdk.ListTasksOptions
+	serve := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve /metrics, refreshing task counts periodically",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if interval < time.Second {
+				return fmt.Errorf("--interval must be at least 1s")
+			}
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+			m := newMetricSet()
+			c.instrument(m)
+			if err := c.scrapeTasks(ctx, m, opts); err != nil {
+				return userScopeError(err, opts)
+			}
+			go func() {
+				tick := time.NewTicker(interval)
+				defer tick.Stop()
+				for {
+					select {
+					case <-ctx.Done():
+						return
+					case <-tick.C:
+						if err := c.scrapeTasks(ctx, m, opts); err != nil && ctx.Err() == nil {
+							fmt.Fprintln(os.Stderr, "Warning: refreshing tasks:", err)
+						}
+					}
+				}
+			}()
+			return serveMetrics(ctx, addr, m)
+		},
+	}
+	serve.Flags().StringVar(&addr, "addr", "127.0.0.1:9464", "listen address")
+	serve.Flags().DurationVar(&interval, "interval", 30*time.Second, "how often to refresh task counts")
+	serve.Flags().StringVarP(&opts.Repository, "repo", "r", "", "only tasks on this repository")
+	addUserScopeFlags(serve, &opts)
+	cmd.AddCommand(serve)
+	return cmd
+}
+
+// writeTaskMetrics writes one finished task's run metrics in the text
+// format. Its repository and type are left to the pushgateway grouping key.
+func writeTaskMetrics(w io.Writer, t Task) {
+	gauge := func(name, help string, v float64, labels ...string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s%s %g\n", name, help, name, name, promLabels(labels...), v)
+	}
+	start, end := t.CreatedAt, t.UpdatedAt
+	if t.StartedAt != nil {
+		start = *t.StartedAt
+	}
+	if t.CompletedAt != nil {
+		end = *t.CompletedAt
+	}
+	gauge("autocodit_task_duration_seconds", "How long the last task ran.", max(end.Sub(start).Seconds(), 0))
+	if t.StartedAt != nil {
+		gauge("autocodit_task_queue_wait_seconds", "How long the last task waited to start.", t.StartedAt.Sub(t.CreatedAt).Seconds())
+	}
+	fmt.Fprintln(w, "# HELP autocodit_task_result How the last task ended: 1 for its result, 0 for the others.")
+	fmt.Fprintln(w, "# TYPE autocodit_task_result gauge")
+	for _, s := range []string{"completed", "failed", "cancelled"} {
+		v := 0
+		if t.Status == s {
+			v = 1
+		}
+		fmt.Fprintf(w, "autocodit_task_result%s %d\n", promLabels("result", s), v)
+	}
+	gauge("autocodit_task_cost_dollars", "What the last task cost.", t.Cost)
+	gauge("autocodit_task_retries", "How many times the last task was retried.", float64(t.RetryCount))
+	gauge("autocodit_task_tokens", "Tokens the last task used.", float64(t.TokensUsed))
+}
+
+// pushTaskMetrics sends t's run metrics to a Prometheus pushgateway,
+// replacing the group for its repository and type, so dashboards see the
+// latest run of each.
+func pushTaskMetrics(ctx context.Context, gateway string, t Task) error {
+	// Label values with slashes have to be base64-encoded in the path.
+	path := "/metrics/job/autocodit/repository@base64/" + base64.RawURLEncoding.EncodeToString([]byte(t.Repository)) +
+		"/type/" + url.PathEscape(t.ActionType)
+	var body bytes.Buffer
+	writeTaskMetrics(&body, t)
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, strings.TrimRight(gateway, "/")+path, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("pushgateway: %s: %s", resp.Status, strings.TrimSpace(string(b)))
+	}
+	return nil
+}
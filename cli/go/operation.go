@@ -0,0 +1,45 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/term"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+// printOperation shows progress of server-side operations the SDK is
+// waiting on, on one rewritten line when stderr is a terminal.
+func printOperation(op sdk.Operation) {
+	line := fmt.Sprintf("Operation %s: %s", op.ID, op.Status)
+	if op.Progress > 0 {
+		line += fmt.Sprintf(" %.0f%%", op.Progress*100)
+	}
+	if op.Message != "" {
+		line += " " + redact(op.Message)
+	}
+	if !term.IsTerminal(int(os.Stderr.Fd())) {
+		if op.Done() {
+			fmt.Fprintln(os.Stderr, line)
+		}
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\r\x1b[K%s", truncateWidth(line, terminalWidth()))
+	if op.Done() {
+		fmt.Fprintln(os.Stderr)
+	}
+}
+
+// printRetry notes each retry of a failed request on stderr, so CI logs show
+// why a command took longer than usual.
+func printRetry(attempt int, delay time.Duration, err error) {
+	reason := err.Error()
+	var apiErr *sdk.APIError
+	if errors.As(err, &apiErr) {
+		reason = apiErr.Status
+	}
+	fmt.Fprintln(os.Stderr, colorize(colorGray, fmt.Sprintf("%s; retry %d in %s", redact(reason), attempt, delay.Round(100*time.Millisecond))))
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+const orgDefaultsPath = "/api/v1/cli/defaults"
+
+// Keys an organization or a repository's autocodit.yaml may set: how output
+// looks and what new tasks default to. Anything that decides where requests
+// and credentials go, or what runs on this machine, is left to the user's
+// own config and environment.
+var sharedConfigAllowed = map[string]bool{
+	"default_repo": true, "default_model": true, "policy_url": true,
+	"preflight": true, "preflight_ttl": true, "repo_cache_ttl": true, "org_defaults_ttl": true,
+	"record_runs": true, "runs_keep": true,
+	"template_max_length": true, "template_forbidden": true, "max_description_length": true,
+	"branch_pattern": true, "commit_template": true, "pr_template": true,
+	"repo_groups": true, "label_types": true, "label_priorities": true,
+	"redact_patterns": true, "dedupe_threshold": true, "freeze_windows": true, "read_only": true,
+	"rate_limit_rps": true, "rate_limit_burst": true, "retries": true, "retry_max_delay": true,
+	"table_max_width": true, "max_column_width": true, "hyperlinks": true, "inline_images": true,
+	"attach_git_context": true, "git_context_exclude": true,
+	"watch_columns": true, "watch_paths": true,
+}
+
+type orgDefaultsCache struct {
+	Endpoint  string         `json:"endpoint"`
+	FetchedAt time.Time      `json:"fetched_at"`
+	Settings  map[string]any `json:"settings"`
+}
+
+// fetchOrgDefaults returns the organization's CLI defaults for the
+// configured endpoint, using the cached copy while it is fresh. A stale
+// cache is still returned when the server cannot be reached.
+func fetchOrgDefaults(refresh bool) (orgDefaultsCache, error) {
+	endpoint := viper.GetString("api_endpoint")
+	var cache orgDefaultsCache
+	_ = readState("cache/org-defaults.json", &cache)
+	if cache.Endpoint != endpoint {
+		cache = orgDefaultsCache{Endpoint: endpoint}
+	}
+	if !refresh && time.Since(cache.FetchedAt) < viper.GetDuration("org_defaults_ttl") {
+		return cache, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var settings map[string]any
+	client := sdk.New(endpoint, viper.GetString("auth_token"))
+	if err := client.DoJSON(ctx, http.MethodGet, orgDefaultsPath, nil, &settings); err != nil {
+		return cache, err
+	}
+	cache.FetchedAt, cache.Settings = time.Now(), settings
+	_ = writeState("cache/org-defaults.json", cache)
+	return cache, nil
+}
+
+// layerOrgDefaults installs org settings as viper defaults, so they sit
+// above built-in defaults but below the user's config file and env vars.
+func layerOrgDefaults(settings map[string]any) {
+	for k, v := range settings {
+		if sharedConfigAllowed[k] {
+			viper.SetDefault(k, v)
+		}
+	}
+}
+
+func cmdOrg() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "org",
+		Short: "Organization-level settings",
+	}
+	var refresh bool
+	defaults := &cobra.Command{
+		Use:   "defaults",
+		Short: "Show organization defaults layered under your config",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cache, err := fetchOrgDefaults(refresh)
+			if err != nil && cache.FetchedAt.IsZero() {
+				return err
+			}
+			if err != nil {
+				fmt.Println("Warning: using cached defaults:", err)
+			}
+			if !viper.GetBool("org_defaults") {
+				fmt.Println("Note: org_defaults is disabled; these settings are not applied.")
+			}
+			fmt.Printf("Fetched from %s at %s\n", cache.Endpoint, cache.FetchedAt.Local().Format(time.DateTime))
+			keys := make([]string, 0, len(cache.Settings))
+			for k := range cache.Settings {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				note := ""
+				switch {
+				case !sharedConfigAllowed[k]:
+					note = " (ignored)"
+				case viper.InConfig(k):
+					note = " (overridden by your config)"
+				}
+				fmt.Printf("  %-24s %v%s\n", k, cache.Settings[k], note)
+			}
+			return nil
+		},
+	}
+	defaults.Flags().BoolVar(&refresh, "refresh", false, "fetch from the server even if the cache is fresh")
+	cmd.AddCommand(defaults)
+	return cmd
+}
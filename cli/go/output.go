@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// outputFormat is the global --output flag. Commands with their own
+// --output (timeline) shadow it.
+var outputFormat string
+
+// machineOutput reports whether the user asked for json or yaml rather than
+// the human-readable table.
+func machineOutput() bool {
+	switch outputFormat {
+	case "json", "ndjson", "yaml":
+		return true
+	}
+	return false
+}
+
+func checkOutputFormat(format string) error {
+	switch format {
+	case "", "table", "text", "json", "ndjson", "yaml":
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q (table, json, ndjson, yaml)", format)
+}
+
+// toYAML renders v through its JSON encoding, so field names and omitempty
+// follow the json tags the API types already carry.
+func toYAML(v any) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	var blockStyle func(n *yaml.Node)
+	blockStyle = func(n *yaml.Node) {
+		n.Style = 0
+		for _, c := range n.Content {
+			blockStyle(c)
+		}
+	}
+	blockStyle(&doc)
+	return yaml.Marshal(&doc)
+}
+
+// writeOutput writes v as json or yaml, redacting secrets.
+func writeOutput(w io.Writer, format string, v any) error {
+	var b []byte
+	var err error
+	switch format {
+	case "json":
+		b, err = json.MarshalIndent(v, "", "  ")
+		b = append(b, '\n')
+	case "ndjson":
+		b, err = json.Marshal(v)
+		b = append(b, '\n')
+	case "yaml":
+		b, err = toYAML(v)
+	default:
+		return checkOutputFormat(format)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, redact(string(b)))
+	return err
+}
+
+// printOutput renders v in the selected format, calling table for the
+// human-readable one.
+func printOutput(v any, table func()) error {
+	switch outputFormat {
+	case "", "table", "text":
+		table()
+		return nil
+	}
+	return writeOutput(os.Stdout, outputFormat, v)
+}
+
+// outputStream writes one value per update for commands that keep
+// printing, such as watch: a JSON object per line, or YAML documents.
+func outputStream(v any) error {
+	switch outputFormat {
+	case "json", "ndjson":
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		fmt.Println(redact(string(b)))
+		return nil
+	case "yaml":
+		b, err := toYAML(v)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("---\n%s", redact(string(b)))
+		return nil
+	}
+	return checkOutputFormat(outputFormat)
+}
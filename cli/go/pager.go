@@ -0,0 +1,35 @@
+package main
+
+import (
+	"io"
+	"os"
+	"os/exec"
+
+	"golang.org/x/term"
+)
+
+// withPager runs fn with its output piped through pager when stdout is a
+// terminal, and straight to stdout otherwise or when no pager is set.
+func withPager(pager string, fn func(io.Writer)) error {
+	if pager == "" || !term.IsTerminal(int(os.Stdout.Fd())) {
+		fn(os.Stdout)
+		return nil
+	}
+	cmd := exec.Command("sh", "-c", pager)
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if os.Getenv("LESS") == "" {
+		// less needs -R to pass colors through.
+		cmd.Env = append(os.Environ(), "LESS=FRX")
+	}
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		fn(os.Stdout)
+		return nil
+	}
+	fn(in)
+	in.Close()
+	return cmd.Wait()
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/spf13/cobra"
+)
+
+type portMapping struct {
+	local, remote int
+}
+
+func parsePortMapping(s string) (portMapping, error) {
+	l, r, ok := strings.Cut(s, ":")
+	if !ok {
+		r = l
+	}
+	local, err1 := strconv.Atoi(l)
+	remote, err2 := strconv.Atoi(r)
+	if err1 != nil || err2 != nil || local < 0 || local > 65535 || remote < 1 || remote > 65535 {
+		return portMapping{}, fmt.Errorf("invalid port mapping %q, want LOCAL:REMOTE", s)
+	}
+	return portMapping{local, remote}, nil
+}
+
+func cmdPortForward(c *Client) *cobra.Command {
+	var address string
+	cmd := &cobra.Command{
+		Use:   "port-forward [id] LOCAL:REMOTE...",
+		Short: "Forward local ports to a task's sandbox",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id := args[0]
+			var mappings []portMapping
+			for _, a := range args[1:] {
+				m, err := parsePortMapping(a)
+				if err != nil {
+					return err
+				}
+				mappings = append(mappings, m)
+			}
+
+			ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer cancel()
+			var wg sync.WaitGroup
+			for _, m := range mappings {
+				ln, err := net.Listen("tcp", net.JoinHostPort(address, strconv.Itoa(m.local)))
+				if err != nil {
+					return err
+				}
+				fmt.Printf("Forwarding %s -> sandbox:%d\n", ln.Addr(), m.remote)
+				wg.Add(1)
+				go func(ln net.Listener, remote int) {
+					defer wg.Done()
+					c.servePortForward(ctx, ln, id, remote)
+				}(ln, m.remote)
+			}
+
+			reason := c.waitTaskEnd(ctx, id)
+			cancel()
+			wg.Wait()
+			fmt.Println("Port forwarding stopped:", reason)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&address, "address", "127.0.0.1", "local address to listen on")
+	return cmd
+}
+
+// waitTaskEnd blocks until the task finishes or ctx is cancelled and
+// returns a short description of which happened.
+func (c *Client) waitTaskEnd(ctx context.Context, id string) string {
+	tick := time.NewTicker(5 * time.Second)
+	defer tick.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return "interrupted"
+		case <-tick.C:
+			var t Task
+			if err := c.DoJSON(ctx, http.MethodGet, "/api/v1/tasks/"+id, nil, &t); err == nil && isFinished(t.Status) {
+				return "task " + t.Status
+			}
+		}
+	}
+}
+
+func (c *Client) servePortForward(ctx context.Context, ln net.Listener, id string, remote int) {
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		local, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer local.Close()
+			q := url.Values{"port": {strconv.Itoa(remote)}}
+			conn, err := c.dialWS(ctx, "/api/v1/tasks/"+id+"/port-forward", q)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "port-forward:", err)
+				return
+			}
+			defer conn.Close()
+			go func() {
+				<-ctx.Done()
+				conn.Close()
+			}()
+			pipeWS(local, conn)
+		}()
+	}
+}
+
+// pipeWS copies bytes between a TCP connection and binary WebSocket frames
+// until either side closes.
+func pipeWS(local net.Conn, conn *websocket.Conn) {
+	done := make(chan struct{}, 2)
+	go func() {
+		buf := make([]byte, 32*1024)
+		for {
+			n, err := local.Read(buf)
+			if n > 0 && conn.WriteMessage(websocket.BinaryMessage, buf[:n]) != nil {
+				break
+			}
+			if err != nil {
+				_ = conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				break
+			}
+		}
+		done <- struct{}{}
+	}()
+	go func() {
+		for {
+			typ, r, err := conn.NextReader()
+			if err != nil {
+				break
+			}
+			if typ == websocket.BinaryMessage {
+				if _, err := io.Copy(local, r); err != nil {
+					break
+				}
+			}
+		}
+		done <- struct{}{}
+	}()
+	<-done
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+)
+
+// The generated part of a PR body sits between these markers; text outside
+// them is the reviewers' and survives --update.
+const (
+	prBeginMarker = "<!-- autocodit:begin -->"
+	prEndMarker   = "<!-- autocodit:end -->"
+)
+
+const defaultPRTemplate = `## Summary
+
+{{.Task.Title}}
+{{with .Task.Description}}
+{{.}}
+{{end}}
+## Plan
+{{range .Steps}}
+{{.Step}}. {{.Summary}}{{else}}
+_No steps recorded._{{end}}
+
+## Changes
+{{with .Task.DiffStats}}
+{{.FilesChanged}} file(s) changed, +{{.Additions}} -{{.Deletions}}{{else}}
+_No diff stats yet._{{end}}
+{{with .Task.Metrics}}{{.Commits}} commit(s), {{.FilesModified}} file(s) touched by the agent.
+{{end}}
+## Tests
+{{range .Checks}}
+- {{if .Passed}}✅{{else}}❌{{end}} ` + "`{{.Command}}`" + ` {{.Status}}{{with .Summary}}: {{.}}{{end}}{{else}}
+_No verification reported._{{end}}
+{{with .Task.Metrics}}{{if or .TestsPassed .TestsFailed}}
+{{.TestsPassed}} passed, {{.TestsFailed}} failed{{with .TestsSkipped}}, {{.}} skipped{{end}}.
+{{end}}{{end}}
+---
+Generated by AutoCodit from task {{if .TaskURL}}[{{.Task.ID}}]({{.TaskURL}}){{else}}{{.Task.ID}}{{end}}.
+`
+
+// prCheck is a verification claim as PR templates see it.
+type prCheck struct {
+	Command string
+	Status  string
+	Summary string
+	Passed  bool
+}
+
+// prData is what PR templates are executed with.
+type prData struct {
+	Task    Task
+	Steps   []checkpoint
+	Checks  []prCheck
+	TaskURL string
+}
+
+type pullRequest struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+// prTemplate loads the template for repo: --template, then the repo's and
+// the top-level pr_template. Relative paths are taken from ~/.autocodit.
+func (cfg *Config) prTemplate(repo, flag string) (string, error) {
+	p := flag
+	if p == "" {
+		p = cfg.Repos[strings.ToLower(repo)].PRTemplate
+	}
+	if p == "" {
+		p = cfg.PRTemplate
+	}
+	if p == "" {
+		return defaultPRTemplate, nil
+	}
+	if !filepath.IsAbs(p) && flag == "" {
+		if dir, err := configDir(); err == nil {
+			p = filepath.Join(dir, p)
+		}
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return "", fmt.Errorf("pr template: %w", err)
+	}
+	return string(b), nil
+}
+
+func (c *Client) prData(ctx context.Context, t Task) (prData, error) {
+	d := prData{Task: t, TaskURL: c.taskURL(t.ID)}
+	// Tasks from before checkpoints existed just have no plan section.
+	d.Steps, _ = c.checkpoints(ctx, t.ID)
+	claims, err := c.verificationClaims(ctx, t.ID)
+	if err != nil {
+		return d, err
+	}
+	for _, cl := range claims {
+		d.Checks = append(d.Checks, prCheck{Command: cl.Command, Status: cl.Status, Summary: cl.Summary, Passed: cl.passed()})
+	}
+	return d, nil
+}
+
+func renderPRBody(text string, d prData) (string, error) {
+	tmpl, err := template.New("pr").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("pr template: %w", err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, d); err != nil {
+		return "", fmt.Errorf("pr template: %w", err)
+	}
+	return redact(strings.TrimSpace(b.String())), nil
+}
+
+// mergePRBody replaces the generated section of body, or appends one to
+// what the author wrote if it has none yet.
+func mergePRBody(body, generated string) string {
+	section := prBeginMarker + "\n" + generated + "\n" + prEndMarker
+	begin := strings.Index(body, prBeginMarker)
+	end := strings.Index(body, prEndMarker)
+	if begin < 0 || end < begin {
+		if strings.TrimSpace(body) == "" {
+			return section
+		}
+		return strings.TrimRight(body, "\n") + "\n\n" + section
+	}
+	return body[:begin] + section + body[end+len(prEndMarker):]
+}
+
+func cmdPR(c *Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pr",
+		Short: "Work with the pull requests tasks open",
+	}
+	cmd.AddCommand(cmdPRDescribe(c))
+	return cmd
+}
+
+func cmdPRDescribe(c *Client) *cobra.Command {
+	var update, keepTitle bool
+	var templatePath string
+	cmd := &cobra.Command{
+		Use:   "describe [task]",
+		Short: "Regenerate a task's PR title and body from its plan, diff stats, and test results",
+		Long: `describe renders the PR description for a task from a markdown text/template
+(pr_template in the config, per repo under repos.<owner/repo>, or --template) and
+prints it. With --update it replaces the generated section of the PR body, keeping
+anything reviewers wrote outside the autocodit markers (the first time, the section
+is appended to the existing body), and sets the title from commit_template.
+
+Templates see .Task, .Steps (checkpoints), .Checks (.Command, .Status, .Summary,
+.Passed), and .TaskURL.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if update && c.cfg.ReadOnly {
+				return readOnlyError("autocodit pr describe --update")
+			}
+			ctx := cmd.Context()
+			var t Task
+			if err := c.DoJSON(ctx, http.MethodGet, "/api/v1/tasks/"+args[0], nil, &t); err != nil {
+				return err
+			}
+			text, err := c.cfg.prTemplate(t.Repository, templatePath)
+			if err != nil {
+				return err
+			}
+			d, err := c.prData(ctx, t)
+			if err != nil {
+				return err
+			}
+			body, err := renderPRBody(text, d)
+			if err != nil {
+				return err
+			}
+			rs := c.cfg.repoSettings(t.Repository)
+			title := redact(renderConvention(rs.CommitTemplate, conventionVars(t.ActionType, t.Title, t.ID, t.Repository)))
+
+			if !update {
+				return printOutput(map[string]string{"title": title, "body": body}, func() {
+					fmt.Println(colorize("1", title))
+					fmt.Println()
+					fmt.Println(body)
+				})
+			}
+			if t.PRNumber == nil {
+				return fmt.Errorf("task %s has no pull request yet", t.ID)
+			}
+			path := fmt.Sprintf("/api/v1/repositories/%s/pulls/%d", t.Repository, *t.PRNumber)
+			var pr pullRequest
+			if err := c.DoJSON(ctx, http.MethodGet, path, nil, &pr); err != nil {
+				return err
+			}
+			patch := map[string]string{}
+			if merged := mergePRBody(pr.Body, body); merged != pr.Body {
+				patch["body"] = merged
+			}
+			if !keepTitle && title != pr.Title {
+				patch["title"] = title
+			}
+			if len(patch) == 0 {
+				fmt.Printf("%s is up to date\n", prLink(t.Repository, *t.PRNumber))
+				return nil
+			}
+			if err := c.DoJSON(ctx, http.MethodPatch, path, patch, nil); err != nil {
+				return err
+			}
+			audit("pr.describe", map[string]any{"task": t.ID, "repository": t.Repository, "pr": *t.PRNumber})
+			fmt.Printf("Updated %s\n", prLink(t.Repository, *t.PRNumber))
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&update, "update", false, "push the description to the PR instead of printing it")
+	cmd.Flags().BoolVar(&keepTitle, "keep-title", false, "leave the PR title alone")
+	cmd.Flags().StringVar(&templatePath, "template", "", "template file to use instead of pr_template")
+	return cmd
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type preflightCache map[string]time.Time
+
+func (c *Client) preflightKey() string {
+	sum := sha256.Sum256([]byte(c.cfg.APIEndpoint + "\x00" + c.Token))
+	return hex.EncodeToString(sum[:8])
+}
+
+// preflight verifies that the endpoint is reachable and the token is accepted.
+// Successful checks are cached per endpoint/token pair for preflight_ttl.
+func (c *Client) preflight(ctx context.Context, force bool) error {
+	cache := preflightCache{}
+	_ = readState("preflight.json", &cache)
+	key := c.preflightKey()
+	if !force && time.Since(cache[key]) < c.cfg.PreflightTTL {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := c.DoJSON(ctx, http.MethodGet, "/api/v1/health/", nil, nil); err != nil {
+		return fmt.Errorf("preflight: endpoint %s unreachable: %w", c.cfg.APIEndpoint, err)
+	}
+	if c.Token == "" {
+		return fmt.Errorf("preflight: no auth_token configured")
+	}
+	if err := c.DoJSON(ctx, http.MethodGet, "/api/v1/users/me", nil, nil); err != nil {
+		return fmt.Errorf("preflight: token rejected: %w", err)
+	}
+
+	now := time.Now()
+	_ = updateState("preflight.json", &cache, func() { cache[key] = now })
+	return nil
+}
+
+func cmdVerifyConnectivity(c *Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "verify-config-connectivity",
+		Short: "Check endpoint reachability and token validity",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := c.preflight(cmd.Context(), true); err != nil {
+				return err
+			}
+			fmt.Println("OK:", c.cfg.APIEndpoint)
+			if c.cfg.PolicyURL != "" {
+				fmt.Println("Usage policy:", c.cfg.PolicyURL)
+			}
+			return nil
+		},
+	}
+	return cmd
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+// Profile is a named deployment in the config's profiles section, also
+// called a context. Empty fields fall back to the top-level settings;
+// endpoint and token are accepted for api_endpoint and auth_token.
+type Profile struct {
+	APIEndpoint string `mapstructure:"api_endpoint"`
+	AuthToken   string `mapstructure:"auth_token"`
+	Endpoint    string `mapstructure:"endpoint"`
+	Token       string `mapstructure:"token"`
+	DefaultRepo string `mapstructure:"default_repo"`
+	WebURL      string `mapstructure:"web_url"`
+}
+
+func (p Profile) endpoint() string {
+	if p.APIEndpoint != "" {
+		return p.APIEndpoint
+	}
+	return p.Endpoint
+}
+
+// applyProfile overlays p on cfg. A login's tokens belong to the endpoint
+// they were issued by, so they are dropped when the endpoint changes and the
+// profile has none of its own; the keyring may hold a token for the new one.
+func (cfg *Config) applyProfile(p Profile) {
+	endpoint := cfg.APIEndpoint
+	if e := p.endpoint(); e != "" {
+		cfg.APIEndpoint = e
+		if alias, ok := cfg.EndpointAliases[cfg.APIEndpoint]; ok {
+			cfg.APIEndpoint = alias
+		}
+	}
+	token := p.AuthToken
+	if token == "" {
+		token = p.Token
+	}
+	switch {
+	case token != "":
+		cfg.AuthToken, cfg.RefreshToken, cfg.TokenExpiresAt = token, "", time.Time{}
+	case cfg.APIEndpoint != endpoint:
+		cfg.AuthToken, cfg.RefreshToken, cfg.TokenExpiresAt = "", "", time.Time{}
+		if cfg.CredentialStore != storeFile {
+			if cr, err := keyringCredentials(cfg.APIEndpoint); err == nil {
+				cfg.AuthToken, cfg.RefreshToken = cr.AuthToken, cr.RefreshToken
+			}
+		}
+	}
+	if p.DefaultRepo != "" {
+		cfg.DefaultRepo = p.DefaultRepo
+	}
+	if p.WebURL != "" {
+		cfg.WebURL = p.WebURL
+	}
+}
+
+func (cfg *Config) contextNames() []string {
+	names := make([]string, 0, len(cfg.Profiles))
+	for name := range cfg.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// useContext switches cfg to the named profile, keeping the top-level
+// settings for other profiles to fall back on.
+func (cfg *Config) useContext(name string) error {
+	p, ok := cfg.Profiles[strings.ToLower(name)]
+	if !ok {
+		return fmt.Errorf("no context %q in the config (have: %s)", name, strings.Join(cfg.contextNames(), ", "))
+	}
+	base := *cfg
+	cfg.applyProfile(p)
+	cfg.context, cfg.base = strings.ToLower(name), &base
+	return nil
+}
+
+// withProfile returns a client for p that shares this client's settings
+// otherwise. The SSH tunnel is not carried over, as it is tied to the
+// top-level endpoint.
+func (c *Client) withProfile(p Profile) *Client {
+	cfg := *c.cfg
+	if c.cfg.base != nil {
+		cfg = *c.cfg.base
+		cfg.ReadOnly = c.cfg.ReadOnly
+	}
+	cfg.applyProfile(p)
+	pc := &Client{Client: sdk.New(cfg.APIEndpoint, cfg.AuthToken), cfg: &cfg}
+	pc.Limiter = sdk.NewRateLimiter(cfg.RateLimitRPS, cfg.RateLimitBurst)
+	pc.OnOperation = c.OnOperation
+	pc.Retry = c.Retry
+	if cfg.ReadOnly {
+		pc.useReadOnly()
+	}
+	return pc
+}
+
+type profileClient struct {
+	name string
+	*Client
+}
+
+// profileClients returns a client per configured profile, led by the
+// top-level settings as "default" unless a profile already uses that name
+// or the same endpoint and token.
+func (c *Client) profileClients() []profileClient {
+	var out []profileClient
+	seen := map[string]bool{}
+	for _, name := range c.cfg.contextNames() {
+		pc := c.withProfile(c.cfg.Profiles[name])
+		seen[pc.cfg.APIEndpoint+"\x00"+pc.cfg.AuthToken] = true
+		out = append(out, profileClient{name: name, Client: pc})
+	}
+	top := c
+	if c.cfg.base != nil {
+		top = c.withProfile(Profile{})
+	}
+	if _, ok := c.cfg.Profiles["default"]; !ok && !seen[top.cfg.APIEndpoint+"\x00"+top.cfg.AuthToken] {
+		out = append([]profileClient{{name: "default", Client: top}}, out...)
+	}
+	return out
+}
+
+// profileTask is a task tagged with the profile it was listed from.
+type profileTask struct {
+	Profile  string `json:"profile"`
+	Endpoint string `json:"endpoint"`
+	Task
+	client *Client
+}
+
+// listAllProfiles lists tasks from every profile concurrently, newest
+// first. A profile that fails is reported on stderr and skipped; the error
+// is returned only when every profile failed.
+func (c *Client) listAllProfiles(ctx context.Context, opts sdk.ListTasksOptions, all bool) ([]profileTask, error) {
+	clients := c.profileClients()
+	var mu sync.Mutex
+	var tasks []profileTask
+	errs := make([]error, len(clients))
+	var wg sync.WaitGroup
+	for i, pc := range clients {
+		wg.Add(1)
+		go func(i int, pc profileClient) {
+			defer wg.Done()
+			add := func(t Task) {
+				mu.Lock()
+				tasks = append(tasks, profileTask{Profile: pc.name, Endpoint: pc.BaseURL, Task: t, client: pc.Client})
+				mu.Unlock()
+			}
+			pager := pc.ListTasks(opts)
+			if !all {
+				resp, err := pager.NextPage(ctx)
+				if err != nil {
+					errs[i] = userScopeError(err, opts)
+					return
+				}
+				for _, t := range resp.Items {
+					add(t)
+				}
+				return
+			}
+			stream, errc := pager.Stream(ctx)
+			for t := range stream {
+				add(t)
+			}
+			errs[i] = userScopeError(<-errc, opts)
+		}(i, pc)
+	}
+	wg.Wait()
+
+	failed := 0
+	for i, err := range errs {
+		if err != nil {
+			failed++
+			fmt.Fprintln(os.Stderr, colorize(colorYellow, fmt.Sprintf("warning: profile %s (%s): %v", clients[i].name, clients[i].BaseURL, err)))
+		}
+	}
+	if failed == len(clients) {
+		return nil, fmt.Errorf("no profile could be listed")
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		if !tasks[i].CreatedAt.Equal(tasks[j].CreatedAt) {
+			return tasks[i].CreatedAt.After(tasks[j].CreatedAt)
+		}
+		return tasks[i].Profile < tasks[j].Profile
+	})
+	return tasks, nil
+}
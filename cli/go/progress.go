@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+// progressColumn is one field of the line watch redraws on every update.
+type progressColumn struct {
+	name   string
+	render func(Task) string
+}
+
+var progressColumns = []progressColumn{
+	{"status", func(t Task) string { return fmt.Sprintf("%-8s", t.Status) }},
+	{"progress", func(t Task) string { return fmt.Sprintf("%6.1f%%", t.Progress*100) }},
+	{"files", func(t Task) string {
+		if t.Metrics == nil {
+			return fmt.Sprintf("%9s", "- files")
+		}
+		return fmt.Sprintf("%3d files", t.Metrics.FilesModified)
+	}},
+	{"tests", func(t Task) string { return padWidth(testsSummary(t.Metrics), 20, false) }},
+	{"commits", func(t Task) string {
+		if t.Metrics == nil {
+			return fmt.Sprintf("%10s", "- commits")
+		}
+		return fmt.Sprintf("%2d commits", t.Metrics.Commits)
+	}},
+	{"tokens", func(t Task) string { return fmt.Sprintf("%6s tok", compactCount(taskTokens(t))) }},
+	{"title", func(t Task) string { return progressTitle(t) + slaLabel(t) }},
+}
+
+// progressTitle fits the title in 60 cells, or max_column_width, so the line
+// can be redrawn in place; --full shows all of it.
+func progressTitle(t Task) string {
+	title := cellReplacer.Replace(redact(t.Title))
+	if fullOutput {
+		return title
+	}
+	w := 60
+	if columnCap > 0 {
+		w = columnCap
+	}
+	return padWidth(truncateCell(title, w), w, false)
+}
+
+var defaultProgressColumns = []string{"status", "progress", "title"}
+
+func progressColumnNames() []string {
+	var names []string
+	for _, pc := range progressColumns {
+		names = append(names, pc.name)
+	}
+	return names
+}
+
+func parseProgressColumns(names []string) ([]progressColumn, error) {
+	if len(names) == 0 {
+		names = defaultProgressColumns
+	}
+	var cols []progressColumn
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		found := false
+		for _, pc := range progressColumns {
+			if pc.name == name {
+				cols, found = append(cols, pc), true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown column %q (want %s)", name, strings.Join(progressColumnNames(), ", "))
+		}
+	}
+	return cols, nil
+}
+
+// taskTokens prefers the live tally in metrics over the task's total, which
+// some servers only fill in when the task finishes.
+func taskTokens(t Task) int {
+	if t.Metrics != nil && t.Metrics.TokensUsed > t.TokensUsed {
+		return t.Metrics.TokensUsed
+	}
+	return t.TokensUsed
+}
+
+func compactCount(n int) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1e6)
+	case n >= 10_000:
+		return fmt.Sprintf("%.0fk", float64(n)/1e3)
+	case n >= 1_000:
+		return fmt.Sprintf("%.1fk", float64(n)/1e3)
+	}
+	return fmt.Sprint(n)
+}
+
+func testsSummary(m *sdk.TaskMetrics) string {
+	if m == nil || m.TestsPassed+m.TestsFailed+m.TestsSkipped == 0 {
+		return "no tests yet"
+	}
+	s := colorize(colorGreen, fmt.Sprintf("%d passed", m.TestsPassed))
+	if m.TestsFailed > 0 {
+		s += ", " + colorize(colorRed, fmt.Sprintf("%d failed", m.TestsFailed))
+	}
+	if m.TestsSkipped > 0 {
+		s += fmt.Sprintf(", %d skipped", m.TestsSkipped)
+	}
+	return s
+}
+
+// metricsSummary is the one-line form get shows.
+func metricsSummary(t Task) string {
+	m := t.Metrics
+	if m == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d file(s) modified, %d commit(s), tests: %s", m.FilesModified, m.Commits, testsSummary(m))
+}
+
+func printProgressColumns(t Task, cols []progressColumn) {
+	parts := []string{fmt.Sprintf("%-10s", t.ID)}
+	for _, pc := range cols {
+		parts = append(parts, pc.render(t))
+	}
+	fmt.Print("\r" + strings.Join(parts, " "))
+}
+
+func printProgress(t Task) {
+	cols, _ := parseProgressColumns(nil)
+	printProgressColumns(t, cols)
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// importedIssue is a card or issue to turn into a task.
+type importedIssue struct {
+	Repo   string   `json:"repository,omitempty"`
+	Number int      `json:"number,omitempty"`
+	Title  string   `json:"title"`
+	Body   string   `json:"-"`
+	URL    string   `json:"url,omitempty"`
+	Labels []string `json:"labels,omitempty"`
+}
+
+// Labels that pick a task's type or priority, matched case-insensitively.
+// label_types and label_priorities in the config add to and override these.
+var (
+	defaultLabelTypes = map[string]string{
+		"bug": "fix", "fix": "fix", "documentation": "document", "docs": "document",
+		"refactor": "refactor", "refactoring": "refactor", "test": "test", "tests": "test",
+		"performance": "optimize", "perf": "optimize",
+	}
+	defaultLabelPriorities = map[string]string{
+		"urgent": "urgent", "critical": "urgent", "p0": "urgent",
+		"p1": "high", "high priority": "high", "priority: high": "high",
+		"p3": "low", "low priority": "low", "priority: low": "low",
+	}
+)
+
+// gh runs the GitHub CLI, which keeps the GitHub token; autocodit never
+// sees it.
+func gh(args ...string) ([]byte, error) {
+	if _, err := exec.LookPath("gh"); err != nil {
+		return nil, fmt.Errorf("importing from GitHub needs the GitHub CLI (gh), signed in with gh auth login")
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("gh", args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("gh %s: %s", args[0], strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+const projectItemsQuery = `query($owner: String!, $number: Int!, $field: String!, $after: String) {
+  repositoryOwner(login: $owner) {
+    ... on ProjectV2Owner {
+      projectV2(number: $number) {
+        title
+        items(first: 100, after: $after) {
+          pageInfo { hasNextPage endCursor }
+          nodes {
+            fieldValueByName(name: $field) {
+              ... on ProjectV2ItemFieldSingleSelectValue { name }
+            }
+            content {
+              ... on Issue {
+                number title body url state
+                repository { nameWithOwner }
+                labels(first: 20) { nodes { name } }
+              }
+              ... on DraftIssue { title body }
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+type projectItems struct {
+	Data struct {
+		RepositoryOwner *struct {
+			ProjectV2 *struct {
+				Title string `json:"title"`
+				Items struct {
+					PageInfo struct {
+						HasNextPage bool   `json:"hasNextPage"`
+						EndCursor   string `json:"endCursor"`
+					} `json:"pageInfo"`
+					Nodes []struct {
+						FieldValueByName *struct {
+							Name string `json:"name"`
+						} `json:"fieldValueByName"`
+						Content *struct {
+							Number     int    `json:"number"`
+							Title      string `json:"title"`
+							Body       string `json:"body"`
+							URL        string `json:"url"`
+							State      string `json:"state"`
+							Repository *struct {
+								NameWithOwner string `json:"nameWithOwner"`
+							} `json:"repository"`
+							Labels *struct {
+								Nodes []struct {
+									Name string `json:"name"`
+								} `json:"nodes"`
+							} `json:"labels"`
+						} `json:"content"`
+					} `json:"nodes"`
+				} `json:"items"`
+			} `json:"projectV2"`
+		} `json:"repositoryOwner"`
+	} `json:"data"`
+}
+
+// projectIssues returns the open issues and draft cards of project
+// owner/number whose field (a single-select such as Status) is column.
+// Pull requests on the board are skipped.
+func projectIssues(ref, field, column string) ([]importedIssue, error) {
+	owner, num, ok := strings.Cut(ref, "/")
+	number, err := strconv.Atoi(num)
+	if !ok || err != nil || owner == "" {
+		return nil, fmt.Errorf("invalid project %q (want owner/number, e.g. my-org/3)", ref)
+	}
+	var out []importedIssue
+	after := ""
+	for {
+		args := []string{"api", "graphql", "-f", "query=" + projectItemsQuery,
+			"-f", "owner=" + owner, "-F", fmt.Sprintf("number=%d", number), "-f", "field=" + field}
+		if after != "" {
+			args = append(args, "-f", "after="+after)
+		}
+		b, err := gh(args...)
+		if err != nil {
+			return nil, err
+		}
+		var resp projectItems
+		if err := json.Unmarshal(b, &resp); err != nil {
+			return nil, err
+		}
+		if resp.Data.RepositoryOwner == nil || resp.Data.RepositoryOwner.ProjectV2 == nil {
+			return nil, fmt.Errorf("project %s not found, or gh lacks the read:project scope (gh auth refresh -s read:project)", ref)
+		}
+		items := resp.Data.RepositoryOwner.ProjectV2.Items
+		for _, n := range items.Nodes {
+			if n.Content == nil || n.Content.Title == "" || n.FieldValueByName == nil || !strings.EqualFold(n.FieldValueByName.Name, column) {
+				continue
+			}
+			ct := n.Content
+			if ct.State != "" && ct.State != "OPEN" {
+				continue
+			}
+			is := importedIssue{Number: ct.Number, Title: ct.Title, Body: ct.Body, URL: ct.URL}
+			if ct.Repository != nil {
+				is.Repo = ct.Repository.NameWithOwner
+			}
+			if ct.Labels != nil {
+				for _, l := range ct.Labels.Nodes {
+					is.Labels = append(is.Labels, l.Name)
+				}
+			}
+			out = append(out, is)
+		}
+		if !items.PageInfo.HasNextPage {
+			return out, nil
+		}
+		after = items.PageInfo.EndCursor
+	}
+}
+
+// decodeJSONStream decodes consecutive JSON arrays, as gh api --paginate
+// prints them, into one slice.
+func decodeJSONStream[T any](b []byte) ([]T, error) {
+	var all []T
+	dec := json.NewDecoder(bytes.NewReader(b))
+	for dec.More() {
+		var page []T
+		if err := dec.Decode(&page); err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+	}
+	return all, nil
+}
+
+// milestoneIssues returns the open issues in repo's milestone, given by
+// title or number.
+func milestoneIssues(repo, milestone string) ([]importedIssue, error) {
+	b, err := gh("api", "--paginate", "repos/"+repo+"/milestones?state=all&per_page=100")
+	if err != nil {
+		return nil, err
+	}
+	milestones, err := decodeJSONStream[struct {
+		Number int    `json:"number"`
+		Title  string `json:"title"`
+	}](b)
+	if err != nil {
+		return nil, err
+	}
+	number := 0
+	for _, m := range milestones {
+		if strings.EqualFold(m.Title, milestone) || strconv.Itoa(m.Number) == milestone {
+			number = m.Number
+		}
+	}
+	if number == 0 {
+		return nil, fmt.Errorf("no milestone %q in %s", milestone, repo)
+	}
+	b, err = gh("api", "--paginate", fmt.Sprintf("repos/%s/issues?milestone=%d&state=open&per_page=100", repo, number))
+	if err != nil {
+		return nil, err
+	}
+	issues, err := decodeJSONStream[struct {
+		Number      int             `json:"number"`
+		Title       string          `json:"title"`
+		Body        string          `json:"body"`
+		URL         string          `json:"html_url"`
+		PullRequest json.RawMessage `json:"pull_request"`
+		Labels      []struct {
+			Name string `json:"name"`
+		} `json:"labels"`
+	}](b)
+	if err != nil {
+		return nil, err
+	}
+	var out []importedIssue
+	for _, is := range issues {
+		if is.PullRequest != nil {
+			continue
+		}
+		ii := importedIssue{Repo: repo, Number: is.Number, Title: is.Title, Body: is.Body, URL: is.URL}
+		for _, l := range is.Labels {
+			ii.Labels = append(ii.Labels, l.Name)
+		}
+		out = append(out, ii)
+	}
+	return out, nil
+}
+
+// labelMapping returns the type and priority labels imply, or the
+// fallbacks when none match.
+func (cfg *Config) labelMapping(labels []string, action, priority string) (string, string) {
+	lookup := func(defaults, overrides map[string]string, label string) string {
+		if v, ok := overrides[strings.ToLower(label)]; ok {
+			return v
+		}
+		return defaults[strings.ToLower(label)]
+	}
+	typed, prioritized := false, false
+	for _, l := range labels {
+		if v := lookup(defaultLabelTypes, cfg.LabelTypes, l); v != "" && !typed {
+			action, typed = v, true
+		}
+		if v := lookup(defaultLabelPriorities, cfg.LabelPriorities, l); v != "" && !prioritized {
+			priority, prioritized = v, true
+		}
+	}
+	return action, priority
+}
+
+// importsState maps an imported issue's URL to the task created for it, so
+// re-running an import only picks up new cards.
+type importsState map[string]string
+
+type importOptions struct {
+	project, column, field, milestone string
+	repo, action, priority            string
+	baseBranch, overrideFreeze        string
+	limit                             int
+	dryRun, yes                       bool
+	agentConfig                       agentConfigOverrides
+}
+
+func (c *Client) importTasks(ctx context.Context, o importOptions) error {
+	var issues []importedIssue
+	var source string
+	var err error
+	if o.project != "" {
+		if o.column == "" {
+			return fmt.Errorf("--from-project needs --column, the %s value to import", o.field)
+		}
+		source = fmt.Sprintf("project %s (%s: %s)", o.project, o.field, o.column)
+		issues, err = projectIssues(o.project, o.field, o.column)
+	} else {
+		if o.repo == "" {
+			return fmt.Errorf("--from-milestone needs --repo or default_repo")
+		}
+		source = fmt.Sprintf("milestone %q of %s", o.milestone, o.repo)
+		issues, err = milestoneIssues(o.repo, o.milestone)
+	}
+	if err != nil {
+		return err
+	}
+
+	done := importsState{}
+	if err := readState("imports.json", &done); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	var reqs []CreateTaskRequest
+	var from []importedIssue
+	skipped := 0
+	for _, is := range issues {
+		if is.URL != "" && done[is.URL] != "" {
+			skipped++
+			continue
+		}
+		repo := is.Repo
+		if repo == "" {
+			repo = o.repo
+		}
+		if repo == "" {
+			fmt.Fprintf(os.Stderr, "%s skipping draft %q: it has no repository; pass --repo\n", colorize(colorYellow, "warning:"), is.Title)
+			continue
+		}
+		action, priority := c.cfg.labelMapping(is.Labels, o.action, o.priority)
+		req := CreateTaskRequest{
+			Title:       is.Title,
+			Description: strings.TrimSpace(is.Body),
+			Repository:  repo,
+			ActionType:  action,
+			Priority:    priority,
+			TriggeredBy: "cli:import",
+			AgentConfig: c.agentConfig(repo, action, is.Title, o.baseBranch),
+		}
+		if is.URL != "" {
+			req.Description = strings.TrimSpace(req.Description + "\n\nImported from " + is.URL)
+			req.AgentConfig["source"] = map[string]any{"url": is.URL, "labels": is.Labels}
+		}
+		ac, err := o.agentConfig.apply(req.AgentConfig)
+		if err != nil {
+			return err
+		}
+		req.AgentConfig = ac
+		if is.Number > 0 && strings.EqualFold(repo, is.Repo) {
+			n := is.Number
+			req.IssueNumber = &n
+		}
+		reqs = append(reqs, req)
+		from = append(from, is)
+		if o.limit > 0 && len(reqs) == o.limit {
+			break
+		}
+	}
+
+	if machineOutput() && o.dryRun {
+		return printOutput(reqs, func() {})
+	}
+	// Keep stdout for the created tasks when it is machine output.
+	w := os.Stdout
+	if machineOutput() {
+		w = os.Stderr
+	}
+	note := ""
+	if skipped > 0 {
+		note = fmt.Sprintf(" (%d already imported)", skipped)
+	}
+	if len(reqs) == 0 {
+		fmt.Fprintf(w, "Nothing to import from %s%s\n", source, note)
+		return nil
+	}
+	fmt.Fprintf(w, "%d task(s) from %s%s:\n", len(reqs), source, note)
+	tbl := newTable(w, c.tableMaxWidth(), column{header: "ISSUE"}, column{header: "TYPE"},
+		column{header: "PRIORITY"}, column{header: "TITLE", flex: true})
+	for i, req := range reqs {
+		ref := "draft"
+		if from[i].Number > 0 {
+			ref = fmt.Sprintf("%s#%d", from[i].Repo, from[i].Number)
+		}
+		tbl.add(ref, req.ActionType, req.Priority, redact(req.Title))
+	}
+	tbl.render()
+	if o.dryRun {
+		return nil
+	}
+
+	repos := map[string]bool{}
+	for _, req := range reqs {
+		repos[req.Repository] = true
+	}
+	names := make([]string, 0, len(repos))
+	for r := range repos {
+		names = append(names, r)
+	}
+	sort.Strings(names)
+	for _, repo := range names {
+		if err := c.checkCreateTarget(ctx, repo, o.baseBranch); err != nil {
+			return err
+		}
+		if err := c.confirmTarget(repo, o.yes); err != nil {
+			return err
+		}
+	}
+	for i := range reqs {
+		if err := c.checkFreeze(&reqs[i], o.overrideFreeze); err != nil {
+			return err
+		}
+	}
+	if err := c.checkBudget(ctx); err != nil {
+		return err
+	}
+	if !o.yes && !confirm(fmt.Sprintf("Create %d task(s)?", len(reqs))) {
+		return fmt.Errorf("aborted; pass --yes to create without asking")
+	}
+
+	type created struct {
+		Issue string `json:"issue"`
+		Task  Task   `json:"task"`
+	}
+	var out []created
+	for i, req := range reqs {
+		err := c.checkDescriptionSize(ctx, req.Description)
+		var t Task
+		if err == nil {
+			err = tooLargeError(c.createTask(ctx, req, &t), req.Description)
+		}
+		if err != nil {
+			audit("create.import", map[string]any{"source": source, "created": len(out), "failed": from[i].URL})
+			return fmt.Errorf("stopped after %d of %d: %q: %w (re-run to continue; imported issues are skipped)", len(out), len(reqs), req.Title, err)
+		}
+		if from[i].URL != "" {
+			url := from[i].URL
+			_ = updateState("imports.json", &done, func() { done[url] = t.ID })
+		}
+		out = append(out, created{Issue: from[i].URL, Task: t})
+		if !machineOutput() {
+			fmt.Printf("%s %s\n", c.taskLink(t.ID), redact(t.Title))
+		}
+	}
+	audit("create.import", map[string]any{"source": source, "created": len(out)})
+	if machineOutput() {
+		return printOutput(out, func() {})
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+var priorityRank = map[string]int{"low": 0, "normal": 1, "high": 2, "urgent": 3}
+
+func isWaiting(status string) bool {
+	return status == "queued" || status == "pending"
+}
+
+// inversion is a task left waiting while lower-priority ones run.
+type inversion struct {
+	Waiting     Task     `json:"waiting"`
+	Blocking    []string `json:"blocking"`
+	Reason      string   `json:"reason"`
+	Suggestions []string `json:"suggestions"`
+}
+
+type queueStatus struct {
+	Running       []Task      `json:"running"`
+	Waiting       []Task      `json:"waiting"`
+	MaxConcurrent int         `json:"max_concurrent,omitempty"`
+	Inversions    []inversion `json:"inversions"`
+}
+
+// findInversions pairs each waiting task with the running tasks of lower
+// priority and works out why they got ahead: the repository's lock when one
+// of them is on the same repository, a full runner pool when the server
+// reports its size, and otherwise work pinned before the task arrived.
+func findInversions(running, waiting []Task, maxConcurrent int) []inversion {
+	var out []inversion
+	for _, w := range waiting {
+		var lower, sameRepo []Task
+		for _, r := range running {
+			if priorityRank[r.Priority] < priorityRank[w.Priority] {
+				lower = append(lower, r)
+				if strings.EqualFold(r.Repository, w.Repository) {
+					sameRepo = append(sameRepo, r)
+				}
+			}
+		}
+		if len(lower) == 0 {
+			continue
+		}
+		inv := inversion{Waiting: w}
+		blockers := lower
+		switch {
+		case len(sameRepo) > 0:
+			blockers = sameRepo
+			inv.Reason = fmt.Sprintf("running %s task %s holds the lock on %s", sameRepo[0].Priority, sameRepo[0].ID, w.Repository)
+		case maxConcurrent > 0 && len(running) >= maxConcurrent:
+			inv.Reason = fmt.Sprintf("all %d runner slots are busy, %d of them with lower-priority tasks", maxConcurrent, len(lower))
+		default:
+			inv.Reason = fmt.Sprintf("%d lower-priority task(s) were started first and keep their runners", len(lower))
+		}
+		for _, b := range blockers {
+			inv.Blocking = append(inv.Blocking, b.ID)
+			inv.Suggestions = append(inv.Suggestions, fmt.Sprintf("autocodit cancel %s  # %s, %s", b.ID, b.Priority, redact(firstLine(b.Title))))
+		}
+		if w.Priority != "urgent" {
+			inv.Suggestions = append(inv.Suggestions, fmt.Sprintf("autocodit edit %s --set priority=urgent", w.ID))
+		}
+		if len(sameRepo) == 0 {
+			inv.Suggestions = append(inv.Suggestions, "raise the runner concurrency (RUNNER_MAX_CONCURRENT on the server)")
+		}
+		out = append(out, inv)
+	}
+	return out
+}
+
+func (c *Client) queueStatus(ctx context.Context, opts sdk.ListTasksOptions) (queueStatus, error) {
+	qs := queueStatus{Running: []Task{}, Waiting: []Task{}, Inversions: []inversion{}}
+	for _, status := range []string{"running", "queued", "pending"} {
+		opts.Status = status
+		tasks, errc := c.ListTasks(opts).Stream(ctx)
+		for t := range tasks {
+			switch {
+			case t.Status == "running":
+				qs.Running = append(qs.Running, t)
+			case isWaiting(t.Status):
+				qs.Waiting = append(qs.Waiting, t)
+			}
+		}
+		if err := <-errc; err != nil {
+			return qs, userScopeError(err, opts)
+		}
+	}
+	// Highest priority first, then oldest, which is the order the scheduler
+	// should be serving them.
+	sort.SliceStable(qs.Waiting, func(i, j int) bool {
+		a, b := qs.Waiting[i], qs.Waiting[j]
+		if priorityRank[a.Priority] != priorityRank[b.Priority] {
+			return priorityRank[a.Priority] > priorityRank[b.Priority]
+		}
+		return a.CreatedAt.Before(b.CreatedAt)
+	})
+	var l serverLimits
+	if err := c.DoJSON(ctx, http.MethodGet, "/api/v1/limits", nil, &l); err == nil {
+		qs.MaxConcurrent = l.MaxConcurrentTasks
+	}
+	if inv := findInversions(qs.Running, qs.Waiting, qs.MaxConcurrent); inv != nil {
+		qs.Inversions = inv
+	}
+	return qs, nil
+}
+
+func (c *Client) printQueueTasks(title string, tasks []Task, since func(Task) time.Time) {
+	fmt.Println(colorize("1", title))
+	if len(tasks) == 0 {
+		fmt.Println("  none")
+		return
+	}
+	tbl := newTable(os.Stdout, c.tableMaxWidth(), column{}, column{}, column{}, column{right: true}, column{flex: true})
+	for _, t := range tasks {
+		age := ""
+		if at := since(t); !at.IsZero() {
+			age = time.Since(at).Truncate(time.Minute).String()
+		}
+		tbl.add("  "+c.taskLink(t.ID), t.Priority, repoLink(t.Repository), age, redact(t.Title))
+	}
+	tbl.render()
+}
+
+func cmdQueue(c *Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "queue",
+		Short: "Inspect the task queue",
+	}
+	var opts sdk.ListTasksOptions
+	status := &cobra.Command{
+		Use:   "status",
+		Short: "Show running and waiting tasks and flag priority inversions",
+		Long: `status lists the running tasks and the waiting ones in the order they should be
+served, then flags every waiting task that has lower-priority tasks running ahead
+of it: because they hold its repository's lock, because the runner pool is full,
+or because they were started first. Each comes with the actions that would let it
+run: cancelling the blockers, bumping its priority, or raising concurrency.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.PerPage = 100
+			qs, err := c.queueStatus(cmd.Context(), opts)
+			if err != nil {
+				return err
+			}
+			return printOutput(qs, func() {
+				running := fmt.Sprintf("Running (%d)", len(qs.Running))
+				if qs.MaxConcurrent > 0 {
+					running = fmt.Sprintf("Running (%d of %d)", len(qs.Running), qs.MaxConcurrent)
+				}
+				c.printQueueTasks(running, qs.Running, func(t Task) time.Time {
+					if t.StartedAt != nil {
+						return *t.StartedAt
+					}
+					return time.Time{}
+				})
+				fmt.Println()
+				c.printQueueTasks(fmt.Sprintf("Waiting (%d)", len(qs.Waiting)), qs.Waiting, func(t Task) time.Time { return t.CreatedAt })
+				if len(qs.Inversions) == 0 {
+					return
+				}
+				fmt.Println()
+				fmt.Println(colorize(colorYellow, fmt.Sprintf("%d priority inversion(s)", len(qs.Inversions))))
+				for _, inv := range qs.Inversions {
+					fmt.Printf("  %s (%s) waits behind %s: %s\n", c.taskLink(inv.Waiting.ID), inv.Waiting.Priority, strings.Join(inv.Blocking, ", "), inv.Reason)
+					for _, s := range inv.Suggestions {
+						fmt.Println("    " + colorize(colorGray, s))
+					}
+				}
+			})
+		},
+	}
+	status.Flags().StringVarP(&opts.Repository, "repo", "r", "", "only tasks for owner/repo")
+	addUserScopeFlags(status, &opts)
+	cmd.AddCommand(status)
+	return cmd
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Limits on what quickfix puts in the task description.
+const (
+	quickfixOutputLines = 200
+	quickfixExcerpts    = 5
+	quickfixContext     = 8 // lines either side of a referenced line
+)
+
+// fileRefPattern finds path:line references in compiler, linter, and test
+// output, e.g. "auth/session.go:42:7:" or "at src/app.ts:17".
+var fileRefPattern = regexp.MustCompile(`([\w./\\-]+\.\w+):(\d+)`)
+
+type fileRef struct {
+	path string // as shown to the agent: from the repository root when known
+	file string // where it is on disk
+	line int
+}
+
+// runCaptured runs command through the shell, echoing its output to echo
+// (if set) while keeping a copy.
+func runCaptured(ctx context.Context, command string, echo io.Writer) ([]byte, int, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	var out bytes.Buffer
+	w := io.Writer(&out)
+	if echo != nil {
+		w = io.MultiWriter(&out, echo)
+	}
+	cmd.Stdout, cmd.Stderr = w, w
+	err := cmd.Run()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		return out.Bytes(), exit.ExitCode(), nil
+	}
+	return out.Bytes(), 0, err
+}
+
+// tailLines keeps the last n lines of s, where failures are usually
+// summarized, and says how many were dropped.
+func tailLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) <= n {
+		return strings.Join(lines, "\n")
+	}
+	return fmt.Sprintf("[... %d earlier line(s) omitted ...]\n", len(lines)-n) + strings.Join(lines[len(lines)-n:], "\n")
+}
+
+// outputFileRefs returns the files in the working tree that output points
+// at, first mention first, leaving out git_context_exclude matches. Paths
+// are tried from the current directory and then from the repository root.
+func (c *Client) outputFileRefs(output, root string) []fileRef {
+	var refs []fileRef
+	seen := map[string]bool{}
+	for _, m := range fileRefPattern.FindAllStringSubmatch(output, -1) {
+		line, _ := strconv.Atoi(m[2])
+		if line < 1 {
+			continue
+		}
+		candidates := []string{m[1]}
+		if root != "" && !filepath.IsAbs(m[1]) {
+			candidates = append(candidates, filepath.Join(root, m[1]))
+		}
+		for _, file := range candidates {
+			if fi, err := os.Stat(file); err != nil || fi.IsDir() {
+				continue
+			}
+			p := filepath.ToSlash(filepath.Clean(file))
+			if root != "" {
+				abs, _ := filepath.Abs(file)
+				rel, err := filepath.Rel(root, abs)
+				if err != nil || strings.HasPrefix(rel, "..") {
+					break // outside the repository
+				}
+				p = filepath.ToSlash(rel)
+			}
+			if !seen[p] && !excluded(c.cfg.GitContextExclude, p) {
+				seen[p] = true
+				refs = append(refs, fileRef{path: p, file: file, line: line})
+			}
+			break
+		}
+		if len(refs) == quickfixExcerpts {
+			break
+		}
+	}
+	return refs
+}
+
+// excerpt returns the lines around ref, numbered.
+func excerpt(ref fileRef) (string, error) {
+	b, err := os.ReadFile(ref.file)
+	if err != nil {
+		return "", err
+	}
+	lines := strings.Split(strings.TrimRight(string(b), "\n"), "\n")
+	from, to := max(ref.line-quickfixContext, 1), min(ref.line+quickfixContext, len(lines))
+	var out strings.Builder
+	for i := from; i <= to; i++ {
+		mark := " "
+		if i == ref.line {
+			mark = ">"
+		}
+		fmt.Fprintf(&out, "%s%5d  %s\n", mark, i, lines[i-1])
+	}
+	return out.String(), nil
+}
+
+// toolVersionArgs is how each known toolchain reports its version. Only
+// these are asked: running an arbitrary program again with "version" could
+// do anything (make version, ./deploy.sh version).
+var toolVersionArgs = map[string]string{
+	"go": "version", "node": "--version", "npm": "--version",
+	"python": "--version", "python3": "--version",
+	"cargo": "--version", "rustc": "--version", "ruby": "--version",
+}
+
+// toolVersion asks the command's program for its version, for the
+// environment section, if it is a known toolchain; it gives up quickly.
+func toolVersion(command string) string {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return ""
+	}
+	arg, ok := toolVersionArgs[fields[0]]
+	if !ok {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, fields[0], arg).Output()
+	if err != nil || len(bytes.TrimSpace(out)) == 0 {
+		return ""
+	}
+	return firstLine(strings.TrimSpace(string(out)))
+}
+
+// quickfixDescription writes up a failed run for the agent: the command, its
+// output, where it ran, and the code the output points at.
+func (c *Client) quickfixDescription(command string, output []byte, code int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "`%s` fails (exit status %d). Make it pass.\n\n", command, code)
+	fmt.Fprintf(&b, "## Output\n\n```\n%s\n```\n\n", tailLines(string(output), quickfixOutputLines))
+
+	b.WriteString("## Environment\n\n")
+	fmt.Fprintf(&b, "- OS: %s/%s\n", runtime.GOOS, runtime.GOARCH)
+	if v := toolVersion(command); v != "" {
+		fmt.Fprintf(&b, "- %s\n", v)
+	}
+	root, _ := git("rev-parse", "--show-toplevel")
+	if wd, err := os.Getwd(); err == nil && root != "" {
+		if rel, err := filepath.Rel(root, wd); err == nil && rel != "." {
+			fmt.Fprintf(&b, "- Run from: %s\n", filepath.ToSlash(rel))
+		}
+	}
+	if head, err := git("rev-parse", "--short", "HEAD"); err == nil {
+		branch, _ := git("rev-parse", "--abbrev-ref", "HEAD")
+		fmt.Fprintf(&b, "- Checkout: %s at %s\n", branch, head)
+		if dirty, _ := gitLines("status", "--porcelain"); len(dirty) > 0 {
+			fmt.Fprintf(&b, "- Uncommitted changes in %d file(s)\n", len(dirty))
+		}
+	}
+
+	refs := c.outputFileRefs(string(output), root)
+	if len(refs) > 0 {
+		b.WriteString("\n## Relevant code\n")
+	}
+	for _, ref := range refs {
+		text, err := excerpt(ref)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s:%d\n\n```\n%s```\n", ref.path, ref.line, text)
+	}
+	return redact(b.String())
+}
+
+func cmdQuickfix(c *Client) *cobra.Command {
+	var repo, priority, baseBranch string
+	var yes, dryRun, allowDup bool
+	cmd := &cobra.Command{
+		Use:   "quickfix <command>",
+		Short: "Run a failing command and create a fix task from its output",
+		Long: `quickfix runs the command through the shell in the current directory. If it
+fails, a fix task is created with the command, the end of its output, details of
+the environment, and excerpts of the files the output points at:
+
+  autocodit quickfix "go test ./..."
+
+The repository defaults to the current checkout's origin. Output and excerpts go
+through secret redaction, and files matching git_context_exclude are never
+quoted.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			// One argument is a shell command line as typed; several are
+			// words the shell already split, so they are quoted again.
+			command := args[0]
+			if len(args) > 1 {
+				quoted := make([]string, len(args))
+				for i, a := range args {
+					quoted[i] = shellQuote(a)
+				}
+				command = strings.Join(quoted, " ")
+			}
+			if repo == "" {
+				repo, _ = originRepo()
+			}
+			if repo == "" {
+				repo = c.cfg.DefaultRepo
+			}
+			if repo == "" {
+				return fmt.Errorf("--repo required: not in a checkout and no default_repo")
+			}
+
+			var echo io.Writer = os.Stderr
+			if machineOutput() {
+				echo = nil
+			}
+			output, code, err := runCaptured(ctx, command, echo)
+			if err != nil {
+				return fmt.Errorf("running %q: %w", command, err)
+			}
+			if code == 0 {
+				fmt.Fprintln(os.Stderr, colorize(colorGreen, "Command succeeded; nothing to fix"))
+				return nil
+			}
+
+			req := CreateTaskRequest{
+				Title:       "Fix " + command,
+				Description: c.quickfixDescription(command, output, code),
+				Repository:  repo,
+				ActionType:  "fix",
+				Priority:    priority,
+				TriggeredBy: "cli:quickfix",
+			}
+			if dryRun {
+				fmt.Println(req.Description)
+				return nil
+			}
+			if err := c.checkCreateTarget(ctx, repo, baseBranch); err != nil {
+				return err
+			}
+			if err := c.confirmTarget(repo, yes); err != nil {
+				return err
+			}
+			req.AgentConfig = c.agentConfig(repo, req.ActionType, command, baseBranch)
+			if err := c.checkFreeze(&req, ""); err != nil {
+				return err
+			}
+			if err := c.checkBudget(ctx); err != nil {
+				return err
+			}
+			c.attachGitContext(&req)
+			if err := c.checkDescriptionSize(ctx, req.Description); err != nil {
+				return err
+			}
+			if !allowDup {
+				if handled, err := c.dedupe(ctx, req); handled || err != nil {
+					return err
+				}
+			}
+			var task Task
+			if err := c.createTask(ctx, req, &task); err != nil {
+				return tooLargeError(err, req.Description)
+			}
+			audit("create.quickfix", map[string]any{"task": task.ID, "repository": repo, "exit_code": code})
+			return printOutput(task, func() {
+				fmt.Printf("%s exited %d; fix task created: %s\n", command, code, c.taskLink(task.ID))
+			})
+		},
+	}
+	// Flags after the command belong to it: quickfix go test -run TestX ./...
+	cmd.Flags().SetInterspersed(false)
+	cmd.Flags().StringVarP(&repo, "repo", "r", "", "owner/repo (default: the checkout's origin, then default_repo)")
+	cmd.Flags().StringVarP(&priority, "priority", "p", "normal", "low|normal|high|urgent")
+	cmd.Flags().StringVar(&baseBranch, "base-branch", "", "branch the agent starts from (default: repository default branch)")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "create without confirming a repository other than the current checkout or a critical one")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the task description instead of creating the task")
+	cmd.Flags().BoolVar(&allowDup, "allow-duplicate", false, "skip the check for similar open tasks")
+	return cmd
+}
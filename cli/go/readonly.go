@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// mutatingCommands change tasks, server settings, or the working tree and
+// are refused up front in read-only mode.
+var mutatingCommands = []string{
+	"create", "quickfix", "template apply", "cancel", "retry", "delete", "restore", "edit", "comment", "apply", "rollback", "exec", "branch", "benchmark",
+	"drafts resume", "rules add", "rules delete", "webhooks create", "webhooks delete",
+	"webhooks ping", "webhooks test", "tokens create", "tokens revoke", "budget set", "cleanup", "watch-files",
+	"annotate-diff", "repos add", "repos remove", "schedule create", "schedule pause", "schedule resume", "schedule delete",
+}
+
+// readOnlySafe lists non-GET endpoints that only read, or, like the token
+// endpoint that refreshes a login and --as-service use, only authenticate.
+var readOnlySafe = []string{"POST /api/v1/tasks/similar", "POST /api/v1/auth/token"}
+
+func readOnlyError(what string) error {
+	return fmt.Errorf("read-only mode: %s is disabled (unset read_only in the config or drop --read-only)", what)
+}
+
+func checkReadOnly(cmd *cobra.Command) error {
+	path := strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+	if contains(mutatingCommands, path) {
+		return readOnlyError("autocodit " + path)
+	}
+	return nil
+}
+
+// readOnlyTransport refuses any request that could change server state, as
+// a backstop for mutations made by commands not listed above (for example
+// the fixes offered by duplicate-finder).
+type readOnlyTransport struct {
+	next http.RoundTripper
+}
+
+func (t readOnlyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		if !contains(readOnlySafe, req.Method+" "+req.URL.Path) {
+			if req.Body != nil {
+				req.Body.Close()
+			}
+			return nil, readOnlyError(req.Method + " " + req.URL.Path)
+		}
+	}
+	return t.next.RoundTrip(req)
+}
+
+func (c *Client) useReadOnly() {
+	next := c.HTTP.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	c.HTTP.Transport = readOnlyTransport{next: next}
+}
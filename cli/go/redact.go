@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sync"
+)
+
+var builtinSecretPatterns = []string{
+	`gh[pousr]_[A-Za-z0-9]{36,}`,
+	`github_pat_[A-Za-z0-9_]{22,}`,
+	`AKIA[0-9A-Z]{16}`,
+	`xox[abprs]-[A-Za-z0-9-]{10,}`,
+	`sk-[A-Za-z0-9_-]{20,}`,
+	`eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`,
+	`(?i)bearer\s+[A-Za-z0-9._~+/-]{20,}=*`,
+	`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`,
+}
+
+var entropyCandidate = regexp.MustCompile(`[A-Za-z0-9+/_=-]{24,}`)
+
+// redactor replaces secrets in server-provided text before it is printed.
+type redactor struct {
+	mu       sync.Mutex
+	patterns []*regexp.Regexp
+	disabled bool
+	count    int
+}
+
+var redactions = &redactor{}
+
+func (r *redactor) configure(custom []string, disabled bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.disabled = disabled
+	r.patterns = nil
+	for _, p := range append(builtinSecretPatterns, custom...) {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return fmt.Errorf("redact_patterns: %q: %w", p, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return nil
+}
+
+func (r *redactor) redact(s string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.disabled {
+		return s
+	}
+	replace := func(string) string {
+		r.count++
+		return "[REDACTED]"
+	}
+	for _, re := range r.patterns {
+		s = re.ReplaceAllStringFunc(s, replace)
+	}
+	return entropyCandidate.ReplaceAllStringFunc(s, func(m string) string {
+		if looksRandom(m) {
+			return replace(m)
+		}
+		return m
+	})
+}
+
+// report notes how many values were hidden so users know output was altered.
+func (r *redactor) report() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.count > 0 {
+		fmt.Fprintf(os.Stderr, "(%d secret(s) redacted; use --no-redact to show)\n", r.count)
+	}
+}
+
+func redact(s string) string {
+	return redactions.redact(s)
+}
+
+// looksRandom flags mixed-case alphanumeric strings with high Shannon
+// entropy. Hex digests and UUIDs stay below the threshold.
+func looksRandom(s string) bool {
+	var upper, lower, digit bool
+	freq := map[rune]int{}
+	for _, ch := range s {
+		freq[ch]++
+		switch {
+		case ch >= 'A' && ch <= 'Z':
+			upper = true
+		case ch >= 'a' && ch <= 'z':
+			lower = true
+		case ch >= '0' && ch <= '9':
+			digit = true
+		}
+	}
+	if !upper || !lower || !digit {
+		return false
+	}
+	var h float64
+	n := float64(len(s))
+	for _, c := range freq {
+		p := float64(c) / n
+		h -= p * math.Log2(p)
+	}
+	return h > 4.3
+}
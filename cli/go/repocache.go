@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+type RepoMeta struct {
+	FullName      string   `json:"full_name"`
+	DefaultBranch string   `json:"default_branch"`
+	Visibility    string   `json:"visibility"`
+	Language      string   `json:"language"`
+	Archived      bool     `json:"archived"`
+	Branches      []string `json:"branches"`
+	// Installation is the GitHub App installation the agent reaches the
+	// repository through; nil when the app isn't installed on it.
+	Installation *RepoInstallation `json:"installation,omitempty"`
+	// AgentSettings are the server-side agent_config defaults for tasks on
+	// the repository.
+	AgentSettings map[string]any `json:"agent_settings,omitempty"`
+}
+
+type RepoInstallation struct {
+	ID      int64  `json:"id"`
+	Account string `json:"account"`
+	// Status is active, suspended, or missing_permissions.
+	Status string `json:"status"`
+}
+
+type cachedRepo struct {
+	FetchedAt time.Time `json:"fetched_at"`
+	Repo      RepoMeta  `json:"repo"`
+}
+
+var repoCache struct {
+	once    sync.Once
+	mu      sync.Mutex
+	entries map[string]cachedRepo
+}
+
+func loadRepoCache() map[string]cachedRepo {
+	repoCache.once.Do(func() {
+		repoCache.entries = map[string]cachedRepo{}
+		_ = readState("cache/repos.json", &repoCache.entries)
+	})
+	return repoCache.entries
+}
+
+// cachedRepoMeta returns metadata only if it is already cached and fresh;
+// it never hits the API, so it is safe to call per list row.
+func (c *Client) cachedRepoMeta(name string) (RepoMeta, bool) {
+	repoCache.mu.Lock()
+	defer repoCache.mu.Unlock()
+	e, ok := loadRepoCache()[name]
+	if !ok || time.Since(e.FetchedAt) > c.cfg.RepoCacheTTL {
+		return RepoMeta{}, false
+	}
+	return e.Repo, true
+}
+
+// repoMeta returns cached metadata for name, fetching it when missing or
+// older than repo_cache_ttl.
+func (c *Client) repoMeta(ctx context.Context, name string) (RepoMeta, error) {
+	if m, ok := c.cachedRepoMeta(name); ok {
+		return m, nil
+	}
+	var m RepoMeta
+	if err := c.DoJSON(ctx, http.MethodGet, "/api/v1/repositories/"+name, nil, &m); err != nil {
+		return m, err
+	}
+	storeRepoMeta(name, &m)
+	return m, nil
+}
+
+// storeRepoMeta caches m for name, or forgets name when m is nil.
+func storeRepoMeta(name string, m *RepoMeta) {
+	repoCache.mu.Lock()
+	defer repoCache.mu.Unlock()
+	onDisk := map[string]cachedRepo{}
+	if m == nil {
+		delete(loadRepoCache(), name)
+		_ = updateState("cache/repos.json", &onDisk, func() { delete(onDisk, name) })
+		return
+	}
+	e := cachedRepo{FetchedAt: time.Now(), Repo: *m}
+	loadRepoCache()[name] = e
+	_ = updateState("cache/repos.json", &onDisk, func() { onDisk[name] = e })
+}
+
+// checkCreateTarget validates a create request against repository
+// metadata. Metadata lookup failures are not fatal: validation is skipped.
+func (c *Client) checkCreateTarget(ctx context.Context, repo, baseBranch string) error {
+	m, err := c.repoMeta(ctx, repo)
+	if err != nil {
+		return nil
+	}
+	if m.Archived {
+		fmt.Fprintf(os.Stderr, "Warning: %s is archived; the agent will not be able to push changes.\n", repo)
+	}
+	if baseBranch != "" && len(m.Branches) > 0 && !contains(m.Branches, baseBranch) {
+		return fmt.Errorf("branch %q not found in %s (default branch: %s)", baseBranch, repo, m.DefaultBranch)
+	}
+	return nil
+}
+
+func (c *Client) languageBadge(repo string) string {
+	if m, ok := c.cachedRepoMeta(repo); ok && m.Language != "" {
+		return "[" + m.Language + "] "
+	}
+	return ""
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+// repoGroup returns the repositories of a repo_groups entry, which may be a
+// list or a comma-separated string.
+func (c *Client) repoGroup(name string) ([]string, error) {
+	entries, ok := c.cfg.RepoGroups[name]
+	if !ok {
+		var names []string
+		for n := range c.cfg.RepoGroups {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return nil, fmt.Errorf("no repo group %q: define it under repo_groups in the config", name)
+		}
+		return nil, fmt.Errorf("no repo group %q (defined: %s)", name, strings.Join(names, ", "))
+	}
+	var repos []string
+	seen := map[string]bool{}
+	for _, e := range entries {
+		for _, r := range strings.Split(e, ",") {
+			if r = strings.TrimSpace(r); r != "" && !seen[r] {
+				seen[r] = true
+				repos = append(repos, r)
+			}
+		}
+	}
+	if len(repos) == 0 {
+		return nil, fmt.Errorf("repo group %q is empty", name)
+	}
+	return repos, nil
+}
+
+// listRepoGroup lists opts across repos, newest first. Listing a
+// repository fails only with a warning unless all of them fail.
+func (c *Client) listRepoGroup(ctx context.Context, opts sdk.ListTasksOptions, repos []string, all bool) ([]Task, error) {
+	var mu sync.Mutex
+	var tasks []Task
+	errs := make([]error, len(repos))
+	var wg sync.WaitGroup
+	for i, repo := range repos {
+		wg.Add(1)
+		go func(i int, repo string) {
+			defer wg.Done()
+			o := opts
+			o.Repository = repo
+			pager := c.ListTasks(o)
+			var got []Task
+			if !all {
+				resp, err := pager.NextPage(ctx)
+				if err != nil {
+					errs[i] = userScopeError(err, o)
+					return
+				}
+				got = resp.Items
+			} else {
+				stream, errc := pager.Stream(ctx)
+				for t := range stream {
+					got = append(got, t)
+				}
+				errs[i] = userScopeError(<-errc, o)
+			}
+			mu.Lock()
+			tasks = append(tasks, got...)
+			mu.Unlock()
+		}(i, repo)
+	}
+	wg.Wait()
+
+	failed := 0
+	for i, err := range errs {
+		if err != nil {
+			failed++
+			fmt.Fprintln(os.Stderr, colorize(colorYellow, fmt.Sprintf("warning: %s: %v", repos[i], err)))
+		}
+	}
+	if failed == len(repos) {
+		return nil, fmt.Errorf("no repository in the group could be listed")
+	}
+	sort.SliceStable(tasks, func(i, j int) bool { return tasks[i].CreatedAt.After(tasks[j].CreatedAt) })
+	if opts.Limit > 0 && len(tasks) > opts.Limit {
+		tasks = tasks[:opts.Limit]
+	}
+	return tasks, nil
+}
+
+// repoGroupManifest is one task per repository of the group, for
+// createTasks.
+func repoGroupManifest(repos []string, req CreateTaskRequest, baseBranch string) taskManifest {
+	m := taskManifest{Defaults: manifestTask{Type: req.ActionType, Priority: req.Priority, BaseBranch: baseBranch}}
+	for _, r := range repos {
+		m.Tasks = append(m.Tasks, manifestTask{Title: req.Title, Description: req.Description, Repo: r})
+	}
+	return m
+}
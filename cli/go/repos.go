@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+var repoNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+/[A-Za-z0-9_.-]+$`)
+
+func installationState(m RepoMeta) string {
+	switch {
+	case m.Installation == nil:
+		return colorize(colorRed, "not installed")
+	case m.Installation.Status == "active":
+		return colorize(colorGreen, "active")
+	}
+	return colorize(colorYellow, strings.ReplaceAll(m.Installation.Status, "_", " "))
+}
+
+// flattenSettings lists nested settings as dotted keys, in order.
+func flattenSettings(prefix string, m map[string]any, out *[][2]string) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if nested, ok := m[k].(map[string]any); ok && len(nested) > 0 {
+			flattenSettings(prefix+k+".", nested, out)
+			continue
+		}
+		v, ok := m[k].(string)
+		if !ok {
+			b, _ := json.Marshal(m[k])
+			v = string(b)
+		}
+		*out = append(*out, [2]string{prefix + k, v})
+	}
+}
+
+func cmdRepos(c *Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "repos",
+		Short: "Manage the repositories connected to the agent",
+	}
+	cmd.AddCommand(cmdReposList(c), cmdReposShow(c), cmdReposAdd(c), cmdReposRemove(c))
+	return cmd
+}
+
+func cmdReposList(c *Client) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List connected repositories with their GitHub App installation status",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var repos []RepoMeta
+			if err := c.DoJSON(cmd.Context(), http.MethodGet, "/api/v1/repositories", nil, &repos); err != nil {
+				return err
+			}
+			sort.Slice(repos, func(i, j int) bool { return repos[i].FullName < repos[j].FullName })
+			if repos == nil {
+				repos = []RepoMeta{}
+			}
+			return printOutput(repos, func() {
+				if len(repos) == 0 {
+					fmt.Println("No repositories connected; add one with: autocodit repos add owner/repo")
+					return
+				}
+				tbl := newTable(os.Stdout, c.tableMaxWidth(), column{header: "REPO", flex: true}, column{header: "DEFAULT BRANCH"},
+					column{header: "VISIBILITY"}, column{header: "LANGUAGE"}, column{header: "INSTALLATION"})
+				for _, m := range repos {
+					name := m.FullName
+					if m.Archived {
+						name += colorize(colorGray, " (archived)")
+					}
+					tbl.add(name, m.DefaultBranch, m.Visibility, m.Language, installationState(m))
+				}
+				tbl.render()
+			})
+		},
+	}
+}
+
+// repoReport is repos show's output: the server's view of the repository
+// and the local config that applies to it.
+type repoReport struct {
+	RepoMeta
+	Local      map[string]any `json:"local_settings"`
+	RepoGroups []string       `json:"repo_groups,omitempty"`
+	OpenTasks  int            `json:"open_tasks"`
+}
+
+func cmdReposShow(c *Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "show [owner/repo]",
+		Short: "Show a repository's installation, default branch, and agent settings",
+		Long: `show prints what the server knows about a repository (by default the current
+checkout's, else default_repo): its default branch, GitHub App installation,
+and the agent settings tasks on it start from, followed by the local settings
+from the config's repos section and the repo_groups it belongs to.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			name := c.cfg.DefaultRepo
+			if origin, err := originRepo(); err == nil {
+				name = origin
+			}
+			if len(args) == 1 {
+				name = args[0]
+			}
+			if name == "" {
+				return fmt.Errorf("owner/repo required (or run it in a checkout, or set default_repo)")
+			}
+			var r repoReport
+			if err := c.DoJSON(ctx, http.MethodGet, "/api/v1/repositories/"+name, nil, &r.RepoMeta); err != nil {
+				return err
+			}
+			storeRepoMeta(name, &r.RepoMeta)
+			rs := c.cfg.repoSettings(name)
+			r.Local = map[string]any{
+				"branch_pattern":  rs.BranchPattern,
+				"commit_template": rs.CommitTemplate,
+				"critical":        rs.Critical,
+			}
+			if len(rs.VerifyCommands) > 0 {
+				r.Local["verify_commands"] = rs.VerifyCommands
+			}
+			if rs.VerifyImage != "" {
+				r.Local["verify_image"] = rs.VerifyImage
+			}
+			for group := range c.cfg.RepoGroups {
+				if repos, err := c.repoGroup(group); err == nil && contains(repos, r.FullName) {
+					r.RepoGroups = append(r.RepoGroups, group)
+				}
+			}
+			sort.Strings(r.RepoGroups)
+			tasks, errc := c.ListTasks(sdk.ListTasksOptions{Repository: r.FullName, PerPage: 100}).Stream(ctx)
+			for t := range tasks {
+				if !isFinished(t.Status) {
+					r.OpenTasks++
+				}
+			}
+			if err := <-errc; err != nil {
+				r.OpenTasks = -1
+			}
+
+			return printOutput(r, func() {
+				row := func(k, v string) { fmt.Printf("  %-26s %s\n", k, v) }
+				fmt.Println(colorize("1", r.FullName))
+				row("default branch", r.DefaultBranch)
+				row("visibility", r.Visibility)
+				if r.Language != "" {
+					row("language", r.Language)
+				}
+				if r.Archived {
+					row("archived", colorize(colorYellow, "yes; the agent can't push to it"))
+				}
+				inst := installationState(r.RepoMeta)
+				if r.Installation != nil {
+					inst += colorize(colorGray, fmt.Sprintf(" (%s, #%d)", r.Installation.Account, r.Installation.ID))
+				}
+				row("installation", inst)
+				if r.OpenTasks >= 0 {
+					row("open tasks", strconv.Itoa(r.OpenTasks))
+				}
+				fmt.Println(colorize("1", "Agent settings"))
+				var settings [][2]string
+				flattenSettings("", r.AgentSettings, &settings)
+				if len(settings) == 0 {
+					fmt.Println(colorize(colorGray, "  none; tasks use the server defaults"))
+				}
+				for _, kv := range settings {
+					row(kv[0], redact(kv[1]))
+				}
+				fmt.Println(colorize("1", "Local settings"))
+				var local [][2]string
+				flattenSettings("", r.Local, &local)
+				for _, kv := range local {
+					row(kv[0], kv[1])
+				}
+				if len(r.RepoGroups) > 0 {
+					row("repo_groups", strings.Join(r.RepoGroups, ", "))
+				}
+			})
+		},
+	}
+	cmd.ValidArgsFunction = c.completeRepos
+	return cmd
+}
+
+func cmdReposAdd(c *Client) *cobra.Command {
+	var sets []string
+	cmd := &cobra.Command{
+		Use:   "add [owner/repo...]",
+		Short: "Connect repositories so tasks can be created on them",
+		Long: `add connects repositories to the agent. The GitHub App has to be installed on
+the repository's owner with access to it; add says so when it isn't. --set
+key=value stores agent settings that tasks on the repositories start from,
+dotted keys reaching into nested settings as with create --set:
+
+  autocodit repos add my-org/api my-org/workers --set model=gpt-5 --set limits.steps=40`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, name := range args {
+				if !repoNamePattern.MatchString(name) {
+					return fmt.Errorf("%q is not owner/repo", name)
+				}
+			}
+			var settings map[string]any
+			if len(sets) > 0 {
+				var err error
+				if settings, err = mergeAgentConfig(nil, sets); err != nil {
+					return fmt.Errorf("--set: %w", err)
+				}
+			}
+			var added []RepoMeta
+			for _, name := range args {
+				body := map[string]any{"full_name": name}
+				if settings != nil {
+					body["agent_settings"] = settings
+				}
+				var m RepoMeta
+				if err := c.DoJSON(cmd.Context(), http.MethodPost, "/api/v1/repositories", body, &m); err != nil {
+					return fmt.Errorf("adding %s: %w", name, err)
+				}
+				storeRepoMeta(m.FullName, &m)
+				audit("repo.add", map[string]any{"repo": m.FullName, "settings": len(settings)})
+				added = append(added, m)
+				if !machineOutput() {
+					fmt.Printf("Connected %s (installation: %s)\n", m.FullName, installationState(m))
+				}
+				if m.Installation == nil {
+					owner, _, _ := strings.Cut(m.FullName, "/")
+					fmt.Fprintf(os.Stderr, "%s install the GitHub App on %s and give it access to %s, or tasks on it will fail\n",
+						colorize(colorYellow, "warning:"), owner, m.FullName)
+				}
+			}
+			if machineOutput() {
+				return printOutput(added, func() {})
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringArrayVar(&sets, "set", nil, "agent setting key=value, repeatable; dotted keys reach nested settings")
+	return cmd
+}
+
+func cmdReposRemove(c *Client) *cobra.Command {
+	var yes bool
+	cmd := &cobra.Command{
+		Use:   "remove [owner/repo...]",
+		Short: "Disconnect repositories from the agent",
+		Long: `remove disconnects repositories: no new tasks can be created on them. Existing
+tasks and pull requests are kept. It asks first, saying how many unfinished
+tasks each repository has, unless --yes is given.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			for _, name := range args {
+				if !yes {
+					open := 0
+					tasks, errc := c.ListTasks(sdk.ListTasksOptions{Repository: name, PerPage: 100}).Stream(ctx)
+					for t := range tasks {
+						if !isFinished(t.Status) {
+							open++
+						}
+					}
+					if err := <-errc; err == nil && open > 0 {
+						fmt.Fprintf(os.Stderr, "%s %s has %d unfinished task(s); they keep running\n",
+							colorize(colorYellow, "warning:"), name, open)
+					}
+					if !confirm(fmt.Sprintf("Disconnect %s?", name)) {
+						return fmt.Errorf("aborted")
+					}
+				}
+				if err := c.DoJSON(ctx, http.MethodDelete, "/api/v1/repositories/"+name, nil, nil); err != nil {
+					return fmt.Errorf("removing %s: %w", name, err)
+				}
+				storeRepoMeta(name, nil)
+				audit("repo.remove", map[string]any{"repo": name})
+				fmt.Println("Disconnected", name)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "disconnect without asking")
+	cmd.ValidArgsFunction = c.completeRepos
+	return cmd
+}
@@ -0,0 +1,9 @@
+//go:build windows
+
+package main
+
+// Windows consoles do not deliver SIGWINCH; the initial size sent on
+// connect is used for the whole session.
+func watchResize(onResize func()) (stop func()) {
+	return func() {}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+const (
+	wakeCheckInterval = 2 * time.Second
+	// A suspended machine's wall clock keeps running while the monotonic
+	// clock stops, so a difference beyond this means we were asleep.
+	wakeMinSleep = 20 * time.Second
+)
+
+// wakeDetector notices resume from sleep and changes to the local network
+// addresses, either of which leaves open connections and polled state stale.
+type wakeDetector struct {
+	last time.Time
+	addr string
+}
+
+func newWakeDetector() *wakeDetector {
+	return &wakeDetector{last: time.Now(), addr: localAddrs()}
+}
+
+func localAddrs() string {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return ""
+	}
+	var s []string
+	for _, a := range addrs {
+		if ip, ok := a.(*net.IPNet); ok && !ip.IP.IsLoopback() && !ip.IP.IsLinkLocalUnicast() {
+			s = append(s, ip.String())
+		}
+	}
+	sort.Strings(s)
+	return strings.Join(s, ",")
+}
+
+// check returns a description of what happened since the last call, or "".
+func (d *wakeDetector) check() string {
+	now := time.Now()
+	slept := now.Round(0).Sub(d.last.Round(0)) - now.Sub(d.last)
+	d.last = now
+	if slept > wakeMinSleep {
+		d.addr = localAddrs()
+		return fmt.Sprintf("resumed from sleep (%s gap)", slept.Round(time.Second))
+	}
+	if addr := localAddrs(); addr != d.addr {
+		d.addr = addr
+		return "network changed"
+	}
+	return ""
+}
+
+// watch reports detected events until ctx is done.
+func (d *wakeDetector) watch(ctx context.Context) <-chan string {
+	ch := make(chan string, 1)
+	go func() {
+		tick := time.NewTicker(wakeCheckInterval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-tick.C:
+				if reason := d.check(); reason != "" {
+					select {
+					case ch <- reason:
+					default:
+					}
+				}
+			}
+		}
+	}()
+	return ch
+}
+
+// eventFollower keeps a task's event stream open to track the last event
+// cursor, reconnecting with Last-Event-ID whenever the stream drops. Live
+// events are passed on through updates.
+type eventFollower struct {
+	c       *Client
+	id      string
+	updates chan sdk.Event
+
+	mu        sync.Mutex
+	cursor    string
+	replaying bool
+	missed    []sdk.Event
+	lastEvent time.Time
+	cancel    context.CancelFunc
+	disabled  bool
+	connected bool
+}
+
+func (c *Client) followEvents(ctx context.Context, id string) *eventFollower {
+	f := &eventFollower{c: c, id: id, updates: make(chan sdk.Event, 64)}
+	go f.run(ctx)
+	return f
+}
+
+// live reports whether the stream is currently connected.
+func (f *eventFollower) live() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.connected
+}
+
+func (f *eventFollower) run(ctx context.Context) {
+	for ctx.Err() == nil {
+		sctx, cancel := context.WithCancel(ctx)
+		f.mu.Lock()
+		f.cancel = cancel
+		cursor := f.cursor
+		f.mu.Unlock()
+
+		s, err := f.c.StreamTaskEvents(sctx, f.id, cursor)
+		var apiErr *sdk.APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode < 500 {
+			cancel()
+			f.mu.Lock()
+			f.disabled = true
+			f.mu.Unlock()
+			return
+		}
+		if err == nil {
+			f.mu.Lock()
+			f.connected = true
+			f.mu.Unlock()
+			for ev := range s.Events() {
+				f.mu.Lock()
+				if ev.ID != "" {
+					f.cursor = ev.ID
+				}
+				if f.replaying {
+					f.missed = append(f.missed, ev)
+					f.lastEvent = time.Now()
+				} else {
+					// A slow consumer only loses intermediate updates; the
+					// next poll catches up.
+					select {
+					case f.updates <- ev:
+					default:
+					}
+				}
+				f.mu.Unlock()
+			}
+			f.mu.Lock()
+			f.connected = false
+			f.mu.Unlock()
+		}
+		cancel()
+		select {
+		case <-ctx.Done():
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// replay drops the current (likely dead) stream, reconnects from the last
+// cursor, and returns the events the server sends back before going quiet.
+func (f *eventFollower) replay() []sdk.Event {
+	f.mu.Lock()
+	if f.disabled || f.cursor == "" {
+		f.mu.Unlock()
+		return nil
+	}
+	f.replaying, f.missed, f.lastEvent = true, nil, time.Now()
+	if f.cancel != nil {
+		f.cancel()
+	}
+	f.mu.Unlock()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		time.Sleep(250 * time.Millisecond)
+		f.mu.Lock()
+		quiet := time.Since(f.lastEvent) > time.Second && (len(f.missed) > 0 || time.Since(f.lastEvent) > 3*time.Second)
+		f.mu.Unlock()
+		if quiet {
+			break
+		}
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.replaying = false
+	return f.missed
+}
+
+// applyEvent updates t from a status or progress event, reporting whether
+// anything changed. Events may carry the whole task or just the fields
+// that changed.
+func applyEvent(t *Task, ev sdk.Event) bool {
+	var d struct {
+		Task     *Task            `json:"task"`
+		Status   string           `json:"status"`
+		Progress *float64         `json:"progress"`
+		Metrics  *sdk.TaskMetrics `json:"metrics"`
+	}
+	if json.Unmarshal(ev.Data, &d) != nil {
+		return false
+	}
+	if d.Task != nil && d.Task.ID == t.ID {
+		*t = *d.Task
+		return true
+	}
+	changed := false
+	if d.Status != "" && d.Status != t.Status {
+		t.Status, changed = d.Status, true
+	}
+	if d.Progress != nil && *d.Progress != t.Progress {
+		t.Progress, changed = *d.Progress, true
+	}
+	if d.Metrics != nil && (t.Metrics == nil || *d.Metrics != *t.Metrics) {
+		t.Metrics, changed = d.Metrics, true
+	}
+	return changed
+}
+
+func eventSummary(ev sdk.Event) string {
+	var d map[string]interface{}
+	if json.Unmarshal(ev.Data, &d) != nil {
+		return redact(firstLine(string(ev.Data)))
+	}
+	if m, ok := d["message"].(string); ok {
+		return redact(firstLine(m))
+	}
+	var parts []string
+	if s, ok := d["status"].(string); ok {
+		parts = append(parts, s)
+	}
+	if p, ok := d["progress"].(float64); ok {
+		parts = append(parts, fmt.Sprintf("%.0f%%", p*100))
+	}
+	return strings.Join(parts, " ")
+}
+
+// transientError reports whether a failed poll is worth retrying: the request
+// never reached the server, or the server is briefly unavailable.
+func transientError(err error) bool {
+	var apiErr *sdk.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= http.StatusInternalServerError || apiErr.StatusCode == http.StatusTooManyRequests
+	}
+	return !errors.Is(err, context.Canceled)
+}
+
+// noteGap interrupts the progress line to say why state was re-synced and
+// what happened in the meantime.
+func noteGap(reason string, missed []sdk.Event) {
+	msg := reason + "; re-synced"
+	if len(missed) > 0 {
+		msg += fmt.Sprintf(", %d missed event(s):", len(missed))
+	}
+	fmt.Fprintf(os.Stderr, "\n%s\n", colorize(colorYellow, msg))
+	for _, ev := range missed {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", ev.Type, eventSummary(ev))
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+// retryRequest is the body of POST /api/v1/tasks/{id}/retry; empty fields
+// keep the original's values.
+type retryRequest struct {
+	Priority    string         `json:"priority,omitempty"`
+	AgentConfig map[string]any `json:"agent_config,omitempty"`
+}
+
+// cloneRequest is the create request for a copy of finished task t.
+func cloneRequest(t Task, config map[string]any, priority string) CreateTaskRequest {
+	return CreateTaskRequest{
+		Title:       t.Title,
+		Description: t.Description,
+		Repository:  t.Repository,
+		ActionType:  t.ActionType,
+		Priority:    pick(priority, t.Priority, "normal"),
+		AgentConfig: config,
+		TriggeredBy: "cli:retry",
+	}
+}
+
+// createClone creates req, checking the repository and description as
+// create does. retry has already run the guards it shares with create:
+// confirmTarget, checkFreeze, and checkBudget.
+func (c *Client) createClone(ctx context.Context, req CreateTaskRequest) (Task, error) {
+	baseBranch, _ := req.AgentConfig["base_branch"].(string)
+	var task Task
+	if err := c.checkCreateTarget(ctx, req.Repository, baseBranch); err != nil {
+		return task, err
+	}
+	if err := c.checkDescriptionSize(ctx, req.Description); err != nil {
+		return task, err
+	}
+	if err := c.createTask(ctx, req, &task); err != nil {
+		return task, tooLargeError(err, req.Description)
+	}
+	return task, nil
+}
+
+func cmdRetry(c *Client) *cobra.Command {
+	var priority, overrideFreeze string
+	var agentConfigFile string
+	var sets []string
+	var clone, edit, yes bool
+	cmd := &cobra.Command{
+		Use:   "retry [id]",
+		Short: "Run a failed or cancelled task again",
+		Long: `retry asks the server to run a finished task again, optionally at another
+priority or with agent_config values changed:
+
+  autocodit retry 3fa2 --priority high --set model=gpt-4o --set limits.max_steps=80
+
+--agent-config FILE replaces the original's agent_config by key, as with create.
+
+--edit opens the original's description in $EDITOR and creates a copy with what
+you write; like create --edit, the text is kept as a draft until the task is
+created, for drafts resume.
+
+With --clone, or when the server has no retry endpoint, a new task is created
+from the original's title, description, repository, type, and agent_config
+instead. Either way the run goes through create's guards first: freeze windows,
+the budget, and confirming a repository other than the current checkout or a
+critical one.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			id := args[0]
+			if priority != "" && !validPriority(priority) {
+				return fmt.Errorf("--priority must be one of low, normal, high, urgent")
+			}
+			overrides, err := loadAgentConfigOverrides(agentConfigFile, sets)
+			if err != nil {
+				return err
+			}
+			var raw json.RawMessage
+			if err := c.DoJSON(ctx, http.MethodGet, "/api/v1/tasks/"+id, nil, &raw); err != nil {
+				return err
+			}
+			var t Task
+			var fields struct {
+				AgentConfig map[string]any `json:"agent_config"`
+			}
+			if err := json.Unmarshal(raw, &t); err != nil {
+				return err
+			}
+			_ = json.Unmarshal(raw, &fields)
+			if !isFinished(t.Status) {
+				return fmt.Errorf("%s is %s; only finished tasks can be retried (cancel it first)", t.ID, t.Status)
+			}
+			config, err := overrides.apply(fields.AgentConfig)
+			if err != nil {
+				return err
+			}
+
+			// A rerun is new work as much as a clone is, so both get
+			// create's guards, once.
+			req := CreateTaskRequest{Repository: t.Repository, ActionType: t.ActionType, AgentConfig: config}
+			if err := c.confirmTarget(req.Repository, yes); err != nil {
+				return err
+			}
+			if err := c.checkFreeze(&req, overrideFreeze); err != nil {
+				return err
+			}
+			if err := c.checkBudget(ctx); err != nil {
+				return err
+			}
+			config = req.AgentConfig
+			_, overridden := config["freeze_override"]
+
+			if edit {
+				req := cloneRequest(t, config, priority)
+				d, err := newDraft("retry", t.ID, &req)
+				if err != nil {
+					return err
+				}
+				text, err := d.edit(req.Description)
+				if err != nil {
+					return err
+				}
+				return c.submitDraft(ctx, d, text, false)
+			}
+			if !clone {
+				body := retryRequest{Priority: priority}
+				if !overrides.empty() || overridden {
+					body.AgentConfig = config
+				}
+				var out Task
+				err := c.DoJSON(ctx, http.MethodPost, "/api/v1/tasks/"+t.ID+"/retry", &body, &out)
+				var apiErr *sdk.APIError
+				if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusNotFound || apiErr.StatusCode == http.StatusMethodNotAllowed) {
+					fmt.Fprintln(os.Stderr, "The server has no retry endpoint; creating a copy of the task instead")
+					clone = true
+				} else if err != nil {
+					return err
+				} else {
+					if out.ID == "" {
+						out.ID = t.ID
+					}
+					audit("retry", map[string]any{"task": t.ID, "retry": out.ID, "priority": priority, "agent_config": !overrides.empty()})
+					return printOutput(out, func() { fmt.Println("Retrying:", c.taskLink(out.ID)) })
+				}
+			}
+			task, err := c.createClone(ctx, cloneRequest(t, config, priority))
+			if err != nil {
+				return err
+			}
+			audit("retry", map[string]any{"task": t.ID, "retry": task.ID, "clone": true, "priority": priority, "agent_config": !overrides.empty()})
+			return printOutput(task, func() { fmt.Printf("Retry of %s created: %s\n", t.ID, c.taskLink(task.ID)) })
+		},
+	}
+	cmd.Flags().StringVarP(&priority, "priority", "p", "", "run at this priority instead (low|normal|high|urgent)")
+	cmd.Flags().StringVar(&agentConfigFile, "agent-config", "", "YAML or JSON file of agent_config settings, replacing the original's by key")
+	cmd.Flags().StringArrayVar(&sets, "set", nil, "agent_config key=value, repeatable; dotted keys reach nested settings, values are read as JSON")
+	cmd.Flags().BoolVar(&clone, "clone", false, "create a new task from the original instead of rerunning it")
+	cmd.Flags().BoolVarP(&edit, "edit", "e", false, "edit the description in $EDITOR and create a copy with it (implies --clone)")
+	cmd.Flags().StringVar(&overrideFreeze, "override-freeze", "", "run during a freeze window, recording this reason")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "run without confirming a repository other than the current checkout or a critical one")
+	return cmd
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// confirm asks a yes/no question on the terminal, defaulting to no. It
+// returns false without asking when stdin is not a terminal.
+func confirm(question string) bool {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// editInstructions opens $EDITOR on a scratch file listing the checkpoints
+// and returns what the user wrote, comments removed.
+func editInstructions(t Task, cps []checkpoint, step int) (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	p := filepath.Join(dir, "rollback-"+t.ID+".md")
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n# Amended instructions for %s after rolling back to step %d.\n", t.ID, step)
+	b.WriteString("# Leave empty to resume with the original description. Lines starting with # are ignored.\n#\n")
+	for _, cp := range cps {
+		mark := " "
+		if cp.Step > step {
+			mark = "x"
+		}
+		fmt.Fprintf(&b, "# [%s] step %d: %s\n", mark, cp.Step, firstLine(cp.Summary))
+	}
+	if err := os.WriteFile(p, []byte(b.String()), 0o600); err != nil {
+		return "", err
+	}
+	defer os.Remove(p)
+	if err := runEditor(p); err != nil {
+		return "", err
+	}
+	text, err := os.ReadFile(p)
+	if err != nil {
+		return "", err
+	}
+	return stripComments(string(text)), nil
+}
+
+func cmdRollback(c *Client) *cobra.Command {
+	var step int
+	var instructions string
+	var edit, yes, watch bool
+	cmd := &cobra.Command{
+		Use:   "rollback [id]",
+		Short: "Roll a task back to an earlier checkpoint and resume from there",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			id := args[0]
+			cps, err := c.checkpoints(ctx, id)
+			if err != nil {
+				return err
+			}
+			if !cmd.Flags().Changed("to-step") {
+				c.printCheckpoints(cps)
+				return fmt.Errorf("choose a checkpoint with --to-step")
+			}
+			if step < 0 || !hasStep(cps, step) {
+				return fmt.Errorf("task %s has no step %d; see: autocodit diff %s --list", id, step, id)
+			}
+
+			var discarded []checkpoint
+			for _, cp := range cps {
+				if cp.Step > step {
+					discarded = append(discarded, cp)
+				}
+			}
+			if len(discarded) == 0 {
+				return fmt.Errorf("step %d is the latest checkpoint; nothing to roll back", step)
+			}
+
+			var t Task
+			if err := c.DoJSON(ctx, http.MethodGet, "/api/v1/tasks/"+id, nil, &t); err != nil {
+				return err
+			}
+			if edit {
+				if instructions, err = editInstructions(t, cps, step); err != nil {
+					return err
+				}
+			}
+
+			if !yes {
+				fmt.Fprintf(os.Stderr, "Rolling back %s to step %d discards %d step(s):\n", id, step, len(discarded))
+				for _, cp := range discarded {
+					fmt.Fprintf(os.Stderr, "  %d  %s  %s\n", cp.Step, diffColumn(cp.DiffStats), redact(firstLine(cp.Summary)))
+				}
+				if !term.IsTerminal(int(os.Stdin.Fd())) {
+					return fmt.Errorf("refusing to roll back without confirmation; pass --yes")
+				}
+				if !confirm("Continue?") {
+					return fmt.Errorf("aborted")
+				}
+			}
+
+			req := map[string]any{"step": step}
+			if instructions != "" {
+				req["instructions"] = instructions
+			}
+			if err := c.DoJSON(ctx, http.MethodPost, "/api/v1/tasks/"+id+"/rollback", req, &t); err != nil {
+				return err
+			}
+			audit("task.rollback", map[string]any{"task": id, "step": step, "discarded": len(discarded), "amended": instructions != ""})
+			fmt.Printf("Rolled back %s to step %d; task is %s\n", id, step, t.Status)
+			if !watch {
+				return nil
+			}
+			_, err = c.waitTask(ctx, id, printProgress)
+			fmt.Println()
+			return err
+		},
+	}
+	cmd.Flags().IntVar(&step, "to-step", 0, "checkpoint to return to (0 is the original workspace)")
+	cmd.Flags().StringVarP(&instructions, "instructions", "m", "", "amended instructions for the agent when it resumes")
+	cmd.Flags().BoolVarP(&edit, "edit", "e", false, "write the amended instructions in $EDITOR")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "skip the confirmation prompt")
+	cmd.Flags().BoolVarP(&watch, "watch", "w", false, "watch the task after it resumes")
+	cmd.MarkFlagsMutuallyExclusive("instructions", "edit")
+	return cmd
+}
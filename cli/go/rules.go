@@ -0,0 +1,371 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+// rule maps an external event to a task. Match fields are glob patterns;
+// empty fields match anything.
+type rule struct {
+	Name     string    `yaml:"name" json:"name"`
+	When     ruleMatch `yaml:"when" json:"when"`
+	Then     ruleTask  `yaml:"then" json:"then"`
+	RemoteID string    `yaml:"remote_id,omitempty" json:"-"`
+}
+
+type ruleMatch struct {
+	Event  string `yaml:"event" json:"event"`
+	Repo   string `yaml:"repo,omitempty" json:"repo,omitempty"`
+	Branch string `yaml:"branch,omitempty" json:"branch,omitempty"`
+	Author string `yaml:"author,omitempty" json:"author,omitempty"`
+}
+
+type ruleTask struct {
+	Type        string `yaml:"type" json:"type"`
+	Priority    string `yaml:"priority,omitempty" json:"priority,omitempty"`
+	Title       string `yaml:"title,omitempty" json:"title,omitempty"`
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+}
+
+// ruleEvent is what rules are evaluated against, either from the daemon's
+// event feed or a payload given to rules test.
+type ruleEvent struct {
+	Event  string         `json:"event"`
+	Repo   string         `json:"repo"`
+	Branch string         `json:"branch"`
+	Author string         `json:"author"`
+	URL    string         `json:"url"`
+	Data   map[string]any `json:"data"`
+}
+
+func globMatch(pattern, s string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, _ := path.Match(pattern, s)
+	return ok
+}
+
+func (r rule) matches(ev ruleEvent) bool {
+	return globMatch(r.When.Event, ev.Event) && globMatch(r.When.Repo, ev.Repo) &&
+		globMatch(r.When.Branch, ev.Branch) && globMatch(r.When.Author, ev.Author)
+}
+
+func (r rule) request(ev ruleEvent) (CreateTaskRequest, error) {
+	render := func(name, text, def string) (string, error) {
+		if text == "" {
+			text = def
+		}
+		t, err := template.New(name).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return "", fmt.Errorf("rule %s: %s: %w", r.Name, name, err)
+		}
+		var b strings.Builder
+		if err := t.Execute(&b, ev); err != nil {
+			return "", fmt.Errorf("rule %s: %s: %w", r.Name, name, err)
+		}
+		return b.String(), nil
+	}
+	title, err := render("title", r.Then.Title, "{{.Event}} on {{.Repo}}")
+	if err != nil {
+		return CreateTaskRequest{}, err
+	}
+	desc, err := render("description", r.Then.Description, "Triggered by rule "+r.Name+" for {{.Event}} on {{.Repo}} {{.Branch}}\n{{.URL}}")
+	if err != nil {
+		return CreateTaskRequest{}, err
+	}
+	priority := r.Then.Priority
+	if priority == "" {
+		priority = "normal"
+	}
+	return CreateTaskRequest{
+		Title:       title,
+		Description: desc,
+		Repository:  ev.Repo,
+		ActionType:  r.Then.Type,
+		Priority:    priority,
+		AgentConfig: map[string]interface{}{"triggered_by": "rule:" + r.Name},
+	}, nil
+}
+
+func rulesPath() (string, error) {
+	return statePath("rules.yaml")
+}
+
+func loadRules() ([]rule, error) {
+	p, err := rulesPath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(p)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rules []rule
+	if err := yaml.Unmarshal(b, &rules); err != nil {
+		return nil, fmt.Errorf("%s: %w", p, err)
+	}
+	return rules, nil
+}
+
+func saveRules(rules []rule) error {
+	p, err := rulesPath()
+	if err != nil {
+		return err
+	}
+	b, err := yaml.Marshal(rules)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(p, b, 0o600)
+}
+
+func findRule(rules []rule, name string) int {
+	for i, r := range rules {
+		if r.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func cmdRules(c *Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "rules",
+		Short: "Manage event-driven automation rules",
+	}
+	cmd.AddCommand(cmdRulesAdd(c), cmdRulesList(), cmdRulesTest(), cmdRulesDelete(c))
+	return cmd
+}
+
+func cmdRulesAdd(c *Client) *cobra.Command {
+	var r rule
+	var localOnly bool
+	cmd := &cobra.Command{
+		Use:   "add [name]",
+		Short: "Add or replace a rule",
+		Long: `add registers the rule with the server as an automation, which creates the
+task when a matching event arrives; replacing a rule updates its automation.
+Servers without automations are refused, as nothing else evaluates rules;
+--local keeps a rule on this machine only, for rules test.`,
+		Example: `  autocodit rules add ci-main --on ci.failed --repo 'org/*' --branch main --create fix
+  autocodit rules add deps --on pr.opened --author 'dependabot*' --create review`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			r.Name = args[0]
+			if r.When.Event == "" || r.Then.Type == "" {
+				return fmt.Errorf("--on and --create are required")
+			}
+			if _, err := r.request(ruleEvent{}); err != nil {
+				return err
+			}
+			unlock, err := lockState("rules.yaml")
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			rules, err := loadRules()
+			if err != nil {
+				return err
+			}
+			i := findRule(rules, r.Name)
+			old := ""
+			if i >= 0 {
+				old = rules[i].RemoteID
+			}
+			var apiErr *sdk.APIError
+			switch {
+			case localOnly && old != "":
+				// The rule stops being the server's, so its automation goes.
+				err := c.DoJSON(cmd.Context(), http.MethodDelete, "/api/v1/automations/"+old, nil, nil)
+				if err != nil && !(errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound) {
+					return err
+				}
+			case !localOnly && old != "":
+				// Replace the automation in place so the old one stops firing.
+				err := c.DoJSON(cmd.Context(), http.MethodPatch, "/api/v1/automations/"+old, &r, nil)
+				switch {
+				case err == nil:
+					r.RemoteID = old
+				case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound:
+					// Deleted on the server since; register it afresh below.
+				default:
+					return err
+				}
+			}
+			if !localOnly && r.RemoteID == "" {
+				var created struct {
+					ID string `json:"id"`
+				}
+				err := c.DoJSON(cmd.Context(), http.MethodPost, "/api/v1/automations", &r, &created)
+				switch {
+				case err == nil:
+					r.RemoteID = created.ID
+				case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound:
+					return fmt.Errorf("the server does not support automations, so nothing would run rule %s; add it with --local to keep it for rules test only", r.Name)
+				default:
+					return err
+				}
+			}
+			if i >= 0 {
+				rules[i] = r
+			} else {
+				rules = append(rules, r)
+			}
+			if err := saveRules(rules); err != nil {
+				return err
+			}
+			where := "local, for rules test only"
+			if r.RemoteID != "" {
+				where = "server " + r.RemoteID
+			}
+			fmt.Printf("Rule %s saved (%s)\n", r.Name, where)
+			return nil
+		},
+	}
+	f := cmd.Flags()
+	f.StringVar(&r.When.Event, "on", "", "event to match, e.g. ci.failed, pr.opened")
+	f.StringVar(&r.When.Repo, "repo", "", "repository glob")
+	f.StringVar(&r.When.Branch, "branch", "", "branch glob")
+	f.StringVar(&r.When.Author, "author", "", "event author glob")
+	f.StringVar(&r.Then.Type, "create", "", "action type of the task to create")
+	f.StringVar(&r.Then.Priority, "priority", "", "priority of the created task")
+	f.StringVar(&r.Then.Title, "title", "", "title template, e.g. 'Fix CI on {{.Branch}}'")
+	f.StringVar(&r.Then.Description, "description", "", "description template")
+	f.BoolVar(&localOnly, "local", false, "do not register the rule with the server; it is only kept for rules test")
+	return cmd
+}
+
+func cmdRulesList() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List rules",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rules, err := loadRules()
+			if err != nil {
+				return err
+			}
+			type listedRule struct {
+				rule
+				RemoteID string `json:"remote_id,omitempty"`
+			}
+			out := make([]listedRule, len(rules))
+			for i, r := range rules {
+				out[i] = listedRule{r, r.RemoteID}
+			}
+			return printOutput(out, func() {
+				for _, r := range rules {
+					where := "local"
+					if r.RemoteID != "" {
+						where = "server"
+					}
+					fmt.Printf("%-16s %-6s when %s repo=%s branch=%s author=%s -> %s\n", r.Name, where,
+						r.When.Event, orAny(r.When.Repo), orAny(r.When.Branch), orAny(r.When.Author), r.Then.Type)
+				}
+			})
+		},
+	}
+}
+
+func orAny(s string) string {
+	if s == "" {
+		return "*"
+	}
+	return s
+}
+
+func cmdRulesTest() *cobra.Command {
+	var payload string
+	cmd := &cobra.Command{
+		Use:   "test [name]",
+		Short: "Show which rules match an event and the tasks they would create",
+		Example: `  autocodit rules test --payload '{"event":"ci.failed","repo":"org/api","branch":"main"}'
+  autocodit rules test ci-main --payload @event.json`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if strings.HasPrefix(payload, "@") {
+				b, err := os.ReadFile(payload[1:])
+				if err != nil {
+					return err
+				}
+				payload = string(b)
+			}
+			var ev ruleEvent
+			if err := json.Unmarshal([]byte(payload), &ev); err != nil {
+				return fmt.Errorf("--payload: %w", err)
+			}
+			rules, err := loadRules()
+			if err != nil {
+				return err
+			}
+			matched := 0
+			for _, r := range rules {
+				if len(args) == 1 && r.Name != args[0] {
+					continue
+				}
+				if !r.matches(ev) {
+					fmt.Printf("%s: no match\n", r.Name)
+					continue
+				}
+				matched++
+				req, err := r.request(ev)
+				if err != nil {
+					return err
+				}
+				out, _ := json.MarshalIndent(req, "", "  ")
+				fmt.Printf("%s: match, would create:\n%s\n", r.Name, out)
+			}
+			if matched == 0 {
+				return fmt.Errorf("no rule matches")
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&payload, "payload", "", "event JSON, or @file")
+	_ = cmd.MarkFlagRequired("payload")
+	return cmd
+}
+
+func cmdRulesDelete(c *Client) *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete [name]",
+		Short: "Delete a rule",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			unlock, err := lockState("rules.yaml")
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			rules, err := loadRules()
+			if err != nil {
+				return err
+			}
+			i := findRule(rules, args[0])
+			if i < 0 {
+				return fmt.Errorf("no rule %q", args[0])
+			}
+			if id := rules[i].RemoteID; id != "" {
+				if err := c.DoJSON(cmd.Context(), http.MethodDelete, "/api/v1/automations/"+id, nil, nil); err != nil {
+					return err
+				}
+			}
+			return saveRules(append(rules[:i], rules[i+1:]...))
+		},
+	}
+}
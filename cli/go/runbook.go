@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+// runbookMaxDiffs caps the diffs fetched to see which files fixes touched.
+const runbookMaxDiffs = 40
+
+// backtickCommand finds the failing command quickfix puts first in its
+// descriptions, e.g. "`go test ./...` fails".
+var backtickCommand = regexp.MustCompile("^`([^`]+)` fails")
+
+type runbookFix struct {
+	Task  string     `json:"task"`
+	Title string     `json:"title"`
+	PR    string     `json:"pr,omitempty"`
+	Files []fileStat `json:"files,omitempty"`
+}
+
+// runbookEntry is one recurring failure: fix tasks alike enough to be the
+// same problem.
+type runbookEntry struct {
+	Title       string       `json:"title"`
+	Occurrences int          `json:"occurrences"`
+	First       time.Time    `json:"first"`
+	Last        time.Time    `json:"last"`
+	Tasks       []string     `json:"tasks"`
+	Fixes       []runbookFix `json:"fixes,omitempty"`
+	Errors      []string     `json:"errors,omitempty"`
+	Command     string       `json:"command,omitempty"`
+	Suggestions []string     `json:"suggestions"`
+
+	tasks []Task
+	words map[string]bool
+}
+
+func runbookText(t Task) string {
+	return dedupeText(t.Title, firstLine(t.Description))
+}
+
+// clusterFixes groups tasks, oldest first, into the first cluster whose
+// founding task is at least threshold similar.
+func clusterFixes(tasks []Task, threshold float64) []*runbookEntry {
+	sort.SliceStable(tasks, func(i, j int) bool { return tasks[i].CreatedAt.Before(tasks[j].CreatedAt) })
+	var entries []*runbookEntry
+	for _, t := range tasks {
+		w := words(runbookText(t))
+		var best *runbookEntry
+		bestScore := threshold
+		for _, e := range entries {
+			if s := jaccard(w, e.words); s >= bestScore {
+				best, bestScore = e, s
+			}
+		}
+		if best == nil {
+			best = &runbookEntry{Title: firstLine(redact(t.Title)), First: t.CreatedAt, words: w}
+			entries = append(entries, best)
+		}
+		best.tasks = append(best.tasks, t)
+		best.Tasks = append(best.Tasks, t.ID)
+		best.Occurrences++
+		best.Last = t.CreatedAt
+	}
+	return entries
+}
+
+// explain fills in what the agent did about e and what might prevent it.
+func (c *Client) explain(ctx context.Context, e *runbookEntry, diffs *int) {
+	touched := map[string]int{}
+	failed := 0
+	seenErr := map[string]bool{}
+	for _, t := range e.tasks {
+		if m := backtickCommand.FindStringSubmatch(t.Description); m != nil && e.Command == "" {
+			e.Command = redact(m[1])
+		}
+		if t.Status != "completed" {
+			failed++
+			if msg := firstLine(redact(t.ErrorMessage)); msg != "" && !seenErr[msg] {
+				seenErr[msg] = true
+				e.Errors = append(e.Errors, msg)
+			}
+			continue
+		}
+		fix := runbookFix{Task: t.ID, Title: firstLine(redact(t.Title))}
+		if t.PRNumber != nil {
+			fix.PR = prURL(t.Repository, *t.PRNumber)
+		}
+		if *diffs < runbookMaxDiffs {
+			*diffs++
+			if patch, err := c.taskPatch(ctx, t.ID); err == nil {
+				fix.Files = patchFileStats(patch)
+				for _, f := range fix.Files {
+					touched[f.Path]++
+				}
+			}
+		}
+		e.Fixes = append(e.Fixes, fix)
+	}
+
+	days := int(e.Last.Sub(e.First).Hours()/24) + 1
+	if e.Occurrences >= 3 {
+		e.Suggestions = append(e.Suggestions, fmt.Sprintf("Recurred %d times in %d day(s): add a regression test or CI check that catches it before merge.", e.Occurrences, days))
+	}
+	if e.Command != "" {
+		e.Suggestions = append(e.Suggestions, fmt.Sprintf("Run `%s` in CI or a pre-commit hook so the failure shows up on the change that causes it.", e.Command))
+	}
+	var hot []string
+	for p, n := range touched {
+		if n >= 2 {
+			hot = append(hot, p)
+		}
+	}
+	sort.Strings(hot)
+	for _, p := range hot {
+		e.Suggestions = append(e.Suggestions, fmt.Sprintf("`%s` was changed by %d of the fixes: consider tests around it, a clearer owner, or a refactor.", p, touched[p]))
+	}
+	if failed > 0 {
+		e.Suggestions = append(e.Suggestions, fmt.Sprintf("The agent did not finish %d attempt(s); write down the manual fix here when it is known.", failed))
+	}
+	if len(e.Suggestions) == 0 {
+		e.Suggestions = append(e.Suggestions, "Note the root cause once known, so the next occurrence can be fixed from this page.")
+	}
+}
+
+func renderRunbook(repo string, since time.Time, total, oneOffs int, entries []*runbookEntry) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Runbook: %s\n\n", repo)
+	fmt.Fprintf(&b, "_Draft generated by autocodit from %d fix task(s) since %s. Review before publishing._\n",
+		total, since.Local().Format(time.DateOnly))
+	for i, e := range entries {
+		fmt.Fprintf(&b, "\n## %d. %s\n\n", i+1, e.Title)
+		fmt.Fprintf(&b, "- Seen %d times, %s to %s; fixed by the agent %d time(s)\n", e.Occurrences,
+			e.First.Local().Format(time.DateOnly), e.Last.Local().Format(time.DateOnly), len(e.Fixes))
+		if e.Command != "" {
+			fmt.Fprintf(&b, "- Failing command: `%s`\n", e.Command)
+		}
+		fmt.Fprintf(&b, "- Tasks: %s\n", strings.Join(e.Tasks, ", "))
+		if len(e.Fixes) > 0 {
+			b.WriteString("\n### What the agent did\n\n")
+			for _, f := range e.Fixes {
+				line := "- " + f.Title + " (" + f.Task
+				if f.PR != "" {
+					line += ", " + f.PR
+				}
+				line += ")"
+				if len(f.Files) > 0 {
+					var files []string
+					for _, fs := range f.Files {
+						files = append(files, fmt.Sprintf("`%s` +%d −%d", fs.Path, fs.Additions, fs.Deletions))
+					}
+					line += ": " + strings.Join(files, ", ")
+				}
+				b.WriteString(line + "\n")
+			}
+		}
+		if len(e.Errors) > 0 {
+			b.WriteString("\n### Errors seen\n\n")
+			for _, msg := range e.Errors {
+				fmt.Fprintf(&b, "- %s\n", msg)
+			}
+		}
+		b.WriteString("\n### Prevention\n\n")
+		for _, s := range e.Suggestions {
+			fmt.Fprintf(&b, "- %s\n", s)
+		}
+	}
+	if len(entries) == 0 {
+		b.WriteString("\nNo failure recurred in this period.\n")
+	}
+	if oneOffs > 0 {
+		fmt.Fprintf(&b, "\n_%d one-off fix(es) left out; lower --min-occurrences to include them._\n", oneOffs)
+	}
+	return b.String()
+}
+
+func cmdRunbook(c *Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "runbook",
+		Short: "Draft runbooks from the fixes the agent has made",
+	}
+	cmd.AddCommand(cmdRunbookSuggest(c))
+	return cmd
+}
+
+func cmdRunbookSuggest(c *Client) *cobra.Command {
+	var repo, since, out string
+	var minOccurrences int
+	var similarity float64
+	cmd := &cobra.Command{
+		Use:   "suggest",
+		Short: "Draft a markdown runbook from a repository's recurring fix tasks",
+		Long: `suggest groups a repository's finished fix tasks by how alike their titles and
+descriptions are, and for each failure that recurred writes a runbook section:
+how often and when it happened, what the agent changed (PRs and files), errors
+from attempts that failed, and suggested preventative actions. The result is a
+draft in markdown for the team wiki; text goes through secret redaction.
+
+  autocodit runbook suggest --repo org/api --since 180d --out docs/runbook.md`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			if repo == "" {
+				repo = c.cfg.DefaultRepo
+			}
+			if repo == "" {
+				return fmt.Errorf("--repo or default_repo required")
+			}
+			if similarity <= 0 || similarity > 1 {
+				return fmt.Errorf("--similarity must be above 0 and at most 1")
+			}
+			from, err := parseSince(since)
+			if err != nil {
+				return err
+			}
+			opts := sdk.ListTasksOptions{Repository: repo, ActionType: "fix", Since: from, PerPage: 100}
+			var fixes []Task
+			tasks, errc := c.ListTasks(opts).Stream(ctx)
+			for t := range tasks {
+				if t.ActionType == "fix" && isFinished(t.Status) && t.Status != "cancelled" && !t.CreatedAt.Before(from) {
+					fixes = append(fixes, t)
+				}
+			}
+			if err := <-errc; err != nil {
+				return err
+			}
+
+			var recurring []*runbookEntry
+			oneOffs := 0
+			diffs := 0
+			for _, e := range clusterFixes(fixes, similarity) {
+				if e.Occurrences < minOccurrences {
+					oneOffs += e.Occurrences
+					continue
+				}
+				recurring = append(recurring, e)
+			}
+			sort.SliceStable(recurring, func(i, j int) bool { return recurring[i].Occurrences > recurring[j].Occurrences })
+			for _, e := range recurring {
+				c.explain(ctx, e, &diffs)
+			}
+			if machineOutput() {
+				return printOutput(recurring, func() {})
+			}
+			doc := renderRunbook(repo, from, len(fixes), oneOffs, recurring)
+			if out == "" {
+				fmt.Print(doc)
+				return nil
+			}
+			if err := os.WriteFile(out, []byte(doc), 0o644); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Wrote %d section(s) to %s\n", len(recurring), out)
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&repo, "repo", "r", "", "owner/repo (default: default_repo)")
+	cmd.Flags().StringVar(&since, "since", "90d", "look at fix tasks created within this long (90d) or since a date")
+	cmd.Flags().IntVar(&minOccurrences, "min-occurrences", 2, "how many similar fixes make a failure recurring")
+	cmd.Flags().Float64Var(&similarity, "similarity", 0.5, "how alike (0-1, word overlap) two fixes must be to count as the same failure")
+	cmd.Flags().StringVar(&out, "out", "", "write the runbook to this file instead of stdout")
+	return cmd
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const maxRunFileSize = 5 << 20
+
+type runEvent struct {
+	Time  time.Time `json:"time"`
+	Event string    `json:"event"`
+	Task  *Task     `json:"task,omitempty"`
+	Error string    `json:"error,omitempty"`
+}
+
+// runRecorder appends what watch observed for a task to runs/<id>.jsonl.
+// Consecutive identical task states are collapsed into one entry.
+type runRecorder struct {
+	f    *os.File
+	last []byte
+}
+
+func openRunRecorder(id string, keep int) (*runRecorder, error) {
+	p, err := statePath("runs", id+".jsonl")
+	if err != nil {
+		return nil, err
+	}
+	if unlock, err := lockState("runs"); err == nil {
+		if fi, err := os.Stat(p); err == nil && fi.Size() > maxRunFileSize {
+			_ = os.Rename(p, p+".1")
+		}
+		pruneRuns(filepath.Dir(p), keep, id)
+		unlock()
+	}
+	f, err := os.OpenFile(p, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	r := &runRecorder{f: f}
+	r.write(runEvent{Event: "watch.start"})
+	return r, nil
+}
+
+// pruneRuns keeps the keep most recently modified run files, counting the
+// run about to be recorded for current, whose files are never removed.
+func pruneRuns(dir string, keep int, current string) {
+	if keep <= 0 {
+		return
+	}
+	all, _ := filepath.Glob(filepath.Join(dir, "*.jsonl*"))
+	var files []string
+	for _, f := range all {
+		if base := filepath.Base(f); base != current+".jsonl" && base != current+".jsonl.1" {
+			files = append(files, f)
+		}
+	}
+	if len(files) < keep {
+		return
+	}
+	mtime := map[string]time.Time{}
+	for _, f := range files {
+		if fi, err := os.Stat(f); err == nil {
+			mtime[f] = fi.ModTime()
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return mtime[files[i]].After(mtime[files[j]]) })
+	for _, f := range files[keep-1:] {
+		_ = os.Remove(f)
+	}
+}
+
+func (r *runRecorder) write(ev runEvent) {
+	if r == nil {
+		return
+	}
+	ev.Time = time.Now().UTC()
+	b, _ := json.Marshal(ev)
+	_, _ = r.f.Write(append(b, '\n'))
+}
+
+func (r *runRecorder) observe(t Task) {
+	if r == nil {
+		return
+	}
+	b, _ := json.Marshal(t)
+	if string(b) == string(r.last) {
+		return
+	}
+	r.last = b
+	r.write(runEvent{Event: "task.update", Task: &t})
+}
+
+func (r *runRecorder) close(err error) {
+	if r == nil {
+		return
+	}
+	ev := runEvent{Event: "watch.end"}
+	if err != nil {
+		ev.Error = err.Error()
+	}
+	r.write(ev)
+	r.f.Close()
+}
+
+func cmdRuns() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "runs",
+		Short: "Inspect recorded watch timelines",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List recorded runs",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dir, err := statePath("runs", "")
+			if err != nil {
+				return err
+			}
+			type recordedRun struct {
+				ID         string    `json:"id"`
+				ModifiedAt time.Time `json:"modified_at"`
+				Size       int64     `json:"size"`
+			}
+			runs := []recordedRun{}
+			files, _ := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+			for _, f := range files {
+				fi, err := os.Stat(f)
+				if err != nil {
+					continue
+				}
+				runs = append(runs, recordedRun{strings.TrimSuffix(filepath.Base(f), ".jsonl"), fi.ModTime(), fi.Size()})
+			}
+			return printOutput(runs, func() {
+				for _, r := range runs {
+					fmt.Printf("%-40s %s %8d bytes\n", r.ID, r.ModifiedAt.Format(time.DateTime), r.Size)
+				}
+			})
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "show [id]",
+		Short: "Print the recorded timeline of a task",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p, err := statePath("runs", args[0]+".jsonl")
+			if err != nil {
+				return err
+			}
+			f, err := os.Open(p)
+			if err != nil {
+				if os.IsNotExist(err) {
+					return fmt.Errorf("no recorded run for %s (record with watch --record)", args[0])
+				}
+				return err
+			}
+			defer f.Close()
+			var start time.Time
+			sc := bufio.NewScanner(f)
+			sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+			for sc.Scan() {
+				var ev runEvent
+				if json.Unmarshal(sc.Bytes(), &ev) != nil {
+					continue
+				}
+				if ev.Event == "watch.start" {
+					start = ev.Time
+				}
+				line := fmt.Sprintf("%s %+8s %-12s", ev.Time.Local().Format("15:04:05"), ev.Time.Sub(start).Round(time.Second), ev.Event)
+				if ev.Task != nil {
+					line += fmt.Sprintf(" %-10s %6.1f%%", ev.Task.Status, ev.Task.Progress*100)
+				}
+				if ev.Error != "" {
+					line += " error: " + ev.Error
+				}
+				fmt.Println(line)
+			}
+			return sc.Err()
+		},
+	})
+	return cmd
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// containerRuntime picks docker or podman, preferring the configured one.
+func containerRuntime(preferred string) (string, error) {
+	if preferred != "" {
+		if _, err := exec.LookPath(preferred); err != nil {
+			return "", fmt.Errorf("container runtime %q not found", preferred)
+		}
+		return preferred, nil
+	}
+	for _, rt := range []string{"docker", "podman"} {
+		if _, err := exec.LookPath(rt); err == nil {
+			return rt, nil
+		}
+	}
+	return "", fmt.Errorf("--container needs docker or podman on PATH")
+}
+
+// stripJSONC removes // and /* */ comments and trailing commas, which
+// devcontainer.json allows.
+func stripJSONC(b []byte) []byte {
+	var out bytes.Buffer
+	inString := false
+	for i := 0; i < len(b); i++ {
+		ch := b[i]
+		switch {
+		case inString:
+			out.WriteByte(ch)
+			if ch == '\\' && i+1 < len(b) {
+				i++
+				out.WriteByte(b[i])
+			} else if ch == '"' {
+				inString = false
+			}
+		case ch == '"':
+			inString = true
+			out.WriteByte(ch)
+		case ch == '/' && i+1 < len(b) && b[i+1] == '/':
+			for i < len(b) && b[i] != '\n' {
+				i++
+			}
+			out.WriteByte('\n')
+		case ch == '/' && i+1 < len(b) && b[i+1] == '*':
+			end := bytes.Index(b[i+2:], []byte("*/"))
+			if end < 0 {
+				i = len(b)
+			} else {
+				i += end + 3
+			}
+		case ch == ',':
+			rest := bytes.TrimLeft(b[i+1:], " \t\r\n")
+			if len(rest) > 0 && (rest[0] == '}' || rest[0] == ']') {
+				continue
+			}
+			out.WriteByte(ch)
+		default:
+			out.WriteByte(ch)
+		}
+	}
+	return out.Bytes()
+}
+
+type devcontainer struct {
+	Image      string `json:"image"`
+	DockerFile string `json:"dockerFile"`
+	Context    string `json:"context"`
+	Build      struct {
+		Dockerfile string `json:"dockerfile"`
+		Context    string `json:"context"`
+	} `json:"build"`
+}
+
+// devcontainerImage finds the devcontainer of the user's own checkout, not
+// the agent's branch, so a task cannot change the sandbox it is checked in.
+// It returns an image name, or a Dockerfile and build context to build.
+func devcontainerImage() (image, dockerfile, buildContext string, err error) {
+	top, err := git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", "", "", err
+	}
+	for _, p := range []string{filepath.Join(top, ".devcontainer", "devcontainer.json"), filepath.Join(top, ".devcontainer.json")} {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		var dc devcontainer
+		if err := json.Unmarshal(stripJSONC(b), &dc); err != nil {
+			return "", "", "", fmt.Errorf("%s: %w", p, err)
+		}
+		if dc.Image != "" {
+			return dc.Image, "", "", nil
+		}
+		file, ctxDir := dc.Build.Dockerfile, dc.Build.Context
+		if file == "" {
+			file, ctxDir = dc.DockerFile, dc.Context
+		}
+		if file == "" {
+			return "", "", "", fmt.Errorf("%s has neither image nor a Dockerfile", p)
+		}
+		if ctxDir == "" {
+			ctxDir = "."
+		}
+		dir := filepath.Dir(p)
+		return "", filepath.Join(dir, file), filepath.Join(dir, ctxDir), nil
+	}
+	return "", "", "", fmt.Errorf("no image configured and no devcontainer.json found; set verify_image or pass --image")
+}
+
+func buildImage(rt, dockerfile, buildContext string) (string, error) {
+	b, err := os.ReadFile(dockerfile)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	tag := "autocodit-verify:" + hex.EncodeToString(sum[:6])
+	if exec.Command(rt, "image", "inspect", tag).Run() == nil {
+		return tag, nil
+	}
+	fmt.Fprintf(os.Stderr, "%s %s\n", colorize(colorGray, "building"), dockerfile)
+	cmd := exec.Command(rt, "build", "-q", "-t", tag, "-f", dockerfile, buildContext)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("%s build: %w", rt, err)
+	}
+	return tag, nil
+}
+
+// containerVerifier starts one long-lived container with the worktree
+// mounted at /workspace and runs each command in it with exec, so setup
+// steps such as installing dependencies carry over to later commands. The
+// container gets no capabilities and, with noNetwork, no network.
+func containerVerifier(ctx context.Context, rt, image, dir string, noNetwork bool) (verifier, func(), error) {
+	args := []string{"run", "-d", "--rm", "--init",
+		"--cap-drop", "ALL", "--security-opt", "no-new-privileges",
+		"-v", dir + ":/workspace", "-w", "/workspace"}
+	if noNetwork {
+		args = append(args, "--network", "none")
+	}
+	if rt == "docker" && runtime.GOOS == "linux" {
+		// Keep files written to the mounted worktree owned by the user.
+		args = append(args, "--user", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()))
+	}
+	args = append(args, "--entrypoint", "sh", image, "-c", "while :; do sleep 3600; done")
+	var stdout, stderr bytes.Buffer
+	start := exec.CommandContext(ctx, rt, args...)
+	start.Stdout, start.Stderr = &stdout, &stderr
+	if err := start.Run(); err != nil {
+		return nil, nil, fmt.Errorf("%s run: %s", rt, strings.TrimSpace(stderr.String()))
+	}
+	id := strings.TrimSpace(stdout.String())
+	stop := func() { _ = exec.Command(rt, "rm", "-f", id).Run() }
+
+	run := func(ctx context.Context, _ string, command string) ([]byte, int, error) {
+		cmd := exec.CommandContext(ctx, rt, "exec", "-w", "/workspace", id, "sh", "-c", command)
+		var out bytes.Buffer
+		cmd.Stdout, cmd.Stderr = &out, &out
+		err := cmd.Run()
+		var exit *exec.ExitError
+		if errors.As(err, &exit) {
+			return out.Bytes(), exit.ExitCode(), nil
+		}
+		return out.Bytes(), 0, err
+	}
+	return run, stop, nil
+}
+
+// sandboxImage resolves the image for repo: --image, then the repo's and
+// the top-level verify_image, then the devcontainer.
+func (cfg *Config) sandboxImage(rt, repo, flag string) (string, error) {
+	if flag != "" {
+		return flag, nil
+	}
+	if img := cfg.Repos[strings.ToLower(repo)].VerifyImage; img != "" {
+		return img, nil
+	}
+	if cfg.VerifyImage != "" {
+		return cfg.VerifyImage, nil
+	}
+	image, dockerfile, buildContext, err := devcontainerImage()
+	if err != nil || image != "" {
+		return image, err
+	}
+	return buildImage(rt, dockerfile, buildContext)
+}
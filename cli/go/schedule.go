@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// schedule is a recurring task definition the server creates a task from
+// each time its cron expression fires.
+type schedule struct {
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	Cron       string            `json:"cron"`
+	Timezone   string            `json:"timezone"`
+	Paused     bool              `json:"paused"`
+	Task       CreateTaskRequest `json:"task"`
+	NextRunAt  *time.Time        `json:"next_run_at,omitempty"`
+	LastRunAt  *time.Time        `json:"last_run_at,omitempty"`
+	LastTaskID string            `json:"last_task_id,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+}
+
+// nextRun is when s runs next: as the server says, else worked out from
+// its cron expression. It is nil for a paused schedule.
+func (s schedule) nextRun(now time.Time) *time.Time {
+	if s.Paused {
+		return nil
+	}
+	if s.NextRunAt != nil {
+		return s.NextRunAt
+	}
+	spec, err := parseCron(s.Cron)
+	if err != nil {
+		return nil
+	}
+	loc, err := time.LoadLocation(pick(s.Timezone, "UTC"))
+	if err != nil {
+		return nil
+	}
+	if next := spec.next(now.In(loc)); !next.IsZero() {
+		return &next
+	}
+	return nil
+}
+
+// localTimezone is the IANA name of this machine's zone, which is what a
+// cron expression typed here is meant in.
+func localTimezone() string {
+	if tz := os.Getenv("TZ"); tz != "" {
+		if _, err := time.LoadLocation(tz); err == nil {
+			return tz
+		}
+	}
+	if target, err := filepath.EvalSymlinks("/etc/localtime"); err == nil {
+		if _, name, ok := strings.Cut(target, "zoneinfo/"); ok {
+			return name
+		}
+	}
+	if name := time.Local.String(); name != "Local" {
+		return name
+	}
+	return "UTC"
+}
+
+func untilText(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "in <1m"
+	case d < 48*time.Hour:
+		text := strings.TrimSuffix(d.Round(time.Minute).String(), "0s")
+		if strings.HasSuffix(text, "h0m") {
+			text = strings.TrimSuffix(text, "0m")
+		}
+		return "in " + text
+	}
+	return fmt.Sprintf("in %dd", int(d.Hours()/24))
+}
+
+func (c *Client) schedules(ctx context.Context) ([]schedule, error) {
+	var out []schedule
+	err := c.DoJSON(ctx, http.MethodGet, "/api/v1/schedules", nil, &out)
+	return out, err
+}
+
+// findSchedule looks ref up by ID or by name.
+func (c *Client) findSchedule(ctx context.Context, ref string) (schedule, error) {
+	all, err := c.schedules(ctx)
+	if err != nil {
+		return schedule{}, err
+	}
+	var found []schedule
+	for _, s := range all {
+		if s.ID == ref {
+			return s, nil
+		}
+		if s.Name == ref {
+			found = append(found, s)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return schedule{}, fmt.Errorf("no schedule %q; see: autocodit schedule list", ref)
+	case 1:
+		return found[0], nil
+	}
+	return schedule{}, fmt.Errorf("%d schedules are named %q; use the ID", len(found), ref)
+}
+
+func cmdSchedule(c *Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "schedule",
+		Short: "Manage recurring tasks the server creates on a cron schedule",
+	}
+	cmd.AddCommand(cmdScheduleCreate(c), cmdScheduleList(c), cmdSchedulePause(c, true), cmdSchedulePause(c, false), cmdScheduleDelete(c))
+	return cmd
+}
+
+func cmdScheduleCreate(c *Client) *cobra.Command {
+	var s schedule
+	var sets []string
+	var agentConfigFile string
+	cmd := &cobra.Command{
+		Use:   "create [description]",
+		Short: "Register a task to be created on a cron schedule",
+		Long: `create registers a recurring task with the server, which creates a task from it
+each time --cron fires, so it runs whether or not this machine is on. The cron
+expression has the usual five fields (minute hour day-of-month month
+day-of-week) or is one of @hourly, @daily, @weekly, @monthly, and @yearly, and
+is read in --timezone, by default this machine's.
+
+The task is given as to create: the description, --repo, --type, --priority,
+--agent-config, and --set. The branch of each run follows the repository's
+branch_pattern rather than being fixed now.
+
+  autocodit schedule create --cron "0 6 * * 1" --name weekly-deps \
+    --repo my-org/api --type apply "Upgrade dependencies to their latest minor versions"`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			spec, err := parseCron(s.Cron)
+			if err != nil {
+				return err
+			}
+			loc, err := time.LoadLocation(s.Timezone)
+			if err != nil {
+				return fmt.Errorf("--timezone: %w", err)
+			}
+			if spec.next(time.Now().In(loc)).IsZero() {
+				return fmt.Errorf("--cron %q never fires", s.Cron)
+			}
+			overrides, err := loadAgentConfigOverrides(agentConfigFile, sets)
+			if err != nil {
+				return err
+			}
+			t := &s.Task
+			if t.Repository == "" {
+				t.Repository = c.cfg.DefaultRepo
+			}
+			if t.Repository == "" {
+				return fmt.Errorf("--repo or default_repo required")
+			}
+			if !contains(actionTypes, t.ActionType) {
+				return fmt.Errorf("unknown --type %q (%s)", t.ActionType, strings.Join(actionTypes, "|"))
+			}
+			t.Description = args[0]
+			if t.Title == "" {
+				t.Title = fmt.Sprintf("%s task", t.ActionType)
+			}
+			ac := c.agentConfig(t.Repository, t.ActionType, t.Description, "")
+			// A branch named now would be reused by every run.
+			delete(ac, "branch_name")
+			if t.AgentConfig, err = overrides.apply(ac); err != nil {
+				return err
+			}
+			if s.Name == "" {
+				s.Name = slugify(firstLine(t.Description))
+			}
+			if err := c.checkDescriptionSize(cmd.Context(), t.Description); err != nil {
+				return err
+			}
+
+			body := map[string]any{"name": s.Name, "cron": s.Cron, "timezone": s.Timezone, "paused": s.Paused, "task": s.Task}
+			var created schedule
+			if err := c.DoJSON(cmd.Context(), http.MethodPost, "/api/v1/schedules", body, &created); err != nil {
+				return err
+			}
+			audit("schedule.create", map[string]any{"schedule": created.ID, "name": created.Name, "cron": created.Cron,
+				"timezone": created.Timezone, "repo": t.Repository, "type": t.ActionType})
+			return printOutput(created, func() {
+				fmt.Printf("Schedule created: %s (%s)\n", created.ID, created.Name)
+				if next := created.nextRun(time.Now()); next != nil {
+					fmt.Printf("Next run: %s (%s)\n", formatTime(next), untilText(time.Until(*next)))
+				} else if created.Paused {
+					fmt.Println("Paused; start it with: autocodit schedule resume", created.ID)
+				}
+			})
+		},
+	}
+	f := cmd.Flags()
+	f.StringVar(&s.Cron, "cron", "", `when to create the task, e.g. "0 6 * * 1" for Mondays at 06:00`)
+	f.StringVar(&s.Timezone, "timezone", localTimezone(), "IANA time zone the cron expression is read in")
+	f.StringVar(&s.Name, "name", "", "name to refer to the schedule by (default: from the description)")
+	f.StringVarP(&s.Task.Repository, "repo", "r", "", "owner/repo")
+	f.StringVarP(&s.Task.ActionType, "type", "t", "plan", strings.Join(actionTypes, "|"))
+	f.StringVarP(&s.Task.Priority, "priority", "p", "normal", "low|normal|high|urgent")
+	f.StringVar(&s.Task.Title, "title", "", "title of the tasks created")
+	f.StringVar(&agentConfigFile, "agent-config", "", "YAML or JSON file of agent_config settings, replacing the computed ones by key")
+	f.StringArrayVar(&sets, "set", nil, "agent_config key=value, repeatable; dotted keys reach nested settings, values are read as JSON")
+	f.BoolVar(&s.Paused, "paused", false, "register the schedule paused")
+	_ = cmd.MarkFlagRequired("cron")
+	return cmd
+}
+
+func cmdScheduleList(c *Client) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List schedules with when each runs next",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			all, err := c.schedules(cmd.Context())
+			if err != nil {
+				return err
+			}
+			now := time.Now()
+			for i := range all {
+				all[i].NextRunAt = all[i].nextRun(now)
+			}
+			// Soonest first; paused ones last.
+			sort.SliceStable(all, func(i, j int) bool {
+				a, b := all[i].NextRunAt, all[j].NextRunAt
+				if a == nil || b == nil {
+					return a != nil
+				}
+				return a.Before(*b)
+			})
+			if all == nil {
+				all = []schedule{}
+			}
+			return printOutput(all, func() {
+				if len(all) == 0 {
+					fmt.Println(`No schedules; add one with: autocodit schedule create --cron "0 6 * * 1" ...`)
+					return
+				}
+				tbl := newTable(os.Stdout, c.tableMaxWidth(), column{header: "ID"}, column{header: "NAME", flex: true},
+					column{header: "CRON"}, column{header: "REPO"}, column{header: "TYPE"}, column{header: "NEXT RUN"}, column{header: "LAST TASK"})
+				for _, s := range all {
+					next := colorize(colorYellow, "paused")
+					if s.NextRunAt != nil {
+						next = formatTime(s.NextRunAt) + colorize(colorGray, " "+untilText(s.NextRunAt.Sub(now)))
+					} else if !s.Paused {
+						next = "-"
+					}
+					cron := s.Cron
+					if s.Timezone != "" && s.Timezone != localTimezone() {
+						cron += colorize(colorGray, " "+s.Timezone)
+					}
+					tbl.add(s.ID, s.Name, cron, s.Task.Repository, s.Task.ActionType, next, pick(s.LastTaskID, "-"))
+				}
+				tbl.render()
+			})
+		},
+	}
+}
+
+// cmdSchedulePause builds schedule pause, or schedule resume when pause is
+// false.
+func cmdSchedulePause(c *Client, pause bool) *cobra.Command {
+	use, short, done := "resume [id|name]", "Resume a paused schedule", "resumed"
+	if pause {
+		use, short, done = "pause [id|name]", "Stop a schedule from creating tasks until it is resumed", "paused"
+	}
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			s, err := c.findSchedule(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			var updated schedule
+			if err := c.DoJSON(ctx, http.MethodPatch, "/api/v1/schedules/"+s.ID, map[string]bool{"paused": pause}, &updated); err != nil {
+				return err
+			}
+			audit("schedule."+strings.Fields(use)[0], map[string]any{"schedule": s.ID, "name": s.Name})
+			updated.NextRunAt = updated.nextRun(time.Now())
+			return printOutput(updated, func() {
+				fmt.Printf("Schedule %s %s\n", s.Name, done)
+				if next := updated.NextRunAt; next != nil {
+					fmt.Printf("Next run: %s (%s)\n", formatTime(next), untilText(time.Until(*next)))
+				}
+			})
+		},
+	}
+	cmd.ValidArgsFunction = c.completeSchedules
+	return cmd
+}
+
+func cmdScheduleDelete(c *Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "delete [id|name]",
+		Short: "Delete a schedule; tasks it already created are kept",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			s, err := c.findSchedule(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			if err := c.DoJSON(ctx, http.MethodDelete, "/api/v1/schedules/"+s.ID, nil, nil); err != nil {
+				return err
+			}
+			audit("schedule.delete", map[string]any{"schedule": s.ID, "name": s.Name})
+			fmt.Println("Schedule deleted:", s.Name)
+			return nil
+		},
+	}
+	cmd.ValidArgsFunction = c.completeSchedules
+	return cmd
+}
+
+func (c *Client) completeSchedules(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	all, err := c.schedules(cmd.Context())
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	var out []string
+	for _, s := range all {
+		if strings.HasPrefix(s.Name, toComplete) {
+			out = append(out, s.Name+"\t"+s.Cron)
+		}
+	}
+	return out, cobra.ShellCompDirectiveNoFileComp
+}
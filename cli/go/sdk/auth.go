@@ -0,0 +1,187 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DeviceCode is the server's answer to a device authorization request
+// (RFC 8628): the user visits VerificationURI and enters UserCode while the
+// client polls for the token.
+type DeviceCode struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval,omitempty"`
+}
+
+// Token is an OAuth access token, with a refresh token when the server
+// issues one.
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	TokenType    string `json:"token_type,omitempty"`
+	ExpiresIn    int    `json:"expires_in,omitempty"`
+}
+
+// Expiry returns when the token expires, or the zero time if it does not
+// say.
+func (t Token) Expiry(issued time.Time) time.Time {
+	if t.ExpiresIn <= 0 {
+		return time.Time{}
+	}
+	return issued.Add(time.Duration(t.ExpiresIn) * time.Second)
+}
+
+// OAuthError is an error response from the token endpoint.
+type OAuthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *OAuthError) Error() string {
+	if e.Description != "" {
+		return e.Code + ": " + e.Description
+	}
+	return e.Code
+}
+
+// ErrDeviceCodeExpired is returned by PollDeviceToken when the user did not
+// approve the request in time.
+var ErrDeviceCodeExpired = errors.New("the login code expired before it was approved")
+
+// postForm sends an unauthenticated form-encoded request, as the OAuth
+// endpoints expect, and decodes the JSON response into out.
+func (c *Client) postForm(ctx context.Context, path string, form url.Values, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.BaseURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if err := c.Limiter.Wait(ctx); err != nil {
+		return err
+	}
+	start := time.Now()
+	resp, err := send(c.httpClient(), req)
+	c.logAttempt(req, 0, start, resp, err)
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusBadRequest || apiErr.StatusCode == http.StatusUnauthorized) {
+			var oe OAuthError
+			if json.Unmarshal([]byte(apiErr.Body), &oe) == nil && oe.Code != "" {
+				return &oe
+			}
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// RequestDeviceCode starts the device authorization flow.
+func (c *Client) RequestDeviceCode(ctx context.Context, clientID, scope string) (*DeviceCode, error) {
+	form := url.Values{"client_id": {clientID}}
+	if scope != "" {
+		form.Set("scope", scope)
+	}
+	var dc DeviceCode
+	if err := c.postForm(ctx, "/api/v1/auth/device/code", form, &dc); err != nil {
+		return nil, err
+	}
+	if dc.DeviceCode == "" || dc.UserCode == "" {
+		return nil, fmt.Errorf("device authorization response is missing the device or user code")
+	}
+	return &dc, nil
+}
+
+// PollDeviceToken polls the token endpoint at the interval the server asks
+// for until the user approves or denies the request, or the code expires.
+func (c *Client) PollDeviceToken(ctx context.Context, clientID string, dc *DeviceCode) (*Token, error) {
+	interval := time.Duration(dc.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+	deadline := time.Now().Add(time.Duration(dc.ExpiresIn) * time.Second)
+	form := url.Values{
+		"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+		"device_code": {dc.DeviceCode},
+		"client_id":   {clientID},
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+		if dc.ExpiresIn > 0 && time.Now().After(deadline) {
+			return nil, ErrDeviceCodeExpired
+		}
+		var tok Token
+		err := c.postForm(ctx, "/api/v1/auth/token", form, &tok)
+		var oe *OAuthError
+		switch {
+		case err == nil:
+			return &tok, nil
+		case errors.As(err, &oe) && oe.Code == "authorization_pending":
+		case errors.As(err, &oe) && oe.Code == "slow_down":
+			interval += 5 * time.Second
+		case errors.As(err, &oe) && oe.Code == "expired_token":
+			return nil, ErrDeviceCodeExpired
+		default:
+			return nil, err
+		}
+	}
+}
+
+// RefreshToken exchanges a refresh token for a new access token.
+func (c *Client) RefreshToken(ctx context.Context, clientID, refreshToken string) (*Token, error) {
+	var tok Token
+	err := c.postForm(ctx, "/api/v1/auth/token", url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+		"client_id":     {clientID},
+	}, &tok)
+	if err != nil {
+		return nil, err
+	}
+	return &tok, nil
+}
+
+// ClientCredentialsToken authenticates a machine identity with the OAuth
+// client credentials grant (RFC 6749 section 4.4). These tokens carry no
+// refresh token; ask for a new one when it expires.
+func (c *Client) ClientCredentialsToken(ctx context.Context, clientID, clientSecret, scope string) (*Token, error) {
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {clientID},
+		"client_secret": {clientSecret},
+	}
+	if scope != "" {
+		form.Set("scope", scope)
+	}
+	var tok Token
+	if err := c.postForm(ctx, "/api/v1/auth/token", form, &tok); err != nil {
+		return nil, err
+	}
+	if tok.AccessToken == "" {
+		return nil, fmt.Errorf("token response has no access token")
+	}
+	return &tok, nil
+}
+
+// RevokeToken asks the server to invalidate token (RFC 7009).
+func (c *Client) RevokeToken(ctx context.Context, clientID, token string) error {
+	return c.postForm(ctx, "/api/v1/auth/revoke", url.Values{"token": {token}, "client_id": {clientID}}, nil)
+}
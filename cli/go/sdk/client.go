@@ -0,0 +1,139 @@
+// Package sdk is a Go client for the AutoCodit Agent API.
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Client talks to an AutoCodit API endpoint.
+type Client struct {
+	BaseURL string
+	Token   string
+	HTTP    *http.Client
+	// Limiter, if set, throttles every request sent through the client.
+	Limiter *RateLimiter
+	// OnOperation, if set, is called with each state of an asynchronous
+	// operation DoJSON is waiting on.
+	OnOperation func(Operation)
+	// Retry, if set, retries transient failures in Do.
+	Retry *RetryPolicy
+	// Hooks are callbacks for authentication, retries, and logging.
+	Hooks Hooks
+
+	middleware []Middleware
+}
+
+// New returns a Client for baseURL authenticating with token.
+func New(baseURL, token string) *Client {
+	return &Client{BaseURL: baseURL, Token: token, HTTP: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// NewRequest builds an authenticated request for path relative to BaseURL.
+func (c *Client) NewRequest(ctx context.Context, method, path string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	token := c.Token
+	if c.Hooks.Auth != nil {
+		if token, err = c.Hooks.Auth(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
+
+// Do sends req and converts HTTP error statuses into *APIError.
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	return c.sendWithRetry(req)
+}
+
+func send(hc *http.Client, req *http.Request) (*http.Response, error) {
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return nil, &APIError{StatusCode: resp.StatusCode, Status: resp.Status, Body: string(b), Header: resp.Header}
+	}
+	return resp, nil
+}
+
+// DoJSON sends in as the JSON request body and decodes the response into out.
+// Either may be nil. A 202 Accepted pointing at an operation is polled until
+// the operation finishes, and its result is decoded into out instead.
+func (c *Client) DoJSON(ctx context.Context, method, path string, in any, out any) error {
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := c.NewRequest(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusAccepted {
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		if op := c.operationPath(resp, b); op != "" {
+			return c.waitOperation(ctx, op, resp, out)
+		}
+		if out != nil && len(bytes.TrimSpace(b)) > 0 {
+			return json.Unmarshal(b, out)
+		}
+		return nil
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
+
+// APIError is returned for responses with a 4xx or 5xx status.
+type APIError struct {
+	StatusCode int
+	Status     string
+	Body       string
+	Header     http.Header
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Status, e.Body)
+}
+
+// Fetch issues a GET for path and returns the raw response body, which the
+// caller must close.
+func (c *Client) Fetch(ctx context.Context, path string) (io.ReadCloser, error) {
+	req, err := c.NewRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "*/*")
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,115 @@
+package sdk
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Event is one server-sent event from a task event stream.
+type Event struct {
+	ID   string          `json:"id,omitempty"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// EventStream delivers server-sent events until the server closes the
+// connection or the context is cancelled.
+type EventStream struct {
+	body   io.ReadCloser
+	events chan Event
+	err    error
+}
+
+// StreamTaskEvents subscribes to GET /api/v1/tasks/{id}/events. If lastID is
+// set it is sent as Last-Event-ID so the server can replay missed events.
+func (c *Client) StreamTaskEvents(ctx context.Context, id, lastID string) (*EventStream, error) {
+	req, err := c.NewRequest(ctx, http.MethodGet, "/api/v1/tasks/"+id+"/events", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+	if err := c.Limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	// Streams go through the middleware like any request, but outlive the
+	// client's request timeout.
+	hc := *c.httpClient()
+	hc.Timeout = 0
+	resp, err := send(&hc, req)
+	if err != nil {
+		return nil, err
+	}
+	s := &EventStream{body: resp.Body, events: make(chan Event)}
+	go s.read(ctx)
+	return s, nil
+}
+
+// Events returns the channel of received events. It is closed when the
+// stream ends; Err reports why.
+func (s *EventStream) Events() <-chan Event {
+	return s.events
+}
+
+// Err returns the error that ended the stream, if any. It is only valid
+// after the Events channel is closed.
+func (s *EventStream) Err() error {
+	return s.err
+}
+
+// Close terminates the stream.
+func (s *EventStream) Close() error {
+	return s.body.Close()
+}
+
+func (s *EventStream) read(ctx context.Context) {
+	defer close(s.events)
+	defer s.body.Close()
+	sc := bufio.NewScanner(s.body)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	var ev Event
+	var data []string
+	for sc.Scan() {
+		line := sc.Text()
+		if line == "" {
+			if len(data) > 0 {
+				ev.Data = json.RawMessage(strings.Join(data, "\n"))
+				if ev.Type == "" {
+					ev.Type = "message"
+				}
+				select {
+				case s.events <- ev:
+				case <-ctx.Done():
+					s.err = ctx.Err()
+					return
+				}
+			}
+			ev, data = Event{ID: ev.ID}, nil
+			continue
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			ev.Type = value
+		case "data":
+			data = append(data, value)
+		case "id":
+			ev.ID = value
+		}
+	}
+	if err := sc.Err(); err != nil && ctx.Err() == nil {
+		s.err = err
+	} else if ctx.Err() != nil {
+		s.err = ctx.Err()
+	}
+}
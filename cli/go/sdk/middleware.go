@@ -0,0 +1,77 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Middleware wraps the transport a Client sends requests through. It sees
+// every attempt, retries included, after authentication headers are set,
+// so it can add headers, record telemetry, or answer from a cache.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc lets a plain function serve as an http.RoundTripper.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// Hooks are typed callbacks for the points integrators most often need.
+// Any of them may be nil.
+type Hooks struct {
+	// Auth supplies the bearer token for each request in place of Token,
+	// e.g. to fetch or refresh it from a secret store.
+	Auth func(ctx context.Context) (string, error)
+	// Retry is called before each retry, alongside RetryPolicy.OnRetry.
+	Retry func(req *http.Request, attempt int, delay time.Duration, err error)
+	// Log is called after each attempt with its outcome.
+	Log func(RequestLog)
+}
+
+// RequestLog describes one attempt at sending a request.
+type RequestLog struct {
+	Method   string
+	URL      string
+	Attempt  int // 0 for the first try
+	Status   int // 0 when no response arrived
+	Duration time.Duration
+	Err      error
+}
+
+// Use appends mw to the client's middleware chain. The first added is the
+// outermost: it sees requests first and responses last.
+func (c *Client) Use(mw ...Middleware) {
+	c.middleware = append(c.middleware, mw...)
+}
+
+// httpClient returns c.HTTP with the middleware chain in front of its
+// transport.
+func (c *Client) httpClient() *http.Client {
+	if len(c.middleware) == 0 {
+		return c.HTTP
+	}
+	hc := *c.HTTP
+	rt := hc.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		rt = c.middleware[i](rt)
+	}
+	hc.Transport = rt
+	return &hc
+}
+
+func (c *Client) logAttempt(req *http.Request, attempt int, start time.Time, resp *http.Response, err error) {
+	if c.Hooks.Log == nil {
+		return
+	}
+	l := RequestLog{Method: req.Method, URL: req.URL.Redacted(), Attempt: attempt, Duration: time.Since(start), Err: err}
+	if resp != nil {
+		l.Status = resp.StatusCode
+	}
+	if apiErr, ok := err.(*APIError); ok {
+		l.Status = apiErr.StatusCode
+	}
+	c.Hooks.Log(l)
+}
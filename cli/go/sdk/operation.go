@@ -0,0 +1,156 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Operation is a long-running server job started by a 202 Accepted response,
+// such as a bulk cancel or a large export.
+type Operation struct {
+	ID       string  `json:"id"`
+	Status   string  `json:"status"`
+	Progress float64 `json:"progress"`
+	Message  string  `json:"message,omitempty"`
+	Error    string  `json:"error,omitempty"`
+	// Result is the final response body, inlined when the operation is done.
+	Result json.RawMessage `json:"result,omitempty"`
+	// ResultURL is fetched for the final response when Result is empty.
+	ResultURL string `json:"result_url,omitempty"`
+}
+
+// Done reports whether the operation has stopped, successfully or not.
+func (o Operation) Done() bool {
+	switch o.Status {
+	case "succeeded", "completed", "done", "failed", "cancelled", "canceled":
+		return true
+	}
+	return false
+}
+
+func (o Operation) failed() bool {
+	return o.Status == "failed" || o.Status == "cancelled" || o.Status == "canceled"
+}
+
+// OperationError is returned when an operation finishes unsuccessfully.
+type OperationError struct {
+	Operation Operation
+}
+
+func (e *OperationError) Error() string {
+	msg := e.Operation.Error
+	if msg == "" {
+		msg = e.Operation.Message
+	}
+	if msg == "" {
+		return fmt.Sprintf("operation %s %s", e.Operation.ID, e.Operation.Status)
+	}
+	return fmt.Sprintf("operation %s %s: %s", e.Operation.ID, e.Operation.Status, msg)
+}
+
+// operationPath finds where to poll a 202 response: the Operation-Location or
+// Location header, or an operation_url (or an operation id) in the body.
+func (c *Client) operationPath(resp *http.Response, body []byte) string {
+	u := resp.Header.Get("Operation-Location")
+	if u == "" {
+		u = resp.Header.Get("Location")
+	}
+	if u == "" {
+		var b struct {
+			OperationURL string `json:"operation_url"`
+			OperationID  string `json:"operation_id"`
+		}
+		json.Unmarshal(body, &b)
+		u = b.OperationURL
+		if u == "" && b.OperationID != "" {
+			u = "/api/v1/operations/" + b.OperationID
+		}
+	}
+	if u == "" {
+		return ""
+	}
+	return c.relPath(u)
+}
+
+// retryAfter honors a Retry-After of whole seconds, else backs off from 1s
+// to 5s.
+func retryAfter(resp *http.Response, n int) time.Duration {
+	if resp != nil {
+		if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s > 0 {
+			return time.Duration(s) * time.Second
+		}
+	}
+	d := time.Duration(n+1) * time.Second
+	if d > 5*time.Second {
+		d = 5 * time.Second
+	}
+	return d
+}
+
+// waitOperation polls path until the operation finishes, reporting each
+// state to OnOperation, and decodes the final result into out.
+func (c *Client) waitOperation(ctx context.Context, path string, first *http.Response, out any) error {
+	delay := retryAfter(first, 0)
+	for n := 0; ; n++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		req, err := c.NewRequest(ctx, http.MethodGet, path, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := c.Do(req)
+		if err != nil {
+			return err
+		}
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		var op Operation
+		if err := json.Unmarshal(b, &op); err != nil {
+			return fmt.Errorf("decoding operation: %w", err)
+		}
+		if c.OnOperation != nil {
+			c.OnOperation(op)
+		}
+		if !op.Done() {
+			delay = retryAfter(resp, n+1)
+			continue
+		}
+		if op.failed() {
+			return &OperationError{Operation: op}
+		}
+		if out == nil {
+			return nil
+		}
+		if len(op.Result) > 0 {
+			return json.Unmarshal(op.Result, out)
+		}
+		if op.ResultURL != "" {
+			return c.DoJSON(ctx, http.MethodGet, c.relPath(op.ResultURL), nil, out)
+		}
+		return nil
+	}
+}
+
+// relPath turns a URL the server returned into a path relative to BaseURL.
+func (c *Client) relPath(u string) string {
+	if strings.HasPrefix(u, c.BaseURL) {
+		return strings.TrimPrefix(u, c.BaseURL)
+	}
+	if p, err := url.Parse(u); err == nil && p.IsAbs() {
+		return p.RequestURI()
+	}
+	return u
+}
@@ -0,0 +1,182 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ListTasksOptions filters and sizes GET /api/v1/tasks.
+type ListTasksOptions struct {
+	Status     string
+	Repository string
+	ActionType string
+	Priority   string
+	User       string
+	AllUsers   bool
+	// CreatedBy is "human" or "service" to split people's tasks from
+	// machine identities'.
+	CreatedBy string
+	// IDPrefix limits results to tasks whose ID starts with it.
+	IDPrefix string
+	// SLABreached limits results to tasks past their SLA deadline.
+	SLABreached bool
+	// Deleted lists the trash, deleted tasks still within the server's
+	// retention window, instead of live tasks.
+	Deleted bool
+	// Since limits results to tasks created at or after it.
+	Since time.Time
+	// Expand asks the server to inline related data, e.g. "diff_stats".
+	Expand  []string
+	Page    int
+	PerPage int
+	// Limit stops the pager after this many tasks; 0 means no limit.
+	Limit int
+}
+
+func (o ListTasksOptions) query(page int) url.Values {
+	q := url.Values{}
+	set := func(k, v string) {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	set("status", o.Status)
+	set("repository", o.Repository)
+	set("action_type", o.ActionType)
+	set("priority", o.Priority)
+	set("user", o.User)
+	set("created_by", o.CreatedBy)
+	set("id_prefix", o.IDPrefix)
+	if o.AllUsers {
+		q.Set("all_users", "true")
+	}
+	if o.SLABreached {
+		q.Set("sla_breached", "true")
+	}
+	if o.Deleted {
+		q.Set("deleted", "true")
+	}
+	if !o.Since.IsZero() {
+		q.Set("since", o.Since.UTC().Format(time.RFC3339))
+	}
+	if len(o.Expand) > 0 {
+		q.Set("expand", strings.Join(o.Expand, ","))
+	}
+	q.Set("page", strconv.Itoa(page))
+	perPage := o.PerPage
+	if o.Limit > 0 && (perPage == 0 || o.Limit < perPage) {
+		perPage = o.Limit
+	}
+	if perPage > 0 {
+		q.Set("per_page", strconv.Itoa(perPage))
+	}
+	return q
+}
+
+// ListTasksPager walks the pages of a task listing, following the server's
+// next links on cursor-paginated deployments and page numbers elsewhere.
+type ListTasksPager struct {
+	c    *Client
+	opts ListTasksOptions
+	page int
+	next string
+	seen int
+	done bool
+}
+
+// ListTasks returns a pager starting at opts.Page (default 1).
+func (c *Client) ListTasks(opts ListTasksOptions) *ListTasksPager {
+	page := opts.Page
+	if page < 1 {
+		page = 1
+	}
+	return &ListTasksPager{c: c, opts: opts, page: page}
+}
+
+// More reports whether NextPage may return another page.
+func (p *ListTasksPager) More() bool {
+	return !p.done
+}
+
+// NextPage fetches the current page and advances the pager.
+func (p *ListTasksPager) NextPage(ctx context.Context) (*TaskList, error) {
+	var list TaskList
+	path := "/api/v1/tasks?" + p.opts.query(p.page).Encode()
+	if p.next != "" {
+		path = p.next
+	}
+	if err := p.c.DoJSON(ctx, http.MethodGet, path, nil, &list); err != nil {
+		return nil, err
+	}
+	p.page++
+	p.done = !list.HasNext && list.Next == ""
+	if list.Next != "" {
+		next, err := p.c.resolveNext(path, list.Next)
+		if err != nil {
+			return nil, err
+		}
+		p.next = next
+	} else {
+		p.next = ""
+	}
+	if p.opts.Limit > 0 {
+		if rest := p.opts.Limit - p.seen; len(list.Items) >= rest {
+			list.Items = list.Items[:rest]
+			p.done = true
+		}
+		p.seen += len(list.Items)
+	}
+	return &list, nil
+}
+
+// resolveNext turns a next link, absolute or relative to the page it came
+// from, into a path on c. Links to another host are refused so the token is
+// never sent there.
+func (c *Client) resolveNext(from, next string) (string, error) {
+	base, err := url.Parse(c.BaseURL + from)
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(next)
+	if err != nil {
+		return "", fmt.Errorf("bad next link %q: %w", next, err)
+	}
+	abs := base.ResolveReference(ref).String()
+	if !strings.HasPrefix(abs, c.BaseURL+"/") {
+		return "", fmt.Errorf("next link %q points outside %s", next, c.BaseURL)
+	}
+	return strings.TrimPrefix(abs, c.BaseURL), nil
+}
+
+// Stream fetches pages in the background and delivers tasks one at a time.
+// Both channels are closed once the listing is exhausted, ctx is cancelled,
+// or a request fails; at most one error is sent.
+func (p *ListTasksPager) Stream(ctx context.Context) (<-chan Task, <-chan error) {
+	tasks := make(chan Task)
+	errc := make(chan error, 1)
+	go func() {
+		defer close(tasks)
+		defer close(errc)
+		for p.More() {
+			list, err := p.NextPage(ctx)
+			if err != nil {
+				errc <- err
+				return
+			}
+			for _, t := range list.Items {
+				select {
+				case tasks <- t:
+				case <-ctx.Done():
+					errc <- ctx.Err()
+					return
+				}
+			}
+		}
+	}()
+	return tasks, errc
+}
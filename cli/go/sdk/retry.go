@@ -0,0 +1,146 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy retries requests that failed for reasons likely to pass: a
+// network error, 429 Too Many Requests, or a 5xx. Requests that change state
+// are retried only when the server cannot have acted on them, unless they
+// carry an Idempotency-Key.
+type RetryPolicy struct {
+	// Max is the number of retries after the first attempt.
+	Max int
+	// Base is the delay before the first retry; each later one doubles it,
+	// up to MaxDelay, with jitter. A Retry-After header overrides both.
+	Base     time.Duration
+	MaxDelay time.Duration
+	// OnRetry, if set, is called before each retry.
+	OnRetry func(attempt int, delay time.Duration, err error)
+}
+
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// retryable reports whether err, from sending req, is worth another try.
+func retryable(req *http.Request, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests:
+			return true
+		case http.StatusServiceUnavailable:
+			// Unavailable with Retry-After means "not processed, come back".
+			return idempotent(req) || apiErr.Header.Get("Retry-After") != ""
+		case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+			return idempotent(req)
+		}
+		return false
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		// Never reached the server.
+		return true
+	}
+	return idempotent(req)
+}
+
+// parseRetryAfter reads a Retry-After of seconds or an HTTP date.
+func parseRetryAfter(h http.Header) (time.Duration, bool) {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(v); err == nil && s >= 0 {
+		return time.Duration(s) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t), true
+	}
+	return 0, false
+}
+
+func (p *RetryPolicy) delay(attempt int, err error) time.Duration {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.Header != nil {
+		if d, ok := parseRetryAfter(apiErr.Header); ok {
+			return d
+		}
+	}
+	d := p.Base
+	if d <= 0 {
+		d = 500 * time.Millisecond
+	}
+	for i := 0; i < attempt && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	// Equal jitter: at least half the delay, so clients spread out without
+	// retrying immediately.
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// sendWithRetry sends req, retrying under p. The request body is replayed
+// from req.GetBody.
+func (c *Client) sendWithRetry(req *http.Request) (*http.Response, error) {
+	p := c.Retry
+	hc := c.httpClient()
+	for attempt := 0; ; attempt++ {
+		if err := c.Limiter.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+		start := time.Now()
+		resp, err := send(hc, req)
+		c.logAttempt(req, attempt, start, resp, err)
+		if err == nil || p == nil || attempt >= p.Max || !retryable(req, err) {
+			return resp, err
+		}
+		if req.Body != nil && req.Body != http.NoBody {
+			if req.GetBody == nil {
+				return nil, err
+			}
+			body, gerr := req.GetBody()
+			if gerr != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+		d := p.delay(attempt, err)
+		if p.OnRetry != nil {
+			p.OnRetry(attempt+1, d, err)
+		}
+		if c.Hooks.Retry != nil {
+			c.Hooks.Retry(req, attempt+1, d, err)
+		}
+		if werr := sleepCtx(req.Context(), d); werr != nil {
+			return nil, err
+		}
+	}
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
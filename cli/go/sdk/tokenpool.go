@@ -0,0 +1,308 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PoolToken is one credential in a TokenPool. Weight is its share of
+// requests relative to the others; 0 counts as 1.
+type PoolToken struct {
+	Name   string
+	Token  string
+	Weight int
+}
+
+// TokenStats is what a TokenPool has learned about one of its tokens.
+type TokenStats struct {
+	Name     string `json:"name"`
+	Weight   int    `json:"weight"`
+	Requests int    `json:"requests"`
+	Errors   int    `json:"errors"`
+	// Limit and Remaining are the server's last rate limit headers for the
+	// token; -1 when it has not sent any.
+	Limit     int        `json:"limit"`
+	Remaining int        `json:"remaining"`
+	Reset     *time.Time `json:"reset,omitempty"`
+	// CoolingUntil is set after a 429 or an exhausted limit: the token is
+	// skipped until then while another one is available.
+	CoolingUntil *time.Time `json:"cooling_until,omitempty"`
+	// Unhealthy is set when the server rejected the token: a 401, or a 403
+	// saying the token is invalid.
+	Unhealthy  bool `json:"unhealthy,omitempty"`
+	LastStatus int  `json:"last_status,omitempty"`
+}
+
+// Available reports whether the pool would pick the token at now.
+func (s TokenStats) Available(now time.Time) bool {
+	return !s.Unhealthy && (s.CoolingUntil == nil || !now.Before(*s.CoolingUntil))
+}
+
+// Resets is when the token's rate limit window ends, if that is known and
+// still ahead.
+func (s TokenStats) Resets(now time.Time) (time.Time, bool) {
+	if s.Reset == nil || !s.Reset.After(now) {
+		return time.Time{}, false
+	}
+	return *s.Reset, true
+}
+
+type poolEntry struct {
+	token   string
+	current int
+	stats   TokenStats
+}
+
+// TokenPool spreads requests over several tokens by smooth weighted
+// round-robin, to pool their rate limits where the server's terms allow it.
+// Tokens that hit their limit cool down until it resets, and tokens the
+// server rejects are dropped from rotation.
+type TokenPool struct {
+	mu      sync.Mutex
+	entries []*poolEntry
+}
+
+// NewTokenPool returns a pool over tokens, which must have distinct names.
+func NewTokenPool(tokens []PoolToken) (*TokenPool, error) {
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("token pool is empty")
+	}
+	p := &TokenPool{}
+	seen := map[string]bool{}
+	total := 0
+	for _, t := range tokens {
+		if t.Token == "" {
+			return nil, fmt.Errorf("token pool entry %q has no token", t.Name)
+		}
+		if seen[t.Name] {
+			return nil, fmt.Errorf("token pool has two entries named %q", t.Name)
+		}
+		seen[t.Name] = true
+		w := t.Weight
+		if w <= 0 {
+			w = 1
+		}
+		p.entries = append(p.entries, &poolEntry{token: t.Token, stats: TokenStats{Name: t.Name, Weight: w, Limit: -1, Remaining: -1}})
+		total += w
+	}
+	// Start at a random point in the rotation so that clients making a
+	// request or two each still spread over the pool by weight.
+	for i := rand.Intn(total); i > 0; i-- {
+		p.pick(nil)
+	}
+	return p, nil
+}
+
+// pick chooses the next token, skipping exclude. When every token is
+// cooling down the one that recovers first is used; when all are unhealthy,
+// none is.
+func (p *TokenPool) pick(exclude map[*poolEntry]bool) *poolEntry {
+	now := time.Now()
+	var best, soonest *poolEntry
+	total := 0
+	for _, e := range p.entries {
+		if exclude[e] || e.stats.Unhealthy {
+			continue
+		}
+		if !e.stats.Available(now) {
+			if soonest == nil || e.stats.CoolingUntil.Before(*soonest.stats.CoolingUntil) {
+				soonest = e
+			}
+			continue
+		}
+		e.current += e.stats.Weight
+		total += e.stats.Weight
+		if best == nil || e.current > best.current {
+			best = e
+		}
+	}
+	if best == nil {
+		return soonest
+	}
+	best.current -= total
+	return best
+}
+
+// have reports whether a token other than those in exclude is available.
+func (p *TokenPool) have(exclude map[*poolEntry]bool) bool {
+	now := time.Now()
+	for _, e := range p.entries {
+		if !exclude[e] && e.stats.Available(now) {
+			return true
+		}
+	}
+	return false
+}
+
+func headerInt(h http.Header, names ...string) (int, bool) {
+	for _, n := range names {
+		if v, err := strconv.Atoi(h.Get(n)); err == nil {
+			return v, true
+		}
+	}
+	return 0, false
+}
+
+// observe records the outcome of a request sent with e.
+func (p *TokenPool) observe(e *poolEntry, resp *http.Response, err error) {
+	now := time.Now()
+	e.stats.Requests++
+	if err != nil {
+		e.stats.Errors++
+		return
+	}
+	e.stats.LastStatus = resp.StatusCode
+	h := resp.Header
+	if v, ok := headerInt(h, "X-RateLimit-Limit", "RateLimit-Limit"); ok {
+		e.stats.Limit = v
+	}
+	if v, ok := headerInt(h, "X-RateLimit-Remaining", "RateLimit-Remaining"); ok {
+		e.stats.Remaining = v
+	}
+	if v, ok := headerInt(h, "X-RateLimit-Reset", "RateLimit-Reset"); ok {
+		// Epoch seconds, or seconds from now as in the IETF draft.
+		reset := now.Add(time.Duration(v) * time.Second)
+		if v > 1_000_000_000 {
+			reset = time.Unix(int64(v), 0)
+		}
+		e.stats.Reset = &reset
+	}
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden && invalidToken(h):
+		// A plain 403 is about the resource, not the token: the caller
+		// gets it as is and the token stays in rotation.
+		e.stats.Errors++
+		e.stats.Unhealthy = true
+	case resp.StatusCode == http.StatusTooManyRequests:
+		e.stats.Errors++
+		until := now.Add(30 * time.Second)
+		if d, ok := parseRetryAfter(h); ok {
+			until = now.Add(d)
+		} else if reset, ok := e.stats.Resets(now); ok {
+			until = reset
+		}
+		e.stats.CoolingUntil = &until
+	case resp.StatusCode >= 500:
+		e.stats.Errors++
+	case e.stats.Remaining == 0:
+		if reset, ok := e.stats.Resets(now); ok {
+			e.stats.CoolingUntil = &reset
+		}
+	}
+}
+
+// invalidToken reports whether the response says the token itself was
+// rejected, as RFC 6750 has servers do with WWW-Authenticate.
+func invalidToken(h http.Header) bool {
+	return strings.Contains(h.Get("WWW-Authenticate"), `error="invalid_token"`)
+}
+
+type poolKey struct{}
+
+// WithPoolToken makes requests sent with ctx use the named pool token
+// instead of rotating, e.g. to check each token's limits.
+func WithPoolToken(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, poolKey{}, name)
+}
+
+// Middleware sets each attempt's bearer token from the pool and learns from
+// the response. A 429 or a rejected token is retried at once with another
+// token while one is available, so one token doesn't stall the rest.
+func (p *TokenPool) Middleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			pinned, _ := req.Context().Value(poolKey{}).(string)
+			tried := map[*poolEntry]bool{}
+			for {
+				p.mu.Lock()
+				var e *poolEntry
+				if pinned == "" {
+					e = p.pick(tried)
+				}
+				for _, pe := range p.entries {
+					if pinned != "" && pe.stats.Name == pinned {
+						e = pe
+					}
+				}
+				p.mu.Unlock()
+				if e == nil {
+					return nil, fmt.Errorf("no usable token in the token pool")
+				}
+				r := req.Clone(req.Context())
+				if len(tried) > 0 && req.GetBody != nil {
+					body, err := req.GetBody()
+					if err != nil {
+						return nil, err
+					}
+					r.Body = body
+				}
+				r.Header.Set("Authorization", "Bearer "+e.token)
+				resp, err := next.RoundTrip(r)
+
+				p.mu.Lock()
+				p.observe(e, resp, err)
+				tried[e] = true
+				again := pinned == "" && err == nil && (e.stats.Unhealthy || resp.StatusCode == http.StatusTooManyRequests) &&
+					(req.Body == nil || req.GetBody != nil) && p.have(tried)
+				p.mu.Unlock()
+				if !again {
+					return resp, err
+				}
+				_, _ = io.Copy(io.Discard, resp.Body)
+				resp.Body.Close()
+			}
+		})
+	}
+}
+
+// Token returns the next token in rotation for connections the middleware
+// doesn't see, such as WebSockets.
+func (p *TokenPool) Token() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if e := p.pick(nil); e != nil {
+		e.stats.Requests++
+		return e.token
+	}
+	return ""
+}
+
+// Stats returns what the pool knows about each token, in configured order.
+func (p *TokenPool) Stats() []TokenStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := make([]TokenStats, len(p.entries))
+	for i, e := range p.entries {
+		out[i] = e.stats
+	}
+	return out
+}
+
+// Restore carries limits and cool-downs over from an earlier process's
+// Stats, so short-lived clients don't rediscover an exhausted token.
+// Counters, health, and limits past their reset are not carried over.
+func (p *TokenPool) Restore(stats []TokenStats) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	for _, s := range stats {
+		for _, e := range p.entries {
+			if e.stats.Name != s.Name {
+				continue
+			}
+			if _, ok := s.Resets(now); ok {
+				e.stats.Limit, e.stats.Remaining, e.stats.Reset = s.Limit, s.Remaining, s.Reset
+			}
+			if !s.Available(now) && !s.Unhealthy {
+				e.stats.CoolingUntil = s.CoolingUntil
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package sdk
+
+import "time"
+
+// Task is a unit of agent work.
+type Task struct {
+	ID          string  `json:"id"`
+	Title       string  `json:"title"`
+	Description string  `json:"description"`
+	Repository  string  `json:"repository"`
+	ActionType  string  `json:"action_type"`
+	Status      string  `json:"status"`
+	Progress    float64 `json:"progress"`
+	UserID      string  `json:"user_id"`
+	BranchName  string  `json:"branch_name"`
+	Priority    string  `json:"priority"`
+	// SLADeadline is set for tasks created with an SLA hint.
+	SLADeadline *time.Time `json:"sla_deadline,omitempty"`
+
+	IssueNumber  *int       `json:"issue_number,omitempty"`
+	PRNumber     *int       `json:"pr_number,omitempty"`
+	ErrorMessage string     `json:"error_message,omitempty"`
+	RetryCount   int        `json:"retry_count"`
+	TokensUsed   int        `json:"tokens_used"`
+	Cost         float64    `json:"cost"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	StartedAt    *time.Time `json:"started_at,omitempty"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+	SessionID    string     `json:"session_id,omitempty"`
+	DiffStats    *DiffStats `json:"diff_stats,omitempty"`
+	// Metrics is the agent's running tally, when the server reports one.
+	Metrics *TaskMetrics `json:"metrics,omitempty"`
+	// TriggeredBy says what created the task; machine identities are
+	// "service:<client id>".
+	TriggeredBy string `json:"triggered_by,omitempty"`
+	// DeletedAt and PurgeAt are set on tasks in the trash: deleted, and
+	// restorable until the server purges them.
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	PurgeAt   *time.Time `json:"purge_at,omitempty"`
+}
+
+// TaskMetrics breaks a task's progress down into what the agent has done so
+// far.
+type TaskMetrics struct {
+	FilesModified int `json:"files_modified"`
+	TestsPassed   int `json:"tests_passed"`
+	TestsFailed   int `json:"tests_failed"`
+	TestsSkipped  int `json:"tests_skipped,omitempty"`
+	Commits       int `json:"commits"`
+	TokensUsed    int `json:"tokens_used"`
+}
+
+// DiffStats summarizes the changes a task produced.
+type DiffStats struct {
+	FilesChanged int `json:"files_changed"`
+	Additions    int `json:"additions"`
+	Deletions    int `json:"deletions"`
+}
+
+// CreateTaskRequest is the body of POST /api/v1/tasks.
+type CreateTaskRequest struct {
+	Title       string                 `json:"title"`
+	Description string                 `json:"description"`
+	Repository  string                 `json:"repository"`
+	ActionType  string                 `json:"action_type"`
+	Priority    string                 `json:"priority"`
+	AgentConfig map[string]interface{} `json:"agent_config"`
+	// Weight and SLASeconds are scheduling hints for deployments using
+	// weighted fair queuing. A nil Weight and a zero SLASeconds are
+	// omitted; an explicit Weight of 0 is sent.
+	Weight     *int  `json:"weight,omitempty"`
+	SLASeconds int64 `json:"sla_seconds,omitempty"`
+	// IssueNumber links the task to an issue in Repository.
+	IssueNumber *int   `json:"issue_number,omitempty"`
+	TriggeredBy string `json:"triggered_by,omitempty"`
+}
+
+// TaskList is one page of GET /api/v1/tasks.
+type TaskList struct {
+	Items   []Task `json:"items"`
+	Total   int    `json:"total"`
+	Page    int    `json:"page"`
+	PerPage int    `json:"per_page"`
+	HasNext bool   `json:"has_next"`
+	HasPrev bool   `json:"has_prev"`
+	// Next links to the following page on deployments that paginate by
+	// cursor; it takes precedence over HasNext and page numbers.
+	Next string `json:"next,omitempty"`
+}
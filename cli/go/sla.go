@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+func slaBreached(t Task) bool {
+	return t.SLADeadline != nil && !isFinished(t.Status) && time.Now().After(*t.SLADeadline)
+}
+
+// slaLabel is a short countdown for list/watch rows, red once breached.
+func slaLabel(t Task) string {
+	if t.SLADeadline == nil || isFinished(t.Status) {
+		return ""
+	}
+	left := time.Until(*t.SLADeadline).Round(time.Minute)
+	if left <= 0 {
+		return " " + colorize(colorRed, fmt.Sprintf("SLA BREACHED %s ago", (-left).String()))
+	}
+	label := fmt.Sprintf("SLA %s", left)
+	if left < 30*time.Minute {
+		return " " + colorize(colorYellow, label)
+	}
+	return " " + label
+}
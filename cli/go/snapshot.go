@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var snapshotEndpoints = []struct{ name, path string }{
+	{"health", "/api/v1/health/"},
+	{"health-info", "/api/v1/health/info"},
+	{"users-me", "/api/v1/users/me"},
+	{"tasks-list", "/api/v1/tasks?page=1&per_page=5"},
+	{"tasks-summary", "/api/v1/tasks/summary"},
+	{"agents-profiles", "/api/v1/agents/profiles"},
+	{"agents-tools", "/api/v1/agents/tools"},
+	{"agents-models", "/api/v1/agents/models"},
+}
+
+type apiSnapshot struct {
+	Path   string `json:"path"`
+	Status int    `json:"status"`
+	Body   any    `json:"body"`
+}
+
+func (c *Client) captureSnapshot(ctx context.Context, path string) (apiSnapshot, error) {
+	req, err := c.NewRequest(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return apiSnapshot{}, err
+	}
+	if err := c.Limiter.Wait(ctx); err != nil {
+		return apiSnapshot{}, err
+	}
+	// Error statuses are part of the snapshot, so bypass Do's conversion.
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return apiSnapshot{}, err
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	snap := apiSnapshot{Path: path, Status: resp.StatusCode}
+	if json.Unmarshal(b, &snap.Body) != nil {
+		snap.Body = string(b)
+	}
+	return snap, nil
+}
+
+// schemaOf flattens v into JSON paths mapped to their type names. Array
+// elements collapse to [] so lists of different lengths compare equal.
+func schemaOf(v any, prefix string, out map[string]string) {
+	switch v := v.(type) {
+	case map[string]any:
+		out[prefix] = "object"
+		for k, child := range v {
+			schemaOf(child, prefix+"."+k, out)
+		}
+	case []any:
+		out[prefix] = "array"
+		for _, child := range v {
+			schemaOf(child, prefix+"[]", out)
+		}
+	case string:
+		out[prefix] = "string"
+	case float64:
+		out[prefix] = "number"
+	case bool:
+		out[prefix] = "boolean"
+	case nil:
+		if _, ok := out[prefix]; !ok {
+			out[prefix] = "null"
+		}
+	}
+}
+
+func diffSnapshots(old, cur apiSnapshot) []string {
+	var out []string
+	if old.Status != cur.Status {
+		out = append(out, fmt.Sprintf("~ status %d -> %d", old.Status, cur.Status))
+	}
+	a, b := map[string]string{}, map[string]string{}
+	schemaOf(old.Body, "$", a)
+	schemaOf(cur.Body, "$", b)
+	keys := map[string]bool{}
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+	for _, k := range sorted {
+		at, inA := a[k]
+		bt, inB := b[k]
+		switch {
+		case !inA:
+			out = append(out, fmt.Sprintf("+ %s (%s)", k, bt))
+		case !inB:
+			out = append(out, fmt.Sprintf("- %s (%s)", k, at))
+		case at != bt && at != "null" && bt != "null":
+			out = append(out, fmt.Sprintf("~ %s %s -> %s", k, at, bt))
+		}
+	}
+	return out
+}
+
+func cmdSnapshotAPI(c *Client) *cobra.Command {
+	var outDir, compareDir string
+	cmd := &cobra.Command{
+		Use:   "snapshot-api",
+		Short: "Capture or compare canonical responses of key API endpoints",
+		Example: `  autocodit snapshot-api --out snapshots/v1.4/
+  autocodit snapshot-api --compare snapshots/v1.4/`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if (outDir == "") == (compareDir == "") {
+				return fmt.Errorf("exactly one of --out or --compare is required")
+			}
+			ctx := cmd.Context()
+			changed := 0
+			for _, ep := range snapshotEndpoints {
+				cur, err := c.captureSnapshot(ctx, ep.path)
+				if err != nil {
+					return fmt.Errorf("%s: %w", ep.name, err)
+				}
+				if outDir != "" {
+					if err := os.MkdirAll(outDir, 0o755); err != nil {
+						return err
+					}
+					b, _ := json.MarshalIndent(cur, "", "  ")
+					if err := os.WriteFile(filepath.Join(outDir, ep.name+".json"), append(b, '\n'), 0o644); err != nil {
+						return err
+					}
+					fmt.Printf("%-16s %d\n", ep.name, cur.Status)
+					continue
+				}
+
+				b, err := os.ReadFile(filepath.Join(compareDir, ep.name+".json"))
+				if err != nil {
+					fmt.Printf("%-16s not in snapshot\n", ep.name)
+					continue
+				}
+				var old apiSnapshot
+				if err := json.Unmarshal(b, &old); err != nil {
+					return fmt.Errorf("%s: %w", ep.name, err)
+				}
+				diffs := diffSnapshots(old, cur)
+				if len(diffs) == 0 {
+					fmt.Printf("%-16s unchanged\n", ep.name)
+					continue
+				}
+				changed++
+				fmt.Printf("%-16s changed\n    %s\n", ep.name, strings.Join(diffs, "\n    "))
+			}
+			if changed > 0 {
+				return fmt.Errorf("%d endpoint(s) differ from the snapshot", changed)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&outDir, "out", "", "directory to write snapshots to")
+	cmd.Flags().StringVar(&compareDir, "compare", "", "directory of snapshots to compare against")
+	return cmd
+}
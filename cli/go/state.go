@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// ephemeralState, when set, holds all local state instead of ~/.autocodit
+// (the config file stays where it is). See useEphemeralState.
+var ephemeralState string
+
+// cleanupState removes ephemeral state; it is a no-op otherwise.
+var cleanupState = func() {}
+
+// useEphemeralState moves state into a fresh temporary directory removed by
+// cleanupState, for jobs that must not share caches or history.
+func useEphemeralState() error {
+	dir, err := os.MkdirTemp("", "autocodit-state-")
+	if err != nil {
+		return err
+	}
+	ephemeralState = dir
+	cleanupState = func() { os.RemoveAll(dir) }
+	return nil
+}
+
+func configDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(home, ".autocodit")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+func stateDir() (string, error) {
+	if ephemeralState != "" {
+		return ephemeralState, nil
+	}
+	return configDir()
+}
+
+func statePath(elem ...string) (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	p := filepath.Join(append([]string{dir}, elem...)...)
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return "", err
+	}
+	return p, nil
+}
+
+func readState(name string, v any) error {
+	p, err := statePath(name)
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+func writeState(name string, v any) error {
+	p, err := statePath(name)
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(p, b, 0o600)
+}
+
+// writeFileAtomic replaces p through a uniquely named temporary file, so
+// concurrent writers never clobber each other's partial output.
+func writeFileAtomic(p string, b []byte, perm os.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(p), filepath.Base(p)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
+
+// lockPath takes an exclusive lock on p.lock, blocking until it is free.
+// The lock belongs to this process and is dropped by the OS if it exits, so
+// a killed CI job never leaves a stale lock behind.
+func lockPath(p string) (unlock func(), err error) {
+	f, err := os.OpenFile(p+".lock", os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+func lockState(name string) (unlock func(), err error) {
+	p, err := statePath(name)
+	if err != nil {
+		return nil, err
+	}
+	return lockPath(p)
+}
+
+// updateState re-reads name under its lock, lets fn modify v, and writes it
+// back, so parallel invocations do not lose each other's changes.
+func updateState(name string, v any, fn func()) error {
+	unlock, err := lockState(name)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := readState(name, v); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	fn()
+	return writeState(name, v)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+type statsRow struct {
+	key                                  string
+	total, completed, failed, inProgress int
+}
+
+func cmdStats(c *Client) *cobra.Command {
+	var opts sdk.ListTasksOptions
+	var byUser bool
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Summarize tasks by status",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.PerPage = 100
+			rows := map[string]*statsRow{}
+			tasks, errc := c.ListTasks(opts).Stream(cmd.Context())
+			for t := range tasks {
+				key := "all"
+				if byUser {
+					key = t.UserID
+					if key == "" {
+						key = "-"
+					}
+				}
+				r := rows[key]
+				if r == nil {
+					r = &statsRow{key: key}
+					rows[key] = r
+				}
+				r.total++
+				switch t.Status {
+				case "completed":
+					r.completed++
+				case "failed":
+					r.failed++
+				case "cancelled":
+				default:
+					r.inProgress++
+				}
+			}
+			if err := <-errc; err != nil {
+				return userScopeError(err, opts)
+			}
+
+			keys := make([]string, 0, len(rows))
+			for k := range rows {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			tbl := newTable(os.Stdout, c.tableMaxWidth(),
+				column{header: "KEY", flex: true}, column{header: "TOTAL", right: true},
+				column{header: "COMPLETED", right: true}, column{header: "FAILED", right: true},
+				column{header: "ACTIVE", right: true})
+			for _, k := range keys {
+				r := rows[k]
+				tbl.add(r.key, strconv.Itoa(r.total), strconv.Itoa(r.completed), strconv.Itoa(r.failed), strconv.Itoa(r.inProgress))
+			}
+			tbl.render()
+			return nil
+		},
+	}
+	cmd.Flags().BoolVar(&byUser, "by-user", false, "aggregate per task creator")
+	cmd.Flags().StringVarP(&opts.Repository, "repo", "r", "", "only tasks for owner/repo")
+	addUserScopeFlags(cmd, &opts)
+	return cmd
+}
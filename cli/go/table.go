@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+	"golang.org/x/text/width"
+)
+
+// runeWidth is the number of terminal cells r occupies: 0 for combining marks
+// and format characters, 2 for East Asian wide/fullwidth runes and emoji.
+func runeWidth(r rune) int {
+	switch {
+	case r == 0 || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Me, r) || unicode.Is(unicode.Cf, r):
+		return 0
+	case r < 0x20 || r == 0x7f:
+		return 0
+	}
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return 2
+	}
+	if r >= 0x1f300 && r <= 0x1faff {
+		return 2
+	}
+	return 1
+}
+
+// ansiLen returns the length of the CSI (color) or OSC (hyperlink) escape
+// sequence at the start of s, or 0.
+func ansiLen(s string) int {
+	switch {
+	case strings.HasPrefix(s, "\x1b["):
+		for i := 2; i < len(s); i++ {
+			if s[i] >= 0x40 && s[i] <= 0x7e {
+				return i + 1
+			}
+		}
+	case strings.HasPrefix(s, "\x1b]"):
+		for i := 2; i < len(s); i++ {
+			if s[i] == '\a' {
+				return i + 1
+			}
+			if s[i] == '\x1b' && i+1 < len(s) && s[i+1] == '\\' {
+				return i + 2
+			}
+		}
+	default:
+		return 0
+	}
+	return len(s)
+}
+
+// displayWidth is the printed width of s, ignoring color escapes.
+func displayWidth(s string) int {
+	n := 0
+	for i := 0; i < len(s); {
+		if l := ansiLen(s[i:]); l > 0 {
+			i += l
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		n += runeWidth(r)
+		i += size
+	}
+	return n
+}
+
+// truncateWidth cuts s to at most w cells, ending in "…" when shortened.
+// Escapes are kept, and colors and links closed if the cut may have left
+// one open.
+func truncateWidth(s string, w int) string {
+	return truncate(s, w, false)
+}
+
+// truncateCell is truncateWidth for table cells: line breaks become spaces
+// so a row stays on one line, and the cut backs up to the last word break
+// when that keeps most of the width.
+func truncateCell(s string, w int) string {
+	return truncate(cellReplacer.Replace(s), w, true)
+}
+
+var cellReplacer = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ", "\t", " ")
+
+func truncate(s string, w int, words bool) string {
+	if displayWidth(s) <= w {
+		return s
+	}
+	if w <= 0 {
+		return ""
+	}
+	var b strings.Builder
+	n, colored, linked := 0, false, false
+	lastBreak := -1
+	for i := 0; i < len(s); {
+		if l := ansiLen(s[i:]); l > 0 {
+			b.WriteString(s[i : i+l])
+			if s[i+1] == ']' {
+				linked = true
+			} else {
+				colored = true
+			}
+			i += l
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		rw := runeWidth(r)
+		if n+rw > w-1 {
+			break
+		}
+		if words && r == ' ' && n >= (w-1)*2/3 {
+			lastBreak = b.Len()
+		}
+		b.WriteRune(r)
+		n += rw
+		i += size
+	}
+	if lastBreak >= 0 {
+		cut := strings.TrimRight(b.String()[:lastBreak], " ")
+		b.Reset()
+		b.WriteString(cut)
+	}
+	b.WriteString("…")
+	if colored {
+		b.WriteString("\x1b[0m")
+	}
+	if linked {
+		b.WriteString("\x1b]8;;\x1b\\")
+	}
+	return b.String()
+}
+
+func padWidth(s string, w int, right bool) string {
+	gap := w - displayWidth(s)
+	if gap <= 0 {
+		return s
+	}
+	if right {
+		return strings.Repeat(" ", gap) + s
+	}
+	return s + strings.Repeat(" ", gap)
+}
+
+// fullOutput is --full: nothing in tables is truncated.
+var fullOutput bool
+
+// columnCap is max_column_width, the most cells any one column may take; 0
+// means no cap.
+var columnCap int
+
+// tableMaxWidth is table_max_width when set, the terminal width when stdout
+// is a terminal, and unlimited (0) when output is piped or under --full.
+func (c *Client) tableMaxWidth() int {
+	if fullOutput {
+		return 0
+	}
+	if c.cfg.TableMaxWidth > 0 {
+		return c.cfg.TableMaxWidth
+	}
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		return terminalWidth()
+	}
+	return 0
+}
+
+type column struct {
+	header string
+	width  int
+	right  bool
+	// flex columns absorb the remaining width and are truncated to fit.
+	flex bool
+}
+
+// table renders aligned columns. With fixed column widths rows can be written
+// as they arrive (writeRow); otherwise rows are buffered and render sizes
+// each column to its widest cell.
+type table struct {
+	w    io.Writer
+	cols []column
+	max  int
+	rows [][]string
+}
+
+func newTable(w io.Writer, max int, cols ...column) *table {
+	return &table{w: w, cols: cols, max: max}
+}
+
+func (t *table) add(cells ...string) {
+	t.rows = append(t.rows, cells)
+}
+
+func (t *table) flexWidth() int {
+	used, flex := 0, 0
+	for i, col := range t.cols {
+		if i > 0 {
+			used++
+		}
+		if col.flex {
+			flex++
+			continue
+		}
+		used += col.width
+	}
+	if t.max <= 0 || flex == 0 {
+		return 0
+	}
+	w := (t.max - used) / flex
+	if w < 10 {
+		w = 10
+	}
+	return w
+}
+
+func (t *table) writeRow(cells []string) {
+	fw := t.flexWidth()
+	parts := make([]string, len(t.cols))
+	for i, col := range t.cols {
+		var cell string
+		if i < len(cells) {
+			cell = cells[i]
+		}
+		w := col.width
+		if col.flex && fw > 0 && (w == 0 || w > fw) {
+			w = fw
+		}
+		if columnCap > 0 && (w == 0 || w > columnCap) {
+			w = columnCap
+		}
+		if w > 0 {
+			cell = truncateCell(cell, w)
+		} else {
+			cell = cellReplacer.Replace(cell)
+		}
+		if col.right || i < len(t.cols)-1 {
+			cell = padWidth(cell, w, col.right)
+		}
+		parts[i] = cell
+	}
+	fmt.Fprintln(t.w, strings.TrimRight(strings.Join(parts, " "), " "))
+}
+
+func (t *table) render() {
+	hasHeader := false
+	for i, col := range t.cols {
+		hasHeader = hasHeader || col.header != ""
+		w := displayWidth(col.header)
+		for _, r := range t.rows {
+			if i < len(r) {
+				w = max(w, displayWidth(cellReplacer.Replace(r[i])))
+			}
+		}
+		t.cols[i].width = w
+	}
+	if hasHeader {
+		headers := make([]string, len(t.cols))
+		for i, col := range t.cols {
+			headers[i] = col.header
+		}
+		t.writeRow(headers)
+	}
+	for _, r := range t.rows {
+		t.writeRow(r)
+	}
+}
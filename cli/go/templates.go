@@ -0,0 +1,715 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var actionTypes = []string{"plan", "apply", "fix", "review", "test", "refactor", "document", "optimize"}
+
+var defaultForbiddenPatterns = []string{
+	`ghp_[A-Za-z0-9]{20,}`,
+	`AKIA[0-9A-Z]{16}`,
+	`(?i)(password|secret|api[_-]?key)\s*[:=]\s*\S+`,
+}
+
+// taskTemplate is a reusable CreateTaskRequest whose string fields are
+// text/template sources filled from Variables at create time.
+type taskTemplate struct {
+	Name      string             `yaml:"name"`
+	Summary   string             `yaml:"summary,omitempty"`
+	Request   templateRequest    `yaml:"request"`
+	Variables []templateVariable `yaml:"variables,omitempty"`
+
+	path   string
+	source string
+}
+
+type templateRequest struct {
+	Title       string                 `yaml:"title"`
+	Description string                 `yaml:"description"`
+	Repository  string                 `yaml:"repository,omitempty"`
+	ActionType  string                 `yaml:"action_type"`
+	Priority    string                 `yaml:"priority,omitempty"`
+	AgentConfig map[string]interface{} `yaml:"agent_config,omitempty"`
+}
+
+type templateVariable struct {
+	Name     string `yaml:"name"`
+	Default  string `yaml:"default,omitempty"`
+	Required bool   `yaml:"required,omitempty"`
+}
+
+type templateSource struct {
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Revision string `json:"revision"`
+}
+
+func (t *taskTemplate) qualifiedName() string {
+	if t.source == "" {
+		return t.Name
+	}
+	return t.source + "/" + t.Name
+}
+
+func loadTemplateFile(path string) (*taskTemplate, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t := &taskTemplate{path: path}
+	if err := yaml.Unmarshal(b, t); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if t.Name == "" {
+		t.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return t, nil
+}
+
+func templateFiles(dir string) []string {
+	var files []string
+	for _, pattern := range []string{"*.yaml", "*.yml", "templates/*.yaml", "templates/*.yml"} {
+		m, _ := filepath.Glob(filepath.Join(dir, pattern))
+		files = append(files, m...)
+	}
+	return files
+}
+
+func loadTemplateSources() ([]templateSource, error) {
+	var sources []templateSource
+	if err := readState("template-sources.json", &sources); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return sources, nil
+}
+
+// loadTemplates returns local templates followed by templates from each
+// synced git source, the latter namespaced as source/name.
+func loadTemplates() ([]*taskTemplate, error) {
+	dir, err := statePath("templates", "")
+	if err != nil {
+		return nil, err
+	}
+	var out []*taskTemplate
+	for _, f := range templateFiles(dir) {
+		t, err := loadTemplateFile(f)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	sources, err := loadTemplateSources()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range sources {
+		sdir, err := statePath("template-sources", s.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range templateFiles(sdir) {
+			t, err := loadTemplateFile(f)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Warning:", err)
+				continue
+			}
+			t.source = s.Name
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+func findTemplate(name string) (*taskTemplate, error) {
+	if strings.ContainsAny(name, `/\`) && (strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".yml")) {
+		return loadTemplateFile(name)
+	}
+	all, err := loadTemplates()
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range all {
+		if t.qualifiedName() == name {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("no template %q", name)
+}
+
+type templateField struct {
+	name, src string
+}
+
+func (t *taskTemplate) fields() []templateField {
+	return []templateField{
+		{"title", t.Request.Title},
+		{"description", t.Request.Description},
+		{"repository", t.Request.Repository},
+	}
+}
+
+// templateVars returns the variable names referenced as {{.name}} in src.
+func templateVars(src string) ([]string, error) {
+	tmpl, err := template.New("").Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var walk func(n parse.Node)
+	walk = func(n parse.Node) {
+		switch n := n.(type) {
+		case *parse.ListNode:
+			if n != nil {
+				for _, c := range n.Nodes {
+					walk(c)
+				}
+			}
+		case *parse.ActionNode:
+			walk(n.Pipe)
+		case *parse.PipeNode:
+			if n != nil {
+				for _, c := range n.Cmds {
+					walk(c)
+				}
+			}
+		case *parse.CommandNode:
+			for _, a := range n.Args {
+				walk(a)
+			}
+		case *parse.FieldNode:
+			seen[n.Ident[0]] = true
+		case *parse.IfNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.RangeNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		case *parse.WithNode:
+			walk(n.Pipe)
+			walk(n.List)
+			walk(n.ElseList)
+		}
+	}
+	if tmpl.Tree != nil {
+		walk(tmpl.Tree.Root)
+	}
+	vars := make([]string, 0, len(seen))
+	for v := range seen {
+		vars = append(vars, v)
+	}
+	sort.Strings(vars)
+	return vars, nil
+}
+
+type lintFinding struct {
+	severity, msg string
+}
+
+func (c *Client) lintTemplate(t *taskTemplate) []lintFinding {
+	var out []lintFinding
+	add := func(sev, format string, a ...any) {
+		out = append(out, lintFinding{sev, fmt.Sprintf(format, a...)})
+	}
+
+	if t.Request.Title == "" {
+		add("error", "request.title is empty")
+	}
+	if t.Request.ActionType == "" {
+		add("error", "request.action_type is empty")
+	} else if !contains(actionTypes, t.Request.ActionType) {
+		add("error", "request.action_type %q is not one of %s", t.Request.ActionType, strings.Join(actionTypes, ", "))
+	}
+	if n := len(t.Request.Description); c.cfg.TemplateMaxLength > 0 && n > c.cfg.TemplateMaxLength {
+		add("error", "request.description is %d bytes, limit is %d", n, c.cfg.TemplateMaxLength)
+	}
+
+	declared := map[string]bool{}
+	for _, v := range t.Variables {
+		if v.Name == "" {
+			add("error", "variable with empty name")
+			continue
+		}
+		if declared[v.Name] {
+			add("error", "variable %q declared twice", v.Name)
+		}
+		declared[v.Name] = true
+		if v.Required && v.Default != "" {
+			add("warning", "variable %q is required but has a default", v.Name)
+		}
+	}
+	used := map[string]bool{}
+	for _, f := range t.fields() {
+		vars, err := templateVars(f.src)
+		if err != nil {
+			add("error", "request.%s: %v", f.name, err)
+			continue
+		}
+		for _, v := range vars {
+			used[v] = true
+			if !declared[v] {
+				add("error", "request.%s uses undeclared variable %q", f.name, v)
+			}
+		}
+	}
+	for _, v := range t.Variables {
+		if v.Name != "" && !used[v.Name] {
+			add("warning", "variable %q is declared but never used", v.Name)
+		}
+	}
+
+	patterns := c.cfg.TemplateForbidden
+	if len(patterns) == 0 {
+		patterns = defaultForbiddenPatterns
+	}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			add("error", "invalid forbidden pattern %q in config: %v", p, err)
+			continue
+		}
+		for _, f := range t.fields() {
+			if re.MatchString(f.src) {
+				add("error", "request.%s matches forbidden pattern %q", f.name, p)
+			}
+		}
+	}
+	return out
+}
+
+func cmdTemplate(c *Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "template",
+		Aliases: []string{"templates"},
+		Short:   "Manage task templates",
+	}
+	cmd.AddCommand(cmdTemplateSave(c), cmdTemplateList(), cmdTemplateApply(c), cmdTemplateLint(c),
+		cmdTemplateAddSource(), cmdTemplateSync(), cmdTemplateSources())
+	return cmd
+}
+
+func cmdTemplateLint(c *Client) *cobra.Command {
+	return &cobra.Command{
+		Use:   "lint [name|file...]",
+		Short: "Check templates for undeclared variables, length, and forbidden content",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var targets []*taskTemplate
+			if len(args) == 0 {
+				all, err := loadTemplates()
+				if err != nil {
+					return err
+				}
+				targets = all
+			}
+			for _, a := range args {
+				t, err := findTemplate(a)
+				if err != nil {
+					return err
+				}
+				targets = append(targets, t)
+			}
+			errorsFound := 0
+			for _, t := range targets {
+				findings := c.lintTemplate(t)
+				if len(findings) == 0 {
+					fmt.Printf("%s: ok\n", t.qualifiedName())
+				}
+				for _, f := range findings {
+					if f.severity == "error" {
+						errorsFound++
+					}
+					fmt.Printf("%s: %s: %s\n", t.qualifiedName(), f.severity, f.msg)
+				}
+			}
+			if errorsFound > 0 {
+				return fmt.Errorf("%d lint error(s)", errorsFound)
+			}
+			return nil
+		},
+	}
+}
+
+func sourceName(url string) string {
+	name := strings.TrimSuffix(filepath.Base(strings.TrimRight(url, "/")), ".git")
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+func cmdTemplateAddSource() *cobra.Command {
+	var name string
+	cmd := &cobra.Command{
+		Use:     "add-source [git-url]",
+		Short:   "Add a git repository of shared templates",
+		Example: "  autocodit template add-source git@github.com:org/autocodit-templates",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			unlock, err := lockState("template-sources.json")
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			url := args[0]
+			if name == "" {
+				name = sourceName(url)
+			}
+			sources, err := loadTemplateSources()
+			if err != nil {
+				return err
+			}
+			for _, s := range sources {
+				if s.Name == name {
+					return fmt.Errorf("source %q already exists", name)
+				}
+			}
+			dir, err := statePath("template-sources", name)
+			if err != nil {
+				return err
+			}
+			if _, err := git("clone", "--depth", "1", "--", url, dir); err != nil {
+				return err
+			}
+			rev, _ := git("-C", dir, "rev-parse", "--short", "HEAD")
+			sources = append(sources, templateSource{Name: name, URL: url, Revision: rev})
+			if err := writeState("template-sources.json", sources); err != nil {
+				return err
+			}
+			fmt.Printf("Added template source %s at %s (%d templates)\n", name, rev, len(templateFiles(dir)))
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&name, "name", "", "namespace for the source's templates (default: repository name)")
+	return cmd
+}
+
+func cmdTemplateSync() *cobra.Command {
+	return &cobra.Command{
+		Use:   "sync",
+		Short: "Pull the latest templates from every source",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			unlock, err := lockState("template-sources.json")
+			if err != nil {
+				return err
+			}
+			defer unlock()
+			sources, err := loadTemplateSources()
+			if err != nil {
+				return err
+			}
+			for i, s := range sources {
+				dir, err := statePath("template-sources", s.Name)
+				if err != nil {
+					return err
+				}
+				if _, err := git("-C", dir, "pull", "--ff-only", "-q"); err != nil {
+					fmt.Fprintf(os.Stderr, "%s: %v\n", s.Name, err)
+					continue
+				}
+				rev, _ := git("-C", dir, "rev-parse", "--short", "HEAD")
+				if rev != s.Revision {
+					fmt.Printf("%s: %s -> %s\n", s.Name, s.Revision, rev)
+				} else {
+					fmt.Printf("%s: up to date at %s\n", s.Name, rev)
+				}
+				sources[i].Revision = rev
+			}
+			return writeState("template-sources.json", sources)
+		},
+	}
+}
+
+func cmdTemplateSources() *cobra.Command {
+	return &cobra.Command{
+		Use:   "sources",
+		Short: "List template sources",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			sources, err := loadTemplateSources()
+			if err != nil {
+				return err
+			}
+			for _, s := range sources {
+				fmt.Printf("%-20s %-10s %s\n", s.Name, s.Revision, s.URL)
+			}
+			return nil
+		},
+	}
+}
+
+var templateName = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*$`)
+
+// parseTemplateVars reads repeatable name=value flags.
+func parseTemplateVars(sets []string) (map[string]string, error) {
+	vars := map[string]string{}
+	for _, s := range sets {
+		k, v, ok := strings.Cut(s, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid --set %q, want name=value", s)
+		}
+		vars[k] = v
+	}
+	return vars, nil
+}
+
+// render fills t's request from vars, then variable defaults. Required
+// variables without a value and references to unknown ones are errors.
+func (t *taskTemplate) render(vars map[string]string) (templateRequest, error) {
+	data := map[string]string{}
+	var missing []string
+	for _, v := range t.Variables {
+		if val, ok := vars[v.Name]; ok {
+			data[v.Name] = val
+		} else if v.Required && v.Default == "" {
+			missing = append(missing, v.Name)
+		} else {
+			data[v.Name] = v.Default
+		}
+	}
+	for k := range vars {
+		if _, ok := data[k]; !ok {
+			return templateRequest{}, fmt.Errorf("template %s has no variable %q", t.qualifiedName(), k)
+		}
+	}
+	if len(missing) > 0 {
+		return templateRequest{}, fmt.Errorf("template %s needs --set for: %s", t.qualifiedName(), strings.Join(missing, ", "))
+	}
+	out := t.Request
+	for _, f := range []*string{&out.Title, &out.Description, &out.Repository} {
+		tmpl, err := template.New(t.Name).Option("missingkey=error").Parse(*f)
+		if err != nil {
+			return templateRequest{}, err
+		}
+		var b strings.Builder
+		if err := tmpl.Execute(&b, data); err != nil {
+			return templateRequest{}, err
+		}
+		*f = b.String()
+	}
+	return out, nil
+}
+
+func cmdTemplateSave(c *Client) *cobra.Command {
+	var t taskTemplate
+	var vars []string
+	var fromTask string
+	var force bool
+	cmd := &cobra.Command{
+		Use:   "save [name]",
+		Short: "Save a task request as a template",
+		Long: `save stores a template under ~/.autocodit/templates. Its title, description,
+and repository may use {{.name}} placeholders, declared with --var name (required)
+or --var name=default, and filled by template apply:
+
+  autocodit template save deps --title "Upgrade {{.ecosystem}} dependencies" \
+    --description "Upgrade outdated {{.ecosystem}} packages and fix what breaks." \
+    --type apply --var ecosystem=npm
+
+--from-task starts from an existing task's request instead.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			t.Name = args[0]
+			if !templateName.MatchString(t.Name) {
+				return fmt.Errorf("invalid template name %q (lowercase letters, digits, '.', '_', and '-')", t.Name)
+			}
+			if fromTask != "" {
+				var task Task
+				if err := c.DoJSON(cmd.Context(), http.MethodGet, "/api/v1/tasks/"+fromTask, nil, &task); err != nil {
+					return err
+				}
+				// Flags given alongside --from-task win.
+				from := map[string]string{"title": task.Title, "description": task.Description,
+					"repo": task.Repository, "type": task.ActionType, "priority": task.Priority}
+				to := map[string]*string{"title": &t.Request.Title, "description": &t.Request.Description,
+					"repo": &t.Request.Repository, "type": &t.Request.ActionType, "priority": &t.Request.Priority}
+				for flag, p := range to {
+					if !cmd.Flags().Changed(flag) {
+						*p = from[flag]
+					}
+				}
+			}
+			for _, v := range vars {
+				name, def, hasDefault := strings.Cut(v, "=")
+				t.Variables = append(t.Variables, templateVariable{Name: name, Default: def, Required: !hasDefault})
+			}
+			p, err := statePath("templates", t.Name+".yaml")
+			if err != nil {
+				return err
+			}
+			if _, err := os.Stat(p); err == nil && !force {
+				return fmt.Errorf("template %s exists; pass --force to replace it", t.Name)
+			}
+			failed := 0
+			for _, f := range c.lintTemplate(&t) {
+				fmt.Fprintf(os.Stderr, "%s: %s: %s\n", t.Name, f.severity, f.msg)
+				if f.severity == "error" {
+					failed++
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("not saved: %d lint error(s)", failed)
+			}
+			b, err := yaml.Marshal(&t)
+			if err != nil {
+				return err
+			}
+			if err := writeFileAtomic(p, b, 0o600); err != nil {
+				return err
+			}
+			fmt.Println("Saved template", t.Name, "to", p)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&t.Request.Title, "title", "", "task title")
+	cmd.Flags().StringVar(&t.Request.Description, "description", "", "task description")
+	cmd.Flags().StringVarP(&t.Request.Repository, "repo", "r", "", "owner/repo (default at apply: default_repo)")
+	cmd.Flags().StringVarP(&t.Request.ActionType, "type", "t", "plan", strings.Join(actionTypes, "|"))
+	cmd.Flags().StringVarP(&t.Request.Priority, "priority", "p", "", "low|normal|high|urgent")
+	cmd.Flags().StringVar(&t.Summary, "summary", "", "one line shown by template list")
+	cmd.Flags().StringArrayVar(&vars, "var", nil, "declare a variable: name for a required one, name=default, repeatable")
+	cmd.Flags().StringVar(&fromTask, "from-task", "", "start from this task's title, description, repository, type, and priority")
+	cmd.Flags().BoolVar(&force, "force", false, "replace an existing template")
+	return cmd
+}
+
+func cmdTemplateList() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List local and synced templates",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			all, err := loadTemplates()
+			if err != nil {
+				return err
+			}
+			type entry struct {
+				Name      string   `json:"name"`
+				Type      string   `json:"action_type"`
+				Variables []string `json:"variables"`
+				Summary   string   `json:"summary,omitempty"`
+				Path      string   `json:"path"`
+			}
+			out := []entry{}
+			for _, t := range all {
+				e := entry{Name: t.qualifiedName(), Type: t.Request.ActionType, Variables: []string{}, Summary: t.Summary, Path: t.path}
+				for _, v := range t.Variables {
+					if v.Required && v.Default == "" {
+						e.Variables = append(e.Variables, v.Name)
+					} else {
+						e.Variables = append(e.Variables, v.Name+"="+v.Default)
+					}
+				}
+				out = append(out, e)
+			}
+			return printOutput(out, func() {
+				if len(out) == 0 {
+					fmt.Println("No templates; create one with: autocodit template save")
+					return
+				}
+				tbl := newTable(os.Stdout, 0, column{header: "NAME"}, column{header: "TYPE"},
+					column{header: "VARIABLES"}, column{header: "SUMMARY", flex: true})
+				for _, e := range out {
+					tbl.add(e.Name, e.Type, strings.Join(e.Variables, " "), e.Summary)
+				}
+				tbl.render()
+			})
+		},
+	}
+}
+
+func cmdTemplateApply(c *Client) *cobra.Command {
+	var sets []string
+	var repo, priority, baseBranch string
+	var yes, dryRun, allowDup bool
+	cmd := &cobra.Command{
+		Use:   "apply [name|file]",
+		Short: "Create a task from a template, filling its variables",
+		Long: `apply fills the template's placeholders from --set name=value, falling back to
+each variable's default, and creates the task as create would:
+
+  autocodit template apply deps --set ecosystem=go -r my-org/api`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			t, err := findTemplate(args[0])
+			if err != nil {
+				return err
+			}
+			vars, err := parseTemplateVars(sets)
+			if err != nil {
+				return err
+			}
+			r, err := t.render(vars)
+			if err != nil {
+				return err
+			}
+			req := CreateTaskRequest{
+				Title:       r.Title,
+				Description: r.Description,
+				Repository:  pick(repo, r.Repository, c.cfg.DefaultRepo),
+				ActionType:  r.ActionType,
+				Priority:    pick(priority, r.Priority, "normal"),
+				TriggeredBy: "cli:template",
+			}
+			if req.Repository == "" {
+				return fmt.Errorf("--repo required: the template names none and there is no default_repo")
+			}
+			req.AgentConfig = c.agentConfig(req.Repository, req.ActionType, req.Title, baseBranch)
+			for k, v := range r.AgentConfig {
+				req.AgentConfig[k] = v
+			}
+			if dryRun {
+				return printOutput(req, func() {
+					fmt.Printf("%s (%s, %s) on %s\n\n%s\n", req.Title, req.ActionType, req.Priority, req.Repository, req.Description)
+				})
+			}
+			if err := c.checkCreateTarget(ctx, req.Repository, baseBranch); err != nil {
+				return err
+			}
+			if err := c.confirmTarget(req.Repository, yes); err != nil {
+				return err
+			}
+			if err := c.checkFreeze(&req, ""); err != nil {
+				return err
+			}
+			if err := c.checkBudget(ctx); err != nil {
+				return err
+			}
+			c.attachGitContext(&req)
+			if err := c.checkDescriptionSize(ctx, req.Description); err != nil {
+				return err
+			}
+			if !allowDup {
+				if handled, err := c.dedupe(ctx, req); handled || err != nil {
+					return err
+				}
+			}
+			var task Task
+			if err := c.createTask(ctx, req, &task); err != nil {
+				return tooLargeError(err, req.Description)
+			}
+			audit("create.template", map[string]any{"task": task.ID, "template": t.qualifiedName(), "repository": req.Repository})
+			return printOutput(task, func() { fmt.Println("Task created:", task.ID) })
+		},
+	}
+	cmd.Flags().StringArrayVar(&sets, "set", nil, "variable value as name=value, repeatable")
+	cmd.Flags().StringVarP(&repo, "repo", "r", "", "owner/repo, overriding the template's")
+	cmd.Flags().StringVarP(&priority, "priority", "p", "", "low|normal|high|urgent, overriding the template's")
+	cmd.Flags().StringVar(&baseBranch, "base-branch", "", "branch the agent starts from (default: repository default branch)")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "create without confirming a repository other than the current checkout or a critical one")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "print the filled-in request instead of creating the task")
+	cmd.Flags().BoolVar(&allowDup, "allow-duplicate", false, "skip the check for similar open tasks")
+	return cmd
+}
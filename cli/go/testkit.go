@@ -0,0 +1,663 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// testkitFixture is the file --fixtures loads. Tasks are free-form task JSON;
+// a task's "diff", "logs", and "comments" keys are served from those
+// endpoints instead of being part of it.
+type testkitFixture struct {
+	Tasks        []map[string]any `yaml:"tasks" json:"tasks"`
+	Repositories []RepoMeta       `yaml:"repositories" json:"repositories"`
+}
+
+// defaultFixtures covers each task status on two repositories, with fixed
+// IDs tests can refer to.
+func defaultFixtures() testkitFixture {
+	day := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	at := func(h int) string { return day.Add(time.Duration(h) * time.Hour).Format(time.RFC3339) }
+	task := func(n int, status, repo, action, title string) map[string]any {
+		return map[string]any{
+			"id":          fmt.Sprintf("00000000-0000-4000-8000-%012d", n),
+			"title":       title,
+			"description": title + ".",
+			"repository":  repo,
+			"action_type": action,
+			"status":      status,
+			"priority":    "normal",
+			"user_id":     "testkit",
+			"branch_name": fmt.Sprintf("autocodit/%s/%d", action, n),
+			"created_at":  at(n),
+			"updated_at":  at(n),
+		}
+	}
+	completed := task(1, "completed", "acme/api", "fix", "Fix nil pointer in token refresh")
+	completed["progress"] = 1.0
+	completed["pr_number"] = 42
+	completed["completed_at"] = at(2)
+	completed["diff_stats"] = map[string]any{"files_changed": 1, "additions": 3, "deletions": 1}
+	completed["diff"] = "diff --git a/auth/refresh.go b/auth/refresh.go\n--- a/auth/refresh.go\n+++ b/auth/refresh.go\n@@ -10,3 +10,5 @@\n func refresh(t *Token) error {\n-\treturn t.renew()\n+\tif t == nil {\n+\t\treturn errNoToken\n+\t}\n+\treturn t.renew()\n }\n"
+	completed["logs"] = []any{
+		map[string]any{"timestamp": at(1), "level": "INFO", "message": "Reproduced the panic with go test ./auth"},
+		map[string]any{"timestamp": at(2), "level": "INFO", "message": "Opened pull request #42"},
+	}
+	completed["comments"] = []any{
+		map[string]any{"id": "c1", "author": "agent", "role": "agent", "kind": "question", "body": "Should a nil token return an error or refresh anonymously?", "created_at": at(1)},
+		map[string]any{"id": "c2", "author": "testkit", "role": "human", "body": "Return errNoToken.", "created_at": at(1)},
+	}
+	failed := task(2, "failed", "acme/api", "fix", "Fix flaky TestUpload")
+	failed["progress"] = 0.6
+	failed["error_message"] = "tests still failing after 3 attempts"
+	running := task(3, "running", "acme/web", "feature", "Add dark mode toggle")
+	running["progress"] = 0.4
+	running["started_at"] = at(3)
+	queued := task(4, "queued", "acme/web", "docs", "Document the deploy script")
+	cancelled := task(5, "cancelled", "acme/web", "refactor", "Split the settings page")
+	cancelled["progress"] = 0.1
+	return testkitFixture{Tasks: []map[string]any{completed, failed, running, queued, cancelled}}
+}
+
+func readFixtures(path string) (testkitFixture, error) {
+	var f testkitFixture
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return f, err
+	}
+	// JSON is YAML, so one decoder reads either; the round trip through
+	// JSON lets repositories use RepoMeta's json field names.
+	var raw any
+	if err := yaml.Unmarshal(b, &raw); err != nil {
+		return f, fmt.Errorf("%s: %w", path, err)
+	}
+	if b, err = json.Marshal(raw); err == nil {
+		err = json.Unmarshal(b, &f)
+	}
+	if err != nil {
+		return f, fmt.Errorf("%s: %w", path, err)
+	}
+	for i, t := range f.Tasks {
+		if id, _ := t["id"].(string); id == "" {
+			return f, fmt.Errorf("%s: task %d has no id", path, i+1)
+		}
+	}
+	return f, nil
+}
+
+type testkitRequest struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Query  string          `json:"query,omitempty"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// testkitServer is an in-memory stand-in for the platform API, enough for
+// the CLI and automations built on it to run against.
+type testkitServer struct {
+	fixtures testkitFixture
+	token    string
+	advance  bool
+
+	mu       sync.Mutex
+	tasks    map[string]map[string]any
+	diffs    map[string]string
+	logs     map[string]any
+	comments map[string][]any
+	repos    map[string]RepoMeta
+	requests []testkitRequest
+}
+
+func newTestkitServer(f testkitFixture, token string, advance bool) *testkitServer {
+	s := &testkitServer{fixtures: f, token: token, advance: advance}
+	s.reset()
+	return s
+}
+
+// reset restores the fixtures, dropping whatever the previous run changed.
+func (s *testkitServer) reset() {
+	s.tasks, s.diffs, s.logs, s.comments, s.requests = map[string]map[string]any{}, map[string]string{}, map[string]any{}, map[string][]any{}, nil
+	for _, t := range s.fixtures.Tasks {
+		var task map[string]any
+		b, _ := json.Marshal(t)
+		_ = json.Unmarshal(b, &task)
+		id := task["id"].(string)
+		if d, ok := task["diff"].(string); ok {
+			s.diffs[id] = d
+		}
+		if l, ok := task["logs"]; ok {
+			s.logs[id] = l
+		}
+		if cs, ok := task["comments"].([]any); ok {
+			s.comments[id] = cs
+		}
+		delete(task, "diff")
+		delete(task, "logs")
+		delete(task, "comments")
+		s.tasks[id] = task
+	}
+	s.repos = map[string]RepoMeta{}
+	for _, m := range s.fixtures.Repositories {
+		s.repos[m.FullName] = m
+	}
+	if len(s.fixtures.Repositories) > 0 {
+		return
+	}
+	for _, t := range s.tasks {
+		if r := strField(t, "repository"); r != "" {
+			owner, _, _ := strings.Cut(r, "/")
+			s.repos[r] = RepoMeta{FullName: r, DefaultBranch: "main", Visibility: "private", Branches: []string{"main"},
+				Installation: &RepoInstallation{ID: 1, Account: owner, Status: "active"}}
+		}
+	}
+}
+
+func (s *testkitServer) repositories() []RepoMeta {
+	repos := []RepoMeta{}
+	for _, m := range s.repos {
+		repos = append(repos, m)
+	}
+	sort.Slice(repos, func(i, j int) bool { return repos[i].FullName < repos[j].FullName })
+	return repos
+}
+
+// addRepository connects a repository, installed when the app already is on
+// another repository of the same owner.
+func (s *testkitServer) addRepository(body []byte) (RepoMeta, int, string) {
+	var req struct {
+		FullName      string         `json:"full_name"`
+		AgentSettings map[string]any `json:"agent_settings"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil || !repoNamePattern.MatchString(req.FullName) {
+		return RepoMeta{}, http.StatusUnprocessableEntity, "full_name must be owner/repo"
+	}
+	if _, ok := s.repos[req.FullName]; ok {
+		return RepoMeta{}, http.StatusConflict, "repository already connected"
+	}
+	owner, _, _ := strings.Cut(req.FullName, "/")
+	m := RepoMeta{FullName: req.FullName, DefaultBranch: "main", Visibility: "private", Branches: []string{"main"}, AgentSettings: req.AgentSettings}
+	for _, other := range s.repos {
+		if other.Installation != nil && strings.EqualFold(other.Installation.Account, owner) {
+			inst := *other.Installation
+			m.Installation = &inst
+		}
+	}
+	s.repos[m.FullName] = m
+	return m, http.StatusCreated, ""
+}
+
+func strField(t map[string]any, k string) string {
+	v, _ := t[k].(string)
+	return v
+}
+
+func newUUID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6], b[8] = b[6]&0x0f|0x40, b[8]&0x3f|0x80
+	h := hex.EncodeToString(b)
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// advanceTask moves a task one status along under --advance, so that
+// waiting on a created task ends.
+func advanceTask(t map[string]any) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	switch strField(t, "status") {
+	case "queued", "pending":
+		t["status"], t["progress"], t["started_at"] = "running", 0.5, now
+	case "running":
+		t["status"], t["progress"], t["completed_at"] = "completed", 1.0, now
+	default:
+		return
+	}
+	t["updated_at"] = now
+}
+
+func (s *testkitServer) listTasks(q map[string][]string) sdkTaskList {
+	get := func(k string) string {
+		if v := q[k]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+	deleted := get("deleted") == "true"
+	since, _ := time.Parse(time.RFC3339, get("since"))
+	var items []map[string]any
+	for _, t := range s.tasks {
+		if (t["deleted_at"] != nil) != deleted {
+			continue
+		}
+		if st := get("status"); st != "" && !contains(strings.Split(st, ","), strField(t, "status")) {
+			continue
+		}
+		if r := get("repository"); r != "" && strField(t, "repository") != r {
+			continue
+		}
+		if a := get("action_type"); a != "" && strField(t, "action_type") != a {
+			continue
+		}
+		if p := get("priority"); p != "" && strField(t, "priority") != p {
+			continue
+		}
+		if u := get("user"); u != "" && strField(t, "user_id") != u {
+			continue
+		}
+		if p := get("id_prefix"); p != "" && !strings.HasPrefix(strField(t, "id"), p) {
+			continue
+		}
+		if created, err := time.Parse(time.RFC3339, strField(t, "created_at")); err == nil && created.Before(since) {
+			continue
+		}
+		items = append(items, t)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if a, b := strField(items[i], "created_at"), strField(items[j], "created_at"); a != b {
+			return a > b
+		}
+		return strField(items[i], "id") < strField(items[j], "id")
+	})
+	page, _ := strconv.Atoi(get("page"))
+	perPage, _ := strconv.Atoi(get("per_page"))
+	if perPage <= 0 {
+		perPage = 50
+	}
+	page = max(page, 1)
+	lo, hi := min((page-1)*perPage, len(items)), min(page*perPage, len(items))
+	return sdkTaskList{Items: append([]map[string]any{}, items[lo:hi]...), Total: len(items), Page: page, PerPage: perPage, HasNext: hi < len(items), HasPrev: page > 1}
+}
+
+// sdkTaskList is sdk.TaskList with tasks kept as the fixtures wrote them.
+type sdkTaskList struct {
+	Items   []map[string]any `json:"items"`
+	Total   int              `json:"total"`
+	Page    int              `json:"page"`
+	PerPage int              `json:"per_page"`
+	HasNext bool             `json:"has_next"`
+	HasPrev bool             `json:"has_prev"`
+}
+
+func (s *testkitServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(io.LimitReader(r.Body, 10<<20))
+	reply := func(code int, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		if v != nil {
+			_ = json.NewEncoder(w).Encode(v)
+		}
+	}
+	fail := func(code int, detail string) { reply(code, map[string]string{"detail": detail}) }
+	path := strings.TrimSuffix(r.URL.Path, "/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if strings.HasPrefix(path, "/testkit") {
+		s.control(w, r, path, body, reply, fail)
+		return
+	}
+	req := testkitRequest{Method: r.Method, Path: path, Query: r.URL.RawQuery}
+	if json.Valid(body) {
+		req.Body = body
+	}
+	s.requests = append(s.requests, req)
+	if path == "/api/v1/health" {
+		reply(http.StatusOK, map[string]string{"status": "ok"})
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+s.token {
+		fail(http.StatusUnauthorized, "invalid or missing token")
+		return
+	}
+
+	switch {
+	case path == "/api/v1/users/me":
+		reply(http.StatusOK, map[string]string{"id": "testkit", "login": "testkit"})
+	case path == "/api/v1/repositories" && r.Method == http.MethodGet:
+		reply(http.StatusOK, s.repositories())
+	case path == "/api/v1/repositories" && r.Method == http.MethodPost:
+		m, code, detail := s.addRepository(body)
+		if detail != "" {
+			fail(code, detail)
+			return
+		}
+		reply(code, m)
+	case strings.HasPrefix(path, "/api/v1/repositories/") && r.Method == http.MethodGet:
+		m, ok := s.repos[strings.TrimPrefix(path, "/api/v1/repositories/")]
+		if !ok {
+			fail(http.StatusNotFound, "repository not found")
+			return
+		}
+		reply(http.StatusOK, m)
+	case strings.HasPrefix(path, "/api/v1/repositories/") && r.Method == http.MethodDelete:
+		name := strings.TrimPrefix(path, "/api/v1/repositories/")
+		if _, ok := s.repos[name]; !ok {
+			fail(http.StatusNotFound, "repository not found")
+			return
+		}
+		delete(s.repos, name)
+		w.WriteHeader(http.StatusNoContent)
+	case path == "/api/v1/tasks" && r.Method == http.MethodGet:
+		reply(http.StatusOK, s.listTasks(r.URL.Query()))
+	case path == "/api/v1/tasks" && r.Method == http.MethodPost:
+		var t map[string]any
+		if err := json.Unmarshal(body, &t); err != nil || strField(t, "title") == "" || strField(t, "repository") == "" {
+			fail(http.StatusUnprocessableEntity, "title and repository are required")
+			return
+		}
+		now := time.Now().UTC().Format(time.RFC3339)
+		t["id"], t["status"], t["progress"], t["user_id"], t["created_at"], t["updated_at"] = newUUID(), "queued", 0.0, "testkit", now, now
+		s.tasks[strField(t, "id")] = t
+		reply(http.StatusCreated, t)
+	case strings.HasPrefix(path, "/api/v1/tasks/"):
+		s.task(w, r, strings.Split(strings.TrimPrefix(path, "/api/v1/tasks/"), "/"), body, reply, fail)
+	default:
+		fail(http.StatusNotFound, "not implemented by testkit: "+r.Method+" "+path)
+	}
+}
+
+func (s *testkitServer) task(w http.ResponseWriter, r *http.Request, parts []string, body []byte, reply func(int, any), fail func(int, string)) {
+	t, ok := s.tasks[parts[0]]
+	if !ok {
+		fail(http.StatusNotFound, "Task not found")
+		return
+	}
+	id, now := parts[0], time.Now().UTC().Format(time.RFC3339)
+	inTrash := t["deleted_at"] != nil
+	action := strings.Join(parts[1:], "/")
+	if inTrash && action != "restore" {
+		fail(http.StatusNotFound, "Task not found")
+		return
+	}
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		if s.advance {
+			advanceTask(t)
+		}
+		reply(http.StatusOK, t)
+	case action == "" && r.Method == http.MethodPatch:
+		if !isWaiting(strField(t, "status")) {
+			fail(http.StatusConflict, "only queued tasks can be edited")
+			return
+		}
+		var changes map[string]any
+		if err := json.Unmarshal(body, &changes); err != nil {
+			fail(http.StatusBadRequest, err.Error())
+			return
+		}
+		for k, v := range changes {
+			if !contains(editableFields, k) {
+				fail(http.StatusUnprocessableEntity, k+" is not editable")
+				return
+			}
+			if v == nil {
+				delete(t, k)
+			} else {
+				t[k] = v
+			}
+		}
+		t["updated_at"] = now
+		reply(http.StatusOK, t)
+	case action == "" && r.Method == http.MethodDelete:
+		if !isFinished(strField(t, "status")) {
+			fail(http.StatusConflict, "cancel the task before deleting it")
+			return
+		}
+		t["deleted_at"], t["purge_at"] = now, time.Now().UTC().Add(30*24*time.Hour).Format(time.RFC3339)
+		w.WriteHeader(http.StatusNoContent)
+	case action == "restore" && r.Method == http.MethodPost:
+		if !inTrash {
+			fail(http.StatusNotFound, "Task not in trash")
+			return
+		}
+		delete(t, "deleted_at")
+		delete(t, "purge_at")
+		reply(http.StatusOK, t)
+	case action == "cancel" && r.Method == http.MethodPost:
+		if isFinished(strField(t, "status")) {
+			fail(http.StatusConflict, "task already "+strField(t, "status"))
+			return
+		}
+		t["status"], t["updated_at"] = "cancelled", now
+		reply(http.StatusOK, t)
+	case action == "retry" && r.Method == http.MethodPost:
+		if !isFinished(strField(t, "status")) {
+			fail(http.StatusConflict, "only finished tasks can be retried")
+			return
+		}
+		retry := map[string]any{}
+		for k, v := range t {
+			retry[k] = v
+		}
+		var overrides map[string]any
+		_ = json.Unmarshal(body, &overrides)
+		for k, v := range overrides {
+			retry[k] = v
+		}
+		for _, k := range []string{"pr_number", "error_message", "started_at", "completed_at", "diff_stats"} {
+			delete(retry, k)
+		}
+		count, _ := t["retry_count"].(float64)
+		retry["id"], retry["status"], retry["progress"], retry["retry_count"], retry["created_at"], retry["updated_at"] = newUUID(), "queued", 0.0, count+1, now, now
+		s.tasks[strField(retry, "id")] = retry
+		reply(http.StatusCreated, retry)
+	case action == "diff" && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "text/x-diff")
+		_, _ = io.WriteString(w, s.diffs[id])
+	case action == "logs" && r.Method == http.MethodGet:
+		logs := s.logs[id]
+		if logs == nil {
+			logs = []any{}
+		}
+		reply(http.StatusOK, logs)
+	case action == "comments" && r.Method == http.MethodGet:
+		since, _ := time.Parse(time.RFC3339Nano, r.URL.Query().Get("since"))
+		comments := []any{}
+		for _, tc := range s.comments[id] {
+			if created, err := time.Parse(time.RFC3339, strField(tc.(map[string]any), "created_at")); err != nil || !created.Before(since) {
+				comments = append(comments, tc)
+			}
+		}
+		reply(http.StatusOK, comments)
+	case action == "comments" && r.Method == http.MethodPost:
+		var in struct {
+			Body string `json:"body"`
+		}
+		if err := json.Unmarshal(body, &in); err != nil || strings.TrimSpace(in.Body) == "" {
+			fail(http.StatusUnprocessableEntity, "body is required")
+			return
+		}
+		tc := map[string]any{"id": newUUID(), "author": "testkit", "role": "human", "body": in.Body, "created_at": now}
+		s.comments[id] = append(s.comments[id], tc)
+		reply(http.StatusCreated, tc)
+	default:
+		fail(http.StatusNotFound, "not implemented by testkit: "+r.Method+" "+r.URL.Path)
+	}
+}
+
+// control serves /testkit, where tests set up and inspect the server
+// without a token: GET /testkit/requests lists the API calls received,
+// POST /testkit/reset restores the fixtures, PUT /testkit/tasks/{id} adds
+// or replaces a task and PATCH merges fields into one, e.g. a new status.
+func (s *testkitServer) control(w http.ResponseWriter, r *http.Request, path string, body []byte, reply func(int, any), fail func(int, string)) {
+	switch {
+	case path == "/testkit/requests" && r.Method == http.MethodGet:
+		reply(http.StatusOK, append([]testkitRequest{}, s.requests...))
+	case path == "/testkit/requests" && r.Method == http.MethodDelete:
+		s.requests = nil
+		w.WriteHeader(http.StatusNoContent)
+	case path == "/testkit/reset" && r.Method == http.MethodPost:
+		s.reset()
+		w.WriteHeader(http.StatusNoContent)
+	case strings.HasPrefix(path, "/testkit/tasks/"):
+		id := strings.TrimPrefix(path, "/testkit/tasks/")
+		var fields map[string]any
+		if err := json.Unmarshal(body, &fields); err != nil {
+			fail(http.StatusBadRequest, err.Error())
+			return
+		}
+		t, ok := s.tasks[id]
+		switch {
+		case r.Method == http.MethodPut:
+			if d, ok := fields["diff"].(string); ok {
+				s.diffs[id] = d
+			}
+			if l, ok := fields["logs"]; ok {
+				s.logs[id] = l
+			}
+			if cs, ok := fields["comments"].([]any); ok {
+				s.comments[id] = cs
+			}
+			delete(fields, "diff")
+			delete(fields, "logs")
+			delete(fields, "comments")
+			fields["id"] = id
+			s.tasks[id] = fields
+			reply(http.StatusOK, fields)
+		case r.Method == http.MethodPatch && ok:
+			for k, v := range fields {
+				t[k] = v
+			}
+			reply(http.StatusOK, t)
+		case r.Method == http.MethodPatch:
+			fail(http.StatusNotFound, "Task not found")
+		default:
+			fail(http.StatusMethodNotAllowed, r.Method+" not allowed")
+		}
+	default:
+		fail(http.StatusNotFound, "no testkit control endpoint "+r.Method+" "+path)
+	}
+}
+
+// testkitEnv is the environment the command runs in: the CLI's own
+// AUTOCODIT_ variables replaced by ones pointing at the server, and local
+// state kept apart from the user's.
+func testkitEnv(url, token string) []string {
+	var env []string
+	for _, kv := range os.Environ() {
+		if !strings.HasPrefix(kv, "AUTOCODIT_") {
+			env = append(env, kv)
+		}
+	}
+	return append(env,
+		"AUTOCODIT_API_ENDPOINT="+url,
+		"AUTOCODIT_AUTH_TOKEN="+token,
+		"AUTOCODIT_TESTKIT_URL="+url,
+		"AUTOCODIT_EPHEMERAL_STATE=1",
+	)
+}
+
+func cmdTestkit(c *Client) *cobra.Command {
+	var fixtures, addr, token, record string
+	var advance bool
+	cmd := &cobra.Command{
+		Use:   "testkit [-- command [args...]]",
+		Short: "Run a command against a local mock of the API with seeded tasks",
+		Long: `testkit starts an in-memory mock of the platform API seeded with fixture tasks,
+runs the given command against it, and shuts it down, exiting with the
+command's exit code. It is for testing plugins and automations without a real
+deployment:
+
+  autocodit testkit -- go test ./...
+  autocodit testkit --fixtures testdata/tasks.yaml --advance -- npm test
+
+The command sees AUTOCODIT_API_ENDPOINT and AUTOCODIT_AUTH_TOKEN for the mock,
+AUTOCODIT_TESTKIT_URL, and no other AUTOCODIT_ variables; autocodit run inside
+it ignores config files and keeps its local state in a temporary directory.
+Without a command the mock serves until interrupted.
+
+Fixtures are YAML or JSON with a "tasks" list of task objects, each with an
+"id" and optionally a "diff", "logs", and "comments" to serve, and an optional
+"repositories" list. The default fixtures have one task in each status.
+Under /testkit, without a token, the mock takes:
+
+  GET    /testkit/requests    API requests received so far
+  DELETE /testkit/requests    forget them
+  POST   /testkit/reset       restore the fixtures
+  PUT    /testkit/tasks/{id}  add or replace a task
+  PATCH  /testkit/tasks/{id}  change fields of a task, e.g. {"status":"failed"}
+
+With --advance, each fetch of a queued or running task moves it to the next
+status, so commands that wait on a task finish.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f := defaultFixtures()
+			if fixtures != "" {
+				var err error
+				if f, err = readFixtures(fixtures); err != nil {
+					return err
+				}
+			}
+			if token == "" {
+				b := make([]byte, 16)
+				_, _ = rand.Read(b)
+				token = "testkit-" + hex.EncodeToString(b)
+			}
+			ln, err := net.Listen("tcp", addr)
+			if err != nil {
+				return err
+			}
+			srv := newTestkitServer(f, token, advance)
+			hs := &http.Server{Handler: srv, ReadHeaderTimeout: 10 * time.Second}
+			go func() { _ = hs.Serve(ln) }()
+			url := "http://" + ln.Addr().String()
+			defer func() {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				_ = hs.Shutdown(ctx)
+				if record != "" {
+					srv.mu.Lock()
+					b, _ := json.MarshalIndent(srv.requests, "", "  ")
+					srv.mu.Unlock()
+					if err := os.WriteFile(record, append(b, '\n'), 0o644); err != nil {
+						fmt.Fprintln(os.Stderr, "Warning: writing --record:", err)
+					}
+				}
+			}()
+
+			if len(args) == 0 {
+				fmt.Printf("export AUTOCODIT_API_ENDPOINT=%s AUTOCODIT_AUTH_TOKEN=%s AUTOCODIT_TESTKIT_URL=%s AUTOCODIT_EPHEMERAL_STATE=1\n", url, token, url)
+				fmt.Fprintf(os.Stderr, "Serving %d fixture task(s) on %s; Ctrl-C to stop\n", len(f.Tasks), url)
+				ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+				defer stop()
+				<-ctx.Done()
+				return nil
+			}
+			run := exec.Command(args[0], args[1:]...)
+			run.Env = testkitEnv(url, token)
+			run.Stdin, run.Stdout, run.Stderr = os.Stdin, os.Stdout, os.Stderr
+			// Ctrl-C reaches the command too; let it finish before tearing down.
+			signal.Ignore(os.Interrupt)
+			defer signal.Reset(os.Interrupt)
+			err = run.Run()
+			var exit *exec.ExitError
+			if errors.As(err, &exit) {
+				cmd.SilenceErrors, cmd.SilenceUsage = true, true
+				return exitCodeError{code: exit.ExitCode()}
+			}
+			return err
+		},
+	}
+	cmd.Flags().StringVar(&fixtures, "fixtures", "", "seed the mock from this YAML or JSON file instead of the default tasks")
+	cmd.Flags().StringVar(&addr, "addr", "127.0.0.1:0", "listen address; port 0 picks a free one")
+	cmd.Flags().StringVar(&token, "token", "", "token the mock accepts (default: random)")
+	cmd.Flags().BoolVar(&advance, "advance", false, "move queued and running tasks one status along each time they are fetched")
+	cmd.Flags().StringVar(&record, "record", "", "write the API requests received to this JSON file on exit")
+	return cmd
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+// phase is one stretch of a task's life: queued, planning, coding, testing,
+// opening the PR, or an agent step. EndedAt is nil while it is in progress.
+type phase struct {
+	Name      string     `json:"name"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+}
+
+func (p phase) end() time.Time {
+	if p.EndedAt != nil {
+		return *p.EndedAt
+	}
+	return time.Now()
+}
+
+func (p phase) duration() time.Duration {
+	return p.end().Sub(p.StartedAt)
+}
+
+// taskTimeline returns the server's phase breakdown, or one pieced together
+// from the task's timestamps and checkpoints when the server has none.
+func (c *Client) taskTimeline(ctx context.Context, t Task) ([]phase, error) {
+	var phases []phase
+	err := c.DoJSON(ctx, http.MethodGet, "/api/v1/tasks/"+t.ID+"/timeline", nil, &phases)
+	var apiErr *sdk.APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		return phases, err
+	}
+
+	phases = []phase{{Name: "queued", StartedAt: t.CreatedAt, EndedAt: t.StartedAt}}
+	if t.StartedAt == nil {
+		return phases, nil
+	}
+	start := *t.StartedAt
+	cps, _ := c.checkpoints(ctx, t.ID)
+	for _, cp := range cps {
+		if cp.Step == 0 || cp.CreatedAt.Before(start) {
+			continue
+		}
+		end := cp.CreatedAt
+		name := firstLine(cp.Summary)
+		if name == "" {
+			name = fmt.Sprintf("step %d", cp.Step)
+		}
+		phases = append(phases, phase{Name: name, StartedAt: start, EndedAt: &end})
+		start = end
+	}
+	if t.CompletedAt == nil || t.CompletedAt.After(start) {
+		name := "running"
+		if len(phases) > 1 {
+			name = "finishing"
+		}
+		phases = append(phases, phase{Name: name, StartedAt: start, EndedAt: t.CompletedAt})
+	}
+	return phases, nil
+}
+
+// longestPhase returns the index of the longest phase.
+func longestPhase(phases []phase) int {
+	best := -1
+	for i, p := range phases {
+		if best < 0 || p.duration() > phases[best].duration() {
+			best = i
+		}
+	}
+	return best
+}
+
+func printTimeline(w io.Writer, t Task, phases []phase, width int) {
+	if len(phases) == 0 {
+		fmt.Fprintln(w, "No timeline recorded")
+		return
+	}
+	first, last := phases[0].StartedAt, phases[0].end()
+	for _, p := range phases {
+		if p.end().After(last) {
+			last = p.end()
+		}
+	}
+	span := last.Sub(first)
+	if span <= 0 {
+		span = time.Second
+	}
+	const labelW, timeW, durW = 26, 8, 9
+	barW := width - labelW - timeW - durW - 4
+	if barW < 10 {
+		barW = 10
+	}
+	col := func(at time.Time) int {
+		return int(float64(at.Sub(first)) / float64(span) * float64(barW))
+	}
+	row := func(label, start, dur, bar string) {
+		fmt.Fprintf(w, "%s %s %s  %s\n", padWidth(truncateWidth(label, labelW), labelW, false),
+			padWidth(start, timeW, false), padWidth(dur, durW, true), bar)
+	}
+
+	fmt.Fprintf(w, "%s  %s, %s total\n\n", colorize("1", t.ID), redact(t.Title), span.Round(time.Second))
+	longest := longestPhase(phases)
+	for i, p := range phases {
+		if i > 0 && p.StartedAt.Sub(phases[i-1].end()) >= time.Second {
+			prev := phases[i-1].end()
+			gap := p.StartedAt.Sub(prev)
+			a, b := col(prev), col(p.StartedAt)
+			row(colorize(colorGray, "  waiting"), "", colorize(colorGray, gap.Round(time.Second).String()),
+				strings.Repeat(" ", a)+colorize(colorGray, strings.Repeat("·", max(b-a, 1))))
+		}
+		a, b := col(p.StartedAt), col(p.end())
+		n := max(b-a, 1)
+		bar := strings.Repeat("█", n)
+		dur := p.duration().Round(time.Second).String()
+		label := redact(p.Name)
+		switch {
+		case p.EndedAt == nil:
+			bar = colorize(colorBlue, bar) + " …"
+		case i == longest && len(phases) > 1:
+			bar = colorize(colorYellow, bar) + colorize(colorYellow, " longest")
+			dur = colorize("1", dur)
+		default:
+			bar = colorize(colorGreen, bar)
+		}
+		row(label, p.StartedAt.Local().Format("15:04:05"), dur, strings.Repeat(" ", a)+bar)
+	}
+}
+
+func mermaidTimeline(w io.Writer, t Task, phases []phase) {
+	fmt.Fprintln(w, "gantt")
+	fmt.Fprintf(w, "    title %s: %s\n", t.ID, strings.ReplaceAll(redact(t.Title), ":", " "))
+	fmt.Fprintln(w, "    dateFormat YYYY-MM-DDTHH:mm:ss")
+	fmt.Fprintln(w, "    axisFormat %H:%M")
+	fmt.Fprintf(w, "    section %s\n", t.ID)
+	longest := longestPhase(phases)
+	for i, p := range phases {
+		tags := "done"
+		if p.EndedAt == nil {
+			tags = "active"
+		}
+		if i == longest && len(phases) > 1 {
+			tags = "crit, " + tags
+		}
+		secs := int(p.duration().Round(time.Second).Seconds())
+		fmt.Fprintf(w, "    %s :%s, p%d, %s, %ds\n", strings.NewReplacer(":", " ", "#", " ", ";", " ").Replace(redact(p.Name)),
+			tags, i, p.StartedAt.UTC().Format("2006-01-02T15:04:05"), max(secs, 1))
+	}
+}
+
+func svgTimeline(w io.Writer, t Task, phases []phase) {
+	const width, labelW, rowH, top = 900, 220, 28, 40
+	first, last := phases[0].StartedAt, phases[0].end()
+	for _, p := range phases {
+		if p.end().After(last) {
+			last = p.end()
+		}
+	}
+	span := last.Sub(first).Seconds()
+	if span <= 0 {
+		span = 1
+	}
+	scale := float64(width-labelW-20) / span
+	height := top + rowH*len(phases) + 20
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="12">`+"\n", width, height)
+	fmt.Fprintf(w, `  <text x="10" y="22" font-size="14" font-weight="bold">%s: %s (%s)</text>`+"\n",
+		html.EscapeString(t.ID), html.EscapeString(redact(t.Title)), last.Sub(first).Round(time.Second))
+	longest := longestPhase(phases)
+	for i, p := range phases {
+		y := top + i*rowH
+		x := labelW + p.StartedAt.Sub(first).Seconds()*scale
+		bw := p.duration().Seconds() * scale
+		if bw < 2 {
+			bw = 2
+		}
+		fill := "#4caf50"
+		switch {
+		case p.EndedAt == nil:
+			fill = "#2196f3"
+		case i == longest && len(phases) > 1:
+			fill = "#ff9800"
+		}
+		fmt.Fprintf(w, `  <text x="10" y="%d">%s</text>`+"\n", y+17, html.EscapeString(redact(p.Name)))
+		fmt.Fprintf(w, `  <rect x="%.1f" y="%d" width="%.1f" height="%d" rx="3" fill="%s"><title>%s</title></rect>`+"\n",
+			x, y+4, bw, rowH-8, fill, p.duration().Round(time.Second))
+	}
+	fmt.Fprintln(w, "</svg>")
+}
+
+func cmdTimeline(c *Client) *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:   "timeline [id]",
+		Short: "Show how long each phase of a task took",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var t Task
+			if err := c.DoJSON(cmd.Context(), http.MethodGet, "/api/v1/tasks/"+args[0], nil, &t); err != nil {
+				return err
+			}
+			phases, err := c.taskTimeline(cmd.Context(), t)
+			if err != nil {
+				return err
+			}
+			switch output {
+			case "text":
+				width := c.tableMaxWidth()
+				if width <= 0 {
+					width = 100
+				}
+				printTimeline(os.Stdout, t, phases, width)
+			case "mermaid":
+				mermaidTimeline(os.Stdout, t, phases)
+			case "svg":
+				if len(phases) == 0 {
+					return fmt.Errorf("no timeline recorded for %s", t.ID)
+				}
+				svgTimeline(os.Stdout, t, phases)
+			default:
+				return fmt.Errorf("unknown --output %q (text|mermaid|svg)", output)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "text|mermaid|svg")
+	return cmd
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+// TokenPoolConfig is the token_pool section:
+//
+//	token_pool:
+//	  tokens:
+//	    - {name: ci-1, token_env: CI1_TOKEN, weight: 2}
+//	    - {name: ci-2, token_env: CI2_TOKEN}
+type TokenPoolConfig struct {
+	Tokens []PoolTokenConfig `mapstructure:"tokens"`
+}
+
+// PoolTokenConfig is one pool entry; TokenEnv names an environment variable
+// holding the token, to keep it out of the config file.
+type PoolTokenConfig struct {
+	Name     string `mapstructure:"name"`
+	Token    string `mapstructure:"token"`
+	TokenEnv string `mapstructure:"token_env"`
+	Weight   int    `mapstructure:"weight"`
+}
+
+func (cfg *Config) poolTokens() ([]sdk.PoolToken, error) {
+	var tokens []sdk.PoolToken
+	for i, t := range cfg.TokenPool.Tokens {
+		name := t.Name
+		if name == "" {
+			name = "token-" + strconv.Itoa(i+1)
+		}
+		token := t.Token
+		if t.TokenEnv != "" {
+			if token = os.Getenv(t.TokenEnv); token == "" {
+				return nil, fmt.Errorf("token_pool: %s: $%s is not set", name, t.TokenEnv)
+			}
+		}
+		if t.Weight < 0 {
+			return nil, fmt.Errorf("token_pool: %s: weight must not be negative", name)
+		}
+		tokens = append(tokens, sdk.PoolToken{Name: name, Token: token, Weight: t.Weight})
+	}
+	return tokens, nil
+}
+
+// useTokenPool sends requests through the configured pool, picking up
+// cool-downs earlier runs saw.
+func (c *Client) useTokenPool() error {
+	tokens, err := c.cfg.poolTokens()
+	if err != nil {
+		return err
+	}
+	pool, err := sdk.NewTokenPool(tokens)
+	if err != nil {
+		return fmt.Errorf("token_pool: %w", err)
+	}
+	var saved map[string][]sdk.TokenStats
+	if err := readState("cache/token-pool.json", &saved); err == nil {
+		pool.Restore(saved[c.BaseURL])
+	}
+	c.Use(pool.Middleware())
+	c.pool = pool
+	return nil
+}
+
+func (c *Client) saveTokenPool() {
+	if c.pool == nil {
+		return
+	}
+	var saved map[string][]sdk.TokenStats
+	_ = updateState("cache/token-pool.json", &saved, func() {
+		if saved == nil {
+			saved = map[string][]sdk.TokenStats{}
+		}
+		saved[c.BaseURL] = c.pool.Stats()
+	})
+}
+
+func tokenState(s sdk.TokenStats, now time.Time) string {
+	switch {
+	case s.Unhealthy:
+		return colorize(colorRed, fmt.Sprintf("rejected (%d)", s.LastStatus))
+	case !s.Available(now):
+		return colorize(colorYellow, "cooling "+s.CoolingUntil.Sub(now).Round(time.Second).String())
+	case s.Errors > 0:
+		return colorize(colorYellow, fmt.Sprintf("ok, %d error(s)", s.Errors))
+	}
+	return colorize(colorGreen, "ok")
+}
+
+type limitsReport struct {
+	Server         serverLimits     `json:"server"`
+	RateLimitRPS   float64          `json:"rate_limit_rps"`
+	RateLimitBurst int              `json:"rate_limit_burst"`
+	Tokens         []sdk.TokenStats `json:"tokens,omitempty"`
+}
+
+func cmdLimits(c *Client) *cobra.Command {
+	return &cobra.Command{
+		Use:   "limits",
+		Short: "Show the server's limits and, with token_pool, each pooled token's rate limit",
+		Long: `limits prints the limits the server reports and the client-side rate limit.
+With token_pool configured, each token is checked with one request and listed
+with its weight, the rate limit the server last reported for it, and whether
+the pool is using it, cooling it down after a 429, or has dropped it because
+the server rejected it.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			r := limitsReport{RateLimitRPS: c.cfg.RateLimitRPS, RateLimitBurst: c.cfg.RateLimitBurst}
+			var limitsErr error
+			if c.pool == nil {
+				limitsErr = c.DoJSON(ctx, http.MethodGet, "/api/v1/limits", nil, &r.Server)
+			} else {
+				for _, s := range c.pool.Stats() {
+					err := c.DoJSON(sdk.WithPoolToken(ctx, s.Name), http.MethodGet, "/api/v1/limits", nil, &r.Server)
+					if limitsErr == nil || err == nil {
+						limitsErr = err
+					}
+				}
+				r.Tokens = c.pool.Stats()
+			}
+			if limitsErr != nil && len(r.Tokens) == 0 {
+				return limitsErr
+			}
+			return printOutput(r, func() {
+				show := func(name string, v int, unit string) {
+					if v > 0 {
+						fmt.Printf("%-22s %d%s\n", name, v, unit)
+					} else {
+						fmt.Printf("%-22s %s\n", name, colorize(colorGray, "not reported"))
+					}
+				}
+				fmt.Println(colorize("1", "Server"))
+				show("  concurrent tasks", r.Server.MaxConcurrentTasks, "")
+				show("  description size", r.Server.MaxDescriptionBytes, " bytes")
+				show("  request size", r.Server.MaxRequestBytes, " bytes")
+				fmt.Println(colorize("1", "Client"))
+				rate := "unlimited"
+				if r.RateLimitRPS > 0 {
+					rate = fmt.Sprintf("%g/s, bursts of %d", r.RateLimitRPS, r.RateLimitBurst)
+				}
+				fmt.Printf("%-22s %s\n", "  rate_limit_rps", rate)
+				if len(r.Tokens) == 0 {
+					return
+				}
+				fmt.Println()
+				fmt.Println(colorize("1", "Token pool"))
+				now := time.Now()
+				tbl := newTable(os.Stdout, c.tableMaxWidth(), column{header: "NAME"}, column{header: "WEIGHT", right: true},
+					column{header: "REMAINING", right: true}, column{header: "RESETS"}, column{header: "REQUESTS", right: true}, column{header: "STATE", flex: true})
+				for _, s := range r.Tokens {
+					remaining, resets := "-", "-"
+					if s.Remaining >= 0 {
+						remaining = strconv.Itoa(s.Remaining)
+						if s.Limit > 0 {
+							remaining += "/" + strconv.Itoa(s.Limit)
+						}
+					}
+					if reset, ok := s.Resets(now); ok {
+						resets = "in " + reset.Sub(now).Round(time.Second).String()
+					}
+					tbl.add(s.Name, strconv.Itoa(s.Weight), remaining, resets, strconv.Itoa(s.Requests), tokenState(s, now))
+				}
+				tbl.render()
+				if r.RateLimitRPS > 0 {
+					fmt.Println(colorize(colorGray, "rate_limit_rps still caps the pool as a whole; raise it to use the pooled limits."))
+				}
+			})
+		},
+	}
+}
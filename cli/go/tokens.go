@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var tokenScopes = []string{"read:tasks", "write:tasks", "read:repos", "write:repos", "read:logs", "admin"}
+
+type scopedToken struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Scopes       []string  `json:"scopes"`
+	Repositories []string  `json:"repositories,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+	Endpoint     string    `json:"endpoint"`
+}
+
+type createTokenRequest struct {
+	Name         string   `json:"name"`
+	Scopes       []string `json:"scopes"`
+	Repositories []string `json:"repositories,omitempty"`
+	TTLSeconds   int64    `json:"ttl_seconds"`
+}
+
+type createTokenResponse struct {
+	scopedToken
+	Token string `json:"token"`
+}
+
+func loadTokenMeta() ([]scopedToken, error) {
+	var tokens []scopedToken
+	if err := readState("tokens.json", &tokens); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func cmdTokens(c *Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tokens",
+		Short: "Manage scoped API tokens for CI and automation",
+	}
+	cmd.AddCommand(cmdTokensCreate(c), cmdTokensList(c), cmdTokensRevoke(c))
+	return cmd
+}
+
+func cmdTokensCreate(c *Client) *cobra.Command {
+	var req createTokenRequest
+	var ttl string
+	cmd := &cobra.Command{
+		Use:     "create",
+		Short:   "Create a least-privilege token",
+		Example: "  autocodit tokens create --name ci --scope read:tasks --scope write:tasks --ttl 24h --repo org/api",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(req.Scopes) == 0 {
+				return fmt.Errorf("at least one --scope is required (%s)", strings.Join(tokenScopes, ", "))
+			}
+			for _, s := range req.Scopes {
+				if !contains(tokenScopes, s) {
+					return fmt.Errorf("unknown scope %q (known: %s)", s, strings.Join(tokenScopes, ", "))
+				}
+			}
+			d, err := parseDuration(ttl)
+			if err != nil || d <= 0 {
+				return fmt.Errorf("invalid --ttl %q", ttl)
+			}
+			req.TTLSeconds = int64(d / time.Second)
+			if req.Name == "" {
+				req.Name = "cli-" + time.Now().Format("20060102-150405")
+			}
+
+			var resp createTokenResponse
+			if err := c.DoJSON(cmd.Context(), http.MethodPost, "/api/v1/tokens", &req, &resp); err != nil {
+				return err
+			}
+			meta := resp.scopedToken
+			meta.Endpoint = c.BaseURL
+			var tokens []scopedToken
+			if err := updateState("tokens.json", &tokens, func() { tokens = append(tokens, meta) }); err != nil {
+				return err
+			}
+
+			fmt.Fprintf(os.Stderr, "Token %s created, expires %s. It will not be shown again:\n", meta.ID, meta.ExpiresAt.Local().Format(time.RFC1123))
+			fmt.Println(resp.Token)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&req.Name, "name", "", "label for the token")
+	cmd.Flags().StringSliceVar(&req.Scopes, "scope", nil, "scope to grant, repeatable: "+strings.Join(tokenScopes, ","))
+	cmd.Flags().StringSliceVar(&req.Repositories, "repo", nil, "restrict the token to owner/repo, repeatable")
+	cmd.Flags().StringVar(&ttl, "ttl", "24h", "token lifetime, e.g. 1h, 24h, 30d")
+	return cmd
+}
+
+func cmdTokensList(c *Client) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List tokens created from this machine",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tokens, err := loadTokenMeta()
+			if err != nil {
+				return err
+			}
+			shown := []scopedToken{}
+			for _, t := range tokens {
+				if t.Endpoint == c.BaseURL {
+					shown = append(shown, t)
+				}
+			}
+			return printOutput(shown, func() {
+				for _, t := range shown {
+					state := "expires " + t.ExpiresAt.Local().Format(time.DateTime)
+					if time.Now().After(t.ExpiresAt) {
+						state = "expired"
+					}
+					repos := strings.Join(t.Repositories, ",")
+					if repos == "" {
+						repos = "*"
+					}
+					fmt.Printf("%s %-20s %-30s %-20s %s\n", t.ID, t.Name, strings.Join(t.Scopes, ","), repos, state)
+				}
+			})
+		},
+	}
+}
+
+func cmdTokensRevoke(c *Client) *cobra.Command {
+	return &cobra.Command{
+		Use:   "revoke [id]",
+		Short: "Revoke a token",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id := args[0]
+			if err := c.DoJSON(cmd.Context(), http.MethodDelete, "/api/v1/tokens/"+id, nil, nil); err != nil {
+				return err
+			}
+			var tokens []scopedToken
+			err := updateState("tokens.json", &tokens, func() {
+				kept := tokens[:0]
+				for _, t := range tokens {
+					if t.ID != id {
+						kept = append(kept, t)
+					}
+				}
+				tokens = kept
+			})
+			if err != nil {
+				return err
+			}
+			fmt.Println("Token revoked:", id)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+// listTrash returns the deleted tasks the server can still restore, most
+// recently deleted first.
+func (c *Client) listTrash(ctx context.Context, opts sdk.ListTasksOptions) ([]Task, error) {
+	opts.Deleted = true
+	var tasks []Task
+	ch, errc := c.ListTasks(opts).Stream(ctx)
+	for t := range ch {
+		tasks = append(tasks, t)
+	}
+	if err := <-errc; err != nil {
+		return nil, userScopeError(err, opts)
+	}
+	deleted := func(t Task) time.Time {
+		if t.DeletedAt != nil {
+			return *t.DeletedAt
+		}
+		return t.UpdatedAt
+	}
+	sort.SliceStable(tasks, func(i, j int) bool { return deleted(tasks[i]).After(deleted(tasks[j])) })
+	return tasks, nil
+}
+
+func restoreError(id string, err error) error {
+	var apiErr *sdk.APIError
+	if errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusNotFound || apiErr.StatusCode == http.StatusGone) {
+		return fmt.Errorf("%s is not in the trash: it was not deleted, or the server has purged it", id)
+	}
+	return err
+}
+
+func (c *Client) printTrash(tasks []Task) {
+	tbl := newTable(os.Stdout, c.tableMaxWidth(), column{header: "ID"}, column{header: "STATUS"}, column{header: "REPO"},
+		column{header: "DELETED"}, column{header: "PURGED"}, column{header: "TITLE", flex: true})
+	for _, t := range tasks {
+		tbl.add(t.ID, statusColor(t.Status), t.Repository, formatTime(t.DeletedAt), formatTime(t.PurgeAt), redact(t.Title))
+	}
+	tbl.render()
+}
+
+func cmdTrash(c *Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "trash",
+		Short: "Deleted tasks that can still be restored",
+	}
+	cmd.AddCommand(cmdTrashList(c))
+	return cmd
+}
+
+func cmdTrashList(c *Client) *cobra.Command {
+	var opts sdk.ListTasksOptions
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List deleted tasks within the server's retention window",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tasks, err := c.listTrash(cmd.Context(), opts)
+			if err != nil {
+				return err
+			}
+			return printOutput(tasks, func() {
+				if len(tasks) == 0 {
+					fmt.Println("The trash is empty")
+					return
+				}
+				c.printTrash(tasks)
+				fmt.Fprintln(os.Stderr, colorize(colorGray, "Restore with: autocodit restore <id>, or --since 1h for everything deleted in the last hour"))
+			})
+		},
+	}
+	cmd.Flags().StringVarP(&opts.Repository, "repo", "r", "", "only tasks on this repository")
+	addUserScopeFlags(cmd, &opts)
+	return cmd
+}
+
+// selectTasks lists the tasks delete --status/--older-than picks: finished
+// ones only, since running tasks need cancelling first.
+func (c *Client) selectTasks(ctx context.Context, opts sdk.ListTasksOptions, repoGroup string, olderThan time.Time) ([]Task, error) {
+	var tasks []Task
+	if repoGroup != "" {
+		repos, err := c.repoGroup(repoGroup)
+		if err != nil {
+			return nil, err
+		}
+		if tasks, err = c.listRepoGroup(ctx, opts, repos, true); err != nil {
+			return nil, err
+		}
+	} else {
+		ch, errc := c.ListTasks(opts).Stream(ctx)
+		for t := range ch {
+			tasks = append(tasks, t)
+		}
+		if err := <-errc; err != nil {
+			return nil, userScopeError(err, opts)
+		}
+	}
+	kept := tasks[:0]
+	for _, t := range tasks {
+		if (opts.Status == "" || t.Status == opts.Status) && isFinished(t.Status) && (olderThan.IsZero() || (!t.CreatedAt.IsZero() && t.CreatedAt.Before(olderThan))) {
+			kept = append(kept, t)
+		}
+	}
+	return kept, nil
+}
+
+func cmdDelete(c *Client) *cobra.Command {
+	var opts sdk.ListTasksOptions
+	var repoGroup, olderThan string
+	var yes, dryRun bool
+	cmd := &cobra.Command{
+		Use:   "delete [id...]",
+		Short: "Delete tasks by ID, or every finished task matching filters",
+		Long: `delete removes the given tasks, or with --status and/or --older-than every
+finished task that matches, optionally on one repository or repo group:
+
+  autocodit delete --status failed --older-than 30d --repo org/api --yes
+
+Deleted tasks go to the trash and can be brought back with restore until the
+server purges them. Running and queued tasks are never selected; cancel them
+first. Without --yes, the selection is shown and confirmed.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			filtered := opts.Status != "" || olderThan != ""
+			if len(args) == 0 && !filtered {
+				return fmt.Errorf("give task IDs, or select tasks with --status and/or --older-than")
+			}
+			if len(args) > 0 && (filtered || opts.Repository != "" || repoGroup != "") {
+				return fmt.Errorf("give task IDs or filters, not both")
+			}
+			if opts.Status != "" && !isFinished(opts.Status) {
+				return fmt.Errorf("--status must be completed, failed, or cancelled; cancel unfinished tasks first")
+			}
+			var targets []Task
+			for _, id := range args {
+				targets = append(targets, Task{ID: id})
+			}
+			if filtered {
+				var cutoff time.Time
+				if olderThan != "" {
+					d, err := parseDuration(olderThan)
+					if err != nil || d <= 0 {
+						return fmt.Errorf("invalid --older-than %q (want a duration like 30d)", olderThan)
+					}
+					cutoff = time.Now().Add(-d)
+				}
+				var err error
+				if targets, err = c.selectTasks(ctx, opts, repoGroup, cutoff); err != nil {
+					return err
+				}
+				if len(targets) == 0 {
+					fmt.Fprintln(os.Stderr, "No finished tasks match")
+					return nil
+				}
+			}
+			if filtered || dryRun {
+				w := os.Stderr
+				if !machineOutput() {
+					w = os.Stdout
+				}
+				fmt.Fprintf(w, "%d task(s) to delete:\n", len(targets))
+				if !machineOutput() && !filtered {
+					for _, t := range targets {
+						fmt.Println(t.ID)
+					}
+				} else if !machineOutput() {
+					tbl := newTable(os.Stdout, c.tableMaxWidth(), column{header: "ID"}, column{header: "STATUS"}, column{header: "REPO"},
+						column{header: "CREATED"}, column{header: "TITLE", flex: true})
+					for _, t := range targets {
+						tbl.add(t.ID, statusColor(t.Status), t.Repository, formatTime(&t.CreatedAt), redact(t.Title))
+					}
+					tbl.render()
+				}
+				if dryRun {
+					if machineOutput() {
+						return printOutput(targets, func() {})
+					}
+					return nil
+				}
+			}
+			if !yes && !confirm(fmt.Sprintf("Delete %d task(s)?", len(targets))) {
+				return fmt.Errorf("aborted; pass --yes to delete without asking")
+			}
+
+			var results []taskResult
+			failed := 0
+			for _, t := range targets {
+				r := taskResult{ID: t.ID, Title: t.Title}
+				if err := c.DoJSON(ctx, http.MethodDelete, "/api/v1/tasks/"+t.ID, nil, nil); err != nil {
+					r.Error = fmt.Sprintf("%s: %v", t.ID, err)
+					failed++
+				}
+				results = append(results, r)
+				if machineOutput() {
+					continue
+				}
+				if r.Error != "" {
+					fmt.Printf("%s %s\n", colorize(colorRed, "failed "), r.Error)
+				} else {
+					fmt.Printf("%s %s %s\n", colorize(colorGreen, "deleted"), t.ID, redact(t.Title))
+				}
+			}
+			audit("delete", map[string]any{"tasks": len(results) - failed, "failed": failed, "status": opts.Status, "older_than": olderThan})
+			if machineOutput() {
+				if err := printOutput(results, func() {}); err != nil {
+					return err
+				}
+			} else if failed < len(results) {
+				fmt.Fprintln(os.Stderr, colorize(colorGray, "Undo with: autocodit restore --since 10m"))
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d of %d task(s) not deleted", failed, len(results))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&opts.Status, "status", "s", "", "delete every task with this status (completed, failed, cancelled)")
+	cmd.Flags().StringVar(&olderThan, "older-than", "", "delete every finished task created longer ago than this, e.g. 30d")
+	cmd.Flags().StringVarP(&opts.Repository, "repo", "r", "", "with filters, only tasks on this repository")
+	cmd.Flags().StringVar(&repoGroup, "repo-group", "", "with filters, only tasks on the repositories of this repo_groups entry")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "delete without asking")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "show what would be deleted")
+	cmd.MarkFlagsMutuallyExclusive("repo", "repo-group")
+	addUserScopeFlags(cmd, &opts)
+	return cmd
+}
+
+// taskResult is the outcome of restoring or deleting one task.
+type taskResult struct {
+	ID    string `json:"id"`
+	Title string `json:"title,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+func cmdRestore(c *Client) *cobra.Command {
+	var opts sdk.ListTasksOptions
+	var since string
+	var yes bool
+	cmd := &cobra.Command{
+		Use:   "restore [id...]",
+		Short: "Restore deleted tasks from the trash",
+		Long: `restore brings back tasks that were deleted, as long as the server has not yet
+purged them (see trash list for what is left and until when). To undo a bulk
+delete, --since restores every task deleted within that time, after showing them
+and asking:
+
+  autocodit restore --since 30m --repo org/api`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			if len(args) == 0 && since == "" {
+				return fmt.Errorf("give the task IDs to restore or --since (see: autocodit trash list)")
+			}
+			if len(args) > 0 && since != "" {
+				return fmt.Errorf("give task IDs or --since, not both")
+			}
+			var targets []Task
+			for _, id := range args {
+				targets = append(targets, Task{ID: id})
+			}
+			if since != "" {
+				cutoff, err := parseSince(since)
+				if err != nil {
+					return err
+				}
+				trash, err := c.listTrash(ctx, opts)
+				if err != nil {
+					return err
+				}
+				for _, t := range trash {
+					if t.DeletedAt != nil && !t.DeletedAt.Before(cutoff) {
+						targets = append(targets, t)
+					}
+				}
+				if len(targets) == 0 {
+					fmt.Fprintf(os.Stderr, "Nothing in the trash was deleted since %s\n", cutoff.Local().Format(time.DateTime))
+					return nil
+				}
+				w := os.Stdout
+				if machineOutput() {
+					w = os.Stderr
+				}
+				fmt.Fprintf(w, "%d task(s) deleted since %s:\n", len(targets), cutoff.Local().Format(time.DateTime))
+				if !machineOutput() {
+					c.printTrash(targets)
+				}
+				if !yes && !confirm(fmt.Sprintf("Restore %d task(s)?", len(targets))) {
+					return fmt.Errorf("aborted; pass --yes to restore without asking")
+				}
+			}
+
+			var results []taskResult
+			failed := 0
+			for _, t := range targets {
+				r := taskResult{ID: t.ID, Title: t.Title}
+				if err := c.applyFix(ctx, "restore", t); err != nil {
+					r.Error = restoreError(t.ID, err).Error()
+					failed++
+				}
+				results = append(results, r)
+				if machineOutput() {
+					continue
+				}
+				if r.Error != "" {
+					fmt.Printf("%s %s\n", colorize(colorRed, "failed  "), r.Error)
+				} else {
+					fmt.Printf("%s %s %s\n", colorize(colorGreen, "restored"), c.taskLink(t.ID), redact(t.Title))
+				}
+			}
+			audit("restore", map[string]any{"tasks": len(results) - failed, "failed": failed, "since": since})
+			if machineOutput() {
+				if err := printOutput(results, func() {}); err != nil {
+					return err
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d of %d task(s) not restored", failed, len(results))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&since, "since", "", "restore every task deleted within this time (e.g. 30m, 2h) or since a date")
+	cmd.Flags().StringVarP(&opts.Repository, "repo", "r", "", "with --since, only tasks on this repository")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "with --since, restore without asking")
+	addUserScopeFlags(cmd, &opts)
+	return cmd
+}
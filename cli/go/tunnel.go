@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sshTunnel forwards a random loopback port to the API host through a bastion
+// using the system ssh, so the user's keys, agent, and ssh_config apply.
+// It is started on the first connection to the API host, so commands that
+// never reach the API don't spawn ssh.
+type sshTunnel struct {
+	target, remote string
+
+	once  sync.Once
+	err   error
+	local string
+	cmd   *exec.Cmd
+	done  chan struct{}
+}
+
+func newSSHTunnel(target, endpoint string) (*sshTunnel, error) {
+	// ssh would take a leading dash as an option such as -oProxyCommand.
+	if strings.HasPrefix(target, "-") {
+		return nil, fmt.Errorf("ssh tunnel target %q must be user@host, not an option", target)
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return &sshTunnel{target: target, remote: net.JoinHostPort(u.Hostname(), port)}, nil
+}
+
+func (t *sshTunnel) start() error {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	t.local = l.Addr().String()
+	l.Close()
+
+	t.cmd = exec.Command("ssh", "-N",
+		"-o", "ExitOnForwardFailure=yes",
+		"-L", t.local+":"+t.remote, "--", t.target)
+	t.cmd.Stderr = os.Stderr
+	if err := t.cmd.Start(); err != nil {
+		return fmt.Errorf("ssh tunnel: %w", err)
+	}
+	t.done = make(chan struct{})
+	go func() { t.cmd.Wait(); close(t.done) }()
+
+	deadline := time.Now().Add(15 * time.Second)
+	for time.Now().Before(deadline) {
+		select {
+		case <-t.done:
+			return fmt.Errorf("ssh tunnel to %s exited: %v", t.target, t.cmd.ProcessState)
+		default:
+		}
+		if conn, err := net.DialTimeout("tcp", t.local, 200*time.Millisecond); err == nil {
+			conn.Close()
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.close()
+	return fmt.Errorf("ssh tunnel to %s: timed out waiting for forward", t.target)
+}
+
+func (t *sshTunnel) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	var d net.Dialer
+	if addr != t.remote {
+		return d.DialContext(ctx, network, addr)
+	}
+	t.once.Do(func() { t.err = t.start() })
+	if t.err != nil {
+		return nil, t.err
+	}
+	return d.DialContext(ctx, network, t.local)
+}
+
+func (t *sshTunnel) close() {
+	if t == nil || t.cmd == nil || t.cmd.Process == nil {
+		return
+	}
+	t.cmd.Process.Kill()
+	<-t.done
+}
+
+// useTunnel routes HTTP and WebSocket connections to the API host through t.
+// Only the dial target changes, so Host headers and TLS verification still
+// see the real endpoint.
+func (c *Client) useTunnel(t *sshTunnel) {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.DialContext = t.dial
+	c.HTTP.Transport = tr
+	c.tunnel = t
+}
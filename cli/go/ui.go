@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+const (
+	uiRefresh  = 3 * time.Second
+	uiMaxTasks = 200
+)
+
+// uiState is everything the dashboard draws.
+type uiState struct {
+	tasks    []Task
+	sel, top int
+	logs     []taskLog
+	showLogs bool
+	flash    string
+	confirm  string // "cancel" or "retry" while waiting for y
+	updated  time.Time
+	err      error
+}
+
+func (s *uiState) selected() (Task, bool) {
+	if s.sel < 0 || s.sel >= len(s.tasks) {
+		return Task{}, false
+	}
+	return s.tasks[s.sel], true
+}
+
+// uiData is one refresh, fetched off the input loop.
+type uiData struct {
+	tasks []Task
+	logs  []taskLog
+	err   error
+}
+
+func (c *Client) uiFetch(ctx context.Context, opts sdk.ListTasksOptions, logsFor string, tail int) uiData {
+	var d uiData
+	opts.Limit = uiMaxTasks
+	tasks, errc := c.ListTasks(opts).Stream(ctx)
+	for t := range tasks {
+		d.tasks = append(d.tasks, t)
+	}
+	if err := <-errc; err != nil {
+		d.err = userScopeError(err, opts)
+		return d
+	}
+	if logsFor != "" && tail > 0 {
+		d.logs, _ = c.taskLogsQuery(ctx, logsFor, url.Values{"tail": {strconv.Itoa(tail)}})
+		if len(d.logs) > tail {
+			d.logs = d.logs[len(d.logs)-tail:]
+		}
+	}
+	return d
+}
+
+// readKeys turns raw stdin into key names: "up", "down", "pgup", "pgdown",
+// "home", "end", "enter", "quit", or the character typed.
+func readKeys(keys chan<- string) {
+	buf := make([]byte, 16)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			keys <- "quit"
+			return
+		}
+		b := buf[:n]
+		switch {
+		case len(b) >= 3 && b[0] == 0x1b && (b[1] == '[' || b[1] == 'O'):
+			switch b[2] {
+			case 'A':
+				keys <- "up"
+			case 'B':
+				keys <- "down"
+			case 'H', '1':
+				keys <- "home"
+			case 'F', '4':
+				keys <- "end"
+			case '5':
+				keys <- "pgup"
+			case '6':
+				keys <- "pgdown"
+			}
+		case b[0] == 3 || b[0] == 4: // Ctrl-C, Ctrl-D
+			keys <- "quit"
+		case b[0] == '\r' || b[0] == '\n':
+			keys <- "enter"
+		case b[0] == 0x1b:
+			keys <- "esc"
+		default:
+			keys <- string(b[:1])
+		}
+	}
+}
+
+func progressBar(p float64, w int) string {
+	filled := int(min(max(p, 0), 1)*float64(w) + 0.5)
+	return strings.Repeat("█", filled) + strings.Repeat("░", w-filled)
+}
+
+// uiFrame renders s for a width x height terminal. Lines end in \r\n since
+// the terminal is in raw mode.
+func (c *Client) uiFrame(s *uiState, width, height int) string {
+	var lines []string
+	add := func(l string) { lines = append(lines, truncateWidth(l, width)) }
+
+	counts := map[string]int{}
+	for _, t := range s.tasks {
+		counts[t.Status]++
+	}
+	head := fmt.Sprintf("autocodit  %d task(s)  %d running  %d queued  %d failed",
+		len(s.tasks), counts["running"], counts["queued"]+counts["pending"], counts["failed"])
+	state := "loading…"
+	if s.err != nil {
+		state = colorize(colorRed, "error: "+firstLine(s.err.Error()))
+	} else if !s.updated.IsZero() {
+		state = colorize(colorGray, "updated "+s.updated.Format("15:04:05"))
+	}
+	add(colorize("1", head) + strings.Repeat(" ", max(width-displayWidth(head)-displayWidth(state), 2)) + state)
+
+	listHeight := height - 3
+	logHeight := 0
+	if s.showLogs {
+		logHeight = max(height/3, 4)
+		listHeight -= logHeight + 1
+	}
+	listHeight = max(listHeight, 1)
+	if s.sel < s.top {
+		s.top = s.sel
+	}
+	if s.sel >= s.top+listHeight {
+		s.top = s.sel - listHeight + 1
+	}
+
+	const idW, statusW, barW, repoW = 14, 10, 10, 22
+	titleW := max(width-idW-statusW-barW-repoW-11, 10)
+	add(colorize(colorGray, "  "+padWidth("ID", idW, false)+" "+padWidth("STATUS", statusW, false)+" "+
+		padWidth("PROGRESS", barW+5, false)+" "+padWidth("REPO", repoW, false)+" TITLE"))
+	for i := s.top; i < len(s.tasks) && i < s.top+listHeight; i++ {
+		t := s.tasks[i]
+		status := statusColor(t.Status)
+		if i == s.sel {
+			status = t.Status // a color reset would end the highlight
+		}
+		row := padWidth(truncateWidth(t.ID, idW), idW, false) + " " + padWidth(status, statusW, false) + " " +
+			progressBar(t.Progress, barW) + fmt.Sprintf(" %3.0f%%", t.Progress*100) + " " +
+			padWidth(truncateWidth(t.Repository, repoW), repoW, false) + " " + truncateCell(redact(t.Title), titleW)
+		if i == s.sel {
+			add(colorize("7", padWidth("> "+row, width, false)))
+		} else {
+			add("  " + row)
+		}
+	}
+	if len(s.tasks) == 0 && s.err == nil && !s.updated.IsZero() {
+		add("  No tasks")
+	}
+	for len(lines) < listHeight+2 {
+		add("")
+	}
+
+	if s.showLogs {
+		title := "logs"
+		if t, ok := s.selected(); ok {
+			title = "logs: " + t.ID
+			if t.ErrorMessage != "" {
+				title += "  " + colorize(colorRed, firstLine(redact(t.ErrorMessage)))
+			}
+		}
+		add(colorize(colorGray, "── ") + title + " " + colorize(colorGray, strings.Repeat("─", max(width-displayWidth(title)-4, 0))))
+		logs := s.logs
+		if len(logs) > logHeight {
+			logs = logs[len(logs)-logHeight:]
+		}
+		for _, l := range logs {
+			add(cellReplacer.Replace(formatTaskLog(l)))
+		}
+		for len(lines) < height-1 {
+			add("")
+		}
+	}
+
+	footer := colorize(colorGray, "↑/↓ move  enter open  c cancel  r retry  l logs  q quit")
+	switch {
+	case s.confirm != "":
+		t, _ := s.selected()
+		footer = colorize(colorYellow, fmt.Sprintf("%s %s? y/n", strings.ToUpper(s.confirm[:1])+s.confirm[1:], t.ID))
+	case s.flash != "":
+		footer = s.flash
+	}
+	for len(lines) < height-1 {
+		add("")
+	}
+	add(footer)
+	return strings.Join(lines[:min(len(lines), height)], "\x1b[K\r\n") + "\x1b[K"
+}
+
+func cmdUI(c *Client) *cobra.Command {
+	var opts sdk.ListTasksOptions
+	cmd := &cobra.Command{
+		Use:   "ui",
+		Short: "Full-screen dashboard of tasks with live status and logs",
+		Long: `ui shows your tasks full screen, refreshed every few seconds, with the selected
+task's latest log lines underneath. Keys:
+
+  up/down, j/k   select a task     home/end, g/G  first/last
+  pgup/pgdown    move a page       enter, o       open the task (web_url) or its PR
+  c              cancel the task   r              retry the task
+  l              show/hide logs    q, Ctrl-C      quit`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			in, out := int(os.Stdin.Fd()), int(os.Stdout.Fd())
+			if !term.IsTerminal(in) || !term.IsTerminal(out) {
+				return fmt.Errorf("ui needs a terminal; use list or watch instead")
+			}
+			state, err := term.MakeRaw(in)
+			if err != nil {
+				return err
+			}
+			defer term.Restore(in, state)
+			// Alternate screen, cursor hidden; both undone on the way out.
+			fmt.Print("\x1b[?1049h\x1b[?25l")
+			defer fmt.Print("\x1b[?25h\x1b[?1049l")
+
+			ctx, cancel := context.WithCancel(cmd.Context())
+			defer cancel()
+			keys := make(chan string, 8)
+			go readKeys(keys)
+			resized := make(chan struct{}, 1)
+			stop := watchResize(func() {
+				select {
+				case resized <- struct{}{}:
+				default:
+				}
+			})
+			defer stop()
+
+			s := &uiState{showLogs: true}
+			loaded := make(chan uiData, 1)
+			loading := false
+			width, height := 80, 24
+			refresh := func() {
+				if loading {
+					return
+				}
+				loading = true
+				id := ""
+				if t, ok := s.selected(); ok && s.showLogs {
+					id = t.ID
+				}
+				tail := max(height/3, 4)
+				go func() { loaded <- c.uiFetch(ctx, opts, id, tail) }()
+			}
+			draw := func() {
+				if w, h, err := term.GetSize(out); err == nil {
+					width, height = w, h
+				}
+				os.Stdout.WriteString("\x1b[H" + c.uiFrame(s, width, height) + "\x1b[J")
+			}
+			act := func(fix string) {
+				t, ok := s.selected()
+				if !ok {
+					return
+				}
+				if err := c.applyFix(ctx, fix, t); err != nil {
+					s.flash = colorize(colorRed, fix+" "+t.ID+": "+firstLine(err.Error()))
+					return
+				}
+				audit("ui."+fix, map[string]any{"task": t.ID})
+				s.flash = colorize(colorGreen, fix+" requested for "+t.ID)
+				refresh()
+			}
+
+			tick := time.NewTicker(uiRefresh)
+			defer tick.Stop()
+			refresh()
+			draw()
+			for {
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-resized:
+				case <-tick.C:
+					refresh()
+				case d := <-loaded:
+					loading = false
+					s.err = d.err
+					if d.err == nil {
+						prev, _ := s.selected()
+						s.tasks, s.logs, s.updated = d.tasks, d.logs, time.Now()
+						// Keep the same task selected as the list shifts.
+						for i, t := range s.tasks {
+							if t.ID == prev.ID {
+								s.sel = i
+							}
+						}
+						s.sel = min(s.sel, max(len(s.tasks)-1, 0))
+						if cur, ok := s.selected(); ok && cur.ID != prev.ID {
+							s.logs = nil
+							refresh()
+						}
+					}
+				case k := <-keys:
+					if s.confirm != "" {
+						fix := s.confirm
+						s.confirm = ""
+						if k == "y" || k == "Y" {
+							act(fix)
+						} else {
+							s.flash = ""
+						}
+						break
+					}
+					s.flash = ""
+					before := s.sel
+					page := max(height-6, 1)
+					if s.showLogs {
+						page = max(page-height/3, 1)
+					}
+					switch k {
+					case "quit", "q":
+						return nil
+					case "up", "k":
+						s.sel--
+					case "down", "j":
+						s.sel++
+					case "pgup":
+						s.sel -= page
+					case "pgdown":
+						s.sel += page
+					case "home", "g":
+						s.sel = 0
+					case "end", "G":
+						s.sel = len(s.tasks) - 1
+					case "l":
+						s.showLogs = !s.showLogs
+						s.logs = nil
+						refresh()
+					case "c":
+						if t, ok := s.selected(); ok && !isFinished(t.Status) {
+							s.confirm = "cancel"
+						}
+					case "r":
+						if t, ok := s.selected(); ok && isFinished(t.Status) && t.Status != "completed" {
+							s.confirm = "retry"
+						}
+					case "enter", "o":
+						if t, ok := s.selected(); ok {
+							link := c.taskURL(t.ID)
+							if link == "" && t.PRNumber != nil {
+								link = prURL(t.Repository, *t.PRNumber)
+							}
+							if link == "" {
+								s.flash = colorize(colorYellow, "set web_url in the config to open tasks")
+							} else if err := openBrowser(link); err != nil {
+								s.flash = colorize(colorRed, "open: "+err.Error())
+							}
+						}
+					}
+					s.sel = min(max(s.sel, 0), max(len(s.tasks)-1, 0))
+					if s.sel != before && s.showLogs {
+						s.logs = nil
+						refresh()
+					}
+				}
+				draw()
+			}
+		},
+	}
+	cmd.Flags().StringVarP(&opts.Repository, "repo", "r", "", "only tasks on this repository")
+	cmd.Flags().StringVarP(&opts.Status, "status", "s", "", "only tasks with this status")
+	addUserScopeFlags(cmd, &opts)
+	return cmd
+}
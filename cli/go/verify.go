@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/arturwyroslak/autocodit-agent/cli/sdk"
+)
+
+// verificationClaim is a check the agent reports having run, from GET
+// /api/v1/tasks/{id}/verification.
+type verificationClaim struct {
+	Name    string `json:"name,omitempty"`
+	Command string `json:"command"`
+	Status  string `json:"status"`
+	Summary string `json:"summary,omitempty"`
+}
+
+func (vc verificationClaim) passed() bool {
+	return vc.Status == "passed" || vc.Status == "success"
+}
+
+// verifyResult is one command run locally against the task's branch, with
+// what the agent claimed for the same command.
+type verifyResult struct {
+	Command  string             `json:"command"`
+	ExitCode int                `json:"exit_code"`
+	Duration time.Duration      `json:"-"`
+	Seconds  float64            `json:"duration_seconds"`
+	Output   string             `json:"output,omitempty"`
+	Error    string             `json:"error,omitempty"`
+	Claim    *verificationClaim `json:"claim,omitempty"`
+	Verdict  string             `json:"verdict"`
+}
+
+func (r verifyResult) passed() bool { return r.ExitCode == 0 && r.Error == "" }
+
+func (c *Client) verificationClaims(ctx context.Context, id string) ([]verificationClaim, error) {
+	var claims []verificationClaim
+	err := c.DoJSON(ctx, http.MethodGet, "/api/v1/tasks/"+id+"/verification", nil, &claims)
+	var apiErr *sdk.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	return claims, err
+}
+
+// verifyCommands returns the commands to run for repo. They come only from
+// the user's and the workspace's config, never from the agent's branch.
+func (cfg *Config) verifyCommands(repo string) []string {
+	if s := cfg.Repos[strings.ToLower(repo)]; len(s.VerifyCommands) > 0 {
+		return s.VerifyCommands
+	}
+	return cfg.VerifyCommands
+}
+
+// verifier runs one command with dir as the working directory.
+type verifier func(ctx context.Context, dir, command string) (output []byte, exitCode int, err error)
+
+func runLocal(ctx context.Context, dir, command string) ([]byte, int, error) {
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = dir
+	var out bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &out, &out
+	err := cmd.Run()
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		return out.Bytes(), exit.ExitCode(), nil
+	}
+	return out.Bytes(), 0, err
+}
+
+// checkoutTask fetches the task's branch into a detached worktree, leaving
+// the user's working tree alone.
+func checkoutTask(t Task, remote string) (dir string, cleanup func(), err error) {
+	if t.BranchName == "" {
+		return "", nil, fmt.Errorf("task %s has no branch yet", t.ID)
+	}
+	ref := "refs/remotes/" + remote + "/" + t.BranchName
+	if _, err := git("fetch", remote, "+refs/heads/"+t.BranchName+":"+ref); err != nil {
+		return "", nil, err
+	}
+	dir, err = os.MkdirTemp("", "autocodit-verify-")
+	if err != nil {
+		return "", nil, err
+	}
+	if _, err := git("worktree", "add", "--detach", dir, ref); err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+	return dir, func() {
+		_, _ = git("worktree", "remove", "--force", dir)
+		os.RemoveAll(dir)
+	}, nil
+}
+
+// matchClaim finds the agent's claim for command, by command text or name.
+func matchClaim(claims []verificationClaim, command string) *verificationClaim {
+	for i, cl := range claims {
+		if strings.TrimSpace(cl.Command) == strings.TrimSpace(command) || cl.Name != "" && cl.Name == command {
+			return &claims[i]
+		}
+	}
+	return nil
+}
+
+func verdict(r verifyResult) string {
+	switch {
+	case r.Claim == nil && r.passed():
+		return "passed"
+	case r.Claim == nil:
+		return "failed"
+	case r.Claim.passed() && !r.passed():
+		return "discrepancy"
+	case !r.Claim.passed() && r.passed():
+		return "agent failed, passes locally"
+	case r.passed():
+		return "confirmed"
+	}
+	return "failed (as reported)"
+}
+
+func tail(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (c *Client) printVerify(results []verifyResult, claims []verificationClaim) {
+	tbl := newTable(os.Stdout, c.tableMaxWidth(), column{header: "COMMAND", flex: true},
+		column{header: "LOCAL"}, column{header: "AGENT"}, column{header: "VERDICT"})
+	for _, r := range results {
+		local := colorize(colorGreen, "pass "+r.Duration.Round(time.Second).String())
+		if r.Error != "" {
+			local = colorize(colorRed, "error")
+		} else if r.ExitCode != 0 {
+			local = colorize(colorRed, fmt.Sprintf("exit %d", r.ExitCode))
+		}
+		agent := colorize(colorGray, "no claim")
+		if r.Claim != nil {
+			agent = r.Claim.Status
+		}
+		v := r.Verdict
+		switch v {
+		case "discrepancy":
+			v = colorize(colorRed, strings.ToUpper(v))
+		case "confirmed", "passed":
+			v = colorize(colorGreen, v)
+		default:
+			v = colorize(colorYellow, v)
+		}
+		tbl.add(redact(r.Command), local, agent, v)
+	}
+	tbl.render()
+
+	for i, cl := range claims {
+		checked := false
+		for _, r := range results {
+			checked = checked || r.Claim == &claims[i]
+		}
+		if !checked {
+			fmt.Printf("%s the agent reports %q %s; it is not one of your verification commands\n", colorize(colorGray, "note:"), redact(cl.Command), cl.Status)
+		}
+	}
+	for _, r := range results {
+		if r.passed() {
+			continue
+		}
+		fmt.Printf("\n%s\n", colorize("1", "$ "+redact(r.Command)))
+		if r.Error != "" {
+			fmt.Println(colorize(colorRed, r.Error))
+		}
+		if r.Output != "" {
+			fmt.Println(redact(tail(r.Output, 20)))
+		}
+	}
+}
+
+func cmdVerify(c *Client) *cobra.Command {
+	var commands []string
+	var remote string
+	var timeout time.Duration
+	var keep, container, noNetwork bool
+	var image string
+	cmd := &cobra.Command{
+		Use:   "verify [id]",
+		Short: "Re-run verification commands on a task's branch and compare with the agent's report",
+		Long: `verify checks out the task's branch in a temporary worktree, runs the
+verification commands from verify_commands (or repos.<owner/repo>.verify_commands)
+there, and compares each result with what the agent reported. It exits 1 when a
+command fails or contradicts the agent.
+
+With --container (or verify_container: true) the commands run inside a docker or
+podman container instead of on the host, from --image, verify_image, or the
+devcontainer of the current checkout.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			var t Task
+			if err := c.DoJSON(ctx, http.MethodGet, "/api/v1/tasks/"+args[0], nil, &t); err != nil {
+				return err
+			}
+			if len(commands) == 0 {
+				commands = c.cfg.verifyCommands(t.Repository)
+			}
+			if len(commands) == 0 {
+				return fmt.Errorf("no verification commands for %s; set verify_commands in the config or pass --command", t.Repository)
+			}
+			claims, err := c.verificationClaims(ctx, t.ID)
+			if err != nil {
+				return err
+			}
+			dir, cleanup, err := checkoutTask(t, remote)
+			if err != nil {
+				return err
+			}
+			if keep {
+				fmt.Fprintln(os.Stderr, "Worktree kept at", dir)
+			} else {
+				defer cleanup()
+			}
+
+			var run verifier = runLocal
+			if container || image != "" || c.cfg.VerifyContainer {
+				rt, err := containerRuntime(c.cfg.ContainerRuntime)
+				if err != nil {
+					return err
+				}
+				img, err := c.cfg.sandboxImage(rt, t.Repository, image)
+				if err != nil {
+					return err
+				}
+				if !machineOutput() {
+					fmt.Fprintf(os.Stderr, "%s %s (%s)\n", colorize(colorGray, "sandbox"), img, rt)
+				}
+				cv, stop, err := containerVerifier(ctx, rt, img, dir, noNetwork)
+				if err != nil {
+					return err
+				}
+				defer stop()
+				run = cv
+			}
+			var results []verifyResult
+			for _, command := range commands {
+				if !machineOutput() {
+					fmt.Fprintf(os.Stderr, "%s %s\n", colorize(colorGray, "running"), redact(command))
+				}
+				rctx, cancel := context.WithTimeout(ctx, timeout)
+				start := time.Now()
+				out, code, err := run(rctx, dir, command)
+				cancel()
+				r := verifyResult{Command: command, ExitCode: code, Duration: time.Since(start), Output: string(out), Claim: matchClaim(claims, command)}
+				if err != nil {
+					r.Error = err.Error()
+				} else if rctx.Err() == context.DeadlineExceeded {
+					r.Error = fmt.Sprintf("timed out after %s", timeout)
+				}
+				r.Seconds = r.Duration.Seconds()
+				r.Verdict = verdict(r)
+				results = append(results, r)
+			}
+
+			failed := 0
+			for _, r := range results {
+				if !r.passed() {
+					failed++
+				}
+			}
+			audit("task.verify", map[string]any{"task": t.ID, "commands": len(results), "failed": failed})
+			if err := printOutput(results, func() { c.printVerify(results, claims) }); err != nil {
+				return err
+			}
+			if failed > 0 {
+				// The table already says what went wrong.
+				cmd.SilenceErrors, cmd.SilenceUsage = true, true
+				return exitCodeError{code: 1}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringArrayVarP(&commands, "command", "c", nil, "command to run instead of the configured ones (repeatable)")
+	cmd.Flags().StringVar(&remote, "remote", "origin", "git remote the agent pushed to")
+	cmd.Flags().DurationVar(&timeout, "timeout", 15*time.Minute, "time limit per command")
+	cmd.Flags().BoolVar(&keep, "keep", false, "keep the worktree for inspection")
+	cmd.Flags().BoolVar(&container, "container", false, "run the commands in a docker or podman container")
+	cmd.Flags().StringVar(&image, "image", "", "container image (implies --container)")
+	cmd.Flags().BoolVar(&noNetwork, "no-network", false, "give the container no network access")
+	return cmd
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+)
+
+// watchMaxDiff keeps watch-files tasks lightweight: bigger diffs are left
+// for a regular review.
+const watchMaxDiff = 64 * 1024
+
+// watchDirs lists the directories under roots to watch, leaving out .git
+// and whatever git ignores.
+func watchDirs(roots []string) ([]string, error) {
+	var dirs []string
+	for _, root := range roots {
+		err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() {
+				return nil
+			}
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			dirs = append(dirs, p)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	// check-ignore exits 1 when nothing is ignored; its output is what counts.
+	out, _ := gitInput([]byte(strings.Join(dirs, "\n")), "check-ignore", "--stdin")
+	ignored := map[string]bool{}
+	for _, l := range strings.Split(out, "\n") {
+		ignored[strings.TrimSpace(l)] = true
+	}
+	ignoredUnder := func(d string) bool {
+		for p := d; ; p = filepath.Dir(p) {
+			if ignored[p] {
+				return true
+			}
+			if filepath.Dir(p) == p {
+				return false
+			}
+		}
+	}
+	kept := dirs[:0]
+	for _, d := range dirs {
+		if !ignoredUnder(d) {
+			kept = append(kept, d)
+		}
+	}
+	return kept, nil
+}
+
+// workingDiff returns the uncommitted changes under paths against HEAD,
+// untracked files included and git_context_exclude matches left out.
+func (c *Client) workingDiff(root string, paths []string) (string, []string, error) {
+	var rel []string
+	for _, p := range paths {
+		abs, _ := filepath.Abs(p)
+		r, err := filepath.Rel(root, abs)
+		if err != nil {
+			return "", nil, err
+		}
+		rel = append(rel, filepath.ToSlash(r))
+	}
+	tracked, err := gitLines(append([]string{"-C", root, "diff", "--name-only", "HEAD", "--"}, rel...)...)
+	if err != nil {
+		return "", nil, err
+	}
+	untracked, _ := gitLines(append([]string{"-C", root, "ls-files", "--others", "--exclude-standard", "--"}, rel...)...)
+	keep := func(files []string) []string {
+		var out []string
+		for _, f := range files {
+			if f != "" && !excluded(c.cfg.GitContextExclude, f) {
+				out = append(out, f)
+			}
+		}
+		return out
+	}
+	tracked, untracked = keep(tracked), keep(untracked)
+	var b strings.Builder
+	if len(tracked) > 0 {
+		d, err := gitInput(nil, append([]string{"-C", root, "diff", "HEAD", "--"}, tracked...)...)
+		if err != nil {
+			return "", nil, err
+		}
+		b.WriteString(d + "\n")
+	}
+	for _, f := range untracked {
+		// --no-index exits 1 when the files differ, which they always do here.
+		d, _ := gitInput(nil, "-C", root, "diff", "--no-index", "--", os.DevNull, f)
+		b.WriteString(d + "\n")
+	}
+	return strings.TrimSpace(b.String()), append(tracked, untracked...), nil
+}
+
+func watchDescription(action, branch, head, diff string) string {
+	ask := "Review this work in progress: point out bugs, risky changes, and missing tests, briefly and by file:line."
+	if action == "test" {
+		ask = "Run the tests that cover this work in progress and report failures with the relevant output."
+	}
+	return fmt.Sprintf("%s Do not change code or open a pull request.\n\nUncommitted changes on %s at %s:\n\n```diff\n%s\n```\n",
+		ask, branch, head, redact(diff))
+}
+
+// followFeedback prints a task's log lines as they arrive and its outcome
+// when it finishes.
+func (c *Client) followFeedback(ctx context.Context, id string) {
+	cursor := &logCursor{seen: map[string]bool{}}
+	tick := time.NewTicker(logPollInterval)
+	defer tick.Stop()
+	for {
+		var t Task
+		err := c.DoJSON(ctx, http.MethodGet, "/api/v1/tasks/"+id, nil, &t)
+		if err == nil {
+			q := url.Values{}
+			if !cursor.last.IsZero() {
+				q.Set("since", cursor.last.UTC().Format(time.RFC3339Nano))
+			}
+			if logs, lerr := c.taskLogsQuery(ctx, id, q); lerr == nil {
+				for _, l := range cursor.fresh(logs) {
+					printTaskLog(l)
+				}
+			}
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil && isFinished(t.Status) {
+			status := colorize(colorGreen, t.Status)
+			if t.Status != "completed" {
+				status = colorize(colorRed, t.Status)
+			}
+			fmt.Println(strings.TrimSpace(fmt.Sprintf("%s %s %s", c.taskLink(id), status, redact(t.ErrorMessage))))
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+func cmdWatchFiles(c *Client) *cobra.Command {
+	var repo, action string
+	var debounce time.Duration
+	var yes bool
+	cmd := &cobra.Command{
+		Use:   "watch-files [path...]",
+		Short: "Create a review task for your uncommitted changes each time you save",
+		Long: `watch-files watches the given paths (default: watch_paths, then the whole
+checkout) and, once saves have settled for --debounce, creates a small review or
+test task on the uncommitted diff and streams what the agent logs back into the
+terminal. Changes made while a task runs are picked up when it finishes, and a
+diff that has already been sent is not sent again.
+
+Files git ignores or that match git_context_exclude are never sent, the diff goes
+through secret redaction, and diffs over ` + formatBytes(watchMaxDiff) + ` are skipped.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if action != "review" && action != "test" {
+				return fmt.Errorf("--type must be review or test")
+			}
+			root, err := git("rev-parse", "--show-toplevel")
+			if err != nil {
+				return fmt.Errorf("watch-files needs a git checkout: %w", err)
+			}
+			if repo == "" {
+				repo, _ = originRepo()
+			}
+			if repo == "" {
+				repo = c.cfg.DefaultRepo
+			}
+			if repo == "" {
+				return fmt.Errorf("--repo required: the checkout has no origin and there is no default_repo")
+			}
+			paths := args
+			if len(paths) == 0 {
+				for _, p := range c.cfg.WatchPaths {
+					paths = append(paths, filepath.Join(root, p))
+				}
+			}
+			if len(paths) == 0 {
+				paths = []string{root}
+			}
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+			if err := c.checkCreateTarget(ctx, repo, ""); err != nil {
+				return err
+			}
+			if err := c.confirmTarget(repo, yes); err != nil {
+				return err
+			}
+
+			w, err := fsnotify.NewWatcher()
+			if err != nil {
+				return err
+			}
+			defer w.Close()
+			dirs, err := watchDirs(paths)
+			if err != nil {
+				return err
+			}
+			for _, d := range dirs {
+				if err := w.Add(d); err != nil {
+					return fmt.Errorf("watching %s: %w", d, err)
+				}
+			}
+			fmt.Fprintf(os.Stderr, "Watching %d director(ies) under %s for changes; Ctrl-C to stop\n", len(dirs), strings.Join(paths, ", "))
+
+			var lastSum [sha256.Size]byte
+			var running string
+			var pending bool
+			done := make(chan struct{})
+			timer := time.NewTimer(debounce)
+			timer.Stop()
+			submit := func() {
+				diff, files, err := c.workingDiff(root, paths)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, colorize(colorYellow, "diff: "+err.Error()))
+					return
+				}
+				sum := sha256.Sum256([]byte(diff))
+				if diff == "" || sum == lastSum {
+					return
+				}
+				lastSum = sum
+				if len(diff) > watchMaxDiff {
+					fmt.Fprintf(os.Stderr, "%s\n", colorize(colorYellow, fmt.Sprintf("diff is %s, over %s; skipped (commit or narrow the watched paths)", formatBytes(len(diff)), formatBytes(watchMaxDiff))))
+					return
+				}
+				head, _ := git("rev-parse", "--short", "HEAD")
+				branch, _ := git("rev-parse", "--abbrev-ref", "HEAD")
+				title := fmt.Sprintf("%s %d uncommitted file(s) on %s", strings.ToUpper(action[:1])+action[1:], len(files), branch)
+				req := CreateTaskRequest{
+					Title:       title,
+					Description: watchDescription(action, branch, head, diff),
+					Repository:  repo,
+					ActionType:  action,
+					Priority:    "low",
+					TriggeredBy: "cli:watch-files",
+				}
+				req.AgentConfig = c.agentConfig(repo, action, title, "")
+				req.AgentConfig["base_sha"] = head
+				err = c.checkFreeze(&req, "")
+				if err == nil {
+					err = c.checkBudget(ctx)
+				}
+				if err == nil {
+					err = c.checkDescriptionSize(ctx, req.Description)
+				}
+				var t Task
+				if err == nil {
+					err = tooLargeError(c.createTask(ctx, req, &t), req.Description)
+				}
+				if err != nil {
+					fmt.Fprintln(os.Stderr, colorize(colorRed, "not submitted: "+err.Error()))
+					return
+				}
+				audit("create.watch-files", map[string]any{"task": t.ID, "repository": repo, "files": len(files)})
+				if machineOutput() {
+					_ = writeOutput(os.Stdout, "ndjson", t)
+				} else {
+					fmt.Printf("%s %s: %s\n", colorize(colorBlue, time.Now().Format("15:04:05")), title, c.taskLink(t.ID))
+				}
+				running = t.ID
+				go func() {
+					c.followFeedback(ctx, t.ID)
+					select {
+					case done <- struct{}{}:
+					case <-ctx.Done():
+					}
+				}()
+			}
+
+			for {
+				select {
+				case <-ctx.Done():
+					if running != "" {
+						fmt.Fprintf(os.Stderr, "\n%s is still running; see: autocodit logs %s -f\n", running, running)
+					}
+					return nil
+				case ev, ok := <-w.Events:
+					if !ok {
+						return nil
+					}
+					if ev.Has(fsnotify.Create) {
+						if fi, err := os.Stat(ev.Name); err == nil && fi.IsDir() {
+							if sub, err := watchDirs([]string{ev.Name}); err == nil {
+								for _, d := range sub {
+									_ = w.Add(d)
+								}
+							}
+						}
+					}
+					if ev.Has(fsnotify.Chmod) && !ev.Has(fsnotify.Write) {
+						continue
+					}
+					timer.Reset(debounce)
+				case err, ok := <-w.Errors:
+					if !ok {
+						return nil
+					}
+					fmt.Fprintln(os.Stderr, colorize(colorYellow, "watch: "+err.Error()))
+				case <-timer.C:
+					if running != "" {
+						pending = true
+						continue
+					}
+					submit()
+				case <-done:
+					running = ""
+					if pending {
+						pending = false
+						submit()
+					}
+				}
+			}
+		},
+	}
+	cmd.Flags().StringVarP(&repo, "repo", "r", "", "owner/repo (default: the checkout's origin, then default_repo)")
+	cmd.Flags().StringVarP(&action, "type", "t", "review", "review or test")
+	cmd.Flags().DurationVar(&debounce, "debounce", 5*time.Second, "how long saves must settle before a task is created")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "watch without confirming a repository other than the current checkout or a critical one")
+	return cmd
+}
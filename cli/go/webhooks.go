@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var webhookEvents = []string{"task.created", "task.started", "task.progress", "task.completed", "task.failed", "task.cancelled"}
+
+type Webhook struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type createWebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret,omitempty"`
+}
+
+type webhookDelivery struct {
+	StatusCode int    `json:"status_code"`
+	DurationMS int    `json:"duration_ms"`
+	Error      string `json:"error"`
+}
+
+func cmdWebhooks(c *Client) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "webhooks",
+		Short: "Manage task lifecycle webhooks",
+	}
+	cmd.AddCommand(cmdWebhooksCreate(c), cmdWebhooksList(c), cmdWebhooksDelete(c), cmdWebhooksPing(c), cmdWebhooksTest(c))
+	return cmd
+}
+
+func checkWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("--url must be an absolute http(s) URL")
+	}
+	return nil
+}
+
+func cmdWebhooksCreate(c *Client) *cobra.Command {
+	var req createWebhookRequest
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Register a webhook",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkWebhookURL(req.URL); err != nil {
+				return err
+			}
+			for _, e := range req.Events {
+				if !contains(webhookEvents, e) {
+					return fmt.Errorf("unknown event %q (known: %s)", e, strings.Join(webhookEvents, ", "))
+				}
+			}
+			var w Webhook
+			if err := c.DoJSON(cmd.Context(), http.MethodPost, "/api/v1/webhooks", &req, &w); err != nil {
+				return err
+			}
+			return printOutput(w, func() {
+				fmt.Println("Webhook created:", w.ID)
+				if req.Secret == "" {
+					fmt.Fprintln(os.Stderr, colorize(colorGray, "Deliveries are unsigned; pass --secret to let the receiver check they come from the server."))
+				}
+			})
+		},
+	}
+	cmd.Flags().StringVar(&req.URL, "url", "", "callback URL")
+	cmd.Flags().StringSliceVar(&req.Events, "events", []string{"task.completed", "task.failed"}, "comma-separated events: "+strings.Join(webhookEvents, ","))
+	cmd.Flags().StringVar(&req.Secret, "secret", "", "shared secret used to sign payloads")
+	_ = cmd.MarkFlagRequired("url")
+	return cmd
+}
+
+func cmdWebhooksList(c *Client) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List webhooks",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var hooks []Webhook
+			if err := c.DoJSON(cmd.Context(), http.MethodGet, "/api/v1/webhooks", nil, &hooks); err != nil {
+				return err
+			}
+			if hooks == nil {
+				hooks = []Webhook{}
+			}
+			return printOutput(hooks, func() {
+				if len(hooks) == 0 {
+					fmt.Println("No webhooks")
+					return
+				}
+				tbl := newTable(os.Stdout, c.tableMaxWidth(), column{header: "ID"}, column{header: "STATE"},
+					column{header: "URL", flex: true}, column{header: "EVENTS"})
+				for _, w := range hooks {
+					state := colorize(colorGreen, "active")
+					if !w.Active {
+						state = colorize(colorGray, "inactive")
+					}
+					tbl.add(w.ID, state, w.URL, strings.Join(w.Events, ","))
+				}
+				tbl.render()
+			})
+		},
+	}
+}
+
+func cmdWebhooksDelete(c *Client) *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete [id]",
+		Short: "Delete a webhook",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := c.DoJSON(cmd.Context(), http.MethodDelete, "/api/v1/webhooks/"+args[0], nil, nil); err != nil {
+				return err
+			}
+			fmt.Println("Webhook deleted:", args[0])
+			return nil
+		},
+	}
+}
+
+func cmdWebhooksPing(c *Client) *cobra.Command {
+	return &cobra.Command{
+		Use:   "ping [id]",
+		Short: "Ask the server to deliver a ping event to a webhook",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var d webhookDelivery
+			if err := c.DoJSON(cmd.Context(), http.MethodPost, "/api/v1/webhooks/"+args[0]+"/ping", nil, &d); err != nil {
+				return err
+			}
+			if d.Error != "" {
+				return fmt.Errorf("delivery failed: %s", d.Error)
+			}
+			fmt.Printf("Delivered: HTTP %d in %dms\n", d.StatusCode, d.DurationMS)
+			return nil
+		},
+	}
+}
+
+// sampleWebhookPayload is what a delivery of event looks like, for a made-up
+// task.
+func (c *Client) sampleWebhookPayload(event string) map[string]any {
+	status := map[string]string{"task.created": "queued", "task.started": "running", "task.progress": "running",
+		"task.completed": "completed", "task.failed": "failed", "task.cancelled": "cancelled"}[event]
+	now := time.Now().UTC()
+	task := map[string]any{
+		"id":          "00000000-0000-4000-8000-000000000000",
+		"title":       "Sample task for a webhook test",
+		"repository":  pick(c.cfg.DefaultRepo, "my-org/api"),
+		"action_type": "fix",
+		"status":      status,
+		"priority":    "normal",
+		"created_at":  now.Add(-10 * time.Minute),
+		"updated_at":  now,
+	}
+	switch event {
+	case "task.progress":
+		task["progress"] = 0.5
+	case "task.completed":
+		task["progress"] = 1.0
+		task["pr_number"] = 1
+		task["completed_at"] = now
+	case "task.failed":
+		task["error_message"] = "sample failure"
+		task["completed_at"] = now
+	}
+	return map[string]any{"event": event, "delivery": newUUID(), "sent_at": now, "test": true, "task": task}
+}
+
+// signWebhook is the X-AutoCodit-Signature-256 header for body: an HMAC-SHA256
+// with the webhook's secret, in the format of GitHub's X-Hub-Signature-256.
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func cmdWebhooksTest(c *Client) *cobra.Command {
+	var event, secret string
+	var dryRun bool
+	cmd := &cobra.Command{
+		Use:   "test [id|url]",
+		Short: "Send a sample lifecycle event to a webhook, or straight to a URL",
+		Long: `test delivers a sample payload for --event, marked "test": true. Given a
+webhook ID, the server sends it the way it sends real events. Given a URL, the
+CLI posts it there itself, signed with --secret as the server would, so a
+receiver can be checked before it is registered:
+
+  autocodit webhooks test https://ci.example.com/hooks/autocodit --event task.failed --secret "$SECRET"`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !contains(webhookEvents, event) {
+				return fmt.Errorf("unknown event %q (known: %s)", event, strings.Join(webhookEvents, ", "))
+			}
+			target := args[0]
+			local := strings.Contains(target, "://")
+			if !local {
+				if secret != "" || dryRun {
+					return fmt.Errorf("--secret and --dry-run only apply when testing a URL")
+				}
+				var d webhookDelivery
+				if err := c.DoJSON(cmd.Context(), http.MethodPost, "/api/v1/webhooks/"+target+"/test", map[string]string{"event": event}, &d); err != nil {
+					return err
+				}
+				if d.Error != "" {
+					return fmt.Errorf("delivery failed: %s", d.Error)
+				}
+				return printOutput(d, func() { fmt.Printf("Delivered %s: HTTP %d in %dms\n", event, d.StatusCode, d.DurationMS) })
+			}
+
+			if err := checkWebhookURL(target); err != nil {
+				return err
+			}
+			payload := c.sampleWebhookPayload(event)
+			body, err := json.Marshal(payload)
+			if err != nil {
+				return err
+			}
+			if dryRun {
+				return printOutput(payload, func() {
+					out, _ := json.MarshalIndent(payload, "", "  ")
+					fmt.Println(string(out))
+				})
+			}
+			req, err := http.NewRequestWithContext(cmd.Context(), http.MethodPost, target, bytes.NewReader(body))
+			if err != nil {
+				return err
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("User-Agent", "autocodit-cli/"+version)
+			req.Header.Set("X-AutoCodit-Event", event)
+			req.Header.Set("X-AutoCodit-Delivery", payload["delivery"].(string))
+			if secret != "" {
+				req.Header.Set("X-AutoCodit-Signature-256", signWebhook(secret, body))
+			}
+			start := time.Now()
+			resp, err := (&http.Client{Timeout: 15 * time.Second}).Do(req)
+			if err != nil {
+				return fmt.Errorf("delivery failed: %w", err)
+			}
+			defer resp.Body.Close()
+			reply, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+			d := webhookDelivery{StatusCode: resp.StatusCode, DurationMS: int(time.Since(start) / time.Millisecond)}
+			if resp.StatusCode >= 300 {
+				d.Error = strings.TrimSpace(fmt.Sprintf("HTTP %d %s", resp.StatusCode, firstLine(string(reply))))
+			}
+			if err := printOutput(d, func() {
+				if d.Error == "" {
+					fmt.Printf("Delivered %s to %s: HTTP %d in %dms\n", event, target, d.StatusCode, d.DurationMS)
+				}
+			}); err != nil {
+				return err
+			}
+			if d.Error != "" {
+				return fmt.Errorf("delivery failed: %s", d.Error)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&event, "event", "task.completed", "event to send: "+strings.Join(webhookEvents, ","))
+	cmd.Flags().StringVar(&secret, "secret", "", "with a URL, sign the payload with this shared secret")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "with a URL, print the payload instead of sending it")
+	return cmd
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// mergeWorkspaceConfig layers ./autocodit.yaml over the user's config file so
+// a repository can carry its own presentation and task defaults. Like org
+// defaults, it is limited to sharedConfigAllowed.
+func mergeWorkspaceConfig() {
+	p, err := filepath.Abs("autocodit.yaml")
+	if err != nil || p == viper.ConfigFileUsed() {
+		return
+	}
+	b, err := os.ReadFile(p)
+	if err != nil {
+		return
+	}
+	var settings map[string]any
+	if yaml.Unmarshal(b, &settings) != nil {
+		return
+	}
+	for k := range settings {
+		if !sharedConfigAllowed[k] {
+			delete(settings, k)
+		}
+	}
+	_ = viper.MergeConfigMap(settings)
+}
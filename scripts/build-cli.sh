@@ -0,0 +1,36 @@
+#!/usr/bin/env bash
+set -euo pipefail
+
+# Cross-compile the Go CLI for every supported OS/arch pair into dist/
+ROOT_DIR=$(cd "$(dirname "${BASH_SOURCE[0]}")/.." && pwd)
+OUT_DIR="${OUT_DIR:-$ROOT_DIR/dist}"
+VERSION="${VERSION:-$(git -C "$ROOT_DIR" describe --tags --always --dirty 2>/dev/null || echo dev)}"
+# Package builds (Homebrew formula, .deb, scoop manifest) set INSTALL_METHOD so
+# that `autocodit update` defers to the package manager.
+INSTALL_METHOD="${INSTALL_METHOD:-}"
+TARGETS="${TARGETS:-linux/amd64 linux/arm64 darwin/amd64 darwin/arm64 windows/amd64 windows/arm64}"
+
+if ! command -v go >/dev/null 2>&1; then
+  echo "go toolchain not found" >&2
+  exit 1
+fi
+
+mkdir -p "$OUT_DIR"
+LDFLAGS="-s -w -X main.version=$VERSION -X main.installMethod=$INSTALL_METHOD"
+
+for target in $TARGETS; do
+  os="${target%/*}"
+  arch="${target#*/}"
+  ext=""
+  [[ "$os" == "windows" ]] && ext=".exe"
+  out="$OUT_DIR/autocodit_${os}_${arch}${ext}"
+  echo "Building $out..."
+  (cd "$ROOT_DIR/cli/go" && CGO_ENABLED=0 GOOS="$os" GOARCH="$arch" \
+    go build -trimpath -ldflags "$LDFLAGS" -o "$out" .)
+done
+
+if command -v sha256sum >/dev/null 2>&1; then
+  (cd "$OUT_DIR" && sha256sum autocodit_* > checksums.txt)
+fi
+
+echo "CLI $VERSION built into $OUT_DIR."